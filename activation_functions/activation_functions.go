@@ -6,23 +6,7 @@ import (
 	"math"
 )
 
-// implements the softmax activation function which converts a tensor of float64s to a tensor of probability distributions
-func SoftMax(t *tensor.Tensor) *tensor.Tensor {
-
-	result := make([]float64, len(t.Data))
-
-	var sum float64
-	for _, value := range t.Data {
-		sum += math.Exp(value)
-	}
-
-	for i, value := range t.Data {
-		result[i] = math.Exp(value) / sum
-	}
-
-	return &tensor.Tensor{Data: result, Shape: t.Shape}
-}
-
+// the negative slope of Leaky_ReLu, use LeakyReLU for a different one
 const leaky_relu_constant = 0.01
 
 // implements a ReLu activation function on each element in a tensor where f(x) = max(0,x) which essentially zeros out any negative values
@@ -40,15 +24,7 @@ func ReLu(t *tensor.Tensor) *tensor.Tensor {
 
 // implements leaky_relu which is like relu but instead of zero'ing out anything less than 0, we multiply it by a small constant; f(x) = max((x*alpha), x)
 func Leaky_ReLu(t *tensor.Tensor) *tensor.Tensor {
-	result := make([]float64, len(t.Data))
-	for i, val := range t.Data {
-		if val < 0 {
-			result[i] = val * leaky_relu_constant
-		} else {
-			result[i] = val
-		}
-	}
-	return &tensor.Tensor{Data: result, Shape: t.Shape}
+	return LeakyReLU(t, leaky_relu_constant)
 }
 
 // sigmoid activation function: f(x) = 1/(1 + e^(-x))
@@ -67,8 +43,63 @@ func Tanh(t *tensor.Tensor) *tensor.Tensor {
 
 	result := make([]float64, len(t.Data))
 	for i := range t.Data {
-		result[i] = (math.Exp(t.Data[i]-math.Exp(-t.Data[i])) / (math.Exp(t.Data[i]) + math.Exp(-t.Data[i])))
+		result[i] = math.Tanh(t.Data[i])
 	}
 	return &tensor.Tensor{Data: result, Shape: t.Shape}
 
 }
+
+// the backward functions take the input the forward function was given and the gradient of the loss wrt its output,
+// and return the gradient of the loss wrt the input
+
+// checkBackwardShapes panics if the gradient doesn't line up with the input
+func checkBackwardShapes(input, gradOutput *tensor.Tensor) {
+	if len(input.Data) != len(gradOutput.Data) {
+		panic("input and gradient tensors must have the same size")
+	}
+}
+
+// f'(x) = 1 for x > 0 and 0 otherwise, the gradient at 0 is taken to be 0 like PyTorch
+func ReLuBackward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+
+	checkBackwardShapes(input, gradOutput)
+
+	result := make([]float64, len(input.Data))
+	for i, val := range input.Data {
+		if val > 0 {
+			result[i] = gradOutput.Data[i]
+		}
+	}
+	return &tensor.Tensor{Data: result, Shape: input.Shape}
+}
+
+// f'(x) = 1 for x > 0 and alpha otherwise
+func Leaky_ReLuBackward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return LeakyReLUBackward(input, gradOutput, leaky_relu_constant)
+}
+
+// f'(x) = f(x)(1 - f(x))
+func SigmoidBackward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+
+	checkBackwardShapes(input, gradOutput)
+
+	s := Sigmoid(input).Data
+	result := make([]float64, len(s))
+	for i := range s {
+		result[i] = gradOutput.Data[i] * s[i] * (1 - s[i])
+	}
+	return &tensor.Tensor{Data: result, Shape: input.Shape}
+}
+
+// f'(x) = 1 - f(x)^2
+func TanhBackward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+
+	checkBackwardShapes(input, gradOutput)
+
+	result := make([]float64, len(input.Data))
+	for i, val := range input.Data {
+		y := math.Tanh(val)
+		result[i] = gradOutput.Data[i] * (1 - y*y)
+	}
+	return &tensor.Tensor{Data: result, Shape: input.Shape}
+}
@@ -101,7 +101,7 @@ func Test_TanhScalar(t *testing.T) {
 	result := Tanh(tensor)
 
 	expected := []float64{
-		(math.Exp(2-math.Exp(-2)) / (math.Exp(2) + math.Exp(-2))),
+		math.Tanh(2),
 	}
 
 	for i := range result.Data {
@@ -117,10 +117,10 @@ func Test_TanhVector(t *testing.T) {
 	result := Tanh(tensor)
 
 	expected := []float64{
-		(math.Exp(2-math.Exp(-2)) / (math.Exp(2) + math.Exp(-2))),
-		(math.Exp(-3-math.Exp(3)) / (math.Exp(-3) + math.Exp(3))),
-		(math.Exp(4-math.Exp(-4)) / (math.Exp(4) + math.Exp(-4))),
-		(math.Exp(-5-math.Exp(5)) / (math.Exp(-5) + math.Exp(5))),
+		math.Tanh(2),
+		math.Tanh(-3),
+		math.Tanh(4),
+		math.Tanh(-5),
 	}
 
 	for i := range result.Data {
@@ -137,10 +137,10 @@ func Test_TanhMatrix(t *testing.T) {
 	result := Tanh(tensor)
 
 	expected := []float64{
-		(math.Exp(2-math.Exp(-2)) / (math.Exp(2) + math.Exp(-2))),
-		(math.Exp(-3-math.Exp(3)) / (math.Exp(-3) + math.Exp(3))),
-		(math.Exp(4-math.Exp(-4)) / (math.Exp(4) + math.Exp(-4))),
-		(math.Exp(-5-math.Exp(5)) / (math.Exp(-5) + math.Exp(5))),
+		math.Tanh(2),
+		math.Tanh(-3),
+		math.Tanh(4),
+		math.Tanh(-5),
 	}
 
 	for i := range result.Data {
@@ -173,3 +173,83 @@ func Test_SoftmaxVector(t *testing.T) {
 	}
 
 }
+
+// checks an analytic gradient against central finite differences of sum(f(x) * gradOutput)
+func checkGradient(t *testing.T, f func(*tensor.Tensor) *tensor.Tensor, backward func(input, gradOutput *tensor.Tensor) *tensor.Tensor, input *tensor.Tensor) {
+
+	// an uneven output gradient so the softmax jacobian isn't hidden by summing to a constant
+	gradOutput := make([]float64, len(input.Data))
+	for i := range gradOutput {
+		gradOutput[i] = 0.5 + float64(i)
+	}
+	grad := backward(input, tensor.NewTensor(gradOutput, input.Shape...))
+
+	if !reflect.DeepEqual(grad.Shape, input.Shape) {
+		t.Errorf("Gradient shape mismatch, expected: %v, got: %v", input.Shape, grad.Shape)
+	}
+
+	objective := func() float64 {
+		var total float64
+		for i, v := range f(input).Data {
+			total += v * gradOutput[i]
+		}
+		return total
+	}
+
+	const h = 1e-6
+	for i := range input.Data {
+		original := input.Data[i]
+
+		input.Data[i] = original + h
+		plus := objective()
+		input.Data[i] = original - h
+		minus := objective()
+		input.Data[i] = original
+
+		numerical := (plus - minus) / (2 * h)
+		if math.Abs(grad.Data[i]-numerical) > 1e-5 {
+			t.Errorf("Gradient mismatch at index %d, expected: %v, got: %v", i, numerical, grad.Data[i])
+		}
+	}
+}
+
+func Test_Backward(t *testing.T) {
+
+	// no input sits on the kink of relu, where the gradient isn't defined
+	tests := map[string]struct {
+		f        func(*tensor.Tensor) *tensor.Tensor
+		backward func(input, gradOutput *tensor.Tensor) *tensor.Tensor
+	}{
+		"SoftMax":    {SoftMax, SoftMaxBackward},
+		"ReLu":       {ReLu, ReLuBackward},
+		"Leaky_ReLu": {Leaky_ReLu, Leaky_ReLuBackward},
+		"Sigmoid":    {Sigmoid, SigmoidBackward},
+		"Tanh":       {Tanh, TanhBackward},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			checkGradient(t, test.f, test.backward, tensor.NewTensor([][]float64{{0.5, -1.5, 2}, {-0.25, 1, -3}}))
+		})
+	}
+}
+
+func Test_ReLuBackwardAtZero(t *testing.T) {
+
+	result := ReLuBackward(tensor.NewTensor([]float64{0, 1}), tensor.NewTensor([]float64{3, 3}))
+
+	expected := []float64{0, 3}
+	if !reflect.DeepEqual(result.Data, expected) {
+		t.Errorf("ReLuBackward incorrect, expected: %v, got: %v", expected, result.Data)
+	}
+}
+
+func Test_TanhLarge(t *testing.T) {
+
+	result := Tanh(tensor.NewTensor([]float64{-1000, 1000}))
+
+	expected := []float64{-1, 1}
+	if !reflect.DeepEqual(result.Data, expected) {
+		t.Errorf("Tanh incorrect for large inputs, expected: %v, got: %v", expected, result.Data)
+	}
+}
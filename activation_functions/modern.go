@@ -0,0 +1,328 @@
+package af
+
+import (
+	"fmt"
+	"gotorch/tensor"
+	"math"
+)
+
+/*
+Activation functions used by transformers and modern CNNs, each following the definition in PyTorch's torch.nn so
+models and their gradients match. Where PyTorch picks a gradient at a kink, such as the ends of Hardtanh, the same one
+is used here.
+*/
+
+// elementwise applies f to every value of a tensor
+func elementwise(t *tensor.Tensor, f func(x float64) float64) *tensor.Tensor {
+	result := make([]float64, len(t.Data))
+	for i, x := range t.Data {
+		result[i] = f(x)
+	}
+	return &tensor.Tensor{Data: result, Shape: t.Shape}
+}
+
+// elementwiseBackward multiplies the output gradient by the derivative df of an elementwise function
+func elementwiseBackward(input, gradOutput *tensor.Tensor, df func(x float64) float64) *tensor.Tensor {
+
+	checkBackwardShapes(input, gradOutput)
+
+	result := make([]float64, len(input.Data))
+	for i, x := range input.Data {
+		result[i] = gradOutput.Data[i] * df(x)
+	}
+	return &tensor.Tensor{Data: result, Shape: input.Shape}
+}
+
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+// softplus is log(1 + e^x) without overflowing for large x
+func softplus(x float64) float64 {
+	if x > 0 {
+		return x + math.Log1p(math.Exp(-x))
+	}
+	return math.Log1p(math.Exp(x))
+}
+
+// gelu activation function, x times the standard normal cdf; f(x) = x/2 (1 + erf(x/sqrt(2)))
+func GELU(t *tensor.Tensor) *tensor.Tensor {
+	return elementwise(t, func(x float64) float64 {
+		return 0.5 * x * (1 + math.Erf(x/math.Sqrt2))
+	})
+}
+
+// f'(x) = cdf(x) + x pdf(x)
+func GELUBackward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return elementwiseBackward(input, gradOutput, func(x float64) float64 {
+		return 0.5*(1+math.Erf(x/math.Sqrt2)) + x*math.Exp(-x*x/2)/math.Sqrt(2*math.Pi)
+	})
+}
+
+// the tanh approximation of gelu used by GPT-2 and BERT, PyTorch's approximate="tanh"
+// f(x) = x/2 (1 + tanh(sqrt(2/pi) (x + 0.044715 x^3)))
+func GELUTanh(t *tensor.Tensor) *tensor.Tensor {
+	return elementwise(t, func(x float64) float64 {
+		return 0.5 * x * (1 + math.Tanh(math.Sqrt(2/math.Pi)*(x+0.044715*x*x*x)))
+	})
+}
+
+func GELUTanhBackward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return elementwiseBackward(input, gradOutput, func(x float64) float64 {
+		k := math.Sqrt(2 / math.Pi)
+		y := math.Tanh(k * (x + 0.044715*x*x*x))
+		return 0.5*(1+y) + 0.5*x*(1-y*y)*k*(1+3*0.044715*x*x)
+	})
+}
+
+// silu, also called swish; f(x) = x sigmoid(x)
+func SiLU(t *tensor.Tensor) *tensor.Tensor {
+	return elementwise(t, func(x float64) float64 {
+		return x * sigmoid(x)
+	})
+}
+
+// f'(x) = sigmoid(x) (1 + x (1 - sigmoid(x)))
+func SiLUBackward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return elementwiseBackward(input, gradOutput, func(x float64) float64 {
+		s := sigmoid(x)
+		return s * (1 + x*(1-s))
+	})
+}
+
+// mish activation function; f(x) = x tanh(softplus(x))
+func Mish(t *tensor.Tensor) *tensor.Tensor {
+	return elementwise(t, func(x float64) float64 {
+		return x * math.Tanh(softplus(x))
+	})
+}
+
+// f'(x) = tanh(softplus(x)) + x sech^2(softplus(x)) sigmoid(x)
+func MishBackward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return elementwiseBackward(input, gradOutput, func(x float64) float64 {
+		y := math.Tanh(softplus(x))
+		return y + x*(1-y*y)*sigmoid(x)
+	})
+}
+
+// elu activation function; f(x) = x for x > 0 and alpha (e^x - 1) otherwise
+func ELU(t *tensor.Tensor, alpha float64) *tensor.Tensor {
+	return elementwise(t, func(x float64) float64 {
+		if x > 0 {
+			return x
+		}
+		return alpha * math.Expm1(x)
+	})
+}
+
+// f'(x) = 1 for x > 0 and alpha e^x otherwise
+func ELUBackward(input, gradOutput *tensor.Tensor, alpha float64) *tensor.Tensor {
+	return elementwiseBackward(input, gradOutput, func(x float64) float64 {
+		if x > 0 {
+			return 1
+		}
+		return alpha * math.Exp(x)
+	})
+}
+
+// the constants of self-normalizing networks from Klambauer et al., as PyTorch rounds them
+const (
+	seluAlpha = 1.6732632423543772848170429916717
+	seluScale = 1.0507009873554804934193349852946
+)
+
+// selu activation function, a scaled elu that keeps activations close to zero mean and unit variance
+func SELU(t *tensor.Tensor) *tensor.Tensor {
+	return elementwise(ELU(t, seluAlpha), func(x float64) float64 {
+		return seluScale * x
+	})
+}
+
+func SELUBackward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return elementwise(ELUBackward(input, gradOutput, seluAlpha), func(x float64) float64 {
+		return seluScale * x
+	})
+}
+
+// celu activation function, elu made continuously differentiable; f(x) = max(0, x) + min(0, alpha (e^(x/alpha) - 1))
+func CELU(t *tensor.Tensor, alpha float64) *tensor.Tensor {
+	return elementwise(t, func(x float64) float64 {
+		if x > 0 {
+			return x
+		}
+		return alpha * math.Expm1(x/alpha)
+	})
+}
+
+// f'(x) = 1 for x > 0 and e^(x/alpha) otherwise
+func CELUBackward(input, gradOutput *tensor.Tensor, alpha float64) *tensor.Tensor {
+	return elementwiseBackward(input, gradOutput, func(x float64) float64 {
+		if x > 0 {
+			return 1
+		}
+		return math.Exp(x / alpha)
+	})
+}
+
+// softplus activation function, a smooth relu; f(x) = log(1 + e^(beta x)) / beta
+// once beta x is over threshold the function is taken to be linear, like PyTorch which uses beta 1 and threshold 20 by default
+func Softplus(t *tensor.Tensor, beta, threshold float64) *tensor.Tensor {
+	return elementwise(t, func(x float64) float64 {
+		if beta*x > threshold {
+			return x
+		}
+		return softplus(beta*x) / beta
+	})
+}
+
+// f'(x) = sigmoid(beta x)
+func SoftplusBackward(input, gradOutput *tensor.Tensor, beta, threshold float64) *tensor.Tensor {
+	return elementwiseBackward(input, gradOutput, func(x float64) float64 {
+		if beta*x > threshold {
+			return 1
+		}
+		return sigmoid(beta * x)
+	})
+}
+
+// softsign activation function; f(x) = x / (1 + |x|)
+func Softsign(t *tensor.Tensor) *tensor.Tensor {
+	return elementwise(t, func(x float64) float64 {
+		return x / (1 + math.Abs(x))
+	})
+}
+
+// f'(x) = 1 / (1 + |x|)^2
+func SoftsignBackward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return elementwiseBackward(input, gradOutput, func(x float64) float64 {
+		return 1 / ((1 + math.Abs(x)) * (1 + math.Abs(x)))
+	})
+}
+
+// hardtanh activation function, clamps values to [min, max]
+func Hardtanh(t *tensor.Tensor, min, max float64) *tensor.Tensor {
+	return elementwise(t, func(x float64) float64 {
+		return math.Min(math.Max(x, min), max)
+	})
+}
+
+// f'(x) = 1 strictly between min and max and 0 elsewhere, including at min and max themselves
+func HardtanhBackward(input, gradOutput *tensor.Tensor, min, max float64) *tensor.Tensor {
+	return elementwiseBackward(input, gradOutput, func(x float64) float64 {
+		if x > min && x < max {
+			return 1
+		}
+		return 0
+	})
+}
+
+// relu6 activation function, relu capped at 6; f(x) = min(max(0, x), 6)
+func ReLU6(t *tensor.Tensor) *tensor.Tensor {
+	return Hardtanh(t, 0, 6)
+}
+
+func ReLU6Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return HardtanhBackward(input, gradOutput, 0, 6)
+}
+
+// hardsigmoid activation function, a piecewise linear sigmoid; f(x) = 0 for x <= -3, 1 for x >= 3 and x/6 + 1/2 between
+func Hardsigmoid(t *tensor.Tensor) *tensor.Tensor {
+	return elementwise(t, func(x float64) float64 {
+		return math.Min(math.Max(x/6+0.5, 0), 1)
+	})
+}
+
+// f'(x) = 1/6 strictly between -3 and 3 and 0 elsewhere
+func HardsigmoidBackward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return elementwiseBackward(input, gradOutput, func(x float64) float64 {
+		if x > -3 && x < 3 {
+			return 1.0 / 6
+		}
+		return 0
+	})
+}
+
+// hardswish activation function from MobileNetV3; f(x) = x hardsigmoid(x)
+func Hardswish(t *tensor.Tensor) *tensor.Tensor {
+	return elementwise(t, func(x float64) float64 {
+		return x * math.Min(math.Max(x/6+0.5, 0), 1)
+	})
+}
+
+// f'(x) = 0 for x < -3, x/3 + 1/2 for x <= 3 and 1 above
+func HardswishBackward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return elementwiseBackward(input, gradOutput, func(x float64) float64 {
+		switch {
+		case x < -3:
+			return 0
+		case x <= 3:
+			return x/3 + 0.5
+		}
+		return 1
+	})
+}
+
+// glu splits a tensor in half along dim into a and b and returns a * sigmoid(b), which halves the size of dim
+// dim may be negative to count from the end and must have an even size
+func GLU(t *tensor.Tensor, dim int) *tensor.Tensor {
+
+	shape := gluShape(t.Shape, dim)
+	result := make([]float64, len(t.Data)/2)
+
+	outer, length, inner := lanes(t.Shape, dim)
+	half := length / 2
+	for o := 0; o < outer; o++ {
+		for j := 0; j < half; j++ {
+			for i := 0; i < inner; i++ {
+				a := t.Data[(o*length+j)*inner+i]
+				b := t.Data[(o*length+j+half)*inner+i]
+				result[(o*half+j)*inner+i] = a * sigmoid(b)
+			}
+		}
+	}
+
+	return &tensor.Tensor{Data: result, Shape: shape}
+}
+
+// the gradient of GLU, gradOutput has the halved shape GLU returns
+// d/da = sigmoid(b) and d/db = a sigmoid(b) (1 - sigmoid(b))
+func GLUBackward(input, gradOutput *tensor.Tensor, dim int) *tensor.Tensor {
+
+	if len(gradOutput.Data)*2 != len(input.Data) {
+		panic("the gradient must be half the size of the input")
+	}
+
+	outer, length, inner := lanes(input.Shape, dim)
+	half := length / 2
+	result := make([]float64, len(input.Data))
+	for o := 0; o < outer; o++ {
+		for j := 0; j < half; j++ {
+			for i := 0; i < inner; i++ {
+				ia, ib := (o*length+j)*inner+i, (o*length+j+half)*inner+i
+				g := gradOutput.Data[(o*half+j)*inner+i]
+				s := sigmoid(input.Data[ib])
+				result[ia] = g * s
+				result[ib] = g * input.Data[ia] * s * (1 - s)
+			}
+		}
+	}
+
+	return &tensor.Tensor{Data: result, Shape: input.Shape}
+}
+
+// gluShape is shape with dim halved, panicking if it can't be split evenly
+func gluShape(shape []int, dim int) []int {
+
+	_, length, _ := lanes(shape, dim)
+	if length%2 != 0 {
+		panic(fmt.Sprintf("glu needs an even size along dim %d, got shape %v", dim, shape))
+	}
+
+	if dim < 0 {
+		dim += len(shape)
+	}
+	halved := append([]int{}, shape...)
+	halved[dim] /= 2
+
+	return halved
+}
@@ -0,0 +1,157 @@
+package af
+
+import (
+	"gotorch/tensor"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// values from torch.nn.functional at x = -4, -1, -0.5, 0.5, 1, 4
+func Test_ModernActivationsParity(t *testing.T) {
+
+	tests := map[string]struct {
+		f        func(*tensor.Tensor) *tensor.Tensor
+		expected []float64
+	}{
+		"gelu":        {GELU, []float64{-0.0001267, -0.1586553, -0.1542688, 0.3457312, 0.8413447, 3.9998733}},
+		"gelu tanh":   {GELUTanh, []float64{-0.0000702, -0.1588080, -0.1542860, 0.3457140, 0.8411920, 3.9999298}},
+		"silu":        {SiLU, []float64{-0.0719448, -0.2689414, -0.1887703, 0.3112297, 0.7310586, 3.9280552}},
+		"mish":        {Mish, []float64{-0.0725917, -0.3034015, -0.2207438, 0.3752452, 0.8650984, 3.9974128}},
+		"elu":         {func(x *tensor.Tensor) *tensor.Tensor { return ELU(x, 1) }, []float64{-0.9816844, -0.6321206, -0.3934693, 0.5, 1, 4}},
+		"selu":        {SELU, []float64{-1.7258986, -1.1113307, -0.6917582, 0.5253505, 1.0507010, 4.2028039}},
+		"celu":        {func(x *tensor.Tensor) *tensor.Tensor { return CELU(x, 0.5) }, []float64{-0.4998323, -0.4323324, -0.3160603, 0.5, 1, 4}},
+		"softplus":    {func(x *tensor.Tensor) *tensor.Tensor { return Softplus(x, 2, 1) }, []float64{0.0001677, 0.0634640, 0.1566308, 0.6566308, 1, 4}},
+		"softsign":    {Softsign, []float64{-0.8, -0.5, -0.3333333, 0.3333333, 0.5, 0.8}},
+		"hardtanh":    {func(x *tensor.Tensor) *tensor.Tensor { return Hardtanh(x, -1, 2) }, []float64{-1, -1, -0.5, 0.5, 1, 2}},
+		"relu6":       {ReLU6, []float64{0, 0, 0, 0.5, 1, 4}},
+		"hardsigmoid": {Hardsigmoid, []float64{0, 0.3333333, 0.4166667, 0.5833333, 0.6666667, 1}},
+		"hardswish":   {Hardswish, []float64{0, -0.3333333, -0.2083333, 0.2916667, 0.6666667, 4}},
+		"relu6 above 6": {func(x *tensor.Tensor) *tensor.Tensor {
+			return ReLU6(elementwise(x, func(v float64) float64 { return 2 * v }))
+		}, []float64{0, 0, 0, 1, 2, 6}},
+		"softplus large": {func(x *tensor.Tensor) *tensor.Tensor {
+			return Softplus(elementwise(x, func(v float64) float64 { return 200 * v }), 1, 20)
+		}, []float64{0, 0, 0, 100, 200, 800}},
+	}
+
+	input := tensor.NewTensor([]float64{-4, -1, -0.5, 0.5, 1, 4})
+	for name, test := range tests {
+		result := test.f(input)
+		for i := range test.expected {
+			if math.Abs(result.Data[i]-test.expected[i]) > 1e-6 {
+				t.Errorf("%s incorrect at index %d, expected: %v, got: %v", name, i, test.expected[i], result.Data[i])
+			}
+		}
+	}
+}
+
+func Test_ModernActivationsBackward(t *testing.T) {
+
+	tests := map[string]struct {
+		f        func(*tensor.Tensor) *tensor.Tensor
+		backward func(input, gradOutput *tensor.Tensor) *tensor.Tensor
+	}{
+		"GELU":     {GELU, GELUBackward},
+		"GELUTanh": {GELUTanh, GELUTanhBackward},
+		"SiLU":     {SiLU, SiLUBackward},
+		"Mish":     {Mish, MishBackward},
+		"ELU": {func(x *tensor.Tensor) *tensor.Tensor { return ELU(x, 0.7) },
+			func(x, g *tensor.Tensor) *tensor.Tensor { return ELUBackward(x, g, 0.7) }},
+		"SELU": {SELU, SELUBackward},
+		"CELU": {func(x *tensor.Tensor) *tensor.Tensor { return CELU(x, 0.5) },
+			func(x, g *tensor.Tensor) *tensor.Tensor { return CELUBackward(x, g, 0.5) }},
+		"Softplus": {func(x *tensor.Tensor) *tensor.Tensor { return Softplus(x, 2, 20) },
+			func(x, g *tensor.Tensor) *tensor.Tensor { return SoftplusBackward(x, g, 2, 20) }},
+		"Softsign": {Softsign, SoftsignBackward},
+		"Hardtanh": {func(x *tensor.Tensor) *tensor.Tensor { return Hardtanh(x, -1, 2) },
+			func(x, g *tensor.Tensor) *tensor.Tensor { return HardtanhBackward(x, g, -1, 2) }},
+		"ReLU6":       {ReLU6, ReLU6Backward},
+		"Hardsigmoid": {Hardsigmoid, HardsigmoidBackward},
+		"Hardswish":   {Hardswish, HardswishBackward},
+	}
+
+	// no input sits on a kink
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			checkGradient(t, test.f, test.backward, tensor.NewTensor([][]float64{{-4, -1.5, -0.25}, {0.5, 2.5, 7}}))
+		})
+	}
+}
+
+func Test_HardtanhBackwardAtBounds(t *testing.T) {
+
+	// like PyTorch no gradient flows at the clamp values themselves
+	result := HardtanhBackward(tensor.NewTensor([]float64{-1, 0, 1}), tensor.NewTensor([]float64{5, 5, 5}), -1, 1)
+
+	expected := []float64{0, 5, 0}
+	if !reflect.DeepEqual(result.Data, expected) {
+		t.Errorf("HardtanhBackward incorrect, expected: %v, got: %v", expected, result.Data)
+	}
+}
+
+func Test_GLU(t *testing.T) {
+
+	// [2, 4] split along the last dim into a = first two columns and b = last two
+	input := tensor.NewTensor([][]float64{{1, 2, 0, math.Log(3)}, {-1, 4, 100, -100}})
+
+	result := GLU(input, -1)
+
+	expected := []float64{0.5, 2 * 0.75, -1, 0}
+	if !reflect.DeepEqual(result.Shape, []int{2, 2}) || !approxEqual(result.Data, expected) {
+		t.Errorf("GLU incorrect, expected: %v with shape [2 2], got: %v with shape %v", expected, result.Data, result.Shape)
+	}
+
+	// along dim 0 the first row gates with the second
+	rows := GLU(input, 0)
+	if !reflect.DeepEqual(rows.Shape, []int{1, 4}) || math.Abs(rows.Data[0]-1*sigmoid(-1)) > 1e-12 {
+		t.Errorf("GLU along dim 0 incorrect, got: %v with shape %v", rows.Data, rows.Shape)
+	}
+
+	for _, dim := range []int{0, 1, -1} {
+		checkGLUGradient(t, tensor.NewTensor([]float64{0.5, -1.5, 2, -0.25, 1, -3, 0.75, 0.1}, 2, 4), dim)
+	}
+	checkGLUGradient(t, tensor.NewTensor([]float64{0.5, -1.5, 2, -0.25, 1, -3, 0.75, 0.1}, 2, 2, 2), 1)
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected a panic splitting an odd dimension")
+		}
+	}()
+	GLU(tensor.NewTensor([]float64{1, 2, 3}), 0)
+}
+
+// GLU changes the shape so it can't use checkGradient, the output gradient is the halved shape
+func checkGLUGradient(t *testing.T, input *tensor.Tensor, dim int) {
+
+	output := GLU(input, dim)
+	gradOutput := make([]float64, len(output.Data))
+	for i := range gradOutput {
+		gradOutput[i] = 0.5 + float64(i)
+	}
+	grad := GLUBackward(input, tensor.NewTensor(gradOutput, output.Shape...), dim)
+
+	objective := func() float64 {
+		var total float64
+		for i, v := range GLU(input, dim).Data {
+			total += v * gradOutput[i]
+		}
+		return total
+	}
+
+	const h = 1e-6
+	for i := range input.Data {
+		original := input.Data[i]
+
+		input.Data[i] = original + h
+		plus := objective()
+		input.Data[i] = original - h
+		minus := objective()
+		input.Data[i] = original
+
+		numerical := (plus - minus) / (2 * h)
+		if math.Abs(grad.Data[i]-numerical) > 1e-5 {
+			t.Errorf("GLU gradient mismatch along dim %d at index %d, expected: %v, got: %v", dim, i, numerical, grad.Data[i])
+		}
+	}
+}
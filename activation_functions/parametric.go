@@ -0,0 +1,89 @@
+package af
+
+import (
+	"fmt"
+	"gotorch/tensor"
+)
+
+// leaky relu with a configurable slope for negative values; f(x) = x for x >= 0 and negativeSlope * x otherwise
+func LeakyReLU(t *tensor.Tensor, negativeSlope float64) *tensor.Tensor {
+	return elementwise(t, func(x float64) float64 {
+		if x < 0 {
+			return negativeSlope * x
+		}
+		return x
+	})
+}
+
+// f'(x) = 1 for x > 0 and negativeSlope otherwise
+func LeakyReLUBackward(input, gradOutput *tensor.Tensor, negativeSlope float64) *tensor.Tensor {
+	return elementwiseBackward(input, gradOutput, func(x float64) float64 {
+		if x > 0 {
+			return 1
+		}
+		return negativeSlope
+	})
+}
+
+// preluChannel returns a function giving the index into weights of each value of a tensor of the given shape
+// a single weight is shared by every value, otherwise there's one per channel, which is dim 1 like PyTorch, or dim 0
+// of a 1D tensor
+func preluChannel(shape []int, weights int) func(i int) int {
+
+	if weights == 1 {
+		return func(int) int { return 0 }
+	}
+
+	dim := 1
+	if len(shape) == 1 {
+		dim = 0
+	}
+	if len(shape) == 0 || shape[dim] != weights {
+		panic(fmt.Sprintf("%d prelu weights don't match the channels of shape %v", weights, shape))
+	}
+
+	_, channels, inner := lanes(shape, dim)
+	return func(i int) int {
+		return i / inner % channels
+	}
+}
+
+// prelu activation function, a leaky relu whose negative slopes are learned; f(x) = x for x >= 0 and a * x otherwise
+// weights holds a single slope or one per channel
+func PReLU(t *tensor.Tensor, weights []float64) *tensor.Tensor {
+
+	channel := preluChannel(t.Shape, len(weights))
+
+	result := make([]float64, len(t.Data))
+	for i, x := range t.Data {
+		if x < 0 {
+			result[i] = weights[channel(i)] * x
+		} else {
+			result[i] = x
+		}
+	}
+
+	return &tensor.Tensor{Data: result, Shape: t.Shape}
+}
+
+// the gradients of PReLU wrt its input and its weights, the gradient of a slope is the sum of g * x over the negative
+// values it was applied to
+func PReLUBackward(input, gradOutput *tensor.Tensor, weights []float64) (*tensor.Tensor, []float64) {
+
+	checkBackwardShapes(input, gradOutput)
+	channel := preluChannel(input.Shape, len(weights))
+
+	gradInput := make([]float64, len(input.Data))
+	gradWeights := make([]float64, len(weights))
+	for i, x := range input.Data {
+		if x > 0 {
+			gradInput[i] = gradOutput.Data[i]
+		} else {
+			c := channel(i)
+			gradInput[i] = gradOutput.Data[i] * weights[c]
+			gradWeights[c] += gradOutput.Data[i] * x
+		}
+	}
+
+	return &tensor.Tensor{Data: gradInput, Shape: input.Shape}, gradWeights
+}
@@ -0,0 +1,102 @@
+package af
+
+import (
+	"gotorch/tensor"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func Test_LeakyReLU(t *testing.T) {
+
+	input := tensor.NewTensor([]float64{2, -3, 4, -5})
+
+	result := LeakyReLU(input, 0.2)
+
+	expected := []float64{2, -0.6, 4, -1}
+	if !approxEqual(result.Data, expected) {
+		t.Errorf("LeakyReLU incorrect, expected: %v, got: %v", expected, result.Data)
+	}
+
+	if !reflect.DeepEqual(LeakyReLU(input, leaky_relu_constant).Data, Leaky_ReLu(input).Data) {
+		t.Errorf("Expected Leaky_ReLu to be LeakyReLU with a slope of %v", leaky_relu_constant)
+	}
+
+	checkGradient(t, func(x *tensor.Tensor) *tensor.Tensor { return LeakyReLU(x, 0.2) },
+		func(x, g *tensor.Tensor) *tensor.Tensor { return LeakyReLUBackward(x, g, 0.2) },
+		tensor.NewTensor([][]float64{{0.5, -1.5, 2}, {-0.25, 1, -3}}))
+}
+
+func Test_PReLU(t *testing.T) {
+
+	// [batch 2, channels 2, length 2]
+	input := tensor.NewTensor([]float64{-1, 2, -3, -4, 5, -6, 7, -8}, 2, 2, 2)
+
+	shared := PReLU(input, []float64{0.25})
+	expected := []float64{-0.25, 2, -0.75, -1, 5, -1.5, 7, -2}
+	if !approxEqual(shared.Data, expected) {
+		t.Errorf("PReLU with a single weight incorrect, expected: %v, got: %v", expected, shared.Data)
+	}
+
+	perChannel := PReLU(input, []float64{0.5, 0.1})
+	expected = []float64{-0.5, 2, -0.3, -0.4, 5, -3, 7, -0.8}
+	if !approxEqual(perChannel.Data, expected) {
+		t.Errorf("PReLU with a weight per channel incorrect, expected: %v, got: %v", expected, perChannel.Data)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected a panic for weights that don't match the channels")
+		}
+	}()
+	PReLU(input, []float64{1, 2, 3})
+}
+
+func Test_PReLUBackward(t *testing.T) {
+
+	for _, test := range []struct {
+		input   *tensor.Tensor
+		weights []float64
+	}{
+		{tensor.NewTensor([]float64{0.5, -1.5, 2, -0.25, 1, -3}, 2, 3), []float64{0.25}},
+		{tensor.NewTensor([]float64{0.5, -1.5, 2, -0.25, 1, -3}, 2, 3), []float64{0.25, 0.5, -0.1}},
+		{tensor.NewTensor([]float64{0.5, -1.5, 2, -0.25, 1, -3, -0.7, 0.2}, 2, 2, 2), []float64{0.3, 0.6}},
+		{tensor.NewTensor([]float64{-0.5, 1.5, -2}), []float64{0.1, 0.2, 0.3}},
+	} {
+		checkGradient(t, func(x *tensor.Tensor) *tensor.Tensor { return PReLU(x, test.weights) },
+			func(x, g *tensor.Tensor) *tensor.Tensor {
+				grad, _ := PReLUBackward(x, g, test.weights)
+				return grad
+			}, test.input)
+
+		// check the weight gradients against finite differences too
+		gradOutput := make([]float64, len(test.input.Data))
+		for i := range gradOutput {
+			gradOutput[i] = 0.5 + float64(i)
+		}
+		_, gradWeights := PReLUBackward(test.input, tensor.NewTensor(gradOutput, test.input.Shape...), test.weights)
+
+		objective := func() float64 {
+			var total float64
+			for i, v := range PReLU(test.input, test.weights).Data {
+				total += v * gradOutput[i]
+			}
+			return total
+		}
+
+		const h = 1e-6
+		for c := range test.weights {
+			original := test.weights[c]
+			test.weights[c] = original + h
+			plus := objective()
+			test.weights[c] = original - h
+			minus := objective()
+			test.weights[c] = original
+
+			numerical := (plus - minus) / (2 * h)
+			if math.Abs(gradWeights[c]-numerical) > 1e-5 {
+				t.Errorf("PReLU weight gradient mismatch at %d, expected: %v, got: %v", c, numerical, gradWeights[c])
+			}
+		}
+	}
+}
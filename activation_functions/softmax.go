@@ -0,0 +1,166 @@
+package af
+
+import (
+	"fmt"
+	"gotorch/tensor"
+	"math"
+)
+
+/*
+Softmax and log softmax normalize along one dimension of a tensor, so each row of a [batch, classes] tensor of logits
+becomes its own distribution. The largest value of each slice is subtracted before exponentiating so large logits don't
+overflow. -Inf entries, as used to mask out positions, get a probability of 0 and SoftMax passes them no gradient. A
+slice that is entirely masked gives all zeros from SoftMax and all -Inf from LogSoftMax rather than NaN.
+*/
+
+// lanes splits a shape around dim, a slice along dim is length values inner apart, and there are outer * inner slices
+// dim may be negative to count from the end
+func lanes(shape []int, dim int) (outer, length, inner int) {
+
+	if dim < 0 {
+		dim += len(shape)
+	}
+	if dim < 0 || dim >= len(shape) {
+		panic(fmt.Sprintf("dim %d out of range for shape %v", dim, shape))
+	}
+
+	outer, inner = 1, 1
+	for _, s := range shape[:dim] {
+		outer *= s
+	}
+	for _, s := range shape[dim+1:] {
+		inner *= s
+	}
+
+	return outer, shape[dim], inner
+}
+
+// forEachLane calls f with the indices of every slice of a tensor along dim
+func forEachLane(shape []int, dim int, f func(indices []int)) {
+
+	outer, length, inner := lanes(shape, dim)
+	indices := make([]int, length)
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			for j := range indices {
+				indices[j] = (o*length+j)*inner + i
+			}
+			f(indices)
+		}
+	}
+}
+
+// shifted returns the largest value of a slice and the log of the sum of the exponentials of the values minus it
+// a slice that is all -Inf has a log sum of -Inf
+func shifted(data []float64, indices []int) (largest, logSum float64) {
+
+	largest = math.Inf(-1)
+	for _, i := range indices {
+		largest = math.Max(largest, data[i])
+	}
+	if math.IsInf(largest, -1) {
+		return largest, math.Inf(-1)
+	}
+
+	var sum float64
+	for _, i := range indices {
+		sum += math.Exp(data[i] - largest)
+	}
+
+	return largest, math.Log(sum)
+}
+
+// implements the softmax activation function along the last dimension, converting each row of a tensor into a probability distribution
+func SoftMax(t *tensor.Tensor) *tensor.Tensor {
+	return SoftMaxDim(t, -1)
+}
+
+// softmax along dim; f(x)_i = e^(x_i - max(x)) / sum_j e^(x_j - max(x))
+func SoftMaxDim(t *tensor.Tensor, dim int) *tensor.Tensor {
+
+	result := make([]float64, len(t.Data))
+	forEachLane(t.Shape, dim, func(indices []int) {
+		largest, logSum := shifted(t.Data, indices)
+		if math.IsInf(logSum, -1) {
+			return // fully masked, leave as zeros
+		}
+		for _, i := range indices {
+			result[i] = math.Exp(t.Data[i] - largest - logSum)
+		}
+	})
+
+	return &tensor.Tensor{Data: result, Shape: t.Shape}
+}
+
+// log softmax along the last dimension
+func LogSoftMax(t *tensor.Tensor) *tensor.Tensor {
+	return LogSoftMaxDim(t, -1)
+}
+
+// log softmax along dim; f(x)_i = x_i - max(x) - log(sum_j e^(x_j - max(x))), more accurate than taking the log of SoftMax
+func LogSoftMaxDim(t *tensor.Tensor, dim int) *tensor.Tensor {
+
+	result := make([]float64, len(t.Data))
+	forEachLane(t.Shape, dim, func(indices []int) {
+		largest, logSum := shifted(t.Data, indices)
+		for _, i := range indices {
+			if math.IsInf(logSum, -1) {
+				result[i] = math.Inf(-1)
+			} else {
+				result[i] = t.Data[i] - largest - logSum
+			}
+		}
+	})
+
+	return &tensor.Tensor{Data: result, Shape: t.Shape}
+}
+
+// the gradient of SoftMax
+func SoftMaxBackward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return SoftMaxDimBackward(input, gradOutput, -1)
+}
+
+// the softmax jacobian is diag(s) - s s^T, so along each slice the gradient is s * (g - sum(g * s)) without building it
+func SoftMaxDimBackward(input, gradOutput *tensor.Tensor, dim int) *tensor.Tensor {
+
+	checkBackwardShapes(input, gradOutput)
+
+	s := SoftMaxDim(input, dim).Data
+	result := make([]float64, len(s))
+	forEachLane(input.Shape, dim, func(indices []int) {
+		var dot float64
+		for _, i := range indices {
+			dot += gradOutput.Data[i] * s[i]
+		}
+		for _, i := range indices {
+			result[i] = s[i] * (gradOutput.Data[i] - dot)
+		}
+	})
+
+	return &tensor.Tensor{Data: result, Shape: input.Shape}
+}
+
+// the gradient of LogSoftMax
+func LogSoftMaxBackward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return LogSoftMaxDimBackward(input, gradOutput, -1)
+}
+
+// the log softmax jacobian is I - 1 s^T, so along each slice the gradient is g - s * sum(g)
+func LogSoftMaxDimBackward(input, gradOutput *tensor.Tensor, dim int) *tensor.Tensor {
+
+	checkBackwardShapes(input, gradOutput)
+
+	s := SoftMaxDim(input, dim).Data
+	result := make([]float64, len(s))
+	forEachLane(input.Shape, dim, func(indices []int) {
+		var sum float64
+		for _, i := range indices {
+			sum += gradOutput.Data[i]
+		}
+		for _, i := range indices {
+			result[i] = gradOutput.Data[i] - s[i]*sum
+		}
+	})
+
+	return &tensor.Tensor{Data: result, Shape: input.Shape}
+}
@@ -0,0 +1,124 @@
+package af
+
+import (
+	"gotorch/tensor"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func approxEqual(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] && math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func Test_SoftmaxRows(t *testing.T) {
+
+	// each row of a batch is its own distribution
+	result := SoftMax(tensor.NewTensor([][]float64{{1, 1}, {0, math.Log(3)}}))
+
+	expected := []float64{0.5, 0.5, 0.25, 0.75}
+	if !approxEqual(result.Data, expected) {
+		t.Errorf("Softmax incorrect, expected: %v, got: %v", expected, result.Data)
+	}
+}
+
+func Test_SoftmaxDim(t *testing.T) {
+
+	// shape [2, 2, 2], normalizing along the middle dimension pairs up values 2 apart
+	input := tensor.NewTensor([]float64{0, 1, 0, 1, 2, 0, 2 + math.Log(3), 0}, 2, 2, 2)
+
+	result := SoftMaxDim(input, 1)
+
+	expected := []float64{0.5, 0.5, 0.5, 0.5, 0.25, 0.5, 0.75, 0.5}
+	if !approxEqual(result.Data, expected) {
+		t.Errorf("Softmax along dim 1 incorrect, expected: %v, got: %v", expected, result.Data)
+	}
+	if !reflect.DeepEqual(result.Shape, input.Shape) {
+		t.Errorf("Softmax shape mismatch, expected: %v, got: %v", input.Shape, result.Shape)
+	}
+
+	if !approxEqual(SoftMaxDim(input, -3).Data, SoftMaxDim(input, 0).Data) {
+		t.Errorf("Expected dim -3 to be the same as dim 0 for a 3D tensor")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected a panic for a dim out of range")
+		}
+	}()
+	SoftMaxDim(input, 3)
+}
+
+func Test_SoftmaxLargeValues(t *testing.T) {
+
+	input := tensor.NewTensor([]float64{1000, 1000, -1000})
+
+	result := SoftMax(input)
+	if !approxEqual(result.Data, []float64{0.5, 0.5, 0}) {
+		t.Errorf("Softmax overflowed, got: %v", result.Data)
+	}
+
+	log := LogSoftMax(input)
+	if !approxEqual(log.Data, []float64{-math.Ln2, -math.Ln2, -2000 - math.Ln2}) {
+		t.Errorf("LogSoftmax overflowed, got: %v", log.Data)
+	}
+}
+
+func Test_SoftmaxMask(t *testing.T) {
+
+	inf := math.Inf(-1)
+	input := tensor.NewTensor([][]float64{{0, inf, 0}, {inf, inf, inf}})
+
+	result := SoftMax(input)
+	if !approxEqual(result.Data, []float64{0.5, 0, 0.5, 0, 0, 0}) {
+		t.Errorf("Softmax with a mask incorrect, got: %v", result.Data)
+	}
+
+	log := LogSoftMax(input)
+	if !approxEqual(log.Data, []float64{-math.Ln2, inf, -math.Ln2, inf, inf, inf}) {
+		t.Errorf("LogSoftmax with a mask incorrect, got: %v", log.Data)
+	}
+
+	grad := SoftMaxBackward(input, tensor.NewTensor([]float64{1, 2, 3, 4, 5, 6}, 2, 3))
+	for i, g := range grad.Data {
+		if math.IsNaN(g) || (i != 0 && i != 2 && g != 0) {
+			t.Errorf("Expected no gradient for masked entries, got: %v", grad.Data)
+			break
+		}
+	}
+}
+
+func Test_LogSoftmax(t *testing.T) {
+
+	input := tensor.NewTensor([][]float64{{0.5, -1.5, 2}, {-0.25, 1, -3}})
+
+	softmax := SoftMaxDim(input, 0)
+	log := LogSoftMaxDim(input, 0)
+	for i := range softmax.Data {
+		if math.Abs(log.Data[i]-math.Log(softmax.Data[i])) > 1e-12 {
+			t.Errorf("Expected LogSoftmax to be the log of Softmax, expected: %v, got: %v", math.Log(softmax.Data[i]), log.Data[i])
+		}
+	}
+}
+
+func Test_SoftmaxDimBackward(t *testing.T) {
+
+	input := tensor.NewTensor([]float64{0.5, -1.5, 2, -0.25, 1, -3, 0.75, 0.1, -0.6, 1.2, 0.3, -2}, 2, 3, 2)
+
+	for _, dim := range []int{0, 1, 2, -1} {
+		checkGradient(t, func(x *tensor.Tensor) *tensor.Tensor { return SoftMaxDim(x, dim) },
+			func(x, g *tensor.Tensor) *tensor.Tensor { return SoftMaxDimBackward(x, g, dim) }, input)
+		checkGradient(t, func(x *tensor.Tensor) *tensor.Tensor { return LogSoftMaxDim(x, dim) },
+			func(x, g *tensor.Tensor) *tensor.Tensor { return LogSoftMaxDimBackward(x, g, dim) }, input)
+	}
+
+	checkGradient(t, LogSoftMax, LogSoftMaxBackward, tensor.NewTensor([]float64{3, 1, 4, 1, 5}))
+}
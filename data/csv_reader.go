@@ -0,0 +1,400 @@
+package data
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"gotorch/tensor"
+	"io"
+	"iter"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+/*
+LoadCSV reads a whole file into memory. CSVReader instead streams a file a batch at a time so only BatchSize rows
+are ever held in memory, and it understands headers, column selection, target columns and missing values.
+CSVLoader wraps it so that the file is streamed again every epoch, which makes it usable as a training loader.
+*/
+
+// MissingPolicy decides what happens to a field that is empty or one of the NAValues
+type MissingPolicy int
+
+const (
+	// MissingAsNaN stores missing values as NaN
+	MissingAsNaN MissingPolicy = iota
+	// MissingFill replaces missing values with the column's entry in FillValues, or FillValue if it has none
+	MissingFill
+	// MissingSkipRow drops any row that has a missing value in a selected column
+	MissingSkipRow
+	// MissingError stops reading with an error
+	MissingError
+)
+
+// the strings treated as missing values when NAValues is nil
+var defaultNAValues = []string{"", "NA", "N/A", "NaN", "nan", "null", "NULL"}
+
+// CSVOptions configures how a CSVReader parses a file
+// columns are named by the header row when Header is set and by their zero based index ("0", "1", ...) otherwise
+type CSVOptions struct {
+	Comma    rune // field delimiter, defaults to ','
+	Comment  rune // lines starting with this character are ignored, 0 disables comments
+	Header   bool // the first row (after SkipRows) holds the column names
+	SkipRows int  // number of rows to skip before the header or first data row
+
+	// FeatureColumns selects the feature columns in order, nil means every column that isn't a target
+	FeatureColumns []string
+	// TargetColumns selects the target columns in order, nil means there are no targets
+	TargetColumns []string
+
+	Missing    MissingPolicy
+	NAValues   []string // defaults to "", "NA", "N/A", "NaN", "nan", "null" and "NULL"
+	FillValue  float64
+	FillValues map[string]float64
+
+	BatchSize int // rows per batch, defaults to 1
+}
+
+// CSVReader streams rows from a CSV file and returns them as batches of feature and target tensors
+type CSVReader struct {
+	opts     CSVOptions
+	reader   *csv.Reader
+	closer   io.Closer
+	header   []string
+	features []int
+	targets  []int
+	naValues map[string]bool
+	pending  []string
+	line     int
+	err      error
+}
+
+// NewCSVReader reads the header, if there is one, and resolves the selected columns
+func NewCSVReader(r io.Reader, opts CSVOptions) (*CSVReader, error) {
+
+	reader := csv.NewReader(r)
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+	reader.Comment = opts.Comment
+	reader.ReuseRecord = true
+	// skipped rows often have a different width, so the row length is checked against the header in Next instead
+	reader.FieldsPerRecord = -1
+
+	c := &CSVReader{opts: opts, reader: reader, naValues: map[string]bool{}}
+
+	naValues := opts.NAValues
+	if naValues == nil {
+		naValues = defaultNAValues
+	}
+	for _, v := range naValues {
+		c.naValues[v] = true
+	}
+
+	for i := 0; i < opts.SkipRows; i++ {
+		if _, err := c.readRecord(); err != nil {
+			return nil, fmt.Errorf("unable to skip row %d: %w", i+1, err)
+		}
+	}
+
+	// we need the first row to know how many columns there are even without a header
+	first, err := c.readRecord()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the first row: %w", err)
+	}
+
+	if opts.Header {
+		c.header = slices.Clone(first)
+	} else {
+		c.header = make([]string, len(first))
+		for i := range first {
+			c.header[i] = strconv.Itoa(i)
+		}
+	}
+
+	if err := c.resolveColumns(); err != nil {
+		return nil, err
+	}
+
+	// without a header the first row is data, so put it back in front of the stream
+	if !opts.Header {
+		c.pending = slices.Clone(first)
+	}
+
+	return c, nil
+}
+
+// OpenCSV opens a file for streaming, the returned reader must be closed
+func OpenCSV(filePath string, opts CSVOptions) (*CSVReader, error) {
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := NewCSVReader(file, opts)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	c.closer = file
+
+	return c, nil
+}
+
+// Close closes the underlying file if the reader was created by OpenCSV
+func (c *CSVReader) Close() error {
+	if c.closer == nil {
+		return nil
+	}
+	return c.closer.Close()
+}
+
+// Header returns the names of every column in the file
+func (c *CSVReader) Header() []string {
+	return c.header
+}
+
+// FeatureColumns returns the names of the feature columns in the order they appear in the feature tensors
+func (c *CSVReader) FeatureColumns() []string {
+	return c.names(c.features)
+}
+
+// TargetColumns returns the names of the target columns in the order they appear in the target tensors
+func (c *CSVReader) TargetColumns() []string {
+	return c.names(c.targets)
+}
+
+func (c *CSVReader) names(indices []int) []string {
+	names := make([]string, len(indices))
+	for i, index := range indices {
+		names[i] = c.header[index]
+	}
+	return names
+}
+
+// maps the selected column names to their index in each row
+func (c *CSVReader) resolveColumns() error {
+
+	positions := map[string]int{}
+	for i, name := range c.header {
+		if _, ok := positions[name]; ok {
+			return fmt.Errorf("duplicate column name %q", name)
+		}
+		positions[name] = i
+	}
+
+	lookup := func(names []string) ([]int, error) {
+		indices := make([]int, len(names))
+		for i, name := range names {
+			index, ok := positions[name]
+			if !ok {
+				return nil, fmt.Errorf("column %q not found in %v", name, c.header)
+			}
+			indices[i] = index
+		}
+		return indices, nil
+	}
+
+	var err error
+	if c.targets, err = lookup(c.opts.TargetColumns); err != nil {
+		return err
+	}
+
+	if c.opts.FeatureColumns != nil {
+		c.features, err = lookup(c.opts.FeatureColumns)
+		return err
+	}
+
+	for i := range c.header {
+		if !slices.Contains(c.targets, i) {
+			c.features = append(c.features, i)
+		}
+	}
+
+	return nil
+}
+
+// reads the next record, keeping track of the line number for error messages
+func (c *CSVReader) readRecord() ([]string, error) {
+	record, err := c.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	c.line, _ = c.reader.FieldPos(0)
+	return record, nil
+}
+
+// parses the selected columns of a record, appending them to dst
+// it returns false if the row should be skipped because of a missing value
+func (c *CSVReader) parseColumns(dst []float64, record []string, columns []int) ([]float64, bool, error) {
+
+	for _, index := range columns {
+		field := strings.TrimSpace(record[index])
+
+		if c.naValues[field] {
+			switch c.opts.Missing {
+			case MissingAsNaN:
+				dst = append(dst, math.NaN())
+			case MissingFill:
+				value, ok := c.opts.FillValues[c.header[index]]
+				if !ok {
+					value = c.opts.FillValue
+				}
+				dst = append(dst, value)
+			case MissingSkipRow:
+				return dst, false, nil
+			default:
+				return dst, false, fmt.Errorf("line %d: missing value in column %q", c.line, c.header[index])
+			}
+			continue
+		}
+
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return dst, false, fmt.Errorf("line %d: column %q: %w", c.line, c.header[index], err)
+		}
+		dst = append(dst, value)
+	}
+
+	return dst, true, nil
+}
+
+// Next reads up to BatchSize rows and returns them as a [rows, features] tensor and a [rows, targets] tensor
+// targets is nil when there are no target columns, io.EOF is returned once every row has been read
+func (c *CSVReader) Next() (*tensor.Tensor, *tensor.Tensor, error) {
+
+	if c.err != nil {
+		return nil, nil, c.err
+	}
+
+	batchSize := max(c.opts.BatchSize, 1)
+	features := make([]float64, 0, batchSize*len(c.features))
+	targets := make([]float64, 0, batchSize*len(c.targets))
+	rows := 0
+
+	for rows < batchSize {
+		record, err := c.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			c.err = err
+			return nil, nil, err
+		}
+		if len(record) != len(c.header) {
+			c.err = fmt.Errorf("line %d: expected %d fields, got %d", c.line, len(c.header), len(record))
+			return nil, nil, c.err
+		}
+
+		// parse into the tail of the batch so a skipped row can simply be truncated away
+		featureLen, targetLen := len(features), len(targets)
+
+		var keep bool
+		features, keep, err = c.parseColumns(features, record, c.features)
+		if err == nil && keep {
+			targets, keep, err = c.parseColumns(targets, record, c.targets)
+		}
+		if err != nil {
+			c.err = err
+			return nil, nil, err
+		}
+		if !keep {
+			features, targets = features[:featureLen], targets[:targetLen]
+			continue
+		}
+
+		rows++
+	}
+
+	if rows == 0 {
+		c.err = io.EOF
+		return nil, nil, io.EOF
+	}
+
+	var targetTensor *tensor.Tensor
+	if len(c.targets) > 0 {
+		targetTensor = tensor.NewTensor(targets, rows, len(c.targets))
+	}
+
+	return tensor.NewTensor(features, rows, len(c.features)), targetTensor, nil
+}
+
+// returns the row held back while reading the header, or the next row from the file
+func (c *CSVReader) next() ([]string, error) {
+	if c.pending != nil {
+		record := c.pending
+		c.pending = nil
+		return record, nil
+	}
+	return c.readRecord()
+}
+
+// Batches returns an iterator over the remaining batches in the file, any error other than io.EOF is reported by Err
+func (c *CSVReader) Batches(ctx context.Context) iter.Seq2[*tensor.Tensor, *tensor.Tensor] {
+	return func(yield func(*tensor.Tensor, *tensor.Tensor) bool) {
+		for {
+			if err := ctx.Err(); err != nil {
+				c.err = err
+				return
+			}
+
+			features, targets, err := c.Next()
+			if err != nil {
+				return
+			}
+
+			if !yield(features, targets) {
+				return
+			}
+		}
+	}
+}
+
+// Err returns the error that stopped reading, if it was anything other than reaching the end of the file
+func (c *CSVReader) Err() error {
+	if errors.Is(c.err, io.EOF) {
+		return nil
+	}
+	return c.err
+}
+
+// CSVLoader streams a CSV file in batches, opening it again at the start of every epoch
+type CSVLoader struct {
+	Path    string
+	Options CSVOptions
+
+	err error
+}
+
+// Batches returns an iterator over every batch in the file
+func (l *CSVLoader) Batches(ctx context.Context) iter.Seq2[*tensor.Tensor, *tensor.Tensor] {
+	return func(yield func(*tensor.Tensor, *tensor.Tensor) bool) {
+
+		l.err = nil
+
+		reader, err := OpenCSV(l.Path, l.Options)
+		if err != nil {
+			l.err = err
+			return
+		}
+		defer reader.Close()
+
+		for features, targets := range reader.Batches(ctx) {
+			if !yield(features, targets) {
+				break
+			}
+		}
+
+		l.err = reader.Err()
+	}
+}
+
+// Err returns the error that stopped the last epoch, if any
+func (l *CSVLoader) Err() error {
+	return l.err
+}
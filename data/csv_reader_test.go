@@ -0,0 +1,228 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"gotorch/model"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var _ model.Loader = &CSVLoader{}
+
+const housingCSV = `# house prices
+size,rooms,age,price
+100,3,10,200
+80,2,,150
+120,4,5,260
+`
+
+// reads every batch from a reader, failing the test on any error other than io.EOF
+func readAll(t *testing.T, c *CSVReader) (features, targets [][]float64) {
+	t.Helper()
+
+	for {
+		f, tg, err := c.Next()
+		if errors.Is(err, io.EOF) {
+			return features, targets
+		}
+		if err != nil {
+			t.Fatalf("Failed to read batch: %v", err)
+		}
+		features = append(features, f.Data)
+		if tg != nil {
+			targets = append(targets, tg.Data)
+		}
+	}
+}
+
+func Test_CSVReaderHeaderAndTargets(t *testing.T) {
+
+	c, err := NewCSVReader(strings.NewReader(housingCSV), CSVOptions{
+		Header:        true,
+		Comment:       '#',
+		TargetColumns: []string{"price"},
+		Missing:       MissingFill,
+		FillValues:    map[string]float64{"age": -1},
+		BatchSize:     2,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+
+	if !reflect.DeepEqual(c.Header(), []string{"size", "rooms", "age", "price"}) {
+		t.Errorf("Unexpected header %v", c.Header())
+	}
+	if !reflect.DeepEqual(c.FeatureColumns(), []string{"size", "rooms", "age"}) {
+		t.Errorf("Unexpected feature columns %v", c.FeatureColumns())
+	}
+
+	features, targets := readAll(t, c)
+
+	expectedFeatures := [][]float64{{100, 3, 10, 80, 2, -1}, {120, 4, 5}}
+	expectedTargets := [][]float64{{200, 150}, {260}}
+	if !reflect.DeepEqual(features, expectedFeatures) {
+		t.Errorf("Expected features %v, got %v", expectedFeatures, features)
+	}
+	if !reflect.DeepEqual(targets, expectedTargets) {
+		t.Errorf("Expected targets %v, got %v", expectedTargets, targets)
+	}
+}
+
+func Test_CSVReaderColumnSelection(t *testing.T) {
+
+	c, err := NewCSVReader(strings.NewReader(housingCSV), CSVOptions{
+		Header:         true,
+		Comment:        '#',
+		FeatureColumns: []string{"rooms", "size"},
+		TargetColumns:  []string{"price"},
+		BatchSize:      10,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+
+	features, tg, err := c.Next()
+	if err != nil {
+		t.Fatalf("Failed to read batch: %v", err)
+	}
+
+	if !reflect.DeepEqual(features.Shape, []int{3, 2}) || !reflect.DeepEqual(features.Data, []float64{3, 100, 2, 80, 4, 120}) {
+		t.Errorf("Unexpected features %v", features)
+	}
+	if !reflect.DeepEqual(tg.Shape, []int{3, 1}) {
+		t.Errorf("Unexpected target shape %v", tg.Shape)
+	}
+}
+
+func Test_CSVReaderUnknownColumn(t *testing.T) {
+
+	_, err := NewCSVReader(strings.NewReader(housingCSV), CSVOptions{Header: true, Comment: '#', TargetColumns: []string{"cost"}})
+	if err == nil {
+		t.Errorf("Expected an error for a column that doesn't exist")
+	}
+}
+
+func Test_CSVReaderMissingValues(t *testing.T) {
+
+	open := func(policy MissingPolicy) *CSVReader {
+		c, err := NewCSVReader(strings.NewReader(housingCSV), CSVOptions{Header: true, Comment: '#', Missing: policy, BatchSize: 10})
+		if err != nil {
+			t.Fatalf("Failed to create reader: %v", err)
+		}
+		return c
+	}
+
+	features, _ := readAll(t, open(MissingAsNaN))
+	if !math.IsNaN(features[0][6]) {
+		t.Errorf("Expected the missing age to be NaN, got %v", features[0][6])
+	}
+
+	features, _ = readAll(t, open(MissingSkipRow))
+	if len(features[0]) != 8 {
+		t.Errorf("Expected the row with a missing value to be skipped, got %v", features)
+	}
+
+	if _, _, err := open(MissingError).Next(); err == nil || !strings.Contains(err.Error(), "age") {
+		t.Errorf("Expected an error naming the age column, got %v", err)
+	}
+}
+
+func Test_CSVReaderNoHeaderDelimiterAndSkipRows(t *testing.T) {
+
+	input := "generated by a tool\n1;2;3\n4;5;6\n"
+
+	c, err := NewCSVReader(strings.NewReader(input), CSVOptions{Comma: ';', SkipRows: 1, TargetColumns: []string{"2"}})
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+
+	features, targets := readAll(t, c)
+	if !reflect.DeepEqual(features, [][]float64{{1, 2}, {4, 5}}) || !reflect.DeepEqual(targets, [][]float64{{3}, {6}}) {
+		t.Errorf("Unexpected features %v and targets %v", features, targets)
+	}
+}
+
+func Test_CSVReaderParseError(t *testing.T) {
+
+	c, err := OpenCSV("test_error_parsing.csv", CSVOptions{BatchSize: 2})
+	if err != nil {
+		t.Fatalf("Failed to open CSV: %v", err)
+	}
+	defer c.Close()
+
+	for range c.Batches(context.Background()) {
+	}
+
+	if c.Err() == nil || !strings.Contains(c.Err().Error(), "line 3") {
+		t.Errorf("Expected a parse error on line 3, got %v", c.Err())
+	}
+}
+
+func Test_CSVLoaderEpochs(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "housing.csv")
+	if err := os.WriteFile(path, []byte(housingCSV), 0o644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	loader := &CSVLoader{Path: path, Options: CSVOptions{Header: true, Comment: '#', TargetColumns: []string{"price"}, Missing: MissingSkipRow, BatchSize: 1}}
+
+	for epoch := 0; epoch < 2; epoch++ {
+		rows := 0
+		for features, targets := range loader.Batches(context.Background()) {
+			if features.Shape[0] != 1 || targets.Shape[0] != 1 {
+				t.Errorf("Expected single row batches, got %v and %v", features.Shape, targets.Shape)
+			}
+			rows++
+		}
+		if err := loader.Err(); err != nil {
+			t.Fatalf("Failed to load epoch %d: %v", epoch, err)
+		}
+		if rows != 2 {
+			t.Errorf("Expected 2 rows in epoch %d, got %d", epoch, rows)
+		}
+	}
+}
+
+func Test_CSVLoaderMissingFile(t *testing.T) {
+
+	loader := &CSVLoader{Path: "test32r23.csv"}
+	for range loader.Batches(context.Background()) {
+	}
+
+	if loader.Err() == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
+
+func Test_CSVLoaderWithTrainer(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "line.csv")
+	if err := os.WriteFile(path, []byte("x,y\n1,3\n2,5\n3,7\n4,9\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	trainer := &model.Trainer{
+		Model:     &model.Linear{Weights: []float64{0}, Biases: []float64{0}},
+		Loss:      model.MSELoss,
+		Optimizer: &model.SGD{LearningRate: 0.02},
+		Train:     &CSVLoader{Path: path, Options: CSVOptions{Header: true, TargetColumns: []string{"y"}, BatchSize: 2}},
+		Epochs:    5,
+	}
+
+	history, err := trainer.Fit(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+
+	losses := history.Metric("loss")
+	if len(losses) != 5 || losses[4] >= losses[0] {
+		t.Errorf("Expected the loss to fall over 5 epochs, got %v", losses)
+	}
+}
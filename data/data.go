@@ -2,9 +2,12 @@ package data
 
 import (
 	"encoding/csv"
+	"fmt"
 	"gotorch/tensor"
+	"io"
 	"os"
 	"strconv"
+	"strings"
 )
 
 // load a csv file
@@ -46,8 +49,55 @@ func LoadCSV(filepath string) (*tensor.Tensor, error) {
 	return tensor, nil
 }
 
+// CSVLayout decides how a tensor's dimensions are mapped onto rows and columns when it is saved
+type CSVLayout int
+
+const (
+	// FlattenRows writes one row per entry of the first dimension with every other dimension flattened into the columns,
+	// a [2, 3, 4] tensor becomes 2 rows of 12 values and a vector is written as a single column
+	FlattenRows CSVLayout = iota
+	// FlattenColumns writes one column per entry of the last dimension with every other dimension flattened into the rows,
+	// a [2, 3, 4] tensor becomes 6 rows of 4 values and a vector is written as a single row
+	FlattenColumns
+	// LongFormat writes one row per element holding its index along every dimension followed by its value
+	LongFormat
+)
+
+// CSVWriteOptions configures how SaveCSVWithOptions writes a tensor
+type CSVWriteOptions struct {
+	Comma  rune // field delimiter, defaults to ','
+	Layout CSVLayout
+
+	// Header writes a row of column names first, ColumnNames are used if given and generated names otherwise
+	// generated names are the column's index in the flattened dimensions joined by '_', or dim0, dim1, ... value for LongFormat
+	Header      bool
+	ColumnNames []string
+
+	// Format and Precision are passed to strconv.FormatFloat, so a Precision of -1 writes the shortest representation
+	// that reads back as the same value and 0 writes no decimal places with 'f'. Format defaults to 'f'
+	// start from DefaultCSVWriteOptions to get -1, the zero value writes no decimal places
+	Format    byte
+	Precision int
+}
+
+// DefaultCSVWriteOptions returns the options SaveCSV uses, comma separated rows written with the shortest precision
+func DefaultCSVWriteOptions() CSVWriteOptions {
+	return CSVWriteOptions{Comma: ',', Format: 'f', Precision: -1}
+}
+
 // save a csv file
+// the tensor is written one row per entry of its first dimension, see SaveCSVWithOptions for other layouts
 func SaveCSV(t *tensor.Tensor, filePath string) error {
+	return SaveCSVWithOptions(t, filePath, DefaultCSVWriteOptions())
+}
+
+// SaveCSVWithOptions saves a tensor of any rank, the shape is checked before the file is created
+func SaveCSVWithOptions(t *tensor.Tensor, filePath string, opts CSVWriteOptions) error {
+
+	records, err := csvRecords(t, opts)
+	if err != nil {
+		return err
+	}
 
 	file, err := os.Create(filePath)
 	if err != nil {
@@ -55,24 +105,150 @@ func SaveCSV(t *tensor.Tensor, filePath string) error {
 	}
 	defer file.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	if err := writeRecords(file, records, opts); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// WriteCSV writes a tensor to w using the same options as SaveCSVWithOptions
+func WriteCSV(w io.Writer, t *tensor.Tensor, opts CSVWriteOptions) error {
+
+	records, err := csvRecords(t, opts)
+	if err != nil {
+		return err
+	}
+
+	return writeRecords(w, records, opts)
+}
+
+func writeRecords(w io.Writer, records [][]string, opts CSVWriteOptions) error {
+
+	writer := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		writer.Comma = opts.Comma
+	}
+
+	if err := writer.WriteAll(records); err != nil {
+		return fmt.Errorf("unable to write csv: %w", err)
+	}
+
+	return nil
+}
+
+// csvRecords lays a tensor out as rows of formatted values, with the header first if one was asked for
+func csvRecords(t *tensor.Tensor, opts CSVWriteOptions) ([][]string, error) {
+
+	if len(t.Shape) == 0 {
+		return nil, fmt.Errorf("cannot save a tensor with no dimensions")
+	}
+
+	size := 1
+	for _, s := range t.Shape {
+		if s < 0 {
+			return nil, fmt.Errorf("invalid shape %v", t.Shape)
+		}
+		size *= s
+	}
+	if size != len(t.Data) {
+		return nil, fmt.Errorf("shape %v needs %d values but the tensor has %d", t.Shape, size, len(t.Data))
+	}
+
+	format := opts.Format
+	if format == 0 {
+		format = 'f'
+	}
+	if opts.Precision < -1 {
+		return nil, fmt.Errorf("precision must be -1 or more, got %d", opts.Precision)
+	}
+	formatValue := func(v float64) string {
+		return strconv.FormatFloat(v, format, opts.Precision, 64)
+	}
+
+	var names []string
+	var records [][]string
+
+	switch opts.Layout {
+	case FlattenRows, FlattenColumns:
+		// the dimensions that make up the columns
+		columnShape := t.Shape[1:]
+		if opts.Layout == FlattenColumns {
+			columnShape = t.Shape[len(t.Shape)-1:]
+		}
+
+		numCols := 1
+		for _, s := range columnShape {
+			numCols *= s
+		}
+
+		if numCols == 0 {
+			return nil, fmt.Errorf("cannot save shape %v with no columns", t.Shape)
+		}
+
+		for start := 0; start < len(t.Data); start += numCols {
+			record := make([]string, numCols)
+			for j, v := range t.Data[start : start+numCols] {
+				record[j] = formatValue(v)
+			}
+			records = append(records, record)
+		}
+
+		names = make([]string, numCols)
+		for j := range names {
+			names[j] = joinIndex(unravelIndex(j, columnShape), "_")
+		}
+		if len(columnShape) == 0 {
+			names = []string{"0"}
+		}
 
-	// tensors are flattened by default, so need to reshape it
-	numRows, numCols := t.Shape[0], t.Shape[1]
-	dataIndex := 0
+	case LongFormat:
+		for i, v := range t.Data {
+			index := unravelIndex(i, t.Shape)
+			record := make([]string, 0, len(index)+1)
+			for _, n := range index {
+				record = append(record, strconv.Itoa(n))
+			}
+			records = append(records, append(record, formatValue(v)))
+		}
 
-	for i := 0; i < numRows; i++ {
-		record := make([]string, numCols)
-		for j := 0; j < numCols; j++ {
-			record[j] = strconv.FormatFloat(t.Data[dataIndex], 'f', -1, 64)
-			dataIndex++
+		for d := range t.Shape {
+			names = append(names, "dim"+strconv.Itoa(d))
 		}
-		if err := writer.Write(record); err != nil {
-			return err
+		names = append(names, "value")
+
+	default:
+		return nil, fmt.Errorf("unknown csv layout %d", opts.Layout)
+	}
+
+	if opts.ColumnNames != nil {
+		if len(opts.ColumnNames) != len(names) {
+			return nil, fmt.Errorf("got %d column names for %d columns", len(opts.ColumnNames), len(names))
 		}
+		names = opts.ColumnNames
 	}
 
-	return nil
+	if opts.Header {
+		records = append([][]string{names}, records...)
+	}
+
+	return records, nil
+}
 
+// unravelIndex converts a flat row major index into an index along each dimension of shape
+func unravelIndex(i int, shape []int) []int {
+	index := make([]int, len(shape))
+	for d := len(shape) - 1; d >= 0; d-- {
+		index[d] = i % shape[d]
+		i /= shape[d]
+	}
+	return index
+}
+
+func joinIndex(index []int, sep string) string {
+	parts := make([]string, len(index))
+	for i, n := range index {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, sep)
 }
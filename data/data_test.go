@@ -4,7 +4,9 @@ import (
 	"encoding/csv"
 	"gotorch/tensor"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -95,3 +97,146 @@ func Test_SaveCSV_InvalidPath(t *testing.T) {
 		t.Errorf("Expected an error due to invalid file path, but got none")
 	}
 }
+
+// writes a tensor with WriteCSV and reads the records back
+func writeAndRead(t *testing.T, tensor *tensor.Tensor, opts CSVWriteOptions) [][]string {
+	t.Helper()
+
+	var buf strings.Builder
+	if err := WriteCSV(&buf, tensor, opts); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	reader := csv.NewReader(strings.NewReader(buf.String()))
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV back: %v", err)
+	}
+	return records
+}
+
+func Test_SaveCSV_Vector(t *testing.T) {
+
+	vector := tensor.NewTensor([]float64{1, 2, 3})
+
+	records := writeAndRead(t, vector, CSVWriteOptions{})
+	expected := [][]string{{"1"}, {"2"}, {"3"}}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("Expected %v, got %v", expected, records)
+	}
+
+	records = writeAndRead(t, vector, CSVWriteOptions{Layout: FlattenColumns, Header: true})
+	expected = [][]string{{"0", "1", "2"}, {"1", "2", "3"}}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("Expected %v, got %v", expected, records)
+	}
+}
+
+func Test_SaveCSV_HigherRank(t *testing.T) {
+
+	cube := tensor.NewTensor([]float64{0, 1, 2, 3, 4, 5, 6, 7}, 2, 2, 2)
+
+	records := writeAndRead(t, cube, CSVWriteOptions{Header: true})
+	expected := [][]string{{"0_0", "0_1", "1_0", "1_1"}, {"0", "1", "2", "3"}, {"4", "5", "6", "7"}}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("Expected %v, got %v", expected, records)
+	}
+
+	records = writeAndRead(t, cube, CSVWriteOptions{Layout: FlattenColumns})
+	if len(records) != 4 || !reflect.DeepEqual(records[3], []string{"6", "7"}) {
+		t.Errorf("Expected 4 rows of 2 values, got %v", records)
+	}
+
+	records = writeAndRead(t, cube, CSVWriteOptions{Layout: LongFormat, Header: true})
+	if len(records) != 9 {
+		t.Fatalf("Expected a header and 8 rows, got %d", len(records))
+	}
+	if !reflect.DeepEqual(records[0], []string{"dim0", "dim1", "dim2", "value"}) {
+		t.Errorf("Unexpected header %v", records[0])
+	}
+	if !reflect.DeepEqual(records[7], []string{"1", "1", "0", "6"}) {
+		t.Errorf("Unexpected row %v", records[7])
+	}
+}
+
+func Test_SaveCSV_ColumnNamesAndFormatting(t *testing.T) {
+
+	matrix := tensor.NewTensor([][]float64{{1.0 / 3, 2}, {3, 1e-7}})
+
+	records := writeAndRead(t, matrix, CSVWriteOptions{Comma: ';', Header: true, ColumnNames: []string{"a", "b"}, Precision: 3})
+	expected := [][]string{{"a", "b"}, {"0.333", "2.000"}, {"3.000", "0.000"}}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("Expected %v, got %v", expected, records)
+	}
+
+	records = writeAndRead(t, matrix, CSVWriteOptions{Format: 'e', Precision: 1})
+	if records[1][1] != "1.0e-07" {
+		t.Errorf("Expected 1.0e-07, got %v", records[1][1])
+	}
+
+	// a precision of 0 writes no decimal places, as for integer labels
+	labels := tensor.NewTensor([][]float64{{0, 1}, {2, 2.6}})
+	records = writeAndRead(t, labels, CSVWriteOptions{Precision: 0})
+	expected = [][]string{{"0", "1"}, {"2", "3"}}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("Expected %v, got %v", expected, records)
+	}
+
+	records = writeAndRead(t, labels, DefaultCSVWriteOptions())
+	expected = [][]string{{"0", "1"}, {"2", "2.6"}}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("Expected the default options to write the shortest values %v, got %v", expected, records)
+	}
+
+	var buf strings.Builder
+	if err := WriteCSV(&buf, matrix, CSVWriteOptions{Precision: -2}); err == nil {
+		t.Errorf("Expected an error for a precision below -1")
+	}
+	if err := WriteCSV(&buf, matrix, CSVWriteOptions{Header: true, ColumnNames: []string{"a"}}); err == nil {
+		t.Errorf("Expected an error for the wrong number of column names")
+	}
+}
+
+func Test_SaveCSV_RoundTrip(t *testing.T) {
+
+	original := tensor.NewTensor([][]float64{{0.1, 1.0 / 3}, {-2.5e-10, 12345.678}})
+	filePath := filepath.Join(t.TempDir(), "round_trip.csv")
+
+	if err := SaveCSV(original, filePath); err != nil {
+		t.Fatalf("Failed to save CSV: %v", err)
+	}
+
+	loaded, err := LoadCSV(filePath)
+	if err != nil {
+		t.Fatalf("Failed to load CSV: %v", err)
+	}
+
+	if !reflect.DeepEqual(loaded.Data, original.Data) {
+		t.Errorf("Expected %v, got %v", original.Data, loaded.Data)
+	}
+}
+
+func Test_SaveCSV_UnsupportedShapes(t *testing.T) {
+
+	dir := t.TempDir()
+
+	tensors := map[string]*tensor.Tensor{
+		"scalar":   {Data: []float64{1}},
+		"mismatch": {Data: []float64{1, 2, 3}, Shape: []int{2, 2}},
+		"empty":    {Data: []float64{}, Shape: []int{2, 0}},
+	}
+
+	for name, tensor := range tensors {
+		filePath := filepath.Join(dir, name+".csv")
+		if err := SaveCSV(tensor, filePath); err == nil {
+			t.Errorf("Expected an error saving the %s tensor", name)
+		}
+		if _, err := os.Stat(filePath); err == nil {
+			t.Errorf("Expected no file to be created for the %s tensor", name)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"gotorch/tensor"
+	"iter"
+	"math/rand"
+	"sync"
+)
+
+/*
+A DataLoader turns a Dataset into mini-batches. Each epoch a Sampler decides the order the samples are visited in,
+the indices are grouped into batches of BatchSize, and each group of samples is merged by the Collate function.
+It satisfies model.Loader so it can be handed straight to a model.Trainer.
+*/
+
+// Sampler decides which dataset indices are visited in an epoch and in what order
+// Indices is called once per epoch and Len reports how many indices it returns
+type Sampler interface {
+	Indices() []int
+	Len() int
+}
+
+// SequentialSampler visits every index in order
+type SequentialSampler struct {
+	N int
+}
+
+func (s *SequentialSampler) Len() int {
+	return s.N
+}
+
+func (s *SequentialSampler) Indices() []int {
+	indices := make([]int, s.N)
+	for i := range indices {
+		indices[i] = i
+	}
+	return indices
+}
+
+// RandomSampler visits every index in a random order that changes each epoch but is reproducible from the seed
+type RandomSampler struct {
+	N   int
+	rng *rand.Rand
+}
+
+// NewRandomSampler returns a sampler that shuffles n indices with a generator seeded by seed
+func NewRandomSampler(n int, seed int64) *RandomSampler {
+	return &RandomSampler{N: n, rng: rand.New(rand.NewSource(seed))}
+}
+
+func (s *RandomSampler) Len() int {
+	return s.N
+}
+
+func (s *RandomSampler) Indices() []int {
+	return s.rng.Perm(s.N)
+}
+
+// DataLoader yields batches from a Dataset
+// BatchSize defaults to 1 and Collate to DefaultCollate, if Sampler is nil the samples are visited in order or,
+// when Shuffle is set, in a random order seeded by Seed. DropLast drops a final batch smaller than BatchSize
+// NumWorkers > 0 loads and collates batches on that many goroutines, keeping at most NumWorkers*PrefetchFactor
+// batches (PrefetchFactor defaults to 2) ready ahead of the consumer. The Dataset and Collate must then be safe for concurrent use
+type DataLoader struct {
+	Dataset        Dataset
+	BatchSize      int
+	Shuffle        bool
+	Seed           int64
+	DropLast       bool
+	Collate        CollateFunc
+	Sampler        Sampler
+	NumWorkers     int
+	PrefetchFactor int
+
+	sampler Sampler
+	err     error
+}
+
+// returns the sampler used for the next epoch, creating the default one on first use so shuffling carries on between epochs
+func (d *DataLoader) epochSampler() Sampler {
+
+	if d.Sampler != nil {
+		return d.Sampler
+	}
+
+	if d.sampler == nil {
+		if d.Shuffle {
+			d.sampler = NewRandomSampler(d.Dataset.Len(), d.Seed)
+		} else {
+			d.sampler = &SequentialSampler{N: d.Dataset.Len()}
+		}
+	}
+
+	return d.sampler
+}
+
+func (d *DataLoader) batchSize() int {
+	return max(d.BatchSize, 1)
+}
+
+// Len returns the number of batches in an epoch
+func (d *DataLoader) Len() int {
+	n := d.epochSampler().Len()
+	if d.DropLast {
+		return n / d.batchSize()
+	}
+	return (n + d.batchSize() - 1) / d.batchSize()
+}
+
+// splits the indices for an epoch into batches, dropping the last one if it is short and DropLast is set
+func (d *DataLoader) batchIndices() [][]int {
+
+	indices := d.epochSampler().Indices()
+	size := d.batchSize()
+
+	var batches [][]int
+	for start := 0; start < len(indices); start += size {
+		end := min(start+size, len(indices))
+		if end-start < size && d.DropLast {
+			break
+		}
+		batches = append(batches, indices[start:end])
+	}
+
+	return batches
+}
+
+// loads the samples for a batch and merges them with the collate function
+func (d *DataLoader) loadBatch(indices []int) (*tensor.Tensor, *tensor.Tensor, error) {
+
+	samples := make([]Sample, len(indices))
+	for i, index := range indices {
+		sample, err := d.Dataset.Get(index)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to load sample %d: %w", index, err)
+		}
+		samples[i] = sample
+	}
+
+	collate := d.Collate
+	if collate == nil {
+		collate = DefaultCollate
+	}
+
+	return collate(samples)
+}
+
+// Batches returns an iterator over the (inputs, targets) batches of one epoch
+// batches are always yielded in sampler order, even when they are loaded by several workers
+// iteration stops at the first error, or when ctx is cancelled, and the error is then reported by Err
+func (d *DataLoader) Batches(ctx context.Context) iter.Seq2[*tensor.Tensor, *tensor.Tensor] {
+	if d.NumWorkers > 0 {
+		return d.parallelBatches(ctx)
+	}
+
+	return func(yield func(*tensor.Tensor, *tensor.Tensor) bool) {
+
+		d.err = nil
+
+		for _, indices := range d.batchIndices() {
+			if err := ctx.Err(); err != nil {
+				d.err = err
+				return
+			}
+
+			inputs, targets, err := d.loadBatch(indices)
+			if err != nil {
+				d.err = err
+				return
+			}
+
+			if !yield(inputs, targets) {
+				return
+			}
+		}
+	}
+}
+
+// batchResult is a loaded batch, or the error hit while loading it
+type batchResult struct {
+	inputs  *tensor.Tensor
+	targets *tensor.Tensor
+	err     error
+}
+
+// parallelBatches loads batches on a pool of worker goroutines
+// a dispatcher hands batch numbers to the workers, but never more than the prefetch limit ahead of the consumer, and every
+// batch gets its own single slot result channel so the consumer can read them back in order regardless of which worker finishes first
+func (d *DataLoader) parallelBatches(ctx context.Context) iter.Seq2[*tensor.Tensor, *tensor.Tensor] {
+	return func(yield func(*tensor.Tensor, *tensor.Tensor) bool) {
+
+		d.err = nil
+
+		batches := d.batchIndices()
+		results := make([]chan batchResult, len(batches))
+		for i := range results {
+			results[i] = make(chan batchResult, 1)
+		}
+
+		prefetch := d.NumWorkers * 2
+		if d.PrefetchFactor > 0 {
+			prefetch = d.NumWorkers * d.PrefetchFactor
+		}
+
+		// workerCtx is cancelled when the consumer stops early so the dispatcher and workers shut down
+		workerCtx, cancel := context.WithCancel(ctx)
+		var wg sync.WaitGroup
+		defer func() {
+			cancel()
+			wg.Wait()
+		}()
+
+		jobs := make(chan int)
+		slots := make(chan struct{}, prefetch)
+
+		// dispatcher
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(jobs)
+
+			for i := range batches {
+				select {
+				case slots <- struct{}{}:
+				case <-workerCtx.Done():
+					return
+				}
+
+				select {
+				case jobs <- i:
+				case <-workerCtx.Done():
+					return
+				}
+			}
+		}()
+
+		// workers
+		for w := 0; w < d.NumWorkers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				for i := range jobs {
+					if workerCtx.Err() != nil {
+						return
+					}
+					inputs, targets, err := d.loadBatch(batches[i])
+					results[i] <- batchResult{inputs: inputs, targets: targets, err: err}
+				}
+			}()
+		}
+
+		for i := range batches {
+			var result batchResult
+			select {
+			case result = <-results[i]:
+			case <-ctx.Done():
+				d.err = ctx.Err()
+				return
+			}
+
+			// free up a slot so the dispatcher can hand out the next batch
+			<-slots
+
+			if result.err != nil {
+				d.err = result.err
+				return
+			}
+
+			if !yield(result.inputs, result.targets) {
+				return
+			}
+		}
+	}
+}
+
+// Err returns the error that stopped the last iteration, if any
+func (d *DataLoader) Err() error {
+	return d.err
+}
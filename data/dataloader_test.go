@@ -0,0 +1,280 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"gotorch/model"
+	"gotorch/tensor"
+	"reflect"
+	"runtime"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// the DataLoader is used as the training and validation loader of a model.Trainer
+var _ model.Loader = &DataLoader{}
+
+func newRangeDataset(t *testing.T, n int) *TensorDataset {
+	t.Helper()
+
+	features := make([]float64, n)
+	for i := range features {
+		features[i] = float64(i)
+	}
+
+	dataset, err := NewTensorDataset(tensor.NewTensor(features, n, 1), tensor.NewTensor(features))
+	if err != nil {
+		t.Fatalf("Failed to create dataset: %v", err)
+	}
+	return dataset
+}
+
+// collects the first feature of every sample in every batch of an epoch
+func epochOrder(t *testing.T, loader *DataLoader) [][]float64 {
+	t.Helper()
+
+	var batches [][]float64
+	for inputs := range loader.Batches(context.Background()) {
+		batches = append(batches, append([]float64{}, inputs.Data...))
+	}
+	if err := loader.Err(); err != nil {
+		t.Fatalf("Failed to load batches: %v", err)
+	}
+	return batches
+}
+
+func Test_DataLoaderBatches(t *testing.T) {
+
+	loader := &DataLoader{Dataset: newRangeDataset(t, 5), BatchSize: 2}
+
+	expected := [][]float64{{0, 1}, {2, 3}, {4}}
+	if got := epochOrder(t, loader); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected batches %v, got %v", expected, got)
+	}
+	if loader.Len() != 3 {
+		t.Errorf("Expected 3 batches, got %d", loader.Len())
+	}
+}
+
+func Test_DataLoaderDropLast(t *testing.T) {
+
+	loader := &DataLoader{Dataset: newRangeDataset(t, 5), BatchSize: 2, DropLast: true}
+
+	expected := [][]float64{{0, 1}, {2, 3}}
+	if got := epochOrder(t, loader); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected batches %v, got %v", expected, got)
+	}
+	if loader.Len() != 2 {
+		t.Errorf("Expected 2 batches, got %d", loader.Len())
+	}
+}
+
+func Test_DataLoaderShuffleIsSeeded(t *testing.T) {
+
+	first := &DataLoader{Dataset: newRangeDataset(t, 20), BatchSize: 20, Shuffle: true, Seed: 42}
+	second := &DataLoader{Dataset: newRangeDataset(t, 20), BatchSize: 20, Shuffle: true, Seed: 42}
+
+	epoch1 := epochOrder(t, first)[0]
+	epoch2 := epochOrder(t, first)[0]
+
+	if !reflect.DeepEqual(epoch1, epochOrder(t, second)[0]) {
+		t.Errorf("Expected loaders with the same seed to shuffle the same way")
+	}
+	if reflect.DeepEqual(epoch1, epoch2) {
+		t.Errorf("Expected a different order each epoch")
+	}
+
+	sorted := slices.Clone(epoch1)
+	slices.Sort(sorted)
+	if !reflect.DeepEqual(sorted, newRangeDataset(t, 20).Labels.Data) {
+		t.Errorf("Expected every sample exactly once, got %v", epoch1)
+	}
+}
+
+// fixedSampler always visits the same indices
+type fixedSampler []int
+
+func (f fixedSampler) Indices() []int { return f }
+func (f fixedSampler) Len() int       { return len(f) }
+
+func Test_DataLoaderCustomSamplerAndCollate(t *testing.T) {
+
+	// doubles every feature and drops the labels
+	collate := func(samples []Sample) (*tensor.Tensor, *tensor.Tensor, error) {
+		data := make([]float64, len(samples))
+		for i, s := range samples {
+			data[i] = 2 * s.Features.Data[0]
+		}
+		return tensor.NewTensor(data, len(samples), 1), nil, nil
+	}
+
+	loader := &DataLoader{Dataset: newRangeDataset(t, 5), BatchSize: 2, Sampler: fixedSampler{4, 0, 4}, Collate: collate}
+
+	expected := [][]float64{{8, 0}, {8}}
+	if got := epochOrder(t, loader); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected batches %v, got %v", expected, got)
+	}
+}
+
+// failingDataset fails to load one of its samples
+type failingDataset struct {
+	*TensorDataset
+	bad int
+}
+
+func (f *failingDataset) Get(i int) (Sample, error) {
+	if i == f.bad {
+		return Sample{}, errors.New("corrupt sample")
+	}
+	return f.TensorDataset.Get(i)
+}
+
+func Test_DataLoaderError(t *testing.T) {
+
+	loader := &DataLoader{Dataset: &failingDataset{TensorDataset: newRangeDataset(t, 5), bad: 3}, BatchSize: 2}
+
+	batches := 0
+	for range loader.Batches(context.Background()) {
+		batches++
+	}
+
+	if batches != 1 || loader.Err() == nil {
+		t.Errorf("Expected iteration to stop with an error after 1 batch, got %d batches and error %v", batches, loader.Err())
+	}
+}
+
+func Test_DataLoaderContextCancelled(t *testing.T) {
+
+	loader := &DataLoader{Dataset: newRangeDataset(t, 5), BatchSize: 2}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	batches := 0
+	for range loader.Batches(ctx) {
+		batches++
+		cancel()
+	}
+
+	if batches != 1 || !errors.Is(loader.Err(), context.Canceled) {
+		t.Errorf("Expected iteration to stop after cancelling, got %d batches and error %v", batches, loader.Err())
+	}
+}
+
+func Test_DataLoaderWithTrainer(t *testing.T) {
+
+	features := tensor.NewTensor([][]float64{{1, 2}, {3, 4}, {5, 6}, {7, 8}})
+	targets := tensor.NewTensor([]float64{5, 11, 17, 23})
+	dataset, err := NewTensorDataset(features, targets)
+	if err != nil {
+		t.Fatalf("Failed to create dataset: %v", err)
+	}
+
+	trainer := &model.Trainer{
+		Model:     &model.Linear{Weights: []float64{0, 0}, Biases: []float64{0}},
+		Loss:      model.MSELoss,
+		Optimizer: &model.SGD{LearningRate: 0.01},
+		Train:     &DataLoader{Dataset: dataset, BatchSize: 2, Shuffle: true, Seed: 1},
+		Epochs:    200,
+	}
+
+	history, err := trainer.Fit(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+
+	losses := history.Metric("loss")
+	if losses[len(losses)-1] > 0.1*losses[0] {
+		t.Errorf("Expected mini-batch training to reduce the loss, got %v then %v", losses[0], losses[len(losses)-1])
+	}
+}
+
+// slowDataset sleeps for a pseudo random time on every Get so parallel workers finish out of order
+type slowDataset struct {
+	*TensorDataset
+	gets atomic.Int64
+}
+
+func (s *slowDataset) Get(i int) (Sample, error) {
+	s.gets.Add(1)
+	time.Sleep(time.Duration((i*7)%5) * time.Millisecond)
+	return s.TensorDataset.Get(i)
+}
+
+func Test_DataLoaderWorkersKeepOrder(t *testing.T) {
+
+	sequential := &DataLoader{Dataset: newRangeDataset(t, 50), BatchSize: 3, Shuffle: true, Seed: 7}
+	parallel := &DataLoader{Dataset: &slowDataset{TensorDataset: newRangeDataset(t, 50)}, BatchSize: 3, Shuffle: true, Seed: 7, NumWorkers: 4}
+
+	for epoch := 0; epoch < 2; epoch++ {
+		expected := epochOrder(t, sequential)
+		if got := epochOrder(t, parallel); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Epoch %d: expected the same batches as a single threaded loader, got %v and %v", epoch, got, expected)
+		}
+	}
+}
+
+func Test_DataLoaderWorkersBoundedPrefetch(t *testing.T) {
+
+	dataset := &slowDataset{TensorDataset: newRangeDataset(t, 100)}
+	loader := &DataLoader{Dataset: dataset, BatchSize: 2, NumWorkers: 2, PrefetchFactor: 1}
+
+	for range loader.Batches(context.Background()) {
+		// give the workers plenty of time to run ahead of the consumer
+		time.Sleep(50 * time.Millisecond)
+
+		// the consumed batch plus at most NumWorkers*PrefetchFactor batches in flight
+		if gets := dataset.gets.Load(); gets > (1+2)*2 {
+			t.Errorf("Expected at most 6 samples to be loaded, got %d", gets)
+		}
+		break
+	}
+}
+
+func Test_DataLoaderWorkersError(t *testing.T) {
+
+	loader := &DataLoader{Dataset: &failingDataset{TensorDataset: newRangeDataset(t, 20), bad: 9}, BatchSize: 2, NumWorkers: 3}
+
+	batches := 0
+	for range loader.Batches(context.Background()) {
+		batches++
+	}
+
+	// batches 0 to 3 load fine and batch 4 holds the bad sample
+	if batches != 4 || loader.Err() == nil {
+		t.Errorf("Expected 4 batches then an error, got %d batches and error %v", batches, loader.Err())
+	}
+}
+
+func Test_DataLoaderWorkersShutdown(t *testing.T) {
+
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	loader := &DataLoader{Dataset: &slowDataset{TensorDataset: newRangeDataset(t, 100)}, BatchSize: 1, NumWorkers: 4}
+
+	batches := 0
+	for range loader.Batches(ctx) {
+		batches++
+		if batches == 3 {
+			cancel()
+		}
+	}
+
+	if !errors.Is(loader.Err(), context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", loader.Err())
+	}
+
+	// breaking out of the loop early must also stop every worker
+	for range loader.Batches(context.Background()) {
+		break
+	}
+
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Expected every worker to exit, %d goroutines before and %d after", before, after)
+	}
+}
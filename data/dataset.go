@@ -0,0 +1,130 @@
+package data
+
+import (
+	"fmt"
+	"gotorch/tensor"
+	"gotorch/utils"
+)
+
+// Sample is a single example from a dataset, Label is nil for unlabelled datasets
+type Sample struct {
+	Features *tensor.Tensor
+	Label    *tensor.Tensor
+}
+
+// Dataset gives random access to a fixed number of samples
+type Dataset interface {
+	Len() int
+	Get(i int) (Sample, error)
+}
+
+// TensorDataset is a Dataset backed by a features tensor and an optional labels tensor
+// sample i is row i of each tensor, that is the slice along the first dimension
+type TensorDataset struct {
+	Features *tensor.Tensor
+	Labels   *tensor.Tensor
+}
+
+// NewTensorDataset checks that features and labels have the same number of rows, labels may be nil
+func NewTensorDataset(features, labels *tensor.Tensor) (*TensorDataset, error) {
+
+	if len(features.Shape) == 0 {
+		return nil, fmt.Errorf("features must have at least one dimension")
+	}
+
+	if labels != nil && (len(labels.Shape) == 0 || labels.Shape[0] != features.Shape[0]) {
+		return nil, fmt.Errorf("features have %d rows but labels have shape %v", features.Shape[0], labels.Shape)
+	}
+
+	return &TensorDataset{Features: features, Labels: labels}, nil
+}
+
+func (d *TensorDataset) Len() int {
+	return d.Features.Shape[0]
+}
+
+func (d *TensorDataset) Get(i int) (Sample, error) {
+
+	if i < 0 || i >= d.Len() {
+		return Sample{}, fmt.Errorf("index %d out of range for dataset of length %d", i, d.Len())
+	}
+
+	sample := Sample{Features: row(d.Features, i)}
+	if d.Labels != nil {
+		sample.Label = row(d.Labels, i)
+	}
+
+	return sample, nil
+}
+
+// row returns the i'th slice of a tensor along its first dimension, rows of a 1D tensor are returned with shape [1]
+// the data is shared with t rather than copied
+func row(t *tensor.Tensor, i int) *tensor.Tensor {
+
+	shape := t.Shape[1:]
+	if len(shape) == 0 {
+		shape = []int{1}
+	}
+
+	size := 1
+	for _, s := range shape {
+		size *= s
+	}
+
+	return &tensor.Tensor{Data: t.Data[i*size : (i+1)*size], Shape: append([]int{}, shape...)}
+}
+
+// CollateFunc merges a list of samples into a batch of inputs and targets, targets is nil for unlabelled samples
+type CollateFunc func(samples []Sample) (inputs, targets *tensor.Tensor, err error)
+
+// DefaultCollate stacks the features and labels of every sample along a new first dimension
+// every sample must have the same shape, a batch of n samples with shape [d] becomes [n, d]
+func DefaultCollate(samples []Sample) (*tensor.Tensor, *tensor.Tensor, error) {
+
+	if len(samples) == 0 {
+		return nil, nil, fmt.Errorf("cannot collate an empty batch")
+	}
+
+	features := make([]*tensor.Tensor, len(samples))
+	labels := make([]*tensor.Tensor, 0, len(samples))
+	for i, s := range samples {
+		features[i] = s.Features
+		if s.Label != nil {
+			labels = append(labels, s.Label)
+		}
+	}
+
+	inputs, err := stack(features)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to collate features: %w", err)
+	}
+
+	if len(labels) == 0 {
+		return inputs, nil, nil
+	}
+	if len(labels) != len(samples) {
+		return nil, nil, fmt.Errorf("only %d of %d samples in the batch have labels", len(labels), len(samples))
+	}
+
+	targets, err := stack(labels)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to collate labels: %w", err)
+	}
+
+	return inputs, targets, nil
+}
+
+// stack joins tensors of the same shape along a new first dimension
+func stack(tensors []*tensor.Tensor) (*tensor.Tensor, error) {
+
+	shape := tensors[0].Shape
+	data := make([]float64, 0, len(tensors)*len(tensors[0].Data))
+	for _, t := range tensors {
+		if !utils.AreSlicesEqual(t.Shape, shape) {
+			return nil, fmt.Errorf("cannot stack shapes %v and %v", shape, t.Shape)
+		}
+		data = append(data, t.Data...)
+	}
+
+	return tensor.NewTensor(data, append([]int{len(tensors)}, shape...)...), nil
+}
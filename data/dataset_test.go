@@ -0,0 +1,93 @@
+package data
+
+import (
+	"gotorch/tensor"
+	"reflect"
+	"testing"
+)
+
+func Test_NewTensorDatasetMismatchedRows(t *testing.T) {
+
+	features := tensor.NewTensor([][]float64{{1, 2}, {3, 4}})
+	labels := tensor.NewTensor([]float64{1, 2, 3})
+
+	if _, err := NewTensorDataset(features, labels); err == nil {
+		t.Errorf("Expected an error for 2 feature rows and 3 labels")
+	}
+}
+
+func Test_TensorDatasetGet(t *testing.T) {
+
+	features := tensor.NewTensor([][]float64{{1, 2}, {3, 4}, {5, 6}})
+	labels := tensor.NewTensor([]float64{10, 20, 30})
+
+	dataset, err := NewTensorDataset(features, labels)
+	if err != nil {
+		t.Fatalf("Failed to create dataset: %v", err)
+	}
+
+	if dataset.Len() != 3 {
+		t.Errorf("Expected 3 samples, got %d", dataset.Len())
+	}
+
+	sample, err := dataset.Get(1)
+	if err != nil {
+		t.Fatalf("Failed to get sample: %v", err)
+	}
+
+	if !reflect.DeepEqual(sample.Features.Data, []float64{3, 4}) || !reflect.DeepEqual(sample.Features.Shape, []int{2}) {
+		t.Errorf("Unexpected features %v", sample.Features)
+	}
+	if !reflect.DeepEqual(sample.Label.Data, []float64{20}) || !reflect.DeepEqual(sample.Label.Shape, []int{1}) {
+		t.Errorf("Unexpected label %v", sample.Label)
+	}
+
+	if _, err := dataset.Get(3); err == nil {
+		t.Errorf("Expected an error for an out of range index")
+	}
+}
+
+func Test_DefaultCollate(t *testing.T) {
+
+	samples := []Sample{
+		{Features: tensor.NewTensor([]float64{1, 2}), Label: tensor.NewTensor(1.0)},
+		{Features: tensor.NewTensor([]float64{3, 4}), Label: tensor.NewTensor(0.0)},
+	}
+
+	inputs, targets, err := DefaultCollate(samples)
+	if err != nil {
+		t.Fatalf("Failed to collate: %v", err)
+	}
+
+	if !reflect.DeepEqual(inputs.Shape, []int{2, 2}) || !reflect.DeepEqual(inputs.Data, []float64{1, 2, 3, 4}) {
+		t.Errorf("Unexpected inputs %v", inputs)
+	}
+	if !reflect.DeepEqual(targets.Shape, []int{2, 1}) || !reflect.DeepEqual(targets.Data, []float64{1, 0}) {
+		t.Errorf("Unexpected targets %v", targets)
+	}
+}
+
+func Test_DefaultCollateUnlabelled(t *testing.T) {
+
+	samples := []Sample{{Features: tensor.NewTensor([]float64{1, 2})}}
+
+	inputs, targets, err := DefaultCollate(samples)
+	if err != nil {
+		t.Fatalf("Failed to collate: %v", err)
+	}
+	if inputs == nil || targets != nil {
+		t.Errorf("Expected inputs and no targets, got %v and %v", inputs, targets)
+	}
+}
+
+func Test_DefaultCollateMismatchedShapes(t *testing.T) {
+
+	samples := []Sample{
+		{Features: tensor.NewTensor([]float64{1, 2})},
+		{Features: tensor.NewTensor([]float64{1, 2, 3})},
+	}
+
+	if _, _, err := DefaultCollate(samples); err == nil {
+		t.Errorf("Expected an error collating samples of different shapes")
+	}
+}
@@ -0,0 +1,272 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"gotorch/tensor"
+	"io"
+	"math"
+	"os"
+)
+
+/*
+Readers for the files the PyTorch tutorials train on. MNIST and Fashion-MNIST ship as IDX files: two zero bytes, a type
+code, the number of dimensions, each dimension as a big endian uint32 and then the values in row major order. CIFAR-10 and
+CIFAR-100 ship as binary batches of fixed size records, each a label byte (two for CIFAR-100, coarse then fine) followed
+by a 32x32 image stored as its red, green and blue planes. Any of these files may be gzipped.
+*/
+
+// IDX type codes and the size of each value
+var idxTypes = map[byte]elementType{
+	0x08: {binary.BigEndian, 'u', 1},
+	0x09: {binary.BigEndian, 'i', 1},
+	0x0B: {binary.BigEndian, 'i', 2},
+	0x0C: {binary.BigEndian, 'i', 4},
+	0x0D: {binary.BigEndian, 'f', 4},
+	0x0E: {binary.BigEndian, 'f', 8},
+}
+
+// openMaybeGzip opens a file, decompressing it on the fly if it starts with the gzip magic number
+func openMaybeGzip(path string) (io.ReadCloser, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := maybeGunzip(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, file}, nil
+}
+
+func maybeGunzip(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	if magic, _ := buffered.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return gzip.NewReader(buffered)
+	}
+	return buffered, nil
+}
+
+// readIDX returns the type, shape and raw values of an IDX file
+func readIDX(r io.Reader) (elementType, []int, []byte, error) {
+
+	r, err := maybeGunzip(r)
+	if err != nil {
+		return elementType{}, nil, nil, err
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return elementType{}, nil, nil, fmt.Errorf("unable to read idx header: %w", err)
+	}
+	if header[0] != 0 || header[1] != 0 {
+		return elementType{}, nil, nil, fmt.Errorf("not an idx file, bad magic number %x", header)
+	}
+	dtype, ok := idxTypes[header[2]]
+	if !ok {
+		return elementType{}, nil, nil, fmt.Errorf("unknown idx type code 0x%02x", header[2])
+	}
+
+	dims := make([]uint32, header[3])
+	if err := binary.Read(r, binary.BigEndian, dims); err != nil {
+		return elementType{}, nil, nil, fmt.Errorf("unable to read idx dimensions: %w", err)
+	}
+
+	shape := make([]int, len(dims))
+	size := int64(dtype.size)
+	for i, d := range dims {
+		shape[i] = int(d)
+		if size *= int64(d); size > math.MaxInt32*int64(dtype.size) {
+			return elementType{}, nil, nil, fmt.Errorf("idx shape %v is too large", dims)
+		}
+	}
+
+	// read through a limit rather than allocating the size the header claims, which may be corrupt
+	payload, err := io.ReadAll(io.LimitReader(r, size))
+	if err != nil {
+		return elementType{}, nil, nil, fmt.Errorf("unable to read idx data: %w", err)
+	}
+	if int64(len(payload)) != size {
+		return elementType{}, nil, nil, fmt.Errorf("idx file is truncated, expected %d bytes of data for shape %v but got %d", size, shape, len(payload))
+	}
+
+	return dtype, shape, payload, nil
+}
+
+// ReadIDX reads an IDX file of any type and rank, gzipped or not
+func ReadIDX(r io.Reader) (*tensor.Tensor, error) {
+
+	dtype, shape, payload, err := readIDX(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]float64, len(payload)/dtype.size)
+	for i := range data {
+		data[i] = dtype.decode(payload[i*dtype.size:])
+	}
+
+	return tensor.NewTensor(data, shape...), nil
+}
+
+// LoadIDX reads an IDX file such as train-images-idx3-ubyte or train-images-idx3-ubyte.gz
+func LoadIDX(path string) (*tensor.Tensor, error) {
+
+	file, err := openMaybeGzip(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open idx file: %w", err)
+	}
+	defer file.Close()
+
+	return ReadIDX(file)
+}
+
+// ByteImageDataset holds 8 bit images and their class labels, images are only converted to tensors when they are requested
+// Images is every image one after the other, each Channels x Height x Width bytes in channel, row, column order
+// Get returns [Channels, Height, Width] features between 0 and 1, passed through Transform if it is set, and a [1] label
+type ByteImageDataset struct {
+	Images    []byte
+	Labels    []int
+	Channels  int
+	Height    int
+	Width     int
+	Transform Transform
+}
+
+func (d *ByteImageDataset) Len() int {
+	return len(d.Labels)
+}
+
+func (d *ByteImageDataset) Get(i int) (Sample, error) {
+
+	if i < 0 || i >= d.Len() {
+		return Sample{}, fmt.Errorf("index %d out of range for dataset of length %d", i, d.Len())
+	}
+
+	size := d.Channels * d.Height * d.Width
+	data := make([]float64, size)
+	for j, b := range d.Images[i*size : (i+1)*size] {
+		data[j] = float64(b) / 255
+	}
+
+	features := tensor.NewTensor(data, d.Channels, d.Height, d.Width)
+	if d.Transform != nil {
+		var err error
+		if features, err = d.Transform(features); err != nil {
+			return Sample{}, fmt.Errorf("unable to transform image %d: %w", i, err)
+		}
+	}
+
+	return Sample{Features: features, Label: tensor.NewTensor(d.Labels[i])}, nil
+}
+
+// LoadMNIST reads a pair of MNIST or Fashion-MNIST IDX files, for example train-images-idx3-ubyte.gz and
+// train-labels-idx1-ubyte.gz, into a dataset of [1, 28, 28] images
+func LoadMNIST(imagesPath, labelsPath string) (*ByteImageDataset, error) {
+
+	read := func(path string, rank int) ([]int, []byte, error) {
+		file, err := openMaybeGzip(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to open idx file: %w", err)
+		}
+		defer file.Close()
+
+		dtype, shape, payload, err := readIDX(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if dtype.kind != 'u' || dtype.size != 1 || len(shape) != rank {
+			return nil, nil, fmt.Errorf("%s: expected unsigned bytes with %d dimensions, got type %c%d with shape %v", path, rank, dtype.kind, dtype.size, shape)
+		}
+		return shape, payload, nil
+	}
+
+	imageShape, images, err := read(imagesPath, 3)
+	if err != nil {
+		return nil, err
+	}
+	labelShape, labelBytes, err := read(labelsPath, 1)
+	if err != nil {
+		return nil, err
+	}
+	if imageShape[0] != labelShape[0] {
+		return nil, fmt.Errorf("%d images but %d labels", imageShape[0], labelShape[0])
+	}
+
+	labels := make([]int, len(labelBytes))
+	for i, b := range labelBytes {
+		labels[i] = int(b)
+	}
+
+	return &ByteImageDataset{Images: images, Labels: labels, Channels: 1, Height: imageShape[1], Width: imageShape[2]}, nil
+}
+
+// the size of a CIFAR image in bytes
+const cifarImageSize = 3 * 32 * 32
+
+// readCIFAR appends the records in a CIFAR batch to a dataset, records have labelBytes label bytes and label is the one to keep
+func readCIFAR(path string, labelBytes, label int, d *ByteImageDataset) error {
+
+	file, err := openMaybeGzip(path)
+	if err != nil {
+		return fmt.Errorf("unable to open cifar batch: %w", err)
+	}
+	defer file.Close()
+
+	contents, err := io.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %w", path, err)
+	}
+
+	recordSize := labelBytes + cifarImageSize
+	if len(contents) == 0 || len(contents)%recordSize != 0 {
+		return fmt.Errorf("%s is %d bytes, which isn't a whole number of %d byte records", path, len(contents), recordSize)
+	}
+
+	for record := contents; len(record) > 0; record = record[recordSize:] {
+		d.Labels = append(d.Labels, int(record[label]))
+		d.Images = append(d.Images, record[labelBytes:recordSize]...)
+	}
+
+	return nil
+}
+
+// LoadCIFAR10 reads CIFAR-10 binary batches such as data_batch_1.bin to data_batch_5.bin into one dataset of [3, 32, 32] images
+func LoadCIFAR10(paths ...string) (*ByteImageDataset, error) {
+
+	d := &ByteImageDataset{Channels: 3, Height: 32, Width: 32}
+	for _, path := range paths {
+		if err := readCIFAR(path, 1, 0, d); err != nil {
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+// LoadCIFAR100 reads a CIFAR-100 binary batch, train.bin or test.bin, labelling images with their coarse superclass
+// (0 to 19) if coarse is set or their fine class (0 to 99) otherwise
+func LoadCIFAR100(path string, coarse bool) (*ByteImageDataset, error) {
+
+	label := 1
+	if coarse {
+		label = 0
+	}
+
+	d := &ByteImageDataset{Channels: 3, Height: 32, Width: 32}
+	if err := readCIFAR(path, 2, label, d); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
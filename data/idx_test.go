@@ -0,0 +1,164 @@
+package data
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// rawIDX builds an IDX file with the given type code, dimensions and big endian payload
+func rawIDX(typeCode byte, dims []uint32, payload []byte) []byte {
+	b := []byte{0, 0, typeCode, byte(len(dims))}
+	for _, d := range dims {
+		b = binary.BigEndian.AppendUint32(b, d)
+	}
+	return append(b, payload...)
+}
+
+func gzipped(t *testing.T, b []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	return buf.Bytes()
+}
+
+func writeFile(t *testing.T, path string, b []byte) string {
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_ReadIDX(t *testing.T) {
+
+	var payload []byte
+	for _, v := range []float64{1.5, -2, 0.25} {
+		payload = binary.BigEndian.AppendUint64(payload, math.Float64bits(v))
+	}
+
+	got, err := ReadIDX(bytes.NewReader(rawIDX(0x0E, []uint32{3}, payload)))
+	if err != nil {
+		t.Fatalf("Failed to read idx: %v", err)
+	}
+	if !reflect.DeepEqual(got.Data, []float64{1.5, -2, 0.25}) || !reflect.DeepEqual(got.Shape, []int{3}) {
+		t.Errorf("Expected [1.5 -2 0.25], got %v with shape %v", got.Data, got.Shape)
+	}
+
+	// signed shorts, gzipped
+	shorts := []byte{0xff, 0xfe, 0x01, 0x00, 0x00, 0x07, 0x80, 0x00}
+	got, err = ReadIDX(bytes.NewReader(gzipped(t, rawIDX(0x0B, []uint32{2, 2}, shorts))))
+	if err != nil {
+		t.Fatalf("Failed to read gzipped idx: %v", err)
+	}
+	if !reflect.DeepEqual(got.Data, []float64{-2, 256, 7, -32768}) || !reflect.DeepEqual(got.Shape, []int{2, 2}) {
+		t.Errorf("Expected [-2 256 7 -32768] with shape [2 2], got %v with shape %v", got.Data, got.Shape)
+	}
+}
+
+func Test_ReadIDXErrors(t *testing.T) {
+
+	tests := map[string][]byte{
+		"bad magic": {1, 0, 0x08, 1, 0, 0, 0, 1, 5},
+		"bad type":  rawIDX(0x0A, []uint32{1}, []byte{5}),
+		"truncated": rawIDX(0x08, []uint32{2, 3}, []byte{1, 2, 3}),
+		"huge":      rawIDX(0x0D, []uint32{1 << 31, 1 << 31}, nil),
+		"empty":     {},
+	}
+
+	for name, b := range tests {
+		if _, err := ReadIDX(bytes.NewReader(b)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func Test_LoadMNIST(t *testing.T) {
+
+	dir := t.TempDir()
+	images := writeFile(t, filepath.Join(dir, "images.gz"), gzipped(t, rawIDX(0x08, []uint32{2, 2, 3}, []byte{0, 51, 255, 0, 0, 0, 1, 2, 3, 4, 5, 6})))
+	labels := writeFile(t, filepath.Join(dir, "labels"), rawIDX(0x08, []uint32{2}, []byte{7, 3}))
+
+	dataset, err := LoadMNIST(images, labels)
+	if err != nil {
+		t.Fatalf("Failed to load mnist: %v", err)
+	}
+	if dataset.Len() != 2 {
+		t.Fatalf("Expected 2 images, got %d", dataset.Len())
+	}
+
+	sample, err := dataset.Get(0)
+	if err != nil {
+		t.Fatalf("Failed to get sample: %v", err)
+	}
+	if !reflect.DeepEqual(sample.Features.Shape, []int{1, 2, 3}) || !reflect.DeepEqual(sample.Features.Data, []float64{0, 0.2, 1, 0, 0, 0}) {
+		t.Errorf("Expected [0 0.2 1 0 0 0] with shape [1 2 3], got %v with shape %v", sample.Features.Data, sample.Features.Shape)
+	}
+	if sample.Label.Data[0] != 7 {
+		t.Errorf("Expected label 7, got %v", sample.Label.Data)
+	}
+
+	dataset.Transform = Normalize([]float64{0.5}, []float64{0.5})
+	sample, _ = dataset.Get(0)
+	if sample.Features.Data[0] != -1 || sample.Features.Data[2] != 1 {
+		t.Errorf("Expected the transform to be applied, got %v", sample.Features.Data)
+	}
+
+	fewer := writeFile(t, filepath.Join(dir, "fewer"), rawIDX(0x08, []uint32{1}, []byte{7}))
+	if _, err := LoadMNIST(images, fewer); err == nil {
+		t.Errorf("Expected an error for mismatched image and label counts")
+	}
+	if _, err := LoadMNIST(labels, labels); err == nil {
+		t.Errorf("Expected an error for images without 3 dimensions")
+	}
+}
+
+// cifarRecord returns a record whose label bytes are given and whose image bytes are all fill
+func cifarRecord(fill byte, labels ...byte) []byte {
+	return append(labels, bytes.Repeat([]byte{fill}, cifarImageSize)...)
+}
+
+func Test_LoadCIFAR(t *testing.T) {
+
+	dir := t.TempDir()
+	first := writeFile(t, filepath.Join(dir, "data_batch_1.bin"), append(cifarRecord(0, 3), cifarRecord(255, 9)...))
+	second := writeFile(t, filepath.Join(dir, "data_batch_2.bin.gz"), gzipped(t, cifarRecord(51, 1)))
+
+	dataset, err := LoadCIFAR10(first, second)
+	if err != nil {
+		t.Fatalf("Failed to load cifar-10: %v", err)
+	}
+	if dataset.Len() != 3 || !reflect.DeepEqual(dataset.Labels, []int{3, 9, 1}) {
+		t.Fatalf("Expected labels [3 9 1], got %v", dataset.Labels)
+	}
+
+	sample, err := dataset.Get(2)
+	if err != nil {
+		t.Fatalf("Failed to get sample: %v", err)
+	}
+	if !reflect.DeepEqual(sample.Features.Shape, []int{3, 32, 32}) || sample.Features.Data[3071] != 0.2 {
+		t.Errorf("Expected a [3 32 32] image filled with 0.2, got shape %v", sample.Features.Shape)
+	}
+
+	cifar100 := writeFile(t, filepath.Join(dir, "train.bin"), append(cifarRecord(0, 4, 40), cifarRecord(0, 19, 99)...))
+	coarse, err := LoadCIFAR100(cifar100, true)
+	if err != nil {
+		t.Fatalf("Failed to load cifar-100: %v", err)
+	}
+	fine, _ := LoadCIFAR100(cifar100, false)
+	if !reflect.DeepEqual(coarse.Labels, []int{4, 19}) || !reflect.DeepEqual(fine.Labels, []int{40, 99}) {
+		t.Errorf("Expected coarse labels [4 19] and fine labels [40 99], got %v and %v", coarse.Labels, fine.Labels)
+	}
+
+	// a CIFAR-100 file isn't a whole number of CIFAR-10 records
+	if _, err := LoadCIFAR10(cifar100); err == nil {
+		t.Errorf("Expected an error reading cifar-100 records as cifar-10")
+	}
+}
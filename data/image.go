@@ -0,0 +1,170 @@
+package data
+
+import (
+	"fmt"
+	"gotorch/tensor"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+/*
+ImageFolder reads a directory of images laid out one subdirectory per class, the same layout torchvision uses:
+
+	root/cat/001.png
+	root/cat/002.jpg
+	root/dog/001.png
+
+Classes are the subdirectory names in sorted order, so "cat" is label 0 and "dog" is label 1. Images are decoded as
+they are requested, converted to RGB and returned as [3, height, width] tensors with values between 0 and 1.
+*/
+
+// the file extensions ImageFolder picks up, anything else in a class directory is ignored
+var imageExtensions = []string{".png", ".jpg", ".jpeg", ".gif"}
+
+// ImageSample is an image file and the index of its class
+type ImageSample struct {
+	Path  string
+	Class int
+}
+
+// ImageFolder is a Dataset of images stored in one subdirectory per class
+// Transform, if set, is applied to every image after it is decoded
+type ImageFolder struct {
+	Root      string
+	Classes   []string
+	Samples   []ImageSample
+	Transform Transform
+}
+
+// NewImageFolder finds the classes under root and every image in them, searching class directories recursively
+// hidden files and directories are skipped, and a class without any images is an error
+func NewImageFolder(root string, transform Transform) (*ImageFolder, error) {
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read image folder: %w", err)
+	}
+
+	folder := &ImageFolder{Root: root, Transform: transform}
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			folder.Classes = append(folder.Classes, entry.Name())
+		}
+	}
+	if len(folder.Classes) == 0 {
+		return nil, fmt.Errorf("no class directories found in %s", root)
+	}
+	slices.Sort(folder.Classes)
+
+	for class, name := range folder.Classes {
+		found := 0
+		err := filepath.WalkDir(filepath.Join(root, name), func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.IsDir() && slices.Contains(imageExtensions, strings.ToLower(filepath.Ext(path))) {
+				folder.Samples = append(folder.Samples, ImageSample{Path: path, Class: class})
+				found++
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to read class %q: %w", name, err)
+		}
+		if found == 0 {
+			return nil, fmt.Errorf("class %q has no images, supported extensions are %v", name, imageExtensions)
+		}
+	}
+
+	return folder, nil
+}
+
+func (f *ImageFolder) Len() int {
+	return len(f.Samples)
+}
+
+// Get decodes image i, applies the transform and returns it with its class index as a [1] label
+func (f *ImageFolder) Get(i int) (Sample, error) {
+
+	if i < 0 || i >= f.Len() {
+		return Sample{}, fmt.Errorf("index %d out of range for dataset of length %d", i, f.Len())
+	}
+
+	s := f.Samples[i]
+	img, err := LoadImage(s.Path)
+	if err != nil {
+		return Sample{}, err
+	}
+
+	if f.Transform != nil {
+		if img, err = f.Transform(img); err != nil {
+			return Sample{}, fmt.Errorf("unable to transform %s: %w", s.Path, err)
+		}
+	}
+
+	return Sample{Features: img, Label: tensor.NewTensor(s.Class)}, nil
+}
+
+// LoadImage decodes a PNG, JPEG or GIF file into a [3, height, width] tensor, see ImageToTensor
+func LoadImage(path string) (*tensor.Tensor, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open image: %w", err)
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode %s: %w", path, err)
+	}
+
+	return ImageToTensor(img), nil
+}
+
+// ImageToTensor converts an image to RGB in channel, height, width order with values scaled to between 0 and 1
+// transparency is dropped rather than blended with a background, like converting to RGB in PIL
+func ImageToTensor(img image.Image) *tensor.Tensor {
+
+	bounds := img.Bounds()
+	height, width := bounds.Dy(), bounds.Dx()
+	plane := height * width
+
+	data := make([]float64, 3*plane)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := nrgba64(img.At(bounds.Min.X+x, bounds.Min.Y+y))
+			i := y*width + x
+			data[i] = float64(c.R) / 0xffff
+			data[plane+i] = float64(c.G) / 0xffff
+			data[2*plane+i] = float64(c.B) / 0xffff
+		}
+	}
+
+	return tensor.NewTensor(data, 3, height, width)
+}
+
+// nrgba64 returns a colour without alpha premultiplied, colours that are already stored that way are passed through so
+// fully transparent pixels keep their colour instead of turning black
+func nrgba64(c color.Color) color.NRGBA64 {
+	switch c := c.(type) {
+	case color.NRGBA:
+		return color.NRGBA64{R: uint16(c.R) * 0x101, G: uint16(c.G) * 0x101, B: uint16(c.B) * 0x101, A: uint16(c.A) * 0x101}
+	case color.NRGBA64:
+		return c
+	}
+	return color.NRGBA64Model.Convert(c).(color.NRGBA64)
+}
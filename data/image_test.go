@@ -0,0 +1,168 @@
+package data
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeImage saves img under dir/name, encoding it by the file extension
+func writeImage(t *testing.T, dir, name string, img image.Image) {
+
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	switch filepath.Ext(name) {
+	case ".png":
+		err = png.Encode(file, img)
+	case ".jpg":
+		err = jpeg.Encode(file, img, &jpeg.Options{Quality: 100})
+	case ".gif":
+		err = gif.Encode(file, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func solidImage(width, height int, c color.Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func Test_ImageToTensor(t *testing.T) {
+
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.NRGBA{R: 255, G: 0, B: 51, A: 255})
+	img.Set(1, 0, color.NRGBA{R: 0, G: 102, B: 255, A: 0}) // transparent pixels keep their colour
+
+	got := ImageToTensor(img)
+	expected := []float64{1, 0, 0, 0.4, 0.2, 1}
+
+	if !reflect.DeepEqual(got.Shape, []int{3, 1, 2}) {
+		t.Errorf("Expected shape [3 1 2], got %v", got.Shape)
+	}
+	for i := range expected {
+		if math.Abs(got.Data[i]-expected[i]) > 1e-6 {
+			t.Errorf("Expected %v, got %v", expected, got.Data)
+			break
+		}
+	}
+}
+
+func Test_ImageFolder(t *testing.T) {
+
+	root := t.TempDir()
+	writeImage(t, filepath.Join(root, "dog"), "a.png", solidImage(4, 3, color.NRGBA{R: 255, A: 255}))
+	writeImage(t, filepath.Join(root, "dog", "nested"), "b.gif", solidImage(4, 3, color.NRGBA{G: 255, A: 255}))
+	writeImage(t, filepath.Join(root, "cat"), "c.jpg", solidImage(4, 3, color.NRGBA{B: 255, A: 255}))
+	os.WriteFile(filepath.Join(root, "cat", "notes.txt"), []byte("not an image"), 0644)
+	writeImage(t, filepath.Join(root, ".hidden"), "d.png", solidImage(4, 3, color.White))
+
+	folder, err := NewImageFolder(root, nil)
+	if err != nil {
+		t.Fatalf("Failed to create image folder: %v", err)
+	}
+
+	if !reflect.DeepEqual(folder.Classes, []string{"cat", "dog"}) {
+		t.Errorf("Expected classes [cat dog], got %v", folder.Classes)
+	}
+	if folder.Len() != 3 {
+		t.Fatalf("Expected 3 images, got %d", folder.Len())
+	}
+
+	sample, err := folder.Get(0)
+	if err != nil {
+		t.Fatalf("Failed to get sample: %v", err)
+	}
+	if sample.Label.Data[0] != 0 || !reflect.DeepEqual(sample.Features.Shape, []int{3, 3, 4}) {
+		t.Errorf("Expected a [3 3 4] cat image, got label %v with shape %v", sample.Label.Data, sample.Features.Shape)
+	}
+	if sample.Features.Data[0] > 0.05 || sample.Features.Data[2*12] < 0.95 {
+		t.Errorf("Expected the jpeg to decode as blue, got %v", sample.Features.Data)
+	}
+
+	sample, _ = folder.Get(2)
+	if sample.Label.Data[0] != 1 || sample.Features.Data[12] != 1 {
+		t.Errorf("Expected a green dog image from the nested gif, got label %v and %v", sample.Label.Data, sample.Features.Data)
+	}
+
+	if _, err := folder.Get(3); err == nil {
+		t.Errorf("Expected an error for an out of range index")
+	}
+}
+
+func Test_ImageFolderDataLoader(t *testing.T) {
+
+	root := t.TempDir()
+	for i, class := range []string{"a", "b"} {
+		for j := 0; j < 3; j++ {
+			writeImage(t, filepath.Join(root, class), string(rune('0'+j))+".png", solidImage(5+i, 6, color.Gray{Y: uint8(50 * j)}))
+		}
+	}
+
+	// the images have different widths so they need resizing to batch
+	folder, err := NewImageFolder(root, Compose(Resize(4, 4), Grayscale(1), Normalize([]float64{0.5}, []float64{0.5})))
+	if err != nil {
+		t.Fatalf("Failed to create image folder: %v", err)
+	}
+
+	loader := &DataLoader{Dataset: folder, BatchSize: 4, NumWorkers: 2}
+	var shapes [][]int
+	for inputs, targets := range loader.Batches(context.Background()) {
+		shapes = append(shapes, inputs.Shape, targets.Shape)
+	}
+	if err := loader.Err(); err != nil {
+		t.Fatalf("Failed to load batches: %v", err)
+	}
+
+	expected := [][]int{{4, 1, 4, 4}, {4, 1}, {2, 1, 4, 4}, {2, 1}}
+	if !reflect.DeepEqual(shapes, expected) {
+		t.Errorf("Expected batch shapes %v, got %v", expected, shapes)
+	}
+}
+
+func Test_ImageFolderErrors(t *testing.T) {
+
+	if _, err := NewImageFolder(filepath.Join(t.TempDir(), "missing"), nil); err == nil {
+		t.Errorf("Expected an error for a missing directory")
+	}
+
+	root := t.TempDir()
+	writeImage(t, filepath.Join(root, "a"), "x.png", solidImage(1, 1, color.Black))
+	os.Mkdir(filepath.Join(root, "empty"), 0755)
+	if _, err := NewImageFolder(root, nil); err == nil {
+		t.Errorf("Expected an error for a class without images")
+	}
+
+	root = t.TempDir()
+	os.MkdirAll(filepath.Join(root, "a"), 0755)
+	os.WriteFile(filepath.Join(root, "a", "broken.png"), []byte("not a png"), 0644)
+	folder, err := NewImageFolder(root, nil)
+	if err != nil {
+		t.Fatalf("Failed to create image folder: %v", err)
+	}
+	if _, err := folder.Get(0); err == nil {
+		t.Errorf("Expected an error decoding a corrupt image")
+	}
+}
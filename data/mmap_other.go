@@ -0,0 +1,13 @@
+//go:build !unix
+
+package data
+
+import (
+	"errors"
+	"os"
+)
+
+// mapFile isn't supported here, so callers fall back to reading from the file
+func mapFile(file *os.File, size int64) ([]byte, func() error, error) {
+	return nil, nil, errors.New("memory mapping is not supported on this platform")
+}
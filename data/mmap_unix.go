@@ -0,0 +1,19 @@
+//go:build unix
+
+package data
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile memory maps a whole file read only, the returned function unmaps it
+func mapFile(file *os.File, size int64) ([]byte, func() error, error) {
+
+	mapped, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return mapped, func() error { return syscall.Munmap(mapped) }, nil
+}
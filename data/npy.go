@@ -0,0 +1,474 @@
+package data
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"gotorch/internal/half"
+	"gotorch/tensor"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+/*
+NPY is the format numpy.save writes a single array in, and NPZ is a zip archive of NPY files written by numpy.savez.
+An NPY file starts with the magic string "\x93NUMPY", a version, and a header that is a python dict literal describing
+the array, for example {'descr': '<f4', 'fortran_order': False, 'shape': (3, 4), }. The raw array data follows the header.
+See https://numpy.org/doc/stable/reference/generated/numpy.lib.format.html
+*/
+
+const npyMagic = "\x93NUMPY"
+
+// the header is padded so the array data starts on a multiple of this many bytes
+const npyAlignment = 64
+
+var (
+	npyDescrPattern   = regexp.MustCompile(`['"]descr['"]\s*:\s*['"]([^'"]*)['"]`)
+	npyFortranPattern = regexp.MustCompile(`['"]fortran_order['"]\s*:\s*(True|False)`)
+	npyShapePattern   = regexp.MustCompile(`['"]shape['"]\s*:\s*\(([^)]*)\)`)
+)
+
+// NPYOptions configures how an array is written
+type NPYOptions struct {
+	// DType is the numpy type string, a byte order ('<' little, '>' big or '|' for single bytes) followed by the
+	// kind and the size in bytes, for example "<f4" or ">i8". It defaults to "<f8"
+	// floats are f4 and f8, signed integers i1 to i8, unsigned integers u1 to u8 and booleans b1
+	DType string
+	// FortranOrder writes the data in column major order
+	FortranOrder bool
+}
+
+// elementType describes how a single value is stored, the kinds follow numpy's ('f', 'i', 'u' and 'b') plus 'B' for
+// bfloat16 which numpy doesn't have. 2 byte floats and bfloat16 are only produced by the safetensors reader
+type elementType struct {
+	order binary.ByteOrder
+	kind  byte
+	size  int
+}
+
+func parseNPYType(descr string) (elementType, error) {
+
+	if len(descr) < 3 {
+		return elementType{}, fmt.Errorf("unsupported dtype %q", descr)
+	}
+
+	size, err := strconv.Atoi(descr[2:])
+	if err != nil {
+		return elementType{}, fmt.Errorf("unsupported dtype %q", descr)
+	}
+
+	dtype := elementType{kind: descr[1], size: size}
+
+	switch descr[0] {
+	case '<':
+		dtype.order = binary.LittleEndian
+	case '>':
+		dtype.order = binary.BigEndian
+	case '=':
+		dtype.order = binary.NativeEndian
+	case '|':
+		if size != 1 {
+			return elementType{}, fmt.Errorf("dtype %q needs a byte order", descr)
+		}
+		dtype.order = binary.LittleEndian
+	default:
+		return elementType{}, fmt.Errorf("unsupported byte order in dtype %q", descr)
+	}
+
+	switch {
+	case dtype.kind == 'f' && (size == 4 || size == 8):
+	case (dtype.kind == 'i' || dtype.kind == 'u') && (size == 1 || size == 2 || size == 4 || size == 8):
+	case dtype.kind == 'b' && size == 1:
+	default:
+		return elementType{}, fmt.Errorf("unsupported dtype %q", descr)
+	}
+
+	return dtype, nil
+}
+
+// decode reads a single value from the start of b
+func (d elementType) decode(b []byte) float64 {
+
+	var bits uint64
+	switch d.size {
+	case 1:
+		bits = uint64(b[0])
+	case 2:
+		bits = uint64(d.order.Uint16(b))
+	case 4:
+		bits = uint64(d.order.Uint32(b))
+	case 8:
+		bits = d.order.Uint64(b)
+	}
+
+	switch d.kind {
+	case 'f':
+		switch d.size {
+		case 2:
+			return half.ToFloat64(uint16(bits))
+		case 4:
+			return float64(math.Float32frombits(uint32(bits)))
+		}
+		return math.Float64frombits(bits)
+	case 'B':
+		return half.BFloat16ToFloat64(uint16(bits))
+	case 'i':
+		// sign extend from the value's width
+		shift := 64 - 8*d.size
+		return float64(int64(bits<<shift) >> shift)
+	case 'b':
+		if bits != 0 {
+			return 1
+		}
+		return 0
+	default:
+		return float64(bits)
+	}
+}
+
+// encode writes a single value to the start of b, integer and boolean types only accept values they can hold exactly
+func (d elementType) encode(b []byte, v float64) error {
+
+	var bits uint64
+	switch d.kind {
+	case 'f':
+		switch d.size {
+		case 2:
+			bits = uint64(half.FromFloat64(v))
+		case 4:
+			bits = uint64(math.Float32bits(float32(v)))
+		default:
+			bits = math.Float64bits(v)
+		}
+	case 'B':
+		bits = uint64(half.BFloat16FromFloat32(float32(v)))
+	case 'b':
+		if v != 0 && v != 1 {
+			return fmt.Errorf("value %v is not a boolean", v)
+		}
+		bits = uint64(v)
+	case 'i':
+		limit := math.Ldexp(1, 8*d.size-1)
+		if v != math.Trunc(v) || v < -limit || v >= limit {
+			return fmt.Errorf("value %v does not fit in a %d byte integer", v, d.size)
+		}
+		bits = uint64(int64(v))
+	case 'u':
+		if v != math.Trunc(v) || v < 0 || v >= math.Ldexp(1, 8*d.size) {
+			return fmt.Errorf("value %v does not fit in a %d byte unsigned integer", v, d.size)
+		}
+		bits = uint64(v)
+	}
+
+	switch d.size {
+	case 1:
+		b[0] = byte(bits)
+	case 2:
+		d.order.PutUint16(b, uint16(bits))
+	case 4:
+		d.order.PutUint32(b, uint32(bits))
+	case 8:
+		d.order.PutUint64(b, bits)
+	}
+
+	return nil
+}
+
+// fortranOffset returns where the element at a C order position lives in column major data
+func fortranOffset(i int, shape []int) int {
+	offset, stride := 0, 1
+	for d, n := range unravelIndex(i, shape) {
+		offset += n * stride
+		stride *= shape[d]
+	}
+	return offset
+}
+
+// ReadNPY reads a single array in NPY format, every dtype is converted to float64
+func ReadNPY(r io.Reader) (*tensor.Tensor, error) {
+
+	preamble := make([]byte, len(npyMagic)+2)
+	if _, err := io.ReadFull(r, preamble); err != nil {
+		return nil, fmt.Errorf("unable to read npy header: %w", err)
+	}
+	if string(preamble[:len(npyMagic)]) != npyMagic {
+		return nil, fmt.Errorf("not an npy file")
+	}
+
+	// version 1 stores the header length in 2 bytes, versions 2 and 3 in 4 bytes
+	var headerLen int
+	switch major := preamble[len(npyMagic)]; major {
+	case 1:
+		var n uint16
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, fmt.Errorf("unable to read npy header: %w", err)
+		}
+		headerLen = int(n)
+	case 2, 3:
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, fmt.Errorf("unable to read npy header: %w", err)
+		}
+		headerLen = int(n)
+	default:
+		return nil, fmt.Errorf("unsupported npy version %d", major)
+	}
+
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("unable to read npy header: %w", err)
+	}
+
+	descr := npyDescrPattern.FindSubmatch(header)
+	fortran := npyFortranPattern.FindSubmatch(header)
+	shapeMatch := npyShapePattern.FindSubmatch(header)
+	if descr == nil || fortran == nil || shapeMatch == nil {
+		return nil, fmt.Errorf("invalid npy header %q", header)
+	}
+
+	dtype, err := parseNPYType(string(descr[1]))
+	if err != nil {
+		return nil, err
+	}
+
+	shape := []int{}
+	size := 1
+	for _, field := range strings.Split(string(shapeMatch[1]), ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid shape in npy header %q", header)
+		}
+		shape = append(shape, n)
+		// check before multiplying so a corrupt shape can't wrap around to a small size
+		if n != 0 && size > math.MaxInt32/n {
+			return nil, fmt.Errorf("npy shape (%s) is too large", shapeMatch[1])
+		}
+		size *= n
+	}
+
+	// read through a limit rather than allocating the size the header claims, which may be corrupt
+	expected := int64(size) * int64(dtype.size)
+	raw, err := io.ReadAll(io.LimitReader(r, expected))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read npy data for shape %v: %w", shape, err)
+	}
+	if int64(len(raw)) != expected {
+		return nil, fmt.Errorf("npy file is truncated, expected %d bytes of data for shape %v but got %d", expected, shape, len(raw))
+	}
+
+	fortranOrder := string(fortran[1]) == "True"
+	data := make([]float64, size)
+	for i := range data {
+		offset := i
+		if fortranOrder {
+			offset = fortranOffset(i, shape)
+		}
+		data[i] = dtype.decode(raw[offset*dtype.size:])
+	}
+
+	return &tensor.Tensor{Data: data, Shape: shape}, nil
+}
+
+// LoadNPY reads an array saved with numpy.save
+func LoadNPY(filePath string) (*tensor.Tensor, error) {
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadNPY(bufio.NewReader(file))
+}
+
+// WriteNPY writes a tensor in NPY format, using version 1 of the format unless the header is too long for it
+func WriteNPY(w io.Writer, t *tensor.Tensor, opts NPYOptions) error {
+
+	encoded, err := encodeNPY(t, opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(encoded)
+	return err
+}
+
+// SaveNPY saves a tensor as float64 so it can be read with numpy.load
+func SaveNPY(t *tensor.Tensor, filePath string) error {
+	return SaveNPYWithOptions(t, filePath, NPYOptions{})
+}
+
+// SaveNPYWithOptions saves a tensor with the given dtype and order, the tensor is encoded before the file is created
+func SaveNPYWithOptions(t *tensor.Tensor, filePath string, opts NPYOptions) error {
+
+	encoded, err := encodeNPY(t, opts)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filePath, encoded, 0o644)
+}
+
+func encodeNPY(t *tensor.Tensor, opts NPYOptions) ([]byte, error) {
+
+	descr := opts.DType
+	if descr == "" {
+		descr = "<f8"
+	}
+	dtype, err := parseNPYType(descr)
+	if err != nil {
+		return nil, err
+	}
+
+	size := 1
+	dims := make([]string, len(t.Shape))
+	for i, n := range t.Shape {
+		if n < 0 {
+			return nil, fmt.Errorf("invalid shape %v", t.Shape)
+		}
+		size *= n
+		dims[i] = strconv.Itoa(n)
+	}
+	if size != len(t.Data) {
+		return nil, fmt.Errorf("shape %v needs %d values but the tensor has %d", t.Shape, size, len(t.Data))
+	}
+
+	// python needs a trailing comma for a one element tuple
+	shape := strings.Join(dims, ", ")
+	if len(dims) == 1 {
+		shape += ","
+	}
+
+	fortran := "False"
+	if opts.FortranOrder {
+		fortran = "True"
+	}
+
+	header := fmt.Sprintf("{'descr': '%s', 'fortran_order': %s, 'shape': (%s), }", descr, fortran, shape)
+
+	// pad with spaces and end with a newline so the data is aligned
+	version, lenSize := byte(1), 2
+	if len(header)+npyAlignment > math.MaxUint16 {
+		version, lenSize = 2, 4
+	}
+	prefix := len(npyMagic) + 2 + lenSize
+	padding := (npyAlignment - (prefix+len(header)+1)%npyAlignment) % npyAlignment
+	header += strings.Repeat(" ", padding) + "\n"
+
+	var buf bytes.Buffer
+	buf.Grow(prefix + len(header) + size*dtype.size)
+	buf.WriteString(npyMagic)
+	buf.Write([]byte{version, 0})
+	if version == 1 {
+		binary.Write(&buf, binary.LittleEndian, uint16(len(header)))
+	} else {
+		binary.Write(&buf, binary.LittleEndian, uint32(len(header)))
+	}
+	buf.WriteString(header)
+
+	raw := make([]byte, size*dtype.size)
+	for i, v := range t.Data {
+		offset := i
+		if opts.FortranOrder {
+			offset = fortranOffset(i, t.Shape)
+		}
+		if err := dtype.encode(raw[offset*dtype.size:], v); err != nil {
+			return nil, fmt.Errorf("unable to encode element %d as %s: %w", i, descr, err)
+		}
+	}
+	buf.Write(raw)
+
+	return buf.Bytes(), nil
+}
+
+// LoadNPZ reads every array in an archive saved with numpy.savez or numpy.savez_compressed, keyed by array name
+func LoadNPZ(filePath string) (map[string]*tensor.Tensor, error) {
+
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	arrays := map[string]*tensor.Tensor{}
+	for _, f := range archive.File {
+		name := strings.TrimSuffix(f.Name, ".npy")
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("unable to open array %q: %w", name, err)
+		}
+		t, err := ReadNPY(bufio.NewReader(rc))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read array %q: %w", name, err)
+		}
+
+		arrays[name] = t
+	}
+
+	return arrays, nil
+}
+
+// SaveNPZ saves several named float64 arrays in an uncompressed archive like numpy.savez
+func SaveNPZ(arrays map[string]*tensor.Tensor, filePath string) error {
+	return saveNPZ(arrays, filePath, zip.Store)
+}
+
+// SaveNPZCompressed saves several named float64 arrays in a deflate compressed archive like numpy.savez_compressed
+func SaveNPZCompressed(arrays map[string]*tensor.Tensor, filePath string) error {
+	return saveNPZ(arrays, filePath, zip.Deflate)
+}
+
+func saveNPZ(arrays map[string]*tensor.Tensor, filePath string, method uint16) error {
+
+	// encode everything up front so a bad array doesn't leave half an archive behind, and sort the names so the
+	// archive is the same every time
+	names := make([]string, 0, len(arrays))
+	for name := range arrays {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	encoded := make([][]byte, len(names))
+	for i, name := range names {
+		var err error
+		if encoded[i], err = encodeNPY(arrays[name], NPYOptions{}); err != nil {
+			return fmt.Errorf("unable to encode array %q: %w", name, err)
+		}
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	for i, name := range names {
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: name + ".npy", Method: method})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(encoded[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
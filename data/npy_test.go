@@ -0,0 +1,166 @@
+package data
+
+import (
+	"bytes"
+	"encoding/binary"
+	"gotorch/tensor"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// builds an npy file by hand so the reader is checked against the format rather than against WriteNPY
+func rawNPY(version byte, header string, data any) []byte {
+
+	var buf bytes.Buffer
+	buf.WriteString(npyMagic)
+	buf.Write([]byte{version, 0})
+	if version == 1 {
+		binary.Write(&buf, binary.LittleEndian, uint16(len(header)))
+	} else {
+		binary.Write(&buf, binary.LittleEndian, uint32(len(header)))
+	}
+	buf.WriteString(header)
+
+	order := binary.ByteOrder(binary.LittleEndian)
+	if strings.Contains(header, "'>") {
+		order = binary.BigEndian
+	}
+	binary.Write(&buf, order, data)
+
+	return buf.Bytes()
+}
+
+func Test_ReadNPYDTypes(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		raw   []byte
+		shape []int
+		data  []float64
+	}{
+		{"float32", rawNPY(1, "{'descr': '<f4', 'fortran_order': False, 'shape': (2,), }\n", []float32{1.5, -2}), []int{2}, []float64{1.5, -2}},
+		{"big endian float64", rawNPY(1, "{'descr': '>f8', 'fortran_order': False, 'shape': (2,), }\n", []float64{0.1, 3}), []int{2}, []float64{0.1, 3}},
+		{"int16", rawNPY(1, "{'descr': '<i2', 'fortran_order': False, 'shape': (3,), }\n", []int16{-300, 0, 7}), []int{3}, []float64{-300, 0, 7}},
+		{"big endian int64", rawNPY(2, "{'descr': '>i8', 'fortran_order': False, 'shape': (1,), }\n", []int64{-5}), []int{1}, []float64{-5}},
+		{"uint8", rawNPY(1, "{'descr': '|u1', 'fortran_order': False, 'shape': (2,), }\n", []uint8{0, 255}), []int{2}, []float64{0, 255}},
+		{"bool", rawNPY(1, "{'descr': '|b1', 'fortran_order': False, 'shape': (2,), }\n", []uint8{1, 0}), []int{2}, []float64{1, 0}},
+		{"scalar", rawNPY(1, "{'descr': '<f8', 'fortran_order': False, 'shape': (), }\n", []float64{4}), []int{}, []float64{4}},
+		{"fortran", rawNPY(3, "{'descr': '<i4', 'fortran_order': True, 'shape': (2, 3), }\n", []int32{0, 3, 1, 4, 2, 5}), []int{2, 3}, []float64{0, 1, 2, 3, 4, 5}},
+	}
+
+	for _, test := range tests {
+		result, err := ReadNPY(bytes.NewReader(test.raw))
+		if err != nil {
+			t.Errorf("%s: failed to read: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(result.Shape, test.shape) || !reflect.DeepEqual(result.Data, test.data) {
+			t.Errorf("%s: expected %v with shape %v, got %v with shape %v", test.name, test.data, test.shape, result.Data, result.Shape)
+		}
+	}
+}
+
+func Test_ReadNPYErrors(t *testing.T) {
+
+	tests := map[string][]byte{
+		"bad magic":      []byte("\x93NUMPX\x01\x00"),
+		"structured":     rawNPY(1, "{'descr': [('a', '<f8')], 'fortran_order': False, 'shape': (1,), }\n", []float64{1}),
+		"float16":        rawNPY(1, "{'descr': '<f2', 'fortran_order': False, 'shape': (1,), }\n", []uint16{0}),
+		"truncated data": rawNPY(1, "{'descr': '<f8', 'fortran_order': False, 'shape': (3,), }\n", []float64{1}),
+		"truncated 2d":   rawNPY(1, "{'descr': '<i4', 'fortran_order': True, 'shape': (2, 3), }\n", []int32{1, 2, 3}),
+		"wrapping shape": rawNPY(1, "{'descr': '<f8', 'fortran_order': False, 'shape': (4611686018427387904, 4), }\n", []float64{1}),
+		"square shape":   rawNPY(1, "{'descr': '<f8', 'fortran_order': False, 'shape': (3037000500, 3037000500), }\n", []float64{1}),
+		"over the cap":   rawNPY(1, "{'descr': '<u1', 'fortran_order': False, 'shape': (65536, 65536), }\n", []uint8{1}),
+	}
+
+	for name, raw := range tests {
+		if _, err := ReadNPY(bytes.NewReader(raw)); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
+
+func Test_WriteNPYHeader(t *testing.T) {
+
+	var buf bytes.Buffer
+	if err := WriteNPY(&buf, tensor.NewTensor([]float64{1, 2, 3}), NPYOptions{}); err != nil {
+		t.Fatalf("Failed to write npy: %v", err)
+	}
+	raw := buf.Bytes()
+
+	// numpy.save writes a version 1 header padded so the data starts at byte 128
+	if raw[6] != 1 || binary.LittleEndian.Uint16(raw[8:10]) != 118 {
+		t.Fatalf("Expected a version 1 header of 118 bytes, got version %d and %d bytes", raw[6], binary.LittleEndian.Uint16(raw[8:10]))
+	}
+	header := string(raw[10:128])
+	if !strings.HasPrefix(header, "{'descr': '<f8', 'fortran_order': False, 'shape': (3,), }") || !strings.HasSuffix(header, " \n") {
+		t.Errorf("Unexpected header %q", header)
+	}
+	if len(raw) != 128+3*8 || math.Float64frombits(binary.LittleEndian.Uint64(raw[128:])) != 1 {
+		t.Errorf("Unexpected data after the header")
+	}
+}
+
+func Test_NPYRoundTrip(t *testing.T) {
+
+	original := tensor.NewTensor([]float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}, 2, 3, 2)
+
+	for _, opts := range []NPYOptions{{}, {DType: ">f4"}, {DType: "<i8", FortranOrder: true}, {DType: "|u1"}} {
+		path := filepath.Join(t.TempDir(), "array.npy")
+		if err := SaveNPYWithOptions(original, path, opts); err != nil {
+			t.Fatalf("Failed to save with %+v: %v", opts, err)
+		}
+
+		loaded, err := LoadNPY(path)
+		if err != nil {
+			t.Fatalf("Failed to load with %+v: %v", opts, err)
+		}
+		if !reflect.DeepEqual(loaded.Shape, original.Shape) || !reflect.DeepEqual(loaded.Data, original.Data) {
+			t.Errorf("Round trip with %+v gave %v", opts, loaded)
+		}
+	}
+}
+
+func Test_SaveNPYErrors(t *testing.T) {
+
+	var buf bytes.Buffer
+
+	if err := WriteNPY(&buf, tensor.NewTensor([]float64{1.5}), NPYOptions{DType: "<i4"}); err == nil {
+		t.Errorf("Expected an error writing a fraction as an integer")
+	}
+	if err := WriteNPY(&buf, tensor.NewTensor([]float64{256}), NPYOptions{DType: "|u1"}); err == nil {
+		t.Errorf("Expected an error writing 256 as a byte")
+	}
+	if err := WriteNPY(&buf, tensor.NewTensor([]float64{1}), NPYOptions{DType: "<c16"}); err == nil {
+		t.Errorf("Expected an error for a complex dtype")
+	}
+	if err := WriteNPY(&buf, &tensor.Tensor{Data: []float64{1, 2}, Shape: []int{3}}, NPYOptions{}); err == nil {
+		t.Errorf("Expected an error for a shape that doesn't match the data")
+	}
+}
+
+func Test_NPZRoundTrip(t *testing.T) {
+
+	arrays := map[string]*tensor.Tensor{
+		"features": tensor.NewTensor([][]float64{{1, 2}, {3, 4}}),
+		"labels":   tensor.NewTensor([]float64{0, 1}),
+	}
+
+	for _, save := range []func(map[string]*tensor.Tensor, string) error{SaveNPZ, SaveNPZCompressed} {
+		path := filepath.Join(t.TempDir(), "arrays.npz")
+		if err := save(arrays, path); err != nil {
+			t.Fatalf("Failed to save npz: %v", err)
+		}
+
+		loaded, err := LoadNPZ(path)
+		if err != nil {
+			t.Fatalf("Failed to load npz: %v", err)
+		}
+		if !reflect.DeepEqual(loaded, arrays) {
+			t.Errorf("Expected %v, got %v", arrays, loaded)
+		}
+	}
+}
@@ -0,0 +1,655 @@
+package data
+
+import (
+	"fmt"
+	"gotorch/tensor"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+/*
+Preprocessors learn statistics from training data with Fit and apply them to any later data with Transform, so test
+and inference inputs are scaled exactly like the training set was. They work column by column on [samples, features]
+tensors, a single [features] sample is treated as one row, so a fitted preprocessor's Transform method can be used as the
+Transform of a dataset. Their state dicts hold everything Transform needs and can be saved in a checkpoint with
+model.SaveCheckpointWith and restored before inference.
+*/
+
+// Preprocessor is fitted on training data and then transforms data the same way, InverseTransform undoes Transform
+type Preprocessor interface {
+	Fit(x *tensor.Tensor) error
+	Transform(x *tensor.Tensor) (*tensor.Tensor, error)
+	InverseTransform(x *tensor.Tensor) (*tensor.Tensor, error)
+	StateDict() map[string]*tensor.Tensor
+	LoadStateDict(state map[string]*tensor.Tensor) error
+}
+
+// FitTransform fits a preprocessor on x and returns x transformed
+func FitTransform(p Preprocessor, x *tensor.Tensor) (*tensor.Tensor, error) {
+	if err := p.Fit(x); err != nil {
+		return nil, err
+	}
+	return p.Transform(x)
+}
+
+// matrixShape returns the number of rows and columns of a [samples, features] tensor or a single [features] sample
+func matrixShape(x *tensor.Tensor) (rows, cols int, err error) {
+	switch len(x.Shape) {
+	case 1:
+		return 1, x.Shape[0], nil
+	case 2:
+		return x.Shape[0], x.Shape[1], nil
+	}
+	return 0, 0, fmt.Errorf("expected a tensor of shape [samples, features] or [features], got %v", x.Shape)
+}
+
+// withColumns returns a tensor holding data with cols columns and the same number of dimensions as x
+func withColumns(x *tensor.Tensor, data []float64, cols int) *tensor.Tensor {
+	if len(x.Shape) == 1 {
+		return tensor.NewTensor(data, cols)
+	}
+	return tensor.NewTensor(data, len(data)/max(cols, 1), cols)
+}
+
+// column returns column j of a matrix with cols columns
+func column(data []float64, cols, j int) []float64 {
+	values := make([]float64, 0, len(data)/cols)
+	for i := j; i < len(data); i += cols {
+		values = append(values, data[i])
+	}
+	return values
+}
+
+// stateTensor returns a required entry of a state dict
+func stateTensor(state map[string]*tensor.Tensor, name string) (*tensor.Tensor, error) {
+	t, ok := state[name]
+	if !ok {
+		return nil, fmt.Errorf("state dict is missing %q", name)
+	}
+	return t, nil
+}
+
+// affine is the fitted state shared by the scalers, which all transform x to (x - offset) / scale
+type affine struct {
+	offset []float64
+	scale  []float64
+}
+
+func (a *affine) apply(x *tensor.Tensor, inverse bool) (*tensor.Tensor, error) {
+
+	if a.scale == nil {
+		return nil, fmt.Errorf("the scaler hasn't been fitted")
+	}
+	_, cols, err := matrixShape(x)
+	if err != nil {
+		return nil, err
+	}
+	if cols != len(a.scale) {
+		return nil, fmt.Errorf("the scaler was fitted on %d features but got %d", len(a.scale), cols)
+	}
+
+	data := make([]float64, len(x.Data))
+	for i, v := range x.Data {
+		j := i % cols
+		if inverse {
+			data[i] = v*a.scale[j] + a.offset[j]
+		} else {
+			data[i] = (v - a.offset[j]) / a.scale[j]
+		}
+	}
+
+	return withColumns(x, data, cols), nil
+}
+
+// fit computes an offset and scale for every column, a scale of zero is replaced with one so constant features don't divide by zero
+func (a *affine) fit(x *tensor.Tensor, stats func(values []float64) (offset, scale float64)) error {
+
+	rows, cols, err := matrixShape(x)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("can't fit on an empty tensor")
+	}
+
+	a.offset, a.scale = make([]float64, cols), make([]float64, cols)
+	for j := 0; j < cols; j++ {
+		a.offset[j], a.scale[j] = stats(column(x.Data, cols, j))
+		if a.scale[j] == 0 {
+			a.scale[j] = 1
+		}
+	}
+
+	return nil
+}
+
+func (a *affine) stateDict(offsetName, scaleName string) map[string]*tensor.Tensor {
+	return map[string]*tensor.Tensor{
+		offsetName: tensor.NewTensor(slices.Clone(a.offset)),
+		scaleName:  tensor.NewTensor(slices.Clone(a.scale)),
+	}
+}
+
+func (a *affine) loadStateDict(state map[string]*tensor.Tensor, offsetName, scaleName string) error {
+
+	offset, err := stateTensor(state, offsetName)
+	if err != nil {
+		return err
+	}
+	scale, err := stateTensor(state, scaleName)
+	if err != nil {
+		return err
+	}
+	if len(offset.Data) != len(scale.Data) {
+		return fmt.Errorf("%s has %d values but %s has %d", offsetName, len(offset.Data), scaleName, len(scale.Data))
+	}
+
+	a.offset, a.scale = slices.Clone(offset.Data), slices.Clone(scale.Data)
+	return nil
+}
+
+// StandardScaler scales each feature to zero mean and unit variance, using the population standard deviation
+type StandardScaler struct {
+	affine
+}
+
+func (s *StandardScaler) Fit(x *tensor.Tensor) error {
+	return s.fit(x, func(values []float64) (float64, float64) {
+		var mean, variance float64
+		for _, v := range values {
+			mean += v
+		}
+		mean /= float64(len(values))
+		for _, v := range values {
+			variance += (v - mean) * (v - mean)
+		}
+		return mean, math.Sqrt(variance / float64(len(values)))
+	})
+}
+
+func (s *StandardScaler) Transform(x *tensor.Tensor) (*tensor.Tensor, error) {
+	return s.apply(x, false)
+}
+
+func (s *StandardScaler) InverseTransform(x *tensor.Tensor) (*tensor.Tensor, error) {
+	return s.apply(x, true)
+}
+
+// Mean returns the fitted mean of each feature
+func (s *StandardScaler) Mean() []float64 {
+	return slices.Clone(s.offset)
+}
+
+// Scale returns the fitted standard deviation of each feature, with zeros replaced by one
+func (s *StandardScaler) Scale() []float64 {
+	return slices.Clone(s.scale)
+}
+
+func (s *StandardScaler) StateDict() map[string]*tensor.Tensor {
+	return s.stateDict("mean", "scale")
+}
+
+func (s *StandardScaler) LoadStateDict(state map[string]*tensor.Tensor) error {
+	return s.loadStateDict(state, "mean", "scale")
+}
+
+// MinMaxScaler scales each feature linearly so its training minimum and maximum map to Min and Max
+// Min and Max both zero, the zero value, means the range 0 to 1
+type MinMaxScaler struct {
+	Min, Max float64
+
+	affine
+}
+
+// featureRange returns the target range, defaulting to [0, 1]
+func (s *MinMaxScaler) featureRange() (float64, float64) {
+	if s.Min == 0 && s.Max == 0 {
+		return 0, 1
+	}
+	return s.Min, s.Max
+}
+
+func (s *MinMaxScaler) Fit(x *tensor.Tensor) error {
+
+	low, high := s.featureRange()
+	if low >= high {
+		return fmt.Errorf("minimum of the feature range must be below the maximum, got %v and %v", low, high)
+	}
+
+	// (x - offset) / scale maps [dataMin, dataMax] onto [low, high]
+	return s.fit(x, func(values []float64) (float64, float64) {
+		dataMin, dataMax := slices.Min(values), slices.Max(values)
+		scale := (dataMax - dataMin) / (high - low)
+		if scale == 0 {
+			return dataMin - low, 1
+		}
+		return dataMin - low*scale, scale
+	})
+}
+
+func (s *MinMaxScaler) Transform(x *tensor.Tensor) (*tensor.Tensor, error) {
+	return s.apply(x, false)
+}
+
+func (s *MinMaxScaler) InverseTransform(x *tensor.Tensor) (*tensor.Tensor, error) {
+	return s.apply(x, true)
+}
+
+func (s *MinMaxScaler) StateDict() map[string]*tensor.Tensor {
+	state := s.stateDict("offset", "scale")
+	low, high := s.featureRange()
+	state["feature_range"] = tensor.NewTensor([]float64{low, high})
+	return state
+}
+
+func (s *MinMaxScaler) LoadStateDict(state map[string]*tensor.Tensor) error {
+
+	featureRange, err := stateTensor(state, "feature_range")
+	if err != nil {
+		return err
+	}
+	if len(featureRange.Data) != 2 {
+		return fmt.Errorf("feature_range must hold 2 values, got %d", len(featureRange.Data))
+	}
+
+	if err := s.loadStateDict(state, "offset", "scale"); err != nil {
+		return err
+	}
+	s.Min, s.Max = featureRange.Data[0], featureRange.Data[1]
+	return nil
+}
+
+// RobustScaler centers each feature on its median and scales it by its interquartile range, so outliers have little effect
+type RobustScaler struct {
+	affine
+}
+
+// percentile interpolates linearly between the closest ranks like numpy's default, values must be sorted
+func percentile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	lower := int(pos)
+	if lower+1 >= len(sorted) {
+		return sorted[lower]
+	}
+	return sorted[lower] + (pos-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+func (s *RobustScaler) Fit(x *tensor.Tensor) error {
+	return s.fit(x, func(values []float64) (float64, float64) {
+		slices.Sort(values)
+		return percentile(values, 0.5), percentile(values, 0.75) - percentile(values, 0.25)
+	})
+}
+
+func (s *RobustScaler) Transform(x *tensor.Tensor) (*tensor.Tensor, error) {
+	return s.apply(x, false)
+}
+
+func (s *RobustScaler) InverseTransform(x *tensor.Tensor) (*tensor.Tensor, error) {
+	return s.apply(x, true)
+}
+
+func (s *RobustScaler) StateDict() map[string]*tensor.Tensor {
+	return s.stateDict("center", "scale")
+}
+
+func (s *RobustScaler) LoadStateDict(state map[string]*tensor.Tensor) error {
+	return s.loadStateDict(state, "center", "scale")
+}
+
+// uniqueSorted returns the distinct values in ascending order
+func uniqueSorted(values []float64) []float64 {
+	unique := slices.Clone(values)
+	slices.Sort(unique)
+	return slices.Compact(unique)
+}
+
+// LabelEncoder maps class values to the integers 0 to classes-1 in ascending order of value, it works on tensors of any shape
+type LabelEncoder struct {
+	classes []float64
+}
+
+func (e *LabelEncoder) Fit(x *tensor.Tensor) error {
+	if len(x.Data) == 0 {
+		return fmt.Errorf("can't fit on an empty tensor")
+	}
+	e.classes = uniqueSorted(x.Data)
+	return nil
+}
+
+// Classes returns the class values, class i is encoded as i
+func (e *LabelEncoder) Classes() []float64 {
+	return slices.Clone(e.classes)
+}
+
+func (e *LabelEncoder) Transform(x *tensor.Tensor) (*tensor.Tensor, error) {
+
+	if e.classes == nil {
+		return nil, fmt.Errorf("the label encoder hasn't been fitted")
+	}
+
+	data := make([]float64, len(x.Data))
+	for i, v := range x.Data {
+		index, ok := slices.BinarySearch(e.classes, v)
+		if !ok {
+			return nil, fmt.Errorf("unseen label %v", v)
+		}
+		data[i] = float64(index)
+	}
+
+	return tensor.NewTensor(data, slices.Clone(x.Shape)...), nil
+}
+
+func (e *LabelEncoder) InverseTransform(x *tensor.Tensor) (*tensor.Tensor, error) {
+
+	if e.classes == nil {
+		return nil, fmt.Errorf("the label encoder hasn't been fitted")
+	}
+
+	data := make([]float64, len(x.Data))
+	for i, v := range x.Data {
+		index := int(v)
+		if float64(index) != v || index < 0 || index >= len(e.classes) {
+			return nil, fmt.Errorf("%v isn't the index of one of the %d classes", v, len(e.classes))
+		}
+		data[i] = e.classes[index]
+	}
+
+	return tensor.NewTensor(data, slices.Clone(x.Shape)...), nil
+}
+
+func (e *LabelEncoder) StateDict() map[string]*tensor.Tensor {
+	return map[string]*tensor.Tensor{"classes": tensor.NewTensor(slices.Clone(e.classes))}
+}
+
+func (e *LabelEncoder) LoadStateDict(state map[string]*tensor.Tensor) error {
+	classes, err := stateTensor(state, "classes")
+	if err != nil {
+		return err
+	}
+	e.classes = slices.Clone(classes.Data)
+	return nil
+}
+
+// OneHotEncoder replaces each categorical feature with one column per category it took in the training data
+// the columns for each feature are in ascending order of category. Values not seen in training are an error unless
+// IgnoreUnknown is set, in which case they are encoded as all zeros
+type OneHotEncoder struct {
+	IgnoreUnknown bool
+
+	categories [][]float64
+}
+
+func (e *OneHotEncoder) Fit(x *tensor.Tensor) error {
+
+	rows, cols, err := matrixShape(x)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("can't fit on an empty tensor")
+	}
+
+	e.categories = make([][]float64, cols)
+	for j := range e.categories {
+		e.categories[j] = uniqueSorted(column(x.Data, cols, j))
+	}
+
+	return nil
+}
+
+// Categories returns the categories of each input feature
+func (e *OneHotEncoder) Categories() [][]float64 {
+	categories := make([][]float64, len(e.categories))
+	for i, c := range e.categories {
+		categories[i] = slices.Clone(c)
+	}
+	return categories
+}
+
+// width returns the number of output columns
+func (e *OneHotEncoder) width() int {
+	n := 0
+	for _, c := range e.categories {
+		n += len(c)
+	}
+	return n
+}
+
+func (e *OneHotEncoder) Transform(x *tensor.Tensor) (*tensor.Tensor, error) {
+
+	if e.categories == nil {
+		return nil, fmt.Errorf("the one hot encoder hasn't been fitted")
+	}
+	rows, cols, err := matrixShape(x)
+	if err != nil {
+		return nil, err
+	}
+	if cols != len(e.categories) {
+		return nil, fmt.Errorf("the encoder was fitted on %d features but got %d", len(e.categories), cols)
+	}
+
+	width := e.width()
+	data := make([]float64, rows*width)
+	for i := 0; i < rows; i++ {
+		offset := i * width
+		for j, categories := range e.categories {
+			v := x.Data[i*cols+j]
+			index, ok := slices.BinarySearch(categories, v)
+			if ok {
+				data[offset+index] = 1
+			} else if !e.IgnoreUnknown {
+				return nil, fmt.Errorf("unseen category %v in feature %d", v, j)
+			}
+			offset += len(categories)
+		}
+	}
+
+	return withColumns(x, data, width), nil
+}
+
+// InverseTransform returns the category with the largest value in each feature's columns, all zero columns are an error
+func (e *OneHotEncoder) InverseTransform(x *tensor.Tensor) (*tensor.Tensor, error) {
+
+	if e.categories == nil {
+		return nil, fmt.Errorf("the one hot encoder hasn't been fitted")
+	}
+	rows, cols, err := matrixShape(x)
+	if err != nil {
+		return nil, err
+	}
+	if cols != e.width() {
+		return nil, fmt.Errorf("expected %d one hot columns, got %d", e.width(), cols)
+	}
+
+	data := make([]float64, rows*len(e.categories))
+	for i := 0; i < rows; i++ {
+		offset := i * cols
+		for j, categories := range e.categories {
+			block := x.Data[offset : offset+len(categories)]
+			best := slices.Index(block, slices.Max(block))
+			if block[best] <= 0 {
+				return nil, fmt.Errorf("row %d has no category set for feature %d", i, j)
+			}
+			data[i*len(e.categories)+j] = categories[best]
+			offset += len(categories)
+		}
+	}
+
+	return withColumns(x, data, len(e.categories)), nil
+}
+
+func (e *OneHotEncoder) StateDict() map[string]*tensor.Tensor {
+	state := map[string]*tensor.Tensor{}
+	for j, categories := range e.categories {
+		state["categories."+strconv.Itoa(j)] = tensor.NewTensor(slices.Clone(categories))
+	}
+	return state
+}
+
+func (e *OneHotEncoder) LoadStateDict(state map[string]*tensor.Tensor) error {
+
+	var categories [][]float64
+	for j := 0; ; j++ {
+		t, ok := state["categories."+strconv.Itoa(j)]
+		if !ok {
+			break
+		}
+		categories = append(categories, slices.Clone(t.Data))
+	}
+	if len(categories) == 0 {
+		return fmt.Errorf("state dict is missing %q", "categories.0")
+	}
+	for key := range state {
+		if index, ok := strings.CutPrefix(key, "categories."); !ok {
+			return fmt.Errorf("unexpected key %q in state dict", key)
+		} else if j, err := strconv.Atoi(index); err != nil || j < 0 || j >= len(categories) {
+			return fmt.Errorf("unexpected key %q in state dict", key)
+		}
+	}
+
+	e.categories = categories
+	return nil
+}
+
+// PolynomialFeatures adds every product of up to Degree input features, in the same order as scikit-learn:
+// for features a and b and degree 2 that is 1, a, b, a², ab, b². InteractionOnly leaves out powers of a single feature
+// and ExcludeBias leaves out the constant column
+type PolynomialFeatures struct {
+	Degree          int
+	InteractionOnly bool
+	ExcludeBias     bool
+
+	features int
+	terms    [][]int
+}
+
+// combinations returns every non-decreasing (strictly increasing when distinct is set) list of degree feature indices
+func combinations(features, degree int, distinct bool) [][]int {
+
+	var result [][]int
+	var build func(start int, term []int)
+	build = func(start int, term []int) {
+		if len(term) == degree {
+			result = append(result, slices.Clone(term))
+			return
+		}
+		for i := start; i < features; i++ {
+			next := i
+			if distinct {
+				next++
+			}
+			build(next, append(term, i))
+		}
+	}
+	build(0, nil)
+
+	return result
+}
+
+func (p *PolynomialFeatures) Fit(x *tensor.Tensor) error {
+
+	if p.Degree < 1 {
+		return fmt.Errorf("degree must be at least 1, got %d", p.Degree)
+	}
+	_, cols, err := matrixShape(x)
+	if err != nil {
+		return err
+	}
+
+	p.features = cols
+	p.terms = nil
+	start := 0
+	if p.ExcludeBias {
+		start = 1
+	}
+	for degree := start; degree <= p.Degree; degree++ {
+		p.terms = append(p.terms, combinations(cols, degree, p.InteractionOnly)...)
+	}
+
+	return nil
+}
+
+func (p *PolynomialFeatures) Transform(x *tensor.Tensor) (*tensor.Tensor, error) {
+
+	if p.terms == nil {
+		return nil, fmt.Errorf("polynomial features haven't been fitted")
+	}
+	rows, cols, err := matrixShape(x)
+	if err != nil {
+		return nil, err
+	}
+	if cols != p.features {
+		return nil, fmt.Errorf("fitted on %d features but got %d", p.features, cols)
+	}
+
+	data := make([]float64, 0, rows*len(p.terms))
+	for i := 0; i < rows; i++ {
+		row := x.Data[i*cols : (i+1)*cols]
+		for _, term := range p.terms {
+			product := 1.0
+			for _, j := range term {
+				product *= row[j]
+			}
+			data = append(data, product)
+		}
+	}
+
+	return withColumns(x, data, len(p.terms)), nil
+}
+
+// InverseTransform recovers the original features from the degree one columns
+func (p *PolynomialFeatures) InverseTransform(x *tensor.Tensor) (*tensor.Tensor, error) {
+
+	if p.terms == nil {
+		return nil, fmt.Errorf("polynomial features haven't been fitted")
+	}
+	rows, cols, err := matrixShape(x)
+	if err != nil {
+		return nil, err
+	}
+	if cols != len(p.terms) {
+		return nil, fmt.Errorf("expected %d polynomial columns, got %d", len(p.terms), cols)
+	}
+
+	// the degree one terms come straight after the bias, if there is one
+	first := 1
+	if p.ExcludeBias {
+		first = 0
+	}
+
+	data := make([]float64, 0, rows*p.features)
+	for i := 0; i < rows; i++ {
+		data = append(data, x.Data[i*cols+first:i*cols+first+p.features]...)
+	}
+
+	return withColumns(x, data, p.features), nil
+}
+
+func (p *PolynomialFeatures) StateDict() map[string]*tensor.Tensor {
+	flag := func(b bool) float64 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	return map[string]*tensor.Tensor{
+		"config": tensor.NewTensor([]float64{float64(p.features), float64(p.Degree), flag(p.InteractionOnly), flag(p.ExcludeBias)}),
+	}
+}
+
+func (p *PolynomialFeatures) LoadStateDict(state map[string]*tensor.Tensor) error {
+
+	config, err := stateTensor(state, "config")
+	if err != nil {
+		return err
+	}
+	if len(config.Data) != 4 {
+		return fmt.Errorf("config must hold 4 values, got %d", len(config.Data))
+	}
+
+	p.Degree, p.InteractionOnly, p.ExcludeBias = int(config.Data[1]), config.Data[2] != 0, config.Data[3] != 0
+	return p.Fit(tensor.NewTensor(make([]float64, int(config.Data[0])), int(config.Data[0])))
+}
@@ -0,0 +1,191 @@
+package data
+
+import (
+	"gotorch/model"
+	"gotorch/tensor"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func approxSlice(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+// checkRoundTrip fits p on x, checks the transformed values and that InverseTransform gives x back
+func checkRoundTrip(t *testing.T, name string, p Preprocessor, x *tensor.Tensor, expected []float64) {
+
+	t.Helper()
+	got, err := FitTransform(p, x)
+	if err != nil {
+		t.Fatalf("%s: failed to transform: %v", name, err)
+	}
+	if !approxSlice(got.Data, expected) {
+		t.Errorf("%s: expected %v, got %v", name, expected, got.Data)
+	}
+
+	back, err := p.InverseTransform(got)
+	if err != nil {
+		t.Fatalf("%s: failed to inverse transform: %v", name, err)
+	}
+	if !approxSlice(back.Data, x.Data) || !reflect.DeepEqual(back.Shape, x.Shape) {
+		t.Errorf("%s: expected the inverse to give back %v, got %v with shape %v", name, x.Data, back.Data, back.Shape)
+	}
+}
+
+func Test_Scalers(t *testing.T) {
+
+	// the second column is constant
+	x := tensor.NewTensor([][]float64{{1, 5}, {2, 5}, {3, 5}, {10, 5}})
+
+	// mean 4, population variance 12.5
+	std := math.Sqrt(12.5)
+	checkRoundTrip(t, "standard", &StandardScaler{}, x, []float64{-3 / std, 0, -2 / std, 0, -1 / std, 0, 6 / std, 0})
+	checkRoundTrip(t, "min max", &MinMaxScaler{}, x, []float64{0, 0, 1.0 / 9, 0, 2.0 / 9, 0, 1, 0})
+	checkRoundTrip(t, "min max -1 to 1", &MinMaxScaler{Min: -1, Max: 1}, x, []float64{-1, -1, -7.0 / 9, -1, -5.0 / 9, -1, 1, -1})
+
+	// median 2.5, quartiles 1.75 and 4.75
+	checkRoundTrip(t, "robust", &RobustScaler{}, x, []float64{-0.5, 0, -1.0 / 6, 0, 1.0 / 6, 0, 2.5, 0})
+}
+
+func Test_ScalerSingleSample(t *testing.T) {
+
+	scaler := &StandardScaler{}
+	if err := scaler.Fit(tensor.NewTensor([][]float64{{0, 10}, {2, 30}})); err != nil {
+		t.Fatalf("Failed to fit: %v", err)
+	}
+
+	got, err := scaler.Transform(tensor.NewTensor([]float64{1, 50}))
+	if err != nil {
+		t.Fatalf("Failed to transform: %v", err)
+	}
+	if !reflect.DeepEqual(got.Shape, []int{2}) || !approxSlice(got.Data, []float64{0, 3}) {
+		t.Errorf("Expected [0 3] with shape [2], got %v with shape %v", got.Data, got.Shape)
+	}
+
+	if _, err := scaler.Transform(tensor.NewTensor([]float64{1, 2, 3})); err == nil {
+		t.Errorf("Expected an error for the wrong number of features")
+	}
+	if _, err := (&MinMaxScaler{}).Transform(got); err == nil {
+		t.Errorf("Expected an error transforming before fitting")
+	}
+	if err := (&MinMaxScaler{Min: 1, Max: 1}).Fit(got); err == nil {
+		t.Errorf("Expected an error for an empty feature range")
+	}
+}
+
+func Test_LabelEncoder(t *testing.T) {
+
+	encoder := &LabelEncoder{}
+	checkRoundTrip(t, "label", encoder, tensor.NewTensor([]float64{10, -1, 10, 3}), []float64{2, 0, 2, 1})
+
+	if !reflect.DeepEqual(encoder.Classes(), []float64{-1, 3, 10}) {
+		t.Errorf("Expected classes [-1 3 10], got %v", encoder.Classes())
+	}
+	if _, err := encoder.Transform(tensor.NewTensor([]float64{4})); err == nil {
+		t.Errorf("Expected an error for an unseen label")
+	}
+	if _, err := encoder.InverseTransform(tensor.NewTensor([]float64{3})); err == nil {
+		t.Errorf("Expected an error for an out of range index")
+	}
+}
+
+func Test_OneHotEncoder(t *testing.T) {
+
+	x := tensor.NewTensor([][]float64{{1, 7}, {0, 8}, {2, 7}})
+	encoder := &OneHotEncoder{}
+	checkRoundTrip(t, "one hot", encoder, x, []float64{
+		0, 1, 0, 1, 0,
+		1, 0, 0, 0, 1,
+		0, 0, 1, 1, 0,
+	})
+
+	if _, err := encoder.Transform(tensor.NewTensor([]float64{3, 7})); err == nil {
+		t.Errorf("Expected an error for an unseen category")
+	}
+
+	encoder.IgnoreUnknown = true
+	got, err := encoder.Transform(tensor.NewTensor([]float64{3, 7}))
+	if err != nil || !reflect.DeepEqual(got.Data, []float64{0, 0, 0, 1, 0}) {
+		t.Errorf("Expected [0 0 0 1 0], got %v (%v)", got, err)
+	}
+}
+
+func Test_PolynomialFeatures(t *testing.T) {
+
+	x := tensor.NewTensor([][]float64{{2, 3}, {-1, 4}})
+	checkRoundTrip(t, "degree 2", &PolynomialFeatures{Degree: 2}, x, []float64{1, 2, 3, 4, 6, 9, 1, -1, 4, 1, -4, 16})
+
+	interactions := &PolynomialFeatures{Degree: 3, InteractionOnly: true, ExcludeBias: true}
+	checkRoundTrip(t, "interactions", interactions, tensor.NewTensor([]float64{2, 3, 5}), []float64{2, 3, 5, 6, 10, 15, 30})
+
+	if err := (&PolynomialFeatures{}).Fit(x); err == nil {
+		t.Errorf("Expected an error for degree 0")
+	}
+}
+
+func Test_PreprocessorStateDicts(t *testing.T) {
+
+	x := tensor.NewTensor([][]float64{{1, 0}, {4, 1}, {2, 1}})
+	tests := map[string]struct{ fitted, fresh Preprocessor }{
+		"standard":   {&StandardScaler{}, &StandardScaler{}},
+		"min max":    {&MinMaxScaler{Min: -2, Max: 2}, &MinMaxScaler{}},
+		"robust":     {&RobustScaler{}, &RobustScaler{}},
+		"label":      {&LabelEncoder{}, &LabelEncoder{}},
+		"one hot":    {&OneHotEncoder{}, &OneHotEncoder{}},
+		"polynomial": {&PolynomialFeatures{Degree: 2, ExcludeBias: true}, &PolynomialFeatures{}},
+	}
+
+	for name, test := range tests {
+		expected, err := FitTransform(test.fitted, x)
+		if err != nil {
+			t.Fatalf("%s: failed to fit: %v", name, err)
+		}
+		if err := test.fresh.LoadStateDict(test.fitted.StateDict()); err != nil {
+			t.Fatalf("%s: failed to load state dict: %v", name, err)
+		}
+		got, err := test.fresh.Transform(x)
+		if err != nil || !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected %v after loading the state dict, got %v (%v)", name, expected, got, err)
+		}
+		if err := test.fresh.LoadStateDict(nil); err == nil {
+			t.Errorf("%s: expected an error loading an empty state dict", name)
+		}
+	}
+}
+
+func Test_PreprocessorCheckpoint(t *testing.T) {
+
+	features := tensor.NewTensor([][]float64{{100, 1}, {200, 3}})
+	scaler := &StandardScaler{}
+	if err := scaler.Fit(features); err != nil {
+		t.Fatalf("Failed to fit: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "model.ckpt")
+	linear := &model.Linear{Weights: []float64{0.5, -1}, Biases: []float64{2}}
+	if err := model.SaveCheckpointWith(path, linear, nil, 3, nil, map[string]model.Stateful{"scaler": scaler}); err != nil {
+		t.Fatalf("Failed to save checkpoint: %v", err)
+	}
+
+	restored := &StandardScaler{}
+	restoredLinear := &model.Linear{Weights: make([]float64, 2), Biases: make([]float64, 1)}
+	if _, _, err := model.LoadCheckpointWith(path, restoredLinear, nil, map[string]model.Stateful{"scaler": restored}); err != nil {
+		t.Fatalf("Failed to load checkpoint: %v", err)
+	}
+
+	expected, _ := scaler.Transform(features)
+	got, err := restored.Transform(features)
+	if err != nil || !reflect.DeepEqual(got.Data, expected.Data) {
+		t.Errorf("Expected the restored scaler to give %v, got %v (%v)", expected.Data, got, err)
+	}
+}
@@ -0,0 +1,716 @@
+package data
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"gotorch/tensor"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*
+torch.save writes a zip archive holding <name>/data.pkl, a pickle of the saved object, and one <name>/data/<key> file
+per tensor storage. Pickle is a small stack machine; rather than run arbitrary python we implement only the opcodes
+torch.save produces and only the handful of globals needed to rebuild tensors and the dicts holding them. Anything else,
+such as a whole pickled nn.Module, is rejected with an error naming the object.
+See https://github.com/python/cpython/blob/main/Lib/pickletools.py for the opcodes.
+*/
+
+// the storage classes torch pickles for each dtype
+var torchStorageTypes = map[string]elementType{
+	"DoubleStorage":   {order: binary.LittleEndian, kind: 'f', size: 8},
+	"FloatStorage":    {order: binary.LittleEndian, kind: 'f', size: 4},
+	"HalfStorage":     {order: binary.LittleEndian, kind: 'f', size: 2},
+	"BFloat16Storage": {order: binary.LittleEndian, kind: 'B', size: 2},
+	"LongStorage":     {order: binary.LittleEndian, kind: 'i', size: 8},
+	"IntStorage":      {order: binary.LittleEndian, kind: 'i', size: 4},
+	"ShortStorage":    {order: binary.LittleEndian, kind: 'i', size: 2},
+	"CharStorage":     {order: binary.LittleEndian, kind: 'i', size: 1},
+	"ByteStorage":     {order: binary.LittleEndian, kind: 'u', size: 1},
+	"BoolStorage":     {order: binary.LittleEndian, kind: 'b', size: 1},
+}
+
+// the python globals the unpickler will resolve, keyed by "module.name"
+var torchGlobals = map[string]bool{
+	"collections.OrderedDict":                    true,
+	"torch._utils._rebuild_tensor":               true,
+	"torch._utils._rebuild_tensor_v2":            true,
+	"torch._utils._rebuild_parameter":            true,
+	"torch._utils._rebuild_parameter_with_state": true,
+	"torch._tensor._rebuild_from_type_v2":        true,
+	"torch.nn.parameter.Parameter":               true,
+	"torch.Tensor":                               true,
+	"torch.Size":                                 true,
+}
+
+// python values as the unpickler represents them, other values are nil, bool, int64, float64, string and []byte
+type (
+	pyTuple  []any
+	pyList   struct{ items []any }
+	pyGlobal struct{ module, name string }
+	pyMark   struct{}
+)
+
+// pyDict is an insertion ordered dict, keys must be strings, ints, floats, bools or None
+type pyDict struct {
+	keys   []any
+	values []any
+	index  map[any]int
+}
+
+func newPyDict() *pyDict {
+	return &pyDict{index: map[any]int{}}
+}
+
+func (d *pyDict) set(key, value any) error {
+	switch key.(type) {
+	case nil, bool, int64, float64, string:
+	default:
+		return fmt.Errorf("unsupported dict key of type %T", key)
+	}
+
+	if i, ok := d.index[key]; ok {
+		d.values[i] = value
+		return nil
+	}
+	d.index[key] = len(d.keys)
+	d.keys = append(d.keys, key)
+	d.values = append(d.values, value)
+	return nil
+}
+
+// torchStorage is the raw data of one storage file in the archive
+type torchStorage struct {
+	dtype elementType
+	data  []byte
+}
+
+// unpickler runs the subset of the pickle machine used by torch.save
+type unpickler struct {
+	r        *bytes.Reader
+	stack    []any
+	memo     map[int]any
+	storages map[string]*torchStorage
+	archive  map[string]*zip.File
+	prefix   string
+	order    binary.ByteOrder
+}
+
+func (u *unpickler) push(v any) {
+	u.stack = append(u.stack, v)
+}
+
+func (u *unpickler) pop() (any, error) {
+	if len(u.stack) == 0 {
+		return nil, fmt.Errorf("pickle stack underflow")
+	}
+	v := u.stack[len(u.stack)-1]
+	u.stack = u.stack[:len(u.stack)-1]
+	return v, nil
+}
+
+func (u *unpickler) top() (any, error) {
+	if len(u.stack) == 0 {
+		return nil, fmt.Errorf("pickle stack underflow")
+	}
+	return u.stack[len(u.stack)-1], nil
+}
+
+// popMark pops everything down to the last mark
+func (u *unpickler) popMark() ([]any, error) {
+	for i := len(u.stack) - 1; i >= 0; i-- {
+		if _, ok := u.stack[i].(pyMark); ok {
+			items := append([]any{}, u.stack[i+1:]...)
+			u.stack = u.stack[:i]
+			return items, nil
+		}
+	}
+	return nil, fmt.Errorf("pickle mark not found")
+}
+
+func (u *unpickler) readN(n int) ([]byte, error) {
+	if n < 0 || n > u.r.Len() {
+		return nil, fmt.Errorf("pickle data truncated")
+	}
+	b := make([]byte, n)
+	u.r.Read(b)
+	return b, nil
+}
+
+func (u *unpickler) readUint(n int) (uint64, error) {
+	b, err := u.readN(n)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for i := n - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	return v, nil
+}
+
+func (u *unpickler) readLine() (string, error) {
+	var line []byte
+	for {
+		c, err := u.r.ReadByte()
+		if err != nil {
+			return "", fmt.Errorf("pickle data truncated")
+		}
+		if c == '\n' {
+			return string(line), nil
+		}
+		line = append(line, c)
+	}
+}
+
+// run executes the pickle and returns the object it builds
+func (u *unpickler) run() (any, error) {
+
+	for {
+		op, err := u.r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("pickle ended without a STOP opcode")
+		}
+
+		switch op {
+		case 0x80: // PROTO
+			if _, err := u.readN(1); err != nil {
+				return nil, err
+			}
+		case 0x95: // FRAME, frames only matter for streaming so the length is ignored
+			if _, err := u.readN(8); err != nil {
+				return nil, err
+			}
+		case '.': // STOP
+			return u.pop()
+		case '(': // MARK
+			u.push(pyMark{})
+		case 'N': // NONE
+			u.push(nil)
+		case 0x88: // NEWTRUE
+			u.push(true)
+		case 0x89: // NEWFALSE
+			u.push(false)
+		case ')': // EMPTY_TUPLE
+			u.push(pyTuple{})
+		case ']': // EMPTY_LIST
+			u.push(&pyList{})
+		case '}': // EMPTY_DICT
+			u.push(newPyDict())
+
+		case 'K', 'M', 'J': // BININT1, BININT2, BININT
+			size := map[byte]int{'K': 1, 'M': 2, 'J': 4}[op]
+			v, err := u.readUint(size)
+			if err != nil {
+				return nil, err
+			}
+			if op == 'J' {
+				u.push(int64(int32(v)))
+			} else {
+				u.push(int64(v))
+			}
+		case 0x8a: // LONG1
+			n, err := u.readUint(1)
+			if err != nil {
+				return nil, err
+			}
+			if n > 8 {
+				return nil, fmt.Errorf("integer of %d bytes is too large", n)
+			}
+			v, err := u.readUint(int(n))
+			if err != nil {
+				return nil, err
+			}
+			// sign extend the two's complement value
+			if n > 0 {
+				shift := 64 - 8*n
+				v = uint64(int64(v<<shift) >> shift)
+			}
+			u.push(int64(v))
+		case 'G': // BINFLOAT, big endian unlike everything else
+			b, err := u.readN(8)
+			if err != nil {
+				return nil, err
+			}
+			u.push(math.Float64frombits(binary.BigEndian.Uint64(b)))
+
+		case 'X', 0x8c, 0x8d, 'T', 'U', 'B', 'C', 0x8e: // strings and bytes
+			sizes := map[byte]int{'X': 4, 0x8c: 1, 0x8d: 8, 'T': 4, 'U': 1, 'B': 4, 'C': 1, 0x8e: 8}
+			n, err := u.readUint(sizes[op])
+			if err != nil {
+				return nil, err
+			}
+			b, err := u.readN(int(n))
+			if err != nil {
+				return nil, err
+			}
+			if op == 'B' || op == 'C' || op == 0x8e {
+				u.push(b)
+			} else {
+				u.push(string(b))
+			}
+
+		case 'q', 'r': // BINPUT, LONG_BINPUT
+			i, err := u.readUint(map[byte]int{'q': 1, 'r': 4}[op])
+			if err != nil {
+				return nil, err
+			}
+			if u.memo[int(i)], err = u.top(); err != nil {
+				return nil, err
+			}
+		case 0x94: // MEMOIZE
+			v, err := u.top()
+			if err != nil {
+				return nil, err
+			}
+			u.memo[len(u.memo)] = v
+		case 'h', 'j': // BINGET, LONG_BINGET
+			i, err := u.readUint(map[byte]int{'h': 1, 'j': 4}[op])
+			if err != nil {
+				return nil, err
+			}
+			v, ok := u.memo[int(i)]
+			if !ok {
+				return nil, fmt.Errorf("pickle memo entry %d not found", i)
+			}
+			u.push(v)
+
+		case 't': // TUPLE
+			items, err := u.popMark()
+			if err != nil {
+				return nil, err
+			}
+			u.push(pyTuple(items))
+		case 0x85, 0x86, 0x87: // TUPLE1, TUPLE2, TUPLE3
+			n := int(op - 0x84)
+			if len(u.stack) < n {
+				return nil, fmt.Errorf("pickle stack underflow")
+			}
+			items := append(pyTuple{}, u.stack[len(u.stack)-n:]...)
+			u.stack = u.stack[:len(u.stack)-n]
+			u.push(items)
+
+		case 'a', 'e': // APPEND, APPENDS
+			var items []any
+			if op == 'a' {
+				item, err := u.pop()
+				if err != nil {
+					return nil, err
+				}
+				items = []any{item}
+			} else if items, err = u.popMark(); err != nil {
+				return nil, err
+			}
+			top, err := u.top()
+			if err != nil {
+				return nil, err
+			}
+			list, ok := top.(*pyList)
+			if !ok {
+				return nil, fmt.Errorf("cannot append to %T", top)
+			}
+			list.items = append(list.items, items...)
+
+		case 's', 'u': // SETITEM, SETITEMS
+			var items []any
+			if op == 's' {
+				value, err := u.pop()
+				if err != nil {
+					return nil, err
+				}
+				key, err := u.pop()
+				if err != nil {
+					return nil, err
+				}
+				items = []any{key, value}
+			} else if items, err = u.popMark(); err != nil {
+				return nil, err
+			}
+			top, err := u.top()
+			if err != nil {
+				return nil, err
+			}
+			dict, ok := top.(*pyDict)
+			if !ok || len(items)%2 != 0 {
+				return nil, fmt.Errorf("cannot set items on %T", top)
+			}
+			for i := 0; i < len(items); i += 2 {
+				if err := dict.set(items[i], items[i+1]); err != nil {
+					return nil, err
+				}
+			}
+
+		case 'c': // GLOBAL
+			module, err := u.readLine()
+			if err != nil {
+				return nil, err
+			}
+			name, err := u.readLine()
+			if err != nil {
+				return nil, err
+			}
+			global, err := resolveGlobal(module, name)
+			if err != nil {
+				return nil, err
+			}
+			u.push(global)
+		case 0x93: // STACK_GLOBAL
+			name, err := u.pop()
+			if err != nil {
+				return nil, err
+			}
+			module, err := u.pop()
+			if err != nil {
+				return nil, err
+			}
+			moduleName, ok1 := module.(string)
+			globalName, ok2 := name.(string)
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("invalid STACK_GLOBAL operands")
+			}
+			global, err := resolveGlobal(moduleName, globalName)
+			if err != nil {
+				return nil, err
+			}
+			u.push(global)
+
+		case 'R', 0x81: // REDUCE, NEWOBJ
+			args, err := u.pop()
+			if err != nil {
+				return nil, err
+			}
+			callable, err := u.pop()
+			if err != nil {
+				return nil, err
+			}
+			tuple, ok := args.(pyTuple)
+			if !ok {
+				return nil, fmt.Errorf("expected an argument tuple, got %T", args)
+			}
+			result, err := u.call(callable, tuple)
+			if err != nil {
+				return nil, err
+			}
+			u.push(result)
+
+		case 'b': // BUILD
+			// the only state torch pickles is attributes such as a state dict's _metadata, which we don't need
+			if _, err := u.pop(); err != nil {
+				return nil, err
+			}
+			top, err := u.top()
+			if err != nil {
+				return nil, err
+			}
+			switch top.(type) {
+			case *pyDict, *tensor.Tensor:
+			default:
+				return nil, fmt.Errorf("cannot set the state of %T", top)
+			}
+
+		case 'Q': // BINPERSID
+			pid, err := u.pop()
+			if err != nil {
+				return nil, err
+			}
+			storage, err := u.persistentLoad(pid)
+			if err != nil {
+				return nil, err
+			}
+			u.push(storage)
+
+		default:
+			return nil, fmt.Errorf("unsupported pickle opcode %#x", op)
+		}
+	}
+}
+
+// resolveGlobal checks a global against the allowed ones
+func resolveGlobal(module, name string) (pyGlobal, error) {
+
+	if module == "torch" {
+		if _, ok := torchStorageTypes[name]; ok {
+			return pyGlobal{module, name}, nil
+		}
+	}
+	if torchGlobals[module+"."+name] {
+		return pyGlobal{module, name}, nil
+	}
+
+	return pyGlobal{}, fmt.Errorf("unsupported pickled object %s.%s, only tensors and dicts, lists and tuples of them can be loaded", module, name)
+}
+
+// call runs an allowed global with the given arguments
+func (u *unpickler) call(callable any, args pyTuple) (any, error) {
+
+	global, ok := callable.(pyGlobal)
+	if !ok {
+		return nil, fmt.Errorf("cannot call %T", callable)
+	}
+
+	switch global.module + "." + global.name {
+	case "collections.OrderedDict":
+		return newPyDict(), nil
+	case "torch.Size":
+		if len(args) == 1 {
+			if size, ok := args[0].(pyTuple); ok {
+				return size, nil
+			}
+		}
+	case "torch._utils._rebuild_tensor", "torch._utils._rebuild_tensor_v2":
+		if len(args) >= 4 {
+			return u.rebuildTensor(args[0], args[1], args[2], args[3])
+		}
+	case "torch._utils._rebuild_parameter", "torch._utils._rebuild_parameter_with_state":
+		if len(args) >= 1 {
+			if t, ok := args[0].(*tensor.Tensor); ok {
+				return t, nil
+			}
+		}
+	case "torch._tensor._rebuild_from_type_v2":
+		// (func, new_type, args, state), the type is a tensor subclass such as Parameter so only func matters
+		if len(args) >= 3 {
+			if inner, ok := args[2].(pyTuple); ok {
+				return u.call(args[0], inner)
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported call to %s.%s with %d arguments", global.module, global.name, len(args))
+}
+
+// persistentLoad resolves a ('storage', storage_type, key, location, numel) reference to the storage's data
+func (u *unpickler) persistentLoad(pid any) (*torchStorage, error) {
+
+	tuple, ok := pid.(pyTuple)
+	if !ok || len(tuple) < 5 || tuple[0] != "storage" {
+		return nil, fmt.Errorf("unsupported persistent id %v", pid)
+	}
+
+	global, ok1 := tuple[1].(pyGlobal)
+	key, ok2 := tuple[2].(string)
+	numel, ok3 := tuple[4].(int64)
+	if !ok1 || !ok2 || !ok3 || numel < 0 {
+		return nil, fmt.Errorf("invalid storage reference %v", tuple)
+	}
+
+	dtype, ok := torchStorageTypes[global.name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported storage type %s", global.name)
+	}
+	dtype.order = u.order
+
+	if storage, ok := u.storages[key]; ok {
+		return storage, nil
+	}
+
+	f, ok := u.archive[u.prefix+"data/"+key]
+	if !ok {
+		return nil, fmt.Errorf("storage %q not found in the archive", key)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read storage %q: %w", key, err)
+	}
+	if int64(len(data)) < numel*int64(dtype.size) {
+		return nil, fmt.Errorf("storage %q has %d bytes but needs %d", key, len(data), numel*int64(dtype.size))
+	}
+
+	storage := &torchStorage{dtype: dtype, data: data[:numel*int64(dtype.size)]}
+	u.storages[key] = storage
+	return storage, nil
+}
+
+// rebuildTensor copies the elements of a strided view of a storage into a new contiguous tensor
+func (u *unpickler) rebuildTensor(storageArg, offsetArg, sizeArg, strideArg any) (*tensor.Tensor, error) {
+
+	storage, ok1 := storageArg.(*torchStorage)
+	offset, ok2 := offsetArg.(int64)
+	size, ok3 := sizeArg.(pyTuple)
+	stride, ok4 := strideArg.(pyTuple)
+	if !ok1 || !ok2 || !ok3 || !ok4 || len(size) != len(stride) {
+		return nil, fmt.Errorf("invalid tensor arguments")
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("invalid storage offset %d", offset)
+	}
+
+	shape := make([]int, len(size))
+	strides := make([]int, len(stride))
+	elements := int64(1)
+	for d := range size {
+		n, ok1 := size[d].(int64)
+		s, ok2 := stride[d].(int64)
+		if !ok1 || !ok2 || n < 0 || s < 0 {
+			return nil, fmt.Errorf("invalid tensor size %v or stride %v", size, stride)
+		}
+		shape[d], strides[d] = int(n), int(s)
+		// a stride of 0 lets a tensor have more elements than its storage, so cap the size itself, checking before
+		// multiplying so a corrupt size can't wrap around to a small one
+		if n != 0 && elements > math.MaxInt32/n {
+			return nil, fmt.Errorf("tensor size %v is too large", size)
+		}
+		elements *= n
+	}
+
+	// every element lies between the offset and the position of the last one, so checking that once covers them
+	// all, each step is checked against the space left so the sum can't overflow
+	available := int64(len(storage.data) / storage.dtype.size)
+	if elements > 0 {
+		last := offset
+		if last >= available {
+			return nil, fmt.Errorf("tensor offset %d is past the end of its storage of %d", offset, available)
+		}
+		for d, n := range shape {
+			if n > 1 && strides[d] > 0 {
+				if int64(n-1) > (available-1-last)/int64(strides[d]) {
+					return nil, fmt.Errorf("tensor with size %v and stride %v reads past the end of its storage of %d", shape, strides, available)
+				}
+				last += int64(n-1) * int64(strides[d])
+			}
+		}
+	}
+
+	data := make([]float64, elements)
+	for i := range data {
+		position := int(offset)
+		for d, n := range unravelIndex(i, shape) {
+			position += n * strides[d]
+		}
+		data[i] = storage.dtype.decode(storage.data[position*storage.dtype.size:])
+	}
+
+	return &tensor.Tensor{Data: data, Shape: shape}, nil
+}
+
+// flattenTensors collects every tensor in a nested structure of dicts, lists and tuples, naming them by their path
+// joined with dots the way PyTorch names state dict entries, values that aren't tensors are skipped
+func flattenTensors(prefix string, value any, out map[string]*tensor.Tensor) {
+
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+
+	switch v := value.(type) {
+	case *tensor.Tensor:
+		out[prefix] = v
+	case *pyDict:
+		for i, key := range v.keys {
+			var name string
+			switch k := key.(type) {
+			case string:
+				name = k
+			case int64:
+				name = strconv.FormatInt(k, 10)
+			default:
+				continue
+			}
+			flattenTensors(join(name), v.values[i], out)
+		}
+	case *pyList:
+		for i, item := range v.items {
+			flattenTensors(join(strconv.Itoa(i)), item, out)
+		}
+	case pyTuple:
+		for i, item := range v {
+			flattenTensors(join(strconv.Itoa(i)), item, out)
+		}
+	}
+}
+
+// ReadPyTorch reads the tensors in a torch.save archive, see LoadPyTorch
+func ReadPyTorch(r io.ReaderAt, size int64) (map[string]*tensor.Tensor, error) {
+
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not a torch.save zip archive, files from before PyTorch 1.6 need saving again with a newer version: %w", err)
+	}
+
+	files := map[string]*zip.File{}
+	var pickle *zip.File
+	for _, f := range archive.File {
+		files[f.Name] = f
+		if f.Name == "data.pkl" || (strings.HasSuffix(f.Name, "/data.pkl") && strings.Count(f.Name, "/") == 1) {
+			pickle = f
+		}
+	}
+	if pickle == nil {
+		return nil, fmt.Errorf("archive has no data.pkl")
+	}
+
+	u := &unpickler{
+		memo:     map[int]any{},
+		storages: map[string]*torchStorage{},
+		archive:  files,
+		prefix:   strings.TrimSuffix(pickle.Name, "data.pkl"),
+		order:    binary.LittleEndian,
+	}
+
+	if f, ok := files[u.prefix+"byteorder"]; ok {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		order, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(string(order)) == "big" {
+			u.order = binary.BigEndian
+		}
+	}
+
+	rc, err := pickle.Open()
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return nil, err
+	}
+	u.r = bytes.NewReader(data)
+
+	result, err := u.run()
+	if err != nil {
+		return nil, fmt.Errorf("unable to unpickle %s: %w", pickle.Name, err)
+	}
+
+	tensors := map[string]*tensor.Tensor{}
+	flattenTensors("", result, tensors)
+
+	return tensors, nil
+}
+
+// LoadPyTorch reads the tensors in a file written by torch.save, such as a state dict saved from a model
+// nested dicts, lists and tuples are flattened into dotted names, so {"model": {"0.weight": w}} gives "model.0.weight"
+// and a bare tensor is returned under the empty name. Every dtype is converted to float64
+func LoadPyTorch(filePath string) (map[string]*tensor.Tensor, error) {
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return ReadPyTorch(file, stat.Size())
+}
@@ -0,0 +1,282 @@
+package data
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"gotorch/model"
+	"gotorch/tensor"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// pickler writes the opcodes torch.save produces, so tests can build archives without python
+type pickler struct {
+	bytes.Buffer
+}
+
+func (p *pickler) op(ops ...byte) *pickler {
+	p.Write(ops)
+	return p
+}
+
+func (p *pickler) global(module, name string) *pickler {
+	p.WriteString("c" + module + "\n" + name + "\n")
+	return p
+}
+
+func (p *pickler) str(s string) *pickler {
+	p.WriteByte('X')
+	binary.Write(p, binary.LittleEndian, uint32(len(s)))
+	p.WriteString(s)
+	return p
+}
+
+// int writes BININT1 for small values, BININT, a signed 32 bit int, for larger ones and LONG1 for anything else
+func (p *pickler) int(n int) *pickler {
+	if n >= 0 && n < 256 {
+		return p.op('K', byte(n))
+	}
+	if n < math.MinInt32 || n > math.MaxInt32 {
+		p.op(0x8a, 8)
+		binary.Write(p, binary.LittleEndian, int64(n))
+		return p
+	}
+	p.WriteByte('J')
+	binary.Write(p, binary.LittleEndian, int32(n))
+	return p
+}
+
+// tuple writes a MARK, the items written by fn and a TUPLE
+func (p *pickler) tuple(fn func()) *pickler {
+	p.op('(')
+	fn()
+	return p.op('t')
+}
+
+// tensor writes a call to _rebuild_tensor_v2 for a view of a storage
+func (p *pickler) tensor(storageType, key string, numel, offset int, size, stride []int) *pickler {
+	p.global("torch._utils", "_rebuild_tensor_v2")
+	p.tuple(func() {
+		p.tuple(func() { p.str("storage").global("torch", storageType).str(key).str("cpu").int(numel) }).op('Q')
+		p.int(offset)
+		p.tuple(func() {
+			for _, n := range size {
+				p.int(n)
+			}
+		})
+		p.tuple(func() {
+			for _, n := range stride {
+				p.int(n)
+			}
+		})
+		p.op(0x89) // requires_grad False
+		p.global("collections", "OrderedDict").op(')', 'R')
+	})
+	return p.op('R')
+}
+
+// builds a torch.save archive from a pickle and its storages
+func torchArchive(t *testing.T, pickle []byte, storages map[string][]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	files := map[string][]byte{"archive/data.pkl": pickle, "archive/byteorder": []byte("little"), "archive/version": []byte("3\n")}
+	for key, data := range storages {
+		files["archive/data/"+key] = data
+	}
+	for name, data := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		f.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func readTorch(archive []byte) (map[string]*tensor.Tensor, error) {
+	return ReadPyTorch(bytes.NewReader(archive), int64(len(archive)))
+}
+
+func float32Bytes(values ...float32) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, values)
+	return buf.Bytes()
+}
+
+func Test_LoadPyTorchStateDict(t *testing.T) {
+
+	// torch.save(nn.Linear(2, 1).state_dict()), the state dict's _metadata attribute is set with BUILD at the end
+	var p pickler
+	p.op(0x80, 2).global("collections", "OrderedDict").op('q', 0, ')', 'R', 'q', 1, '(')
+	p.str("weight").tensor("FloatStorage", "0", 2, 0, []int{1, 2}, []int{2, 1})
+	p.str("bias").tensor("FloatStorage", "1", 1, 0, []int{1}, []int{1})
+	p.op('u', '}').str("_metadata").global("collections", "OrderedDict").op(')', 'R', 's', 'b', '.')
+
+	archive := torchArchive(t, p.Bytes(), map[string][]byte{"0": float32Bytes(0.5, -1.5), "1": float32Bytes(0.25)})
+
+	tensors, err := readTorch(archive)
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+
+	expected := map[string]*tensor.Tensor{
+		"weight": {Data: []float64{0.5, -1.5}, Shape: []int{1, 2}},
+		"bias":   {Data: []float64{0.25}, Shape: []int{1}},
+	}
+	if !reflect.DeepEqual(tensors, expected) {
+		t.Fatalf("Expected %v, got %v", expected, tensors)
+	}
+
+	linear := &model.Linear{Weights: []float64{0, 0}, Biases: []float64{0}}
+	if _, err := model.LoadStateDict(linear, model.StateDictFromTensors(tensors, ""), true); err != nil {
+		t.Fatalf("Failed to load into a Linear: %v", err)
+	}
+	if !reflect.DeepEqual(linear.Weights, []float64{0.5, -1.5}) || linear.Biases[0] != 0.25 {
+		t.Errorf("Unexpected weights %v and bias %v", linear.Weights, linear.Biases)
+	}
+}
+
+func Test_LoadPyTorchViewsAndNesting(t *testing.T) {
+
+	// {"model": {"w": x.t(), "w_row": x[1]}, "epoch": 3, "history": [p]} written with protocol 4 opcodes, where x is a
+	// 2x3 double tensor, both views share its storage, and p is an nn.Parameter with a long storage
+	var p pickler
+	p.op(0x80, 4, 0x95, 0, 0, 0, 0, 0, 0, 0, 0, '}', 0x94, '(')
+	p.str("model").op('}', 0x94, '(')
+	p.str("w").tensor("DoubleStorage", "0", 6, 0, []int{3, 2}, []int{1, 3})
+	p.str("w_row").tensor("DoubleStorage", "0", 6, 3, []int{3}, []int{1})
+	p.op('u')
+	p.str("epoch").int(3)
+	p.str("history").op(']', 0x94)
+	p.global("torch._utils", "_rebuild_parameter")
+	p.tuple(func() {
+		p.tensor("LongStorage", "1", 2, 0, []int{2}, []int{1})
+		p.op(0x88).global("collections", "OrderedDict").op(')', 'R')
+	}).op('R', 'a')
+	p.op('u', '.')
+
+	var storage bytes.Buffer
+	binary.Write(&storage, binary.LittleEndian, []float64{1, 2, 3, 4, 5, 6})
+	var longs bytes.Buffer
+	binary.Write(&longs, binary.LittleEndian, []int64{-1, 1 << 40})
+
+	tensors, err := readTorch(torchArchive(t, p.Bytes(), map[string][]byte{"0": storage.Bytes(), "1": longs.Bytes()}))
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+
+	expected := map[string]*tensor.Tensor{
+		"model.w":     {Data: []float64{1, 4, 2, 5, 3, 6}, Shape: []int{3, 2}},
+		"model.w_row": {Data: []float64{4, 5, 6}, Shape: []int{3}},
+		"history.0":   {Data: []float64{-1, 1 << 40}, Shape: []int{2}},
+	}
+	if !reflect.DeepEqual(tensors, expected) {
+		t.Errorf("Expected %v, got %v", expected, tensors)
+	}
+
+	state := model.StateDictFromTensors(tensors, "model.")
+	if !reflect.DeepEqual(state.Keys(), []string{"w", "w_row"}) {
+		t.Errorf("Expected only the model entries with the prefix removed, got %v", state.Keys())
+	}
+}
+
+func Test_LoadPyTorchExpandedView(t *testing.T) {
+
+	// torch.tensor([1., 2.]).expand(3, 2) repeats its storage through a stride of 0, so it has more elements than
+	// the storage holds
+	var p pickler
+	p.op(0x80, 2).tensor("DoubleStorage", "0", 2, 0, []int{3, 2}, []int{0, 1}).op('.')
+
+	var storage bytes.Buffer
+	binary.Write(&storage, binary.LittleEndian, []float64{1, 2})
+
+	tensors, err := readTorch(torchArchive(t, p.Bytes(), map[string][]byte{"0": storage.Bytes()}))
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+
+	expected := &tensor.Tensor{Data: []float64{1, 2, 1, 2, 1, 2}, Shape: []int{3, 2}}
+	if !reflect.DeepEqual(tensors[""], expected) {
+		t.Errorf("Expected %v, got %v", expected, tensors)
+	}
+}
+
+func Test_LoadPyTorchHalfPrecision(t *testing.T) {
+
+	var p pickler
+	p.op(0x80, 2).tensor("HalfStorage", "0", 2, 0, []int{2}, []int{1}).op('.')
+
+	var storage bytes.Buffer
+	binary.Write(&storage, binary.LittleEndian, []uint16{0x3555, 0xfc00}) // about 1/3 and -inf
+
+	tensors, err := readTorch(torchArchive(t, p.Bytes(), map[string][]byte{"0": storage.Bytes()}))
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+
+	if data := tensors[""].Data; math.Abs(data[0]-1.0/3) > 1e-3 || !math.IsInf(data[1], -1) {
+		t.Errorf("Expected about 1/3 and -inf, got %v", data)
+	}
+}
+
+func Test_LoadPyTorchErrors(t *testing.T) {
+
+	var module pickler
+	module.op(0x80, 2).global("torch.nn.modules.linear", "Linear").op(')', 'R', '.')
+
+	var outOfRange pickler
+	outOfRange.op(0x80, 2).tensor("FloatStorage", "0", 1, 0, []int{2}, []int{1}).op('.')
+
+	var missing pickler
+	missing.op(0x80, 2).tensor("FloatStorage", "7", 1, 0, []int{1}, []int{1}).op('.')
+
+	var opcode pickler
+	opcode.op(0x80, 2, 'i', '.')
+
+	var negativeOffset pickler
+	negativeOffset.op(0x80, 2).tensor("DoubleStorage", "0", 2, -1, []int{1}, []int{1}).op('.')
+
+	var hugeSize pickler
+	hugeSize.op(0x80, 2).tensor("DoubleStorage", "0", 2, 0, []int{1 << 30, 1 << 30, 1 << 30}, []int{1, 1, 1}).op('.')
+
+	var wrappingSize pickler
+	wrappingSize.op(0x80, 2).tensor("DoubleStorage", "0", 2, 0, []int{1 << 62, 4}, []int{0, 0}).op('.')
+
+	var hugeStride pickler
+	hugeStride.op(0x80, 2).tensor("DoubleStorage", "0", 2, 0, []int{3}, []int{1 << 62}).op('.')
+
+	var offsetPastEnd pickler
+	offsetPastEnd.op(0x80, 2).tensor("DoubleStorage", "0", 2, 2, []int{2}, []int{0}).op('.')
+
+	tests := map[string]struct {
+		archive []byte
+		message string
+	}{
+		"pickled module":  {torchArchive(t, module.Bytes(), nil), "torch.nn.modules.linear.Linear"},
+		"view past end":   {torchArchive(t, outOfRange.Bytes(), map[string][]byte{"0": float32Bytes(1, 2)}), "storage"},
+		"missing storage": {torchArchive(t, missing.Bytes(), nil), `storage "7"`},
+		"unknown opcode":  {torchArchive(t, opcode.Bytes(), nil), "opcode"},
+		"negative offset": {torchArchive(t, negativeOffset.Bytes(), map[string][]byte{"0": make([]byte, 16)}), "offset -1"},
+		"huge size":       {torchArchive(t, hugeSize.Bytes(), map[string][]byte{"0": make([]byte, 16)}), "too large"},
+		"wrapping size":   {torchArchive(t, wrappingSize.Bytes(), map[string][]byte{"0": make([]byte, 16)}), "too large"},
+		"huge stride":     {torchArchive(t, hugeStride.Bytes(), map[string][]byte{"0": make([]byte, 16)}), "past the end"},
+		"offset past end": {torchArchive(t, offsetPastEnd.Bytes(), map[string][]byte{"0": make([]byte, 16)}), "offset 2"},
+		"legacy format":   {[]byte("\x80\x02\x8a\x0al\xfc\x9cF\xf9 j\xa8P\x19."), "zip"},
+	}
+
+	for name, test := range tests {
+		_, err := readTorch(test.archive)
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected an error mentioning %q for %s, got %v", test.message, name, err)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package data
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"gotorch/tensor"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+/*
+Safetensors is the weight format used across the Hugging Face ecosystem. A file is an 8 byte little endian header length,
+a JSON header mapping each tensor name to its dtype, shape and byte range, and then the raw little endian tensor data.
+An optional "__metadata__" entry in the header holds free form string pairs.
+See https://github.com/huggingface/safetensors
+*/
+
+// the header key holding the metadata rather than a tensor
+const safetensorsMetadataKey = "__metadata__"
+
+// headers larger than this are rejected rather than read into memory, the same limit the reference implementation uses
+const maxSafetensorsHeader = 100_000_000
+
+var safetensorsTypes = map[string]elementType{
+	"F64":  {order: binary.LittleEndian, kind: 'f', size: 8},
+	"F32":  {order: binary.LittleEndian, kind: 'f', size: 4},
+	"F16":  {order: binary.LittleEndian, kind: 'f', size: 2},
+	"BF16": {order: binary.LittleEndian, kind: 'B', size: 2},
+	"I64":  {order: binary.LittleEndian, kind: 'i', size: 8},
+	"I32":  {order: binary.LittleEndian, kind: 'i', size: 4},
+	"I16":  {order: binary.LittleEndian, kind: 'i', size: 2},
+	"I8":   {order: binary.LittleEndian, kind: 'i', size: 1},
+	"U64":  {order: binary.LittleEndian, kind: 'u', size: 8},
+	"U32":  {order: binary.LittleEndian, kind: 'u', size: 4},
+	"U16":  {order: binary.LittleEndian, kind: 'u', size: 2},
+	"U8":   {order: binary.LittleEndian, kind: 'u', size: 1},
+	"BOOL": {order: binary.LittleEndian, kind: 'b', size: 1},
+}
+
+// TensorInfo is a tensor's entry in a safetensors header, DataOffsets are relative to the end of the header
+type TensorInfo struct {
+	DType       string   `json:"dtype"`
+	Shape       []int    `json:"shape"`
+	DataOffsets [2]int64 `json:"data_offsets"`
+}
+
+// SafetensorsOptions configures how tensors are written
+type SafetensorsOptions struct {
+	// DType is the type every tensor is stored as unless DTypes names another one for it, it defaults to "F64"
+	// supported types are F64, F32, F16, BF16, I64, I32, I16, I8, U64, U32, U16, U8 and BOOL
+	DType  string
+	DTypes map[string]string
+	// Metadata is stored in the header as is
+	Metadata map[string]string
+}
+
+// SafetensorsFile gives access to the tensors in a safetensors file without reading them all into memory
+// on unix systems the file is memory mapped, elsewhere each tensor is read from the file when it's asked for
+type SafetensorsFile struct {
+	tensors  map[string]TensorInfo
+	metadata map[string]string
+	data     io.ReaderAt
+	offset   int64
+	close    func() error
+	closed   bool
+}
+
+// OpenSafetensors reads the header of a safetensors file, the tensors themselves are only read by Tensor
+// the returned file must be closed
+func OpenSafetensors(filePath string) (*SafetensorsFile, error) {
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	var data io.ReaderAt = file
+	closeFile := file.Close
+
+	if mapped, unmap, err := mapFile(file, stat.Size()); err == nil {
+		// the mapping stays valid once the file is closed
+		file.Close()
+		data, closeFile = bytes.NewReader(mapped), unmap
+	}
+
+	f, err := newSafetensorsFile(data, stat.Size())
+	if err != nil {
+		closeFile()
+		return nil, err
+	}
+	f.close = closeFile
+
+	return f, nil
+}
+
+// parses and checks the header of size bytes of safetensors data
+func newSafetensorsFile(data io.ReaderAt, size int64) (*SafetensorsFile, error) {
+
+	var prefix [8]byte
+	if _, err := data.ReadAt(prefix[:], 0); err != nil {
+		return nil, fmt.Errorf("unable to read safetensors header length: %w", err)
+	}
+
+	headerLen := binary.LittleEndian.Uint64(prefix[:])
+	if headerLen > maxSafetensorsHeader || int64(headerLen) > size-8 {
+		return nil, fmt.Errorf("invalid safetensors header length %d for a %d byte file", headerLen, size)
+	}
+
+	header := make([]byte, headerLen)
+	if _, err := data.ReadAt(header, 8); err != nil {
+		return nil, fmt.Errorf("unable to read safetensors header: %w", err)
+	}
+
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(header, &entries); err != nil {
+		return nil, fmt.Errorf("invalid safetensors header: %w", err)
+	}
+
+	f := &SafetensorsFile{tensors: map[string]TensorInfo{}, data: data, offset: 8 + int64(headerLen), close: func() error { return nil }}
+	dataLen := size - f.offset
+
+	for name, entry := range entries {
+		if name == safetensorsMetadataKey {
+			if err := json.Unmarshal(entry, &f.metadata); err != nil {
+				return nil, fmt.Errorf("invalid safetensors metadata: %w", err)
+			}
+			continue
+		}
+
+		var info TensorInfo
+		if err := json.Unmarshal(entry, &info); err != nil {
+			return nil, fmt.Errorf("invalid header entry for tensor %q: %w", name, err)
+		}
+
+		dtype, ok := safetensorsTypes[info.DType]
+		if !ok {
+			return nil, fmt.Errorf("tensor %q has unsupported dtype %q", name, info.DType)
+		}
+
+		elements := int64(1)
+		for _, n := range info.Shape {
+			if n < 0 {
+				return nil, fmt.Errorf("tensor %q has invalid shape %v", name, info.Shape)
+			}
+			elements *= int64(n)
+		}
+
+		begin, end := info.DataOffsets[0], info.DataOffsets[1]
+		if begin < 0 || end < begin || end > dataLen {
+			return nil, fmt.Errorf("tensor %q has data offsets %v outside the %d bytes of data", name, info.DataOffsets, dataLen)
+		}
+		if end-begin != elements*int64(dtype.size) {
+			return nil, fmt.Errorf("tensor %q with shape %v and dtype %s needs %d bytes but has %d", name, info.Shape, info.DType, elements*int64(dtype.size), end-begin)
+		}
+
+		f.tensors[name] = info
+	}
+
+	return f, nil
+}
+
+// Names returns the names of every tensor in the file in sorted order
+func (f *SafetensorsFile) Names() []string {
+	names := make([]string, 0, len(f.tensors))
+	for name := range f.tensors {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Info returns the header entry for a tensor
+func (f *SafetensorsFile) Info(name string) (TensorInfo, bool) {
+	info, ok := f.tensors[name]
+	return info, ok
+}
+
+// Metadata returns the free form metadata stored in the header, it's nil if there was none
+func (f *SafetensorsFile) Metadata() map[string]string {
+	return f.metadata
+}
+
+// Tensor reads a single tensor and converts it to float64, the result doesn't share memory with the file
+func (f *SafetensorsFile) Tensor(name string) (*tensor.Tensor, error) {
+
+	// once closed the mapping is gone and reading it would fault
+	if f.closed {
+		return nil, fmt.Errorf("unable to read tensor %q: safetensors file is closed", name)
+	}
+
+	info, ok := f.tensors[name]
+	if !ok {
+		return nil, fmt.Errorf("tensor %q not found", name)
+	}
+	dtype := safetensorsTypes[info.DType]
+
+	// a tensor without elements has nothing to read, and may sit at the very end of the file where a bytes.Reader
+	// returns io.EOF even for an empty read
+	raw := make([]byte, info.DataOffsets[1]-info.DataOffsets[0])
+	if len(raw) > 0 {
+		if _, err := f.data.ReadAt(raw, f.offset+info.DataOffsets[0]); err != nil {
+			return nil, fmt.Errorf("unable to read tensor %q: %w", name, err)
+		}
+	}
+
+	data := make([]float64, len(raw)/dtype.size)
+	for i := range data {
+		data[i] = dtype.decode(raw[i*dtype.size:])
+	}
+
+	return &tensor.Tensor{Data: data, Shape: slices.Clone(info.Shape)}, nil
+}
+
+// Tensors reads every tensor in the file
+func (f *SafetensorsFile) Tensors() (map[string]*tensor.Tensor, error) {
+
+	if f.closed {
+		return nil, fmt.Errorf("unable to read tensors: safetensors file is closed")
+	}
+
+	tensors := make(map[string]*tensor.Tensor, len(f.tensors))
+	for name := range f.tensors {
+		t, err := f.Tensor(name)
+		if err != nil {
+			return nil, err
+		}
+		tensors[name] = t
+	}
+
+	return tensors, nil
+}
+
+// Close releases the file or its memory mapping, tensors that have already been read stay valid
+// closing more than once does nothing
+func (f *SafetensorsFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed, f.data = true, nil
+	return f.close()
+}
+
+// LoadSafetensors reads every tensor in a safetensors file along with its metadata
+func LoadSafetensors(filePath string) (map[string]*tensor.Tensor, map[string]string, error) {
+
+	f, err := OpenSafetensors(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	tensors, err := f.Tensors()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tensors, f.Metadata(), nil
+}
+
+// ReadSafetensors reads every tensor and the metadata from a stream holding a whole safetensors file
+func ReadSafetensors(r io.Reader) (map[string]*tensor.Tensor, map[string]string, error) {
+
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := newSafetensorsFile(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tensors, err := f.Tensors()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tensors, f.Metadata(), nil
+}
+
+// SaveSafetensors saves named tensors as F64
+func SaveSafetensors(tensors map[string]*tensor.Tensor, filePath string) error {
+	return SaveSafetensorsWithOptions(tensors, filePath, SafetensorsOptions{})
+}
+
+// SaveSafetensorsWithOptions saves named tensors with the given dtypes and metadata, everything is encoded before the file is created
+func SaveSafetensorsWithOptions(tensors map[string]*tensor.Tensor, filePath string, opts SafetensorsOptions) error {
+
+	encoded, err := encodeSafetensors(tensors, opts)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filePath, encoded, 0o644)
+}
+
+// WriteSafetensors writes named tensors in safetensors format
+func WriteSafetensors(w io.Writer, tensors map[string]*tensor.Tensor, opts SafetensorsOptions) error {
+
+	encoded, err := encodeSafetensors(tensors, opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(encoded)
+	return err
+}
+
+func encodeSafetensors(tensors map[string]*tensor.Tensor, opts SafetensorsOptions) ([]byte, error) {
+
+	names := make([]string, 0, len(tensors))
+	for name := range tensors {
+		if name == safetensorsMetadataKey {
+			return nil, fmt.Errorf("%q is reserved for metadata and can't be used as a tensor name", name)
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for name := range opts.DTypes {
+		if _, ok := tensors[name]; !ok {
+			return nil, fmt.Errorf("dtype given for unknown tensor %q", name)
+		}
+	}
+
+	header := map[string]any{}
+	if opts.Metadata != nil {
+		header[safetensorsMetadataKey] = opts.Metadata
+	}
+
+	// the tensors are laid out back to back in name order
+	var data []byte
+	for _, name := range names {
+		t := tensors[name]
+
+		descr := opts.DTypes[name]
+		if descr == "" {
+			descr = opts.DType
+		}
+		if descr == "" {
+			descr = "F64"
+		}
+		dtype, ok := safetensorsTypes[descr]
+		if !ok {
+			return nil, fmt.Errorf("unsupported dtype %q for tensor %q", descr, name)
+		}
+
+		size := 1
+		for _, n := range t.Shape {
+			size *= n
+		}
+		if size != len(t.Data) {
+			return nil, fmt.Errorf("tensor %q has shape %v but %d values", name, t.Shape, len(t.Data))
+		}
+
+		begin := len(data)
+		data = append(data, make([]byte, size*dtype.size)...)
+		for i, v := range t.Data {
+			if err := dtype.encode(data[begin+i*dtype.size:], v); err != nil {
+				return nil, fmt.Errorf("unable to encode tensor %q as %s: %w", name, descr, err)
+			}
+		}
+
+		// a scalar still needs an empty shape list rather than null
+		shape := t.Shape
+		if shape == nil {
+			shape = []int{}
+		}
+		header[name] = TensorInfo{DType: descr, Shape: shape, DataOffsets: [2]int64{int64(begin), int64(len(data))}}
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	// pad the header with spaces so the data starts 8 byte aligned
+	headerJSON = append(headerJSON, strings.Repeat(" ", (8-len(headerJSON)%8)%8)...)
+
+	var buf bytes.Buffer
+	buf.Grow(8 + len(headerJSON) + len(data))
+	binary.Write(&buf, binary.LittleEndian, uint64(len(headerJSON)))
+	buf.Write(headerJSON)
+	buf.Write(data)
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,210 @@
+package data
+
+import (
+	"bytes"
+	"encoding/binary"
+	"gotorch/tensor"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// builds a safetensors file from a header and data by hand
+func rawSafetensors(header string, data []byte) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint64(len(header)))
+	buf.WriteString(header)
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+func Test_ReadSafetensors(t *testing.T) {
+
+	var data bytes.Buffer
+	binary.Write(&data, binary.LittleEndian, []float32{1, 2, 3, 4})
+	binary.Write(&data, binary.LittleEndian, []int64{-7})
+	binary.Write(&data, binary.LittleEndian, []uint16{0x3c00, 0xc000}) // float16 1 and -2
+	binary.Write(&data, binary.LittleEndian, []uint16{0x3fc0})         // bfloat16 1.5
+
+	header := `{"__metadata__":{"format":"pt"},` +
+		`"weight":{"dtype":"F32","shape":[2,2],"data_offsets":[0,16]},` +
+		`"step":{"dtype":"I64","shape":[],"data_offsets":[16,24]},` +
+		`"half":{"dtype":"F16","shape":[2],"data_offsets":[24,28]},` +
+		`"brain":{"dtype":"BF16","shape":[1],"data_offsets":[28,30]}}`
+
+	tensors, metadata, err := ReadSafetensors(bytes.NewReader(rawSafetensors(header, data.Bytes())))
+	if err != nil {
+		t.Fatalf("Failed to read safetensors: %v", err)
+	}
+
+	if metadata["format"] != "pt" {
+		t.Errorf("Expected format metadata pt, got %v", metadata)
+	}
+
+	expected := map[string]*tensor.Tensor{
+		"weight": {Data: []float64{1, 2, 3, 4}, Shape: []int{2, 2}},
+		"step":   {Data: []float64{-7}, Shape: []int{}},
+		"half":   {Data: []float64{1, -2}, Shape: []int{2}},
+		"brain":  {Data: []float64{1.5}, Shape: []int{1}},
+	}
+	if !reflect.DeepEqual(tensors, expected) {
+		t.Errorf("Expected %v, got %v", expected, tensors)
+	}
+}
+
+func Test_ReadSafetensorsErrors(t *testing.T) {
+
+	tests := map[string][]byte{
+		"header too long":  rawSafetensors(`{}`, nil)[:9],
+		"invalid json":     rawSafetensors(`{"a":`, nil),
+		"unknown dtype":    rawSafetensors(`{"a":{"dtype":"C64","shape":[1],"data_offsets":[0,8]}}`, make([]byte, 8)),
+		"out of range":     rawSafetensors(`{"a":{"dtype":"F32","shape":[1],"data_offsets":[0,4]}}`, make([]byte, 2)),
+		"wrong byte count": rawSafetensors(`{"a":{"dtype":"F32","shape":[2],"data_offsets":[0,4]}}`, make([]byte, 4)),
+	}
+
+	for name, raw := range tests {
+		if _, _, err := ReadSafetensors(bytes.NewReader(raw)); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
+
+func Test_SafetensorsEmptyTensorAtEnd(t *testing.T) {
+
+	// b has no elements and its offsets are the end of the data, so reading it must not touch the data at all
+	var data bytes.Buffer
+	binary.Write(&data, binary.LittleEndian, []float32{1, 2})
+	header := `{"a":{"dtype":"F32","shape":[2],"data_offsets":[0,8]},"b":{"dtype":"F32","shape":[0,3],"data_offsets":[8,8]}}`
+	raw := rawSafetensors(header, data.Bytes())
+
+	expected := map[string]*tensor.Tensor{
+		"a": {Data: []float64{1, 2}, Shape: []int{2}},
+		"b": {Data: []float64{}, Shape: []int{0, 3}},
+	}
+
+	tensors, _, err := ReadSafetensors(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Failed to read safetensors: %v", err)
+	}
+	if !reflect.DeepEqual(tensors, expected) {
+		t.Errorf("Expected %v, got %v", expected, tensors)
+	}
+
+	path := filepath.Join(t.TempDir(), "empty.safetensors")
+	if err := os.WriteFile(path, raw, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	f, err := OpenSafetensors(path)
+	if err != nil {
+		t.Fatalf("Failed to open safetensors: %v", err)
+	}
+	b, err := f.Tensor("b")
+	f.Close()
+	if err != nil || !reflect.DeepEqual(b, expected["b"]) {
+		t.Errorf("Expected %v, got %v and %v", expected["b"], b, err)
+	}
+
+	loaded, _, err := LoadSafetensors(path)
+	if err != nil {
+		t.Fatalf("Failed to load safetensors: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, expected) {
+		t.Errorf("Expected %v, got %v", expected, loaded)
+	}
+}
+
+func Test_SafetensorsRoundTrip(t *testing.T) {
+
+	tensors := map[string]*tensor.Tensor{
+		"linear.weight": tensor.NewTensor([][]float64{{0.5, -1.25}, {3, 1e-3}}),
+		"linear.bias":   tensor.NewTensor([]float64{2}),
+		"counts":        tensor.NewTensor([]float64{0, 1, 2}),
+	}
+	opts := SafetensorsOptions{
+		DType:    "F32",
+		DTypes:   map[string]string{"counts": "U8", "linear.bias": "F64"},
+		Metadata: map[string]string{"format": "pt", "epoch": "3"},
+	}
+
+	path := filepath.Join(t.TempDir(), "model.safetensors")
+	if err := SaveSafetensorsWithOptions(tensors, path, opts); err != nil {
+		t.Fatalf("Failed to save safetensors: %v", err)
+	}
+
+	f, err := OpenSafetensors(path)
+	if err != nil {
+		t.Fatalf("Failed to open safetensors: %v", err)
+	}
+
+	if !reflect.DeepEqual(f.Names(), []string{"counts", "linear.bias", "linear.weight"}) {
+		t.Errorf("Unexpected names %v", f.Names())
+	}
+	if !reflect.DeepEqual(f.Metadata(), opts.Metadata) {
+		t.Errorf("Expected metadata %v, got %v", opts.Metadata, f.Metadata())
+	}
+	if info, _ := f.Info("counts"); info.DType != "U8" || info.DataOffsets != [2]int64{0, 3} {
+		t.Errorf("Unexpected info for counts %+v", info)
+	}
+
+	weight, err := f.Tensor("linear.weight")
+	if err != nil {
+		t.Fatalf("Failed to read weight: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("Expected closing twice to succeed, got %v", err)
+	}
+	if _, err := f.Tensor("linear.weight"); err == nil {
+		t.Errorf("Expected an error reading a tensor after close")
+	}
+	if _, err := f.Tensors(); err == nil {
+		t.Errorf("Expected an error reading tensors after close")
+	}
+
+	// the weight was stored as F32, so it only matches to single precision
+	for i, v := range tensors["linear.weight"].Data {
+		if math.Abs(weight.Data[i]-v) > 1e-6 {
+			t.Errorf("Expected weight %v, got %v", v, weight.Data[i])
+		}
+	}
+
+	loaded, metadata, err := LoadSafetensors(path)
+	if err != nil {
+		t.Fatalf("Failed to load safetensors: %v", err)
+	}
+	if !reflect.DeepEqual(loaded["counts"], tensors["counts"]) || !reflect.DeepEqual(loaded["linear.bias"], tensors["linear.bias"]) {
+		t.Errorf("Expected exact round trips for counts and bias, got %v and %v", loaded["counts"], loaded["linear.bias"])
+	}
+	if metadata["epoch"] != "3" {
+		t.Errorf("Expected epoch metadata 3, got %v", metadata)
+	}
+
+	raw, _ := os.ReadFile(path)
+	if headerLen := binary.LittleEndian.Uint64(raw); headerLen%8 != 0 {
+		t.Errorf("Expected the header to be padded to 8 bytes, got %d", headerLen)
+	}
+}
+
+func Test_SaveSafetensorsErrors(t *testing.T) {
+
+	var buf bytes.Buffer
+	tensors := map[string]*tensor.Tensor{"a": tensor.NewTensor([]float64{1.5})}
+
+	if err := WriteSafetensors(&buf, tensors, SafetensorsOptions{DType: "I32"}); err == nil {
+		t.Errorf("Expected an error writing a fraction as an integer")
+	}
+	if err := WriteSafetensors(&buf, tensors, SafetensorsOptions{DType: "F8"}); err == nil {
+		t.Errorf("Expected an error for an unsupported dtype")
+	}
+	if err := WriteSafetensors(&buf, tensors, SafetensorsOptions{DTypes: map[string]string{"b": "F32"}}); err == nil {
+		t.Errorf("Expected an error for a dtype given for a missing tensor")
+	}
+	if err := WriteSafetensors(&buf, map[string]*tensor.Tensor{"__metadata__": tensor.NewTensor(1.0)}, SafetensorsOptions{}); err == nil {
+		t.Errorf("Expected an error for a reserved tensor name")
+	}
+}
@@ -0,0 +1,223 @@
+package data
+
+import (
+	"cmp"
+	"fmt"
+	"gotorch/tensor"
+	"math"
+	"math/rand"
+	"slices"
+	"sort"
+)
+
+/*
+Samplers beyond visiting every index once. Weighted sampling draws indices in proportion to per-sample weights, which
+with BalancedWeights evens out rare classes, SubsetRandomSampler shuffles a fixed set of indices and DistributedSampler
+gives each of several workers its own share of the dataset. They're all set as the Sampler of a DataLoader.
+*/
+
+// WeightedRandomSampler draws NumSamples indices each epoch, index i with probability proportional to Weights[i]
+// with replacement an index can be drawn many times, without it every index is drawn at most once
+type WeightedRandomSampler struct {
+	Weights     []float64
+	NumSamples  int
+	Replacement bool
+	rng         *rand.Rand
+}
+
+// NewWeightedRandomSampler checks the weights and seeds the sampler, numSamples defaults to len(weights)
+func NewWeightedRandomSampler(weights []float64, numSamples int, replacement bool, seed int64) (*WeightedRandomSampler, error) {
+
+	if numSamples == 0 {
+		numSamples = len(weights)
+	}
+	if numSamples < 0 {
+		return nil, fmt.Errorf("number of samples must be positive, got %d", numSamples)
+	}
+
+	nonZero := 0
+	for i, w := range weights {
+		if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return nil, fmt.Errorf("weights must be finite and non-negative, weight %d is %v", i, w)
+		}
+		if w > 0 {
+			nonZero++
+		}
+	}
+	if nonZero == 0 {
+		return nil, fmt.Errorf("at least one weight must be positive")
+	}
+	if !replacement && numSamples > nonZero {
+		return nil, fmt.Errorf("can't draw %d samples without replacement from %d indices with positive weights", numSamples, nonZero)
+	}
+
+	return &WeightedRandomSampler{Weights: weights, NumSamples: numSamples, Replacement: replacement, rng: rand.New(rand.NewSource(seed))}, nil
+}
+
+func (s *WeightedRandomSampler) Len() int {
+	return s.NumSamples
+}
+
+func (s *WeightedRandomSampler) Indices() []int {
+
+	indices := make([]int, s.NumSamples)
+
+	if s.Replacement {
+		cumulative := make([]float64, len(s.Weights))
+		total := 0.0
+		for i, w := range s.Weights {
+			total += w
+			cumulative[i] = total
+		}
+		for i := range indices {
+			// the first index whose cumulative weight is past the draw, which always has a positive weight
+			u := s.rng.Float64() * total
+			indices[i] = sort.Search(len(cumulative), func(j int) bool { return cumulative[j] > u })
+		}
+		return indices
+	}
+
+	// Efraimidis and Spirakis: giving index i the key log(u) / w and keeping the largest keys is the same as drawing
+	// indices one at a time without replacement
+	type keyed struct {
+		index int
+		key   float64
+	}
+	keys := make([]keyed, 0, len(s.Weights))
+	for i, w := range s.Weights {
+		if w > 0 {
+			keys = append(keys, keyed{i, math.Log(1-s.rng.Float64()) / w})
+		}
+	}
+	slices.SortStableFunc(keys, func(a, b keyed) int {
+		return cmp.Compare(b.key, a.key)
+	})
+	for i := range indices {
+		indices[i] = keys[i].index
+	}
+
+	return indices
+}
+
+// BalancedWeights returns a weight for every row of labels, the inverse of how many rows share its label, so sampling
+// with them draws every class equally often. Labels are a [samples] tensor of class ids or a [samples, ...] tensor
+// such as one-hot rows, rows are the same class when all their values are equal
+func BalancedWeights(labels *tensor.Tensor) ([]float64, error) {
+
+	if len(labels.Shape) == 0 || labels.Shape[0] == 0 {
+		return nil, fmt.Errorf("expected a tensor with a label for every sample, got shape %v", labels.Shape)
+	}
+
+	n := labels.Shape[0]
+	keys := make([]string, n)
+	counts := map[string]int{}
+	for i := range keys {
+		keys[i] = fmt.Sprint(row(labels, i).Data)
+		counts[keys[i]]++
+	}
+
+	weights := make([]float64, n)
+	for i, key := range keys {
+		weights[i] = 1 / float64(counts[key])
+	}
+
+	return weights, nil
+}
+
+// NewBalancedSampler returns a sampler that draws as many samples as there are labels, with replacement, so that each
+// class makes up about the same share of every epoch
+func NewBalancedSampler(labels *tensor.Tensor, seed int64) (*WeightedRandomSampler, error) {
+
+	weights, err := BalancedWeights(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWeightedRandomSampler(weights, len(weights), true, seed)
+}
+
+// SubsetRandomSampler visits the given dataset indices in a random order that changes each epoch
+type SubsetRandomSampler struct {
+	Subset []int
+	rng    *rand.Rand
+}
+
+// NewSubsetRandomSampler returns a sampler that shuffles indices with a generator seeded by seed
+func NewSubsetRandomSampler(indices []int, seed int64) *SubsetRandomSampler {
+	return &SubsetRandomSampler{Subset: indices, rng: rand.New(rand.NewSource(seed))}
+}
+
+func (s *SubsetRandomSampler) Len() int {
+	return len(s.Subset)
+}
+
+func (s *SubsetRandomSampler) Indices() []int {
+	indices := make([]int, len(s.Subset))
+	for i, j := range s.rng.Perm(len(s.Subset)) {
+		indices[i] = s.Subset[j]
+	}
+	return indices
+}
+
+// DistributedSampler splits the indices of a dataset of N samples between NumReplicas workers, Rank picks this
+// worker's share. Every worker must use the same Seed, the shuffled order depends only on the seed and the epoch so the
+// shares never overlap. Unless DropLast is set, indices from the start are repeated so every worker gets the same
+// number, otherwise the tail that doesn't divide evenly is dropped.
+//
+// The epoch moves on each time Indices is called, which is once per DataLoader epoch, SetEpoch sets it explicitly, for
+// example when resuming training
+type DistributedSampler struct {
+	N           int
+	NumReplicas int
+	Rank        int
+	Shuffle     bool
+	Seed        int64
+	DropLast    bool
+	epoch       int
+}
+
+// NewDistributedSampler checks the rank is one of the replicas
+func NewDistributedSampler(n, numReplicas, rank int) (*DistributedSampler, error) {
+	if numReplicas <= 0 {
+		return nil, fmt.Errorf("number of replicas must be positive, got %d", numReplicas)
+	}
+	if rank < 0 || rank >= numReplicas {
+		return nil, fmt.Errorf("rank %d out of range for %d replicas", rank, numReplicas)
+	}
+	return &DistributedSampler{N: n, NumReplicas: numReplicas, Rank: rank}, nil
+}
+
+// SetEpoch sets the epoch the next call to Indices shuffles for
+func (s *DistributedSampler) SetEpoch(epoch int) {
+	s.epoch = epoch
+}
+
+func (s *DistributedSampler) Len() int {
+	if s.DropLast {
+		return s.N / s.NumReplicas
+	}
+	return (s.N + s.NumReplicas - 1) / s.NumReplicas
+}
+
+func (s *DistributedSampler) Indices() []int {
+
+	var all []int
+	if s.Shuffle {
+		all = rand.New(rand.NewSource(s.Seed + int64(s.epoch))).Perm(s.N)
+	} else {
+		all = (&SequentialSampler{N: s.N}).Indices()
+	}
+	s.epoch++
+
+	total := s.Len() * s.NumReplicas
+	for len(all) < total && len(all) > 0 {
+		all = append(all, all[:min(total-len(all), len(all))]...)
+	}
+
+	indices := make([]int, 0, s.Len())
+	for i := s.Rank; i < total; i += s.NumReplicas {
+		indices = append(indices, all[i])
+	}
+
+	return indices
+}
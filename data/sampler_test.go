@@ -0,0 +1,168 @@
+package data
+
+import (
+	"context"
+	"gotorch/tensor"
+	"math"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func Test_WeightedRandomSamplerWithReplacement(t *testing.T) {
+
+	sampler, err := NewWeightedRandomSampler([]float64{1, 0, 3}, 4000, true, 1)
+	if err != nil {
+		t.Fatalf("Failed to create sampler: %v", err)
+	}
+
+	counts := make([]int, 3)
+	for _, i := range sampler.Indices() {
+		counts[i]++
+	}
+	if counts[1] != 0 {
+		t.Errorf("Expected an index with zero weight never to be drawn, got %d draws", counts[1])
+	}
+	if ratio := float64(counts[2]) / float64(counts[0]); math.Abs(ratio-3) > 0.3 {
+		t.Errorf("Expected index 2 to be drawn about 3 times as often as index 0, got %v", counts)
+	}
+}
+
+func Test_WeightedRandomSamplerWithoutReplacement(t *testing.T) {
+
+	sampler, err := NewWeightedRandomSampler([]float64{1, 0, 1, 100}, 3, false, 1)
+	if err != nil {
+		t.Fatalf("Failed to create sampler: %v", err)
+	}
+
+	for epoch := 0; epoch < 10; epoch++ {
+		indices := sampler.Indices()
+		sorted := slices.Sorted(slices.Values(indices))
+		if !reflect.DeepEqual(sorted, []int{0, 2, 3}) {
+			t.Errorf("Expected every index with a positive weight exactly once, got %v", indices)
+		}
+	}
+
+	if _, err := NewWeightedRandomSampler([]float64{1, 0, 1}, 3, false, 1); err == nil {
+		t.Errorf("Expected an error drawing more samples than positive weights without replacement")
+	}
+	if _, err := NewWeightedRandomSampler([]float64{1, -1}, 0, true, 1); err == nil {
+		t.Errorf("Expected an error for a negative weight")
+	}
+	if _, err := NewWeightedRandomSampler([]float64{0, 0}, 0, true, 1); err == nil {
+		t.Errorf("Expected an error when every weight is zero")
+	}
+}
+
+func Test_BalancedSampler(t *testing.T) {
+
+	// 1 positive in 100
+	labels := make([]float64, 100)
+	labels[42] = 1
+
+	weights, err := BalancedWeights(tensor.NewTensor(labels))
+	if err != nil {
+		t.Fatalf("Failed to compute weights: %v", err)
+	}
+	if weights[42] != 1 || weights[0] != 1.0/99 {
+		t.Errorf("Expected weights 1 and 1/99, got %v and %v", weights[42], weights[0])
+	}
+
+	sampler, err := NewBalancedSampler(tensor.NewTensor(labels), 1)
+	if err != nil {
+		t.Fatalf("Failed to create sampler: %v", err)
+	}
+
+	positives, total := 0, 0
+	for epoch := 0; epoch < 50; epoch++ {
+		for _, i := range sampler.Indices() {
+			if labels[i] == 1 {
+				positives++
+			}
+			total++
+		}
+	}
+	if share := float64(positives) / float64(total); math.Abs(share-0.5) > 0.05 {
+		t.Errorf("Expected about half the samples to be positive, got %v", share)
+	}
+
+	// one-hot labels are grouped by row
+	weights, err = BalancedWeights(tensor.NewTensor([]float64{1, 0, 0, 1, 0, 1}, 3, 2))
+	if err != nil || !reflect.DeepEqual(weights, []float64{1, 0.5, 0.5}) {
+		t.Errorf("Expected weights [1 0.5 0.5], got %v, %v", weights, err)
+	}
+}
+
+func Test_SubsetRandomSampler(t *testing.T) {
+
+	sampler := NewSubsetRandomSampler([]int{3, 5, 7, 9}, 1)
+	if sampler.Len() != 4 {
+		t.Errorf("Expected length 4, got %d", sampler.Len())
+	}
+
+	first := sampler.Indices()
+	if sorted := slices.Sorted(slices.Values(first)); !reflect.DeepEqual(sorted, []int{3, 5, 7, 9}) {
+		t.Errorf("Expected a permutation of the subset, got %v", first)
+	}
+
+	loader := &DataLoader{Dataset: newRangeDataset(t, 10), BatchSize: 4, Sampler: NewSubsetRandomSampler([]int{1, 2}, 1)}
+	var seen []float64
+	for inputs := range loader.Batches(context.Background()) {
+		seen = append(seen, inputs.Data...)
+	}
+	if slices.Sort(seen); !reflect.DeepEqual(seen, []float64{1, 2}) {
+		t.Errorf("Expected the loader to visit only the subset, got %v", seen)
+	}
+}
+
+func Test_DistributedSampler(t *testing.T) {
+
+	var shares [][]int
+	for rank := 0; rank < 3; rank++ {
+		sampler, err := NewDistributedSampler(10, 3, rank)
+		if err != nil {
+			t.Fatalf("Failed to create sampler: %v", err)
+		}
+		sampler.Shuffle = true
+		sampler.Seed = 7
+		if sampler.Len() != 4 {
+			t.Errorf("Expected 4 indices per replica, got %d", sampler.Len())
+		}
+		shares = append(shares, sampler.Indices())
+	}
+
+	// 10 indices padded to 12 by repeating the first two
+	var all []int
+	for _, share := range shares {
+		all = append(all, share...)
+	}
+	slices.Sort(all)
+	counts := map[int]int{}
+	for _, i := range all {
+		counts[i]++
+	}
+	if len(all) != 12 || len(counts) != 10 {
+		t.Errorf("Expected the replicas to cover all 10 indices with 2 repeats, got %v", all)
+	}
+
+	// every replica reshuffles the same way for the next epoch
+	a, _ := NewDistributedSampler(10, 2, 0)
+	b, _ := NewDistributedSampler(10, 2, 1)
+	a.Shuffle, b.Shuffle = true, true
+	a.Indices()
+	b.SetEpoch(1)
+	first, second := a.Indices(), b.Indices()
+	if overlap := slices.ContainsFunc(first, func(i int) bool { return slices.Contains(second, i) }); overlap {
+		t.Errorf("Expected replicas to get disjoint shares in the same epoch, got %v and %v", first, second)
+	}
+
+	dropped, _ := NewDistributedSampler(10, 3, 2)
+	dropped.DropLast = true
+	if indices := dropped.Indices(); !reflect.DeepEqual(indices, []int{2, 5, 8}) {
+		t.Errorf("Expected [2 5 8], got %v", indices)
+	}
+
+	if _, err := NewDistributedSampler(10, 2, 2); err == nil {
+		t.Errorf("Expected an error for a rank out of range")
+	}
+}
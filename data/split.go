@@ -0,0 +1,409 @@
+package data
+
+import (
+	"fmt"
+	"gotorch/tensor"
+	"math"
+	"math/rand"
+	"slices"
+)
+
+/*
+Splitting a dataset into train, validation and test sets, and into folds for cross-validation. Splits are Subsets that
+share the underlying dataset rather than copying it. Stratified splits keep the proportion of every label the same in
+each part, they read labels with Label when the dataset implements LabelledDataset so images don't have to be decoded.
+*/
+
+// LabelledDataset is a Dataset that can return a sample's label without loading its features
+type LabelledDataset interface {
+	Dataset
+	Label(i int) (*tensor.Tensor, error)
+}
+
+// Subset is the samples of a dataset at the given indices, in that order
+type Subset struct {
+	Dataset Dataset
+	Indices []int
+}
+
+func (s *Subset) Len() int {
+	return len(s.Indices)
+}
+
+func (s *Subset) Get(i int) (Sample, error) {
+	if i < 0 || i >= s.Len() {
+		return Sample{}, fmt.Errorf("index %d out of range for subset of length %d", i, s.Len())
+	}
+	return s.Dataset.Get(s.Indices[i])
+}
+
+func (s *Subset) Label(i int) (*tensor.Tensor, error) {
+	if i < 0 || i >= s.Len() {
+		return nil, fmt.Errorf("index %d out of range for subset of length %d", i, s.Len())
+	}
+	return labelOf(s.Dataset, s.Indices[i])
+}
+
+func (d *TensorDataset) Label(i int) (*tensor.Tensor, error) {
+	if i < 0 || i >= d.Len() {
+		return nil, fmt.Errorf("index %d out of range for dataset of length %d", i, d.Len())
+	}
+	if d.Labels == nil {
+		return nil, nil
+	}
+	return row(d.Labels, i), nil
+}
+
+func (f *ImageFolder) Label(i int) (*tensor.Tensor, error) {
+	if i < 0 || i >= f.Len() {
+		return nil, fmt.Errorf("index %d out of range for dataset of length %d", i, f.Len())
+	}
+	return tensor.NewTensor(f.Samples[i].Class), nil
+}
+
+func (d *ByteImageDataset) Label(i int) (*tensor.Tensor, error) {
+	if i < 0 || i >= d.Len() {
+		return nil, fmt.Errorf("index %d out of range for dataset of length %d", i, d.Len())
+	}
+	return tensor.NewTensor(d.Labels[i]), nil
+}
+
+// labelOf returns the label of sample i, loading the whole sample if the dataset can't return labels on their own
+func labelOf(d Dataset, i int) (*tensor.Tensor, error) {
+	if labelled, ok := d.(LabelledDataset); ok {
+		return labelled.Label(i)
+	}
+	sample, err := d.Get(i)
+	if err != nil {
+		return nil, err
+	}
+	return sample.Label, nil
+}
+
+// groupByLabel returns the indices of each distinct label, groups are in order of first appearance
+func groupByLabel(d Dataset) ([][]int, error) {
+
+	var groups [][]int
+	index := map[string]int{}
+	for i := 0; i < d.Len(); i++ {
+		label, err := labelOf(d, i)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read label %d: %w", i, err)
+		}
+		if label == nil {
+			return nil, fmt.Errorf("sample %d has no label, stratifying needs a labelled dataset", i)
+		}
+
+		key := fmt.Sprint(label.Data)
+		g, ok := index[key]
+		if !ok {
+			g = len(groups)
+			index[key] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+
+	return groups, nil
+}
+
+// splitLengths turns sizes into the length of each part of n samples, like PyTorch's random_split: sizes are either
+// fractions that sum to 1, with the samples left over from rounding down handed out one at a time from the first part,
+// or lengths that sum to n
+func splitLengths(n int, sizes []float64) ([]int, error) {
+
+	if len(sizes) == 0 {
+		return nil, fmt.Errorf("no split sizes given")
+	}
+
+	var sum float64
+	fractions := true
+	for _, s := range sizes {
+		if s < 0 {
+			return nil, fmt.Errorf("split sizes can't be negative, got %v", sizes)
+		}
+		sum += s
+		fractions = fractions && s <= 1
+	}
+
+	lengths := make([]int, len(sizes))
+	if fractions && math.Abs(sum-1) < 1e-9 {
+		total := 0
+		for i, s := range sizes {
+			lengths[i] = int(math.Floor(float64(n) * s))
+			total += lengths[i]
+		}
+		for i := 0; total < n; i, total = (i+1)%len(lengths), total+1 {
+			lengths[i]++
+		}
+		return lengths, nil
+	}
+
+	total := 0
+	for i, s := range sizes {
+		if s != math.Trunc(s) {
+			return nil, fmt.Errorf("split sizes must be fractions summing to 1 or whole lengths, got %v", sizes)
+		}
+		lengths[i] = int(s)
+		total += lengths[i]
+	}
+	if total != n {
+		return nil, fmt.Errorf("split lengths %v don't add up to the %d samples in the dataset", sizes, n)
+	}
+
+	return lengths, nil
+}
+
+// cut splits indices into consecutive parts of the given lengths
+func cut(indices []int, lengths []int) [][]int {
+	parts := make([][]int, len(lengths))
+	start := 0
+	for i, length := range lengths {
+		parts[i] = indices[start : start+length]
+		start += length
+	}
+	return parts
+}
+
+// RandomSplit shuffles a dataset and splits it into non-overlapping subsets, sizes are fractions that sum to 1, such as
+// 0.8, 0.1, 0.1, or lengths that sum to the size of the dataset
+func RandomSplit(d Dataset, seed int64, sizes ...float64) ([]*Subset, error) {
+
+	lengths, err := splitLengths(d.Len(), sizes)
+	if err != nil {
+		return nil, err
+	}
+
+	indices := rand.New(rand.NewSource(seed)).Perm(d.Len())
+
+	subsets := make([]*Subset, len(lengths))
+	for i, part := range cut(indices, lengths) {
+		subsets[i] = &Subset{Dataset: d, Indices: part}
+	}
+
+	return subsets, nil
+}
+
+// StratifiedSplit is RandomSplit done label by label so every subset has close to the same label proportions as the
+// dataset. Sizes must be fractions summing to 1, each label is split by them on its own so the subset sizes can differ
+// from an unstratified split by up to one sample per label
+func StratifiedSplit(d Dataset, seed int64, sizes ...float64) ([]*Subset, error) {
+
+	var sum float64
+	for _, s := range sizes {
+		sum += s
+	}
+	if len(sizes) == 0 || math.Abs(sum-1) > 1e-9 {
+		return nil, fmt.Errorf("stratified split sizes must be fractions summing to 1, got %v", sizes)
+	}
+
+	groups, err := groupByLabel(d)
+	if err != nil {
+		return nil, err
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	subsets := make([]*Subset, len(sizes))
+	for i := range subsets {
+		subsets[i] = &Subset{Dataset: d}
+	}
+
+	for _, group := range groups {
+		rng.Shuffle(len(group), func(i, j int) { group[i], group[j] = group[j], group[i] })
+		lengths, err := splitLengths(len(group), sizes)
+		if err != nil {
+			return nil, err
+		}
+		for i, part := range cut(group, lengths) {
+			subsets[i].Indices = append(subsets[i].Indices, part...)
+		}
+	}
+
+	// mix the labels back together so a subset isn't ordered by class
+	for _, s := range subsets {
+		rng.Shuffle(len(s.Indices), func(i, j int) { s.Indices[i], s.Indices[j] = s.Indices[j], s.Indices[i] })
+	}
+
+	return subsets, nil
+}
+
+// Fold is one round of cross-validation, the indices to train on and the indices to test on, both in ascending order
+type Fold struct {
+	Train []int
+	Test  []int
+}
+
+// Loaders returns DataLoaders for the train and test parts of a fold, configured like template apart from its Dataset and Sampler
+// the test loader never shuffles or drops samples so every test sample is evaluated exactly once
+func (f Fold) Loaders(d Dataset, template DataLoader) (train, test *DataLoader) {
+
+	train = &DataLoader{
+		Dataset:        &Subset{Dataset: d, Indices: f.Train},
+		BatchSize:      template.BatchSize,
+		Shuffle:        template.Shuffle,
+		Seed:           template.Seed,
+		DropLast:       template.DropLast,
+		Collate:        template.Collate,
+		NumWorkers:     template.NumWorkers,
+		PrefetchFactor: template.PrefetchFactor,
+	}
+
+	test = &DataLoader{
+		Dataset:        &Subset{Dataset: d, Indices: f.Test},
+		BatchSize:      template.BatchSize,
+		Collate:        template.Collate,
+		NumWorkers:     template.NumWorkers,
+		PrefetchFactor: template.PrefetchFactor,
+	}
+
+	return train, test
+}
+
+// CrossValidator splits a dataset into folds
+type CrossValidator interface {
+	Split(d Dataset) ([]Fold, error)
+}
+
+// complement returns the indices below n that aren't in test, test must be sorted
+func complement(n int, test []int) []int {
+	train := make([]int, 0, n-len(test))
+	for i := 0; i < n; i++ {
+		if _, found := slices.BinarySearch(test, i); !found {
+			train = append(train, i)
+		}
+	}
+	return train
+}
+
+// foldsFromTests builds the folds of a dataset of n samples from the test indices of each fold
+func foldsFromTests(n int, tests [][]int) []Fold {
+	folds := make([]Fold, len(tests))
+	for i, test := range tests {
+		slices.Sort(test)
+		folds[i] = Fold{Train: complement(n, test), Test: test}
+	}
+	return folds
+}
+
+// KFold splits a dataset into K folds, each sample is tested exactly once. The first n % K folds have one extra sample
+// samples are taken in order unless Shuffle is set, in which case they're shuffled with Seed first
+type KFold struct {
+	K       int
+	Shuffle bool
+	Seed    int64
+}
+
+func (k KFold) Split(d Dataset) ([]Fold, error) {
+
+	n := d.Len()
+	if k.K < 2 || k.K > n {
+		return nil, fmt.Errorf("k-fold needs between 2 and %d folds, got %d", n, k.K)
+	}
+
+	indices := (&SequentialSampler{N: n}).Indices()
+	if k.Shuffle {
+		indices = rand.New(rand.NewSource(k.Seed)).Perm(n)
+	}
+
+	lengths := make([]int, k.K)
+	for i := range lengths {
+		lengths[i] = n / k.K
+		if i < n%k.K {
+			lengths[i]++
+		}
+	}
+
+	var tests [][]int
+	for _, part := range cut(indices, lengths) {
+		tests = append(tests, slices.Clone(part))
+	}
+
+	return foldsFromTests(n, tests), nil
+}
+
+// StratifiedKFold is KFold that keeps the label proportions of every fold close to the dataset's
+// each label's samples are dealt out to the folds in turn, carrying on from where the previous label stopped so fold
+// sizes differ by at most one. Every label needs at least K samples
+type StratifiedKFold struct {
+	K       int
+	Shuffle bool
+	Seed    int64
+}
+
+func (k StratifiedKFold) Split(d Dataset) ([]Fold, error) {
+
+	if k.K < 2 {
+		return nil, fmt.Errorf("stratified k-fold needs at least 2 folds, got %d", k.K)
+	}
+
+	groups, err := groupByLabel(d)
+	if err != nil {
+		return nil, err
+	}
+
+	rng := rand.New(rand.NewSource(k.Seed))
+	tests := make([][]int, k.K)
+	next := 0
+	for _, group := range groups {
+		if len(group) < k.K {
+			label, _ := labelOf(d, group[0])
+			return nil, fmt.Errorf("label %v has %d samples, fewer than the %d folds", label.Data, len(group), k.K)
+		}
+		if k.Shuffle {
+			rng.Shuffle(len(group), func(i, j int) { group[i], group[j] = group[j], group[i] })
+		}
+		for _, i := range group {
+			tests[next] = append(tests[next], i)
+			next = (next + 1) % k.K
+		}
+	}
+
+	return foldsFromTests(d.Len(), tests), nil
+}
+
+// TimeSeriesSplit makes K folds for ordered data, each fold trains on samples before its test block so the model
+// never sees the future. TestSize defaults to n / (K + 1), Gap leaves out that many samples between train and test,
+// and MaxTrainSize, if set, keeps only the most recent samples for training
+type TimeSeriesSplit struct {
+	K            int
+	TestSize     int
+	Gap          int
+	MaxTrainSize int
+}
+
+func (s TimeSeriesSplit) Split(d Dataset) ([]Fold, error) {
+
+	n := d.Len()
+	if s.K < 2 {
+		return nil, fmt.Errorf("time series split needs at least 2 folds, got %d", s.K)
+	}
+
+	testSize := s.TestSize
+	if testSize <= 0 {
+		testSize = n / (s.K + 1)
+	}
+	firstTest := n - s.K*testSize
+	if testSize == 0 || firstTest-s.Gap <= 0 {
+		return nil, fmt.Errorf("%d samples are too few for %d folds with a gap of %d", n, s.K, s.Gap)
+	}
+
+	folds := make([]Fold, s.K)
+	for i := range folds {
+		testStart := firstTest + i*testSize
+		trainEnd := testStart - s.Gap
+		trainStart := 0
+		if s.MaxTrainSize > 0 {
+			trainStart = max(trainEnd-s.MaxTrainSize, 0)
+		}
+
+		folds[i] = Fold{Train: make([]int, 0, trainEnd-trainStart), Test: make([]int, 0, testSize)}
+		for j := trainStart; j < trainEnd; j++ {
+			folds[i].Train = append(folds[i].Train, j)
+		}
+		for j := testStart; j < testStart+testSize; j++ {
+			folds[i].Test = append(folds[i].Test, j)
+		}
+	}
+
+	return folds, nil
+}
@@ -0,0 +1,223 @@
+package data
+
+import (
+	"context"
+	"gotorch/model"
+	"gotorch/tensor"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// labelledDataset returns a dataset of n samples whose feature is the index and whose label is the index mod classes
+func labelledDataset(n, classes int) *TensorDataset {
+	features, labels := make([]float64, n), make([]float64, n)
+	for i := range features {
+		features[i] = float64(i)
+		labels[i] = float64(i % classes)
+	}
+	dataset, _ := NewTensorDataset(tensor.NewTensor(features, n, 1), tensor.NewTensor(labels))
+	return dataset
+}
+
+func Test_RandomSplit(t *testing.T) {
+
+	dataset := labelledDataset(10, 2)
+
+	subsets, err := RandomSplit(dataset, 7, 0.5, 0.25, 0.25)
+	if err != nil {
+		t.Fatalf("Failed to split: %v", err)
+	}
+
+	// 10 * 0.25 rounds down to 2, the sample left over goes to the first subset
+	var lengths, all []int
+	for _, s := range subsets {
+		lengths = append(lengths, s.Len())
+		all = append(all, s.Indices...)
+	}
+	if !reflect.DeepEqual(lengths, []int{6, 2, 2}) {
+		t.Errorf("Expected lengths [6 2 2], got %v", lengths)
+	}
+	slices.Sort(all)
+	if !reflect.DeepEqual(all, (&SequentialSampler{N: 10}).Indices()) {
+		t.Errorf("Expected every index exactly once, got %v", all)
+	}
+
+	again, _ := RandomSplit(dataset, 7, 0.5, 0.25, 0.25)
+	if !reflect.DeepEqual(again[0].Indices, subsets[0].Indices) {
+		t.Errorf("Expected the same seed to give the same split")
+	}
+
+	sample, err := subsets[1].Get(0)
+	if err != nil || sample.Features.Data[0] != float64(subsets[1].Indices[0]) {
+		t.Errorf("Expected subset samples to come from the dataset, got %v (%v)", sample.Features, err)
+	}
+
+	lengthSplit, err := RandomSplit(dataset, 1, 7, 3)
+	if err != nil || lengthSplit[0].Len() != 7 || lengthSplit[1].Len() != 3 {
+		t.Errorf("Expected lengths 7 and 3, got %v", err)
+	}
+
+	for _, sizes := range [][]float64{{0.5, 0.4}, {5, 4}, {-1, 11}, {}} {
+		if _, err := RandomSplit(dataset, 1, sizes...); err == nil {
+			t.Errorf("Expected an error for sizes %v", sizes)
+		}
+	}
+}
+
+func Test_StratifiedSplit(t *testing.T) {
+
+	// 10 samples of label 0 and 20 of label 1
+	dataset := labelledDataset(30, 3)
+	for i := range dataset.Labels.Data {
+		dataset.Labels.Data[i] = min(dataset.Labels.Data[i], 1)
+	}
+
+	subsets, err := StratifiedSplit(dataset, 3, 0.8, 0.2)
+	if err != nil {
+		t.Fatalf("Failed to split: %v", err)
+	}
+
+	for i, expected := range [][2]int{{8, 16}, {2, 4}} {
+		counts := [2]int{}
+		for _, index := range subsets[i].Indices {
+			counts[int(dataset.Labels.Data[index])]++
+		}
+		if counts != expected {
+			t.Errorf("Expected subset %d to have label counts %v, got %v", i, expected, counts)
+		}
+	}
+
+	unlabelled, _ := NewTensorDataset(tensor.NewTensor([]float64{1, 2}), nil)
+	if _, err := StratifiedSplit(unlabelled, 1, 0.5, 0.5); err == nil {
+		t.Errorf("Expected an error for a dataset without labels")
+	}
+}
+
+func Test_KFold(t *testing.T) {
+
+	dataset := labelledDataset(10, 2)
+
+	for _, shuffle := range []bool{false, true} {
+		folds, err := KFold{K: 3, Shuffle: shuffle, Seed: 5}.Split(dataset)
+		if err != nil {
+			t.Fatalf("Failed to split: %v", err)
+		}
+
+		var tests []int
+		for i, fold := range folds {
+			if len(fold.Test) != []int{4, 3, 3}[i] || len(fold.Train)+len(fold.Test) != 10 {
+				t.Errorf("Unexpected fold sizes %d and %d", len(fold.Train), len(fold.Test))
+			}
+			for _, index := range fold.Test {
+				if slices.Contains(fold.Train, index) {
+					t.Errorf("Index %d is in both train and test", index)
+				}
+			}
+			tests = append(tests, fold.Test...)
+		}
+		slices.Sort(tests)
+		if !reflect.DeepEqual(tests, (&SequentialSampler{N: 10}).Indices()) {
+			t.Errorf("Expected every index to be tested once, got %v", tests)
+		}
+	}
+
+	folds, _ := KFold{K: 3}.Split(dataset)
+	if !reflect.DeepEqual(folds[1], Fold{Train: []int{0, 1, 2, 3, 7, 8, 9}, Test: []int{4, 5, 6}}) {
+		t.Errorf("Expected the second unshuffled fold to test [4 5 6], got %v", folds[1])
+	}
+
+	if _, err := (KFold{K: 11}).Split(dataset); err == nil {
+		t.Errorf("Expected an error for more folds than samples")
+	}
+}
+
+func Test_StratifiedKFold(t *testing.T) {
+
+	dataset := labelledDataset(12, 3)
+	folds, err := StratifiedKFold{K: 4, Shuffle: true, Seed: 2}.Split(dataset)
+	if err != nil {
+		t.Fatalf("Failed to split: %v", err)
+	}
+
+	for _, fold := range folds {
+		counts := map[float64]int{}
+		for _, index := range fold.Test {
+			counts[dataset.Labels.Data[index]]++
+		}
+		if len(fold.Test) != 3 || counts[0] != 1 || counts[1] != 1 || counts[2] != 1 {
+			t.Errorf("Expected one of each label in every test fold, got %v", counts)
+		}
+	}
+
+	if _, err := (StratifiedKFold{K: 5}).Split(dataset); err == nil {
+		t.Errorf("Expected an error for a label with fewer samples than folds")
+	}
+}
+
+func Test_TimeSeriesSplit(t *testing.T) {
+
+	dataset := labelledDataset(6, 1)
+	folds, err := TimeSeriesSplit{K: 5}.Split(dataset)
+	if err != nil {
+		t.Fatalf("Failed to split: %v", err)
+	}
+	if len(folds) != 5 || !reflect.DeepEqual(folds[0], Fold{Train: []int{0}, Test: []int{1}}) ||
+		!reflect.DeepEqual(folds[4], Fold{Train: []int{0, 1, 2, 3, 4}, Test: []int{5}}) {
+		t.Errorf("Unexpected folds %v", folds)
+	}
+
+	folds, err = TimeSeriesSplit{K: 2, TestSize: 2, Gap: 1, MaxTrainSize: 3}.Split(labelledDataset(10, 1))
+	if err != nil {
+		t.Fatalf("Failed to split: %v", err)
+	}
+	expected := []Fold{{Train: []int{2, 3, 4}, Test: []int{6, 7}}, {Train: []int{4, 5, 6}, Test: []int{8, 9}}}
+	if !reflect.DeepEqual(folds, expected) {
+		t.Errorf("Expected %v, got %v", expected, folds)
+	}
+
+	if _, err := (TimeSeriesSplit{K: 6}).Split(dataset); err == nil {
+		t.Errorf("Expected an error for too many folds")
+	}
+}
+
+func Test_CrossValidateFolds(t *testing.T) {
+
+	// y = 2x + 1
+	features, labels := make([]float64, 12), make([]float64, 12)
+	for i := range features {
+		features[i] = float64(i) / 12
+		labels[i] = 2*features[i] + 1
+	}
+	dataset, _ := NewTensorDataset(tensor.NewTensor(features, 12, 1), tensor.NewTensor(labels, 12, 1))
+
+	folds, err := KFold{K: 3, Shuffle: true, Seed: 1}.Split(dataset)
+	if err != nil {
+		t.Fatalf("Failed to split: %v", err)
+	}
+
+	var models []*model.Linear
+	result, err := model.CrossValidate(context.Background(), len(folds), func(fold int) (*model.Trainer, error) {
+		train, validation := folds[fold].Loaders(dataset, DataLoader{BatchSize: 4, Shuffle: true})
+		linear := &model.Linear{Weights: []float64{0}, Biases: []float64{0}, GradWeights: []float64{0}, GradBiases: []float64{0}}
+		models = append(models, linear)
+		return &model.Trainer{
+			Model:      linear,
+			Loss:       model.MSELoss,
+			Optimizer:  &model.SGD{LearningRate: 0.5},
+			Train:      train,
+			Validation: validation,
+			Epochs:     50,
+		}, nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to cross-validate: %v", err)
+	}
+
+	if len(result.Histories) != 3 || len(models) != 3 || models[0] == models[1] {
+		t.Fatalf("Expected a fresh model for each of 3 folds, got %d histories", len(result.Histories))
+	}
+	if result.Mean["val_loss"] > 1e-3 || result.Std["val_loss"] < 0 {
+		t.Errorf("Expected every fold to fit the line, got mean validation loss %v", result.Mean["val_loss"])
+	}
+}
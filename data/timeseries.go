@@ -0,0 +1,245 @@
+package data
+
+import (
+	"fmt"
+	"gotorch/tensor"
+	"math"
+	"slices"
+)
+
+/*
+Forecasting datasets cut a series, a [time, columns] tensor like the one LoadCSV returns, into overlapping windows. Each
+sample is Window steps of history and the following Horizon steps of the target columns, the next window starts Stride
+steps later. Lag and rolling statistic features are computed from past values only, so no sample ever sees the future,
+and Split keeps the windows in time order so validation always comes after training.
+*/
+
+// WindowOptions configures a SlidingWindow
+// Window is the number of history steps and Horizon, which defaults to 1, the number of future steps to predict. Stride
+// defaults to 1. FeatureColumns and TargetColumns pick columns of the series and default to all of them.
+//
+// Lags adds, for every feature column, its value that many steps before each history step, and RollingWindows adds the
+// mean and standard deviation over that many steps up to and including each history step. The derived columns follow
+// the feature columns in the order: every lag of every column, then the rolling mean and std of every column for each
+// window size. Steps at the start of the series without enough history for them are skipped.
+//
+// Flatten returns features as [Window * features] and labels as [Horizon * targets] instead of [Window, features] and
+// [Horizon, targets], which is what Linear layers expect
+type WindowOptions struct {
+	Window         int
+	Horizon        int
+	Stride         int
+	FeatureColumns []int
+	TargetColumns  []int
+	Lags           []int
+	RollingWindows []int
+	Flatten        bool
+}
+
+// SlidingWindow is a Dataset of (history, horizon) pairs from a time series
+type SlidingWindow struct {
+	features    []float64
+	targets     []float64
+	featureCols int
+	targetCols  int
+	steps       int
+	start       int
+	opts        WindowOptions
+}
+
+// NewSlidingWindow computes the features of a [time, columns] series, a 1D series is treated as a single column
+func NewSlidingWindow(series *tensor.Tensor, opts WindowOptions) (*SlidingWindow, error) {
+
+	var steps, cols int
+	switch len(series.Shape) {
+	case 1:
+		steps, cols = series.Shape[0], 1
+	case 2:
+		steps, cols = series.Shape[0], series.Shape[1]
+	default:
+		return nil, fmt.Errorf("expected a series of shape [time, columns] or [time], got %v", series.Shape)
+	}
+
+	if opts.Window <= 0 {
+		return nil, fmt.Errorf("window length must be positive, got %d", opts.Window)
+	}
+	if opts.Horizon == 0 {
+		opts.Horizon = 1
+	}
+	if opts.Stride == 0 {
+		opts.Stride = 1
+	}
+	if opts.Horizon < 0 || opts.Stride < 0 {
+		return nil, fmt.Errorf("horizon and stride must be positive, got %d and %d", opts.Horizon, opts.Stride)
+	}
+
+	featureColumns, err := windowColumns(opts.FeatureColumns, cols)
+	if err != nil {
+		return nil, fmt.Errorf("invalid feature columns: %w", err)
+	}
+	targetColumns, err := windowColumns(opts.TargetColumns, cols)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target columns: %w", err)
+	}
+
+	// the first step that has enough history for every lag and rolling window
+	start := 0
+	for _, lag := range opts.Lags {
+		if lag <= 0 {
+			return nil, fmt.Errorf("lags must be positive, got %v", opts.Lags)
+		}
+		start = max(start, lag)
+	}
+	for _, size := range opts.RollingWindows {
+		if size <= 0 {
+			return nil, fmt.Errorf("rolling windows must be positive, got %v", opts.RollingWindows)
+		}
+		start = max(start, size-1)
+	}
+
+	value := func(t, j int) float64 {
+		return series.Data[t*cols+j]
+	}
+
+	w := &SlidingWindow{
+		featureCols: len(featureColumns) * (1 + len(opts.Lags) + 2*len(opts.RollingWindows)),
+		targetCols:  len(targetColumns),
+		steps:       steps,
+		start:       start,
+		opts:        opts,
+	}
+
+	w.features = make([]float64, 0, steps*w.featureCols)
+	w.targets = make([]float64, 0, steps*w.targetCols)
+	for t := 0; t < steps; t++ {
+
+		for _, j := range targetColumns {
+			w.targets = append(w.targets, value(t, j))
+		}
+
+		// steps before start are never part of a window, their derived features are left as zeros
+		for _, j := range featureColumns {
+			w.features = append(w.features, value(t, j))
+		}
+		for _, lag := range opts.Lags {
+			for _, j := range featureColumns {
+				if t >= lag {
+					w.features = append(w.features, value(t-lag, j))
+				} else {
+					w.features = append(w.features, 0)
+				}
+			}
+		}
+		for _, size := range opts.RollingWindows {
+			for _, j := range featureColumns {
+				if t < size-1 {
+					w.features = append(w.features, 0, 0)
+					continue
+				}
+				var sum, squares float64
+				for k := t - size + 1; k <= t; k++ {
+					sum += value(k, j)
+				}
+				mean := sum / float64(size)
+				for k := t - size + 1; k <= t; k++ {
+					squares += (value(k, j) - mean) * (value(k, j) - mean)
+				}
+				w.features = append(w.features, mean, math.Sqrt(squares/float64(size)))
+			}
+		}
+	}
+
+	return w, nil
+}
+
+// windowColumns checks column indices, nil means every column
+func windowColumns(columns []int, cols int) ([]int, error) {
+	if columns == nil {
+		columns = make([]int, cols)
+		for j := range columns {
+			columns[j] = j
+		}
+		return columns, nil
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns given")
+	}
+	for _, j := range columns {
+		if j < 0 || j >= cols {
+			return nil, fmt.Errorf("column %d out of range for a series with %d columns", j, cols)
+		}
+	}
+	return columns, nil
+}
+
+// Len is the number of windows that fit in the series
+func (w *SlidingWindow) Len() int {
+	span := w.steps - w.start - w.opts.Window - w.opts.Horizon
+	if span < 0 {
+		return 0
+	}
+	return span/w.opts.Stride + 1
+}
+
+// historyStart returns the first step of window i's history, its horizon starts Window steps later
+func (w *SlidingWindow) historyStart(i int) int {
+	return w.start + i*w.opts.Stride
+}
+
+func (w *SlidingWindow) Get(i int) (Sample, error) {
+
+	if i < 0 || i >= w.Len() {
+		return Sample{}, fmt.Errorf("index %d out of range for dataset of length %d", i, w.Len())
+	}
+
+	history := w.historyStart(i)
+	horizon := history + w.opts.Window
+
+	features := slices.Clone(w.features[history*w.featureCols : horizon*w.featureCols])
+	targets := slices.Clone(w.targets[horizon*w.targetCols : (horizon+w.opts.Horizon)*w.targetCols])
+
+	if w.opts.Flatten {
+		return Sample{Features: tensor.NewTensor(features), Label: tensor.NewTensor(targets)}, nil
+	}
+
+	return Sample{
+		Features: tensor.NewTensor(features, w.opts.Window, w.featureCols),
+		Label:    tensor.NewTensor(targets, w.opts.Horizon, w.targetCols),
+	}, nil
+}
+
+// NumFeatures returns the number of feature columns in each history step, including lag and rolling features
+func (w *SlidingWindow) NumFeatures() int {
+	return w.featureCols
+}
+
+// Split divides the series into consecutive periods and returns the windows whose horizon falls inside each period,
+// in time order. Sizes are fractions of the series summing to 1, such as 0.8, 0.2, or lengths in steps summing to the
+// length of the series. A window's history may reach back into earlier periods, as it would when forecasting, but its
+// targets never cross into a later one, so windows that straddle a boundary are left out of both periods
+func (w *SlidingWindow) Split(sizes ...float64) ([]*Subset, error) {
+
+	lengths, err := splitLengths(w.steps, sizes)
+	if err != nil {
+		return nil, err
+	}
+
+	subsets := make([]*Subset, len(lengths))
+	periodStart, i := 0, 0
+	for p, length := range lengths {
+		periodEnd := periodStart + length
+		subsets[p] = &Subset{Dataset: w, Indices: []int{}}
+		for ; i < w.Len(); i++ {
+			horizon := w.historyStart(i) + w.opts.Window
+			if horizon+w.opts.Horizon > periodEnd {
+				break
+			}
+			if horizon >= periodStart {
+				subsets[p].Indices = append(subsets[p].Indices, i)
+			}
+		}
+		periodStart = periodEnd
+	}
+
+	return subsets, nil
+}
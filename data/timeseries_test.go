@@ -0,0 +1,154 @@
+package data
+
+import (
+	"context"
+	"gotorch/model"
+	"gotorch/tensor"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// a series of n steps with columns t and 10t
+func newSeries(n int) *tensor.Tensor {
+	values := make([]float64, 0, 2*n)
+	for t := 0; t < n; t++ {
+		values = append(values, float64(t), float64(10*t))
+	}
+	return tensor.NewTensor(values, n, 2)
+}
+
+func Test_SlidingWindow(t *testing.T) {
+
+	w, err := NewSlidingWindow(newSeries(10), WindowOptions{Window: 3, Horizon: 2, Stride: 2, TargetColumns: []int{1}})
+	if err != nil {
+		t.Fatalf("Failed to create dataset: %v", err)
+	}
+
+	// windows start at 0, 2, 4 and the last one's horizon ends at step 9
+	if w.Len() != 3 {
+		t.Errorf("Expected 3 windows, got %d", w.Len())
+	}
+
+	sample, err := w.Get(1)
+	if err != nil {
+		t.Fatalf("Failed to get window: %v", err)
+	}
+	if !reflect.DeepEqual(sample.Features.Shape, []int{3, 2}) || !reflect.DeepEqual(sample.Features.Data, []float64{2, 20, 3, 30, 4, 40}) {
+		t.Errorf("Expected history [[2 20] [3 30] [4 40]], got %v %v", sample.Features.Shape, sample.Features.Data)
+	}
+	if !reflect.DeepEqual(sample.Label.Shape, []int{2, 1}) || !reflect.DeepEqual(sample.Label.Data, []float64{50, 60}) {
+		t.Errorf("Expected horizon [[50] [60]], got %v %v", sample.Label.Shape, sample.Label.Data)
+	}
+
+	if _, err := w.Get(3); err == nil {
+		t.Errorf("Expected an error for an index out of range")
+	}
+}
+
+func Test_SlidingWindowLagAndRollingFeatures(t *testing.T) {
+
+	w, err := NewSlidingWindow(newSeries(6), WindowOptions{Window: 1, FeatureColumns: []int{0}, Lags: []int{1, 2}, RollingWindows: []int{3}, Flatten: true})
+	if err != nil {
+		t.Fatalf("Failed to create dataset: %v", err)
+	}
+
+	// the first two steps don't have two lags of history
+	if w.Len() != 3 || w.NumFeatures() != 5 {
+		t.Errorf("Expected 3 windows of 5 features, got %d of %d", w.Len(), w.NumFeatures())
+	}
+
+	sample, err := w.Get(0)
+	if err != nil {
+		t.Fatalf("Failed to get window: %v", err)
+	}
+
+	// value, lag 1, lag 2, mean and std of 0, 1, 2
+	expected := []float64{2, 1, 0, 1, math.Sqrt(2.0 / 3)}
+	for i := range expected {
+		if math.Abs(sample.Features.Data[i]-expected[i]) > 1e-12 {
+			t.Errorf("Expected features %v, got %v", expected, sample.Features.Data)
+			break
+		}
+	}
+	if !reflect.DeepEqual(sample.Label.Data, []float64{3, 30}) || !reflect.DeepEqual(sample.Label.Shape, []int{2}) {
+		t.Errorf("Expected flattened label [3 30], got %v %v", sample.Label.Shape, sample.Label.Data)
+	}
+}
+
+func Test_SlidingWindowErrors(t *testing.T) {
+
+	tests := map[string]WindowOptions{
+		"window":  {},
+		"column":  {Window: 2, TargetColumns: []int{2}},
+		"lag":     {Window: 2, Lags: []int{0}},
+		"rolling": {Window: 2, RollingWindows: []int{-1}},
+	}
+	for name, opts := range tests {
+		if _, err := NewSlidingWindow(newSeries(5), opts); err == nil {
+			t.Errorf("Expected an error for an invalid %s", name)
+		}
+	}
+
+	w, err := NewSlidingWindow(tensor.NewTensor([]float64{1, 2}), WindowOptions{Window: 2})
+	if err != nil {
+		t.Fatalf("Failed to create dataset: %v", err)
+	}
+	if w.Len() != 0 {
+		t.Errorf("Expected no windows in a series shorter than a window and horizon, got %d", w.Len())
+	}
+}
+
+func Test_SlidingWindowSplit(t *testing.T) {
+
+	w, err := NewSlidingWindow(newSeries(10), WindowOptions{Window: 2, Horizon: 2})
+	if err != nil {
+		t.Fatalf("Failed to create dataset: %v", err)
+	}
+
+	parts, err := w.Split(0.6, 0.4)
+	if err != nil {
+		t.Fatalf("Failed to split: %v", err)
+	}
+
+	// training targets must end by step 6, so window 3 whose horizon is steps 5 and 6 straddles the boundary
+	if !reflect.DeepEqual(parts[0].Indices, []int{0, 1, 2}) || !reflect.DeepEqual(parts[1].Indices, []int{4, 5, 6}) {
+		t.Errorf("Expected windows [0 1 2] and [4 5 6], got %v and %v", parts[0].Indices, parts[1].Indices)
+	}
+
+	if _, err := w.Split(0.5, 0.6); err == nil {
+		t.Errorf("Expected an error for sizes that don't add up")
+	}
+}
+
+func Test_SlidingWindowForecast(t *testing.T) {
+
+	// predict the next value of a line from the last two
+	w, err := NewSlidingWindow(newSeries(40), WindowOptions{Window: 2, FeatureColumns: []int{0}, TargetColumns: []int{0}, Flatten: true})
+	if err != nil {
+		t.Fatalf("Failed to create dataset: %v", err)
+	}
+	parts, err := w.Split(0.8, 0.2)
+	if err != nil {
+		t.Fatalf("Failed to split: %v", err)
+	}
+
+	trainer := &model.Trainer{
+		Model:      &model.Linear{Weights: []float64{0, 0}, Biases: []float64{0}},
+		Loss:       model.MSELoss,
+		Optimizer:  &model.SGD{LearningRate: 0.0001},
+		Train:      &DataLoader{Dataset: parts[0], BatchSize: 8},
+		Validation: &DataLoader{Dataset: parts[1], BatchSize: 8},
+		Epochs:     20,
+	}
+
+	history, err := trainer.Fit(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+
+	losses := history.Metric("val_loss")
+	if len(losses) != 20 || losses[19] >= losses[0] {
+		t.Errorf("Expected the validation loss to fall over 20 epochs, got %v", losses)
+	}
+}
@@ -0,0 +1,274 @@
+package data
+
+import (
+	"fmt"
+	"gotorch/tensor"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+/*
+Transforms change a single sample's features as it is loaded, most often to resize, crop and normalize images.
+Image transforms work on [channels, height, width] tensors like the ones ImageFolder returns and never modify their input.
+Random transforms draw from their own seeded generator so a run can be repeated, and are safe to use from DataLoader workers.
+*/
+
+// Transform changes one sample's features
+type Transform func(t *tensor.Tensor) (*tensor.Tensor, error)
+
+// Compose chains transforms, applying them in the order given
+func Compose(transforms ...Transform) Transform {
+	return func(t *tensor.Tensor) (*tensor.Tensor, error) {
+		var err error
+		for _, transform := range transforms {
+			if t, err = transform(t); err != nil {
+				return nil, err
+			}
+		}
+		return t, nil
+	}
+}
+
+// chw returns the dimensions of an image tensor
+func chw(t *tensor.Tensor) (channels, height, width int, err error) {
+	if len(t.Shape) != 3 {
+		return 0, 0, 0, fmt.Errorf("expected an image with shape [channels, height, width], got %v", t.Shape)
+	}
+	return t.Shape[0], t.Shape[1], t.Shape[2], nil
+}
+
+// Resize scales an image to height x width with bilinear interpolation, sampling at pixel centers like PyTorch does
+// without align_corners. There's no antialiasing, so shrinking an image a long way can alias
+func Resize(height, width int) Transform {
+	return func(t *tensor.Tensor) (*tensor.Tensor, error) {
+		if height <= 0 || width <= 0 {
+			return nil, fmt.Errorf("can't resize to %dx%d", height, width)
+		}
+		return resize(t, height, width)
+	}
+}
+
+// ResizeShorter scales an image so its shorter side is size, keeping the aspect ratio
+func ResizeShorter(size int) Transform {
+	return func(t *tensor.Tensor) (*tensor.Tensor, error) {
+
+		_, h, w, err := chw(t)
+		if err != nil {
+			return nil, err
+		}
+		if size <= 0 {
+			return nil, fmt.Errorf("can't resize the shorter side to %d", size)
+		}
+
+		if h <= w {
+			return resize(t, size, int(float64(size)*float64(w)/float64(h)))
+		}
+		return resize(t, int(float64(size)*float64(h)/float64(w)), size)
+	}
+}
+
+func resize(t *tensor.Tensor, height, width int) (*tensor.Tensor, error) {
+
+	channels, h, w, err := chw(t)
+	if err != nil {
+		return nil, err
+	}
+
+	// source coordinate of each output row and column, and the weight given to the next pixel along
+	sample := func(out, in int) ([]int, []float64) {
+		indices, weights := make([]int, out), make([]float64, out)
+		scale := float64(in) / float64(out)
+		for i := range indices {
+			src := math.Max((float64(i)+0.5)*scale-0.5, 0)
+			indices[i] = min(int(src), in-1)
+			weights[i] = src - float64(indices[i])
+		}
+		return indices, weights
+	}
+	ys, wy := sample(height, h)
+	xs, wx := sample(width, w)
+
+	data := make([]float64, channels*height*width)
+	for c := 0; c < channels; c++ {
+		in := t.Data[c*h*w : (c+1)*h*w]
+		out := data[c*height*width : (c+1)*height*width]
+		for i, y0 := range ys {
+			y1 := min(y0+1, h-1)
+			for j, x0 := range xs {
+				x1 := min(x0+1, w-1)
+				top := in[y0*w+x0]*(1-wx[j]) + in[y0*w+x1]*wx[j]
+				bottom := in[y1*w+x0]*(1-wx[j]) + in[y1*w+x1]*wx[j]
+				out[i*width+j] = top*(1-wy[i]) + bottom*wy[i]
+			}
+		}
+	}
+
+	return tensor.NewTensor(data, channels, height, width), nil
+}
+
+// crop cuts the height x width region with its top left corner at (top, left) out of an image
+func crop(t *tensor.Tensor, top, left, height, width int) (*tensor.Tensor, error) {
+
+	channels, h, w, err := chw(t)
+	if err != nil {
+		return nil, err
+	}
+	if height <= 0 || width <= 0 || height > h || width > w {
+		return nil, fmt.Errorf("can't crop %dx%d out of a %dx%d image", height, width, h, w)
+	}
+
+	data := make([]float64, 0, channels*height*width)
+	for c := 0; c < channels; c++ {
+		for y := top; y < top+height; y++ {
+			start := (c*h+y)*w + left
+			data = append(data, t.Data[start:start+width]...)
+		}
+	}
+
+	return tensor.NewTensor(data, channels, height, width), nil
+}
+
+// CenterCrop cuts a height x width region out of the middle of an image, which must be at least that large
+func CenterCrop(height, width int) Transform {
+	return func(t *tensor.Tensor) (*tensor.Tensor, error) {
+		_, h, w, err := chw(t)
+		if err != nil {
+			return nil, err
+		}
+		// torchvision rounds the margin half to even, so the same image crops to the same pixels
+		return crop(t, int(math.RoundToEven(float64(h-height)/2)), int(math.RoundToEven(float64(w-width)/2)), height, width)
+	}
+}
+
+// lockedRand is a random generator that can be shared between DataLoader workers
+type lockedRand struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{rng: rand.New(rand.NewSource(seed))}
+}
+
+func (r *lockedRand) Intn(n int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rng.Intn(n)
+}
+
+func (r *lockedRand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rng.Float64()
+}
+
+// RandomCrop cuts a height x width region from a random position in an image, which must be at least that large
+func RandomCrop(height, width int, seed int64) Transform {
+	rng := newLockedRand(seed)
+	return func(t *tensor.Tensor) (*tensor.Tensor, error) {
+		_, h, w, err := chw(t)
+		if err != nil {
+			return nil, err
+		}
+		if height > h || width > w {
+			return nil, fmt.Errorf("can't crop %dx%d out of a %dx%d image", height, width, h, w)
+		}
+		return crop(t, rng.Intn(h-height+1), rng.Intn(w-width+1), height, width)
+	}
+}
+
+// HorizontalFlip mirrors an image left to right
+func HorizontalFlip() Transform {
+	return func(t *tensor.Tensor) (*tensor.Tensor, error) {
+
+		channels, h, w, err := chw(t)
+		if err != nil {
+			return nil, err
+		}
+
+		data := make([]float64, len(t.Data))
+		for row := 0; row < channels*h; row++ {
+			for x := 0; x < w; x++ {
+				data[row*w+x] = t.Data[row*w+w-1-x]
+			}
+		}
+
+		return tensor.NewTensor(data, channels, h, w), nil
+	}
+}
+
+// RandomHorizontalFlip mirrors an image left to right with probability p
+func RandomHorizontalFlip(p float64, seed int64) Transform {
+	rng := newLockedRand(seed)
+	flip := HorizontalFlip()
+	return func(t *tensor.Tensor) (*tensor.Tensor, error) {
+		if rng.Float64() < p {
+			return flip(t)
+		}
+		return t, nil
+	}
+}
+
+// Normalize subtracts mean and divides by std channel by channel, they need a value for each channel of the image
+func Normalize(mean, std []float64) Transform {
+	return func(t *tensor.Tensor) (*tensor.Tensor, error) {
+
+		channels, h, w, err := chw(t)
+		if err != nil {
+			return nil, err
+		}
+		if len(mean) != channels || len(std) != channels {
+			return nil, fmt.Errorf("image has %d channels but got %d means and %d standard deviations", channels, len(mean), len(std))
+		}
+
+		plane := h * w
+		data := make([]float64, len(t.Data))
+		for c := 0; c < channels; c++ {
+			if std[c] == 0 {
+				return nil, fmt.Errorf("standard deviation of channel %d is zero", c)
+			}
+			for i := c * plane; i < (c+1)*plane; i++ {
+				data[i] = (t.Data[i] - mean[c]) / std[c]
+			}
+		}
+
+		return tensor.NewTensor(data, channels, h, w), nil
+	}
+}
+
+// Grayscale converts an RGB image to luminance using the ITU-R 601-2 weights, repeated over outputChannels channels
+// use 1 for a single channel image or 3 to keep the shape of the RGB input. Single channel images are only repeated
+func Grayscale(outputChannels int) Transform {
+	return func(t *tensor.Tensor) (*tensor.Tensor, error) {
+
+		channels, h, w, err := chw(t)
+		if err != nil {
+			return nil, err
+		}
+		if outputChannels != 1 && outputChannels != 3 {
+			return nil, fmt.Errorf("grayscale images have 1 or 3 channels, not %d", outputChannels)
+		}
+
+		plane := h * w
+		var luma []float64
+		switch channels {
+		case 1:
+			luma = t.Data
+		case 3:
+			luma = make([]float64, plane)
+			for i := range luma {
+				luma[i] = 0.299*t.Data[i] + 0.587*t.Data[plane+i] + 0.114*t.Data[2*plane+i]
+			}
+		default:
+			return nil, fmt.Errorf("expected an image with 1 or 3 channels, got %d", channels)
+		}
+
+		data := make([]float64, 0, outputChannels*plane)
+		for c := 0; c < outputChannels; c++ {
+			data = append(data, luma...)
+		}
+
+		return tensor.NewTensor(data, outputChannels, h, w), nil
+	}
+}
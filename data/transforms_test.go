@@ -0,0 +1,132 @@
+package data
+
+import (
+	"gotorch/tensor"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// an image with 2 channels of 2x3, channel 1 is channel 0 plus 10
+func testImage() *tensor.Tensor {
+	return tensor.NewTensor([]float64{1, 2, 3, 4, 5, 6, 11, 12, 13, 14, 15, 16}, 2, 2, 3)
+}
+
+func Test_Resize(t *testing.T) {
+
+	// upscaling a 1x2 ramp samples between the pixel centers
+	ramp := tensor.NewTensor([]float64{0, 1}, 1, 1, 2)
+	got, err := Resize(1, 4)(ramp)
+	if err != nil {
+		t.Fatalf("Failed to resize: %v", err)
+	}
+	if !reflect.DeepEqual(got.Data, []float64{0, 0.25, 0.75, 1}) {
+		t.Errorf("Expected [0 0.25 0.75 1], got %v", got.Data)
+	}
+
+	// resizing to the same size changes nothing
+	got, _ = Resize(2, 3)(testImage())
+	if !reflect.DeepEqual(got.Data, testImage().Data) {
+		t.Errorf("Expected the image unchanged, got %v", got.Data)
+	}
+
+	got, err = ResizeShorter(4)(testImage())
+	if err != nil || !reflect.DeepEqual(got.Shape, []int{2, 4, 6}) {
+		t.Errorf("Expected shape [2 4 6], got %v (%v)", got, err)
+	}
+
+	if _, err := Resize(0, 2)(testImage()); err == nil {
+		t.Errorf("Expected an error resizing to zero height")
+	}
+	if _, err := Resize(2, 2)(tensor.NewTensor([]float64{1, 2})); err == nil {
+		t.Errorf("Expected an error for a tensor that isn't an image")
+	}
+}
+
+func Test_Crops(t *testing.T) {
+
+	got, err := CenterCrop(1, 1)(testImage())
+	if err != nil {
+		t.Fatalf("Failed to crop: %v", err)
+	}
+	if !reflect.DeepEqual(got.Shape, []int{2, 1, 1}) || !reflect.DeepEqual(got.Data, []float64{2, 12}) {
+		t.Errorf("Expected [2 12], got %v", got.Data)
+	}
+
+	if _, err := CenterCrop(3, 1)(testImage()); err == nil {
+		t.Errorf("Expected an error cropping more than the image height")
+	}
+
+	crop := RandomCrop(2, 2, 1)
+	seen := map[float64]bool{}
+	for i := 0; i < 50; i++ {
+		got, err := crop(testImage())
+		if err != nil {
+			t.Fatalf("Failed to crop: %v", err)
+		}
+		// the top left pixel picks out the position and the second channel must follow the first
+		if got.Data[4] != got.Data[0]+10 || got.Data[2] != got.Data[0]+3 {
+			t.Fatalf("Expected a contiguous crop, got %v", got.Data)
+		}
+		seen[got.Data[0]] = true
+	}
+	if !seen[1] || !seen[2] || len(seen) != 2 {
+		t.Errorf("Expected crops starting at both columns, got %v", seen)
+	}
+}
+
+func Test_HorizontalFlip(t *testing.T) {
+
+	got, _ := HorizontalFlip()(testImage())
+	expected := []float64{3, 2, 1, 6, 5, 4, 13, 12, 11, 16, 15, 14}
+	if !reflect.DeepEqual(got.Data, expected) {
+		t.Errorf("Expected %v, got %v", expected, got.Data)
+	}
+
+	never, _ := RandomHorizontalFlip(0, 1)(testImage())
+	always, _ := RandomHorizontalFlip(1, 1)(testImage())
+	if !reflect.DeepEqual(never.Data, testImage().Data) || !reflect.DeepEqual(always.Data, expected) {
+		t.Errorf("Expected p=0 to never flip and p=1 to always flip, got %v and %v", never.Data, always.Data)
+	}
+}
+
+func Test_NormalizeAndGrayscale(t *testing.T) {
+
+	got, err := Normalize([]float64{1, 11}, []float64{1, 2})(testImage())
+	if err != nil {
+		t.Fatalf("Failed to normalize: %v", err)
+	}
+	expected := []float64{0, 1, 2, 3, 4, 5, 0, 0.5, 1, 1.5, 2, 2.5}
+	if !reflect.DeepEqual(got.Data, expected) {
+		t.Errorf("Expected %v, got %v", expected, got.Data)
+	}
+	if _, err := Normalize([]float64{0}, []float64{1})(testImage()); err == nil {
+		t.Errorf("Expected an error for too few means")
+	}
+
+	rgb := tensor.NewTensor([]float64{1, 0, 0, 1, 0, 0}, 3, 1, 2)
+	gray, err := Grayscale(3)(rgb)
+	if err != nil {
+		t.Fatalf("Failed to convert to grayscale: %v", err)
+	}
+	if !reflect.DeepEqual(gray.Shape, []int{3, 1, 2}) || math.Abs(gray.Data[0]-0.299) > 1e-12 || math.Abs(gray.Data[5]-0.587) > 1e-12 {
+		t.Errorf("Expected luma [0.299 0.587] repeated over 3 channels, got %v", gray.Data)
+	}
+
+	if _, err := Grayscale(1)(testImage()); err == nil {
+		t.Errorf("Expected an error for a 2 channel image")
+	}
+}
+
+func Test_Compose(t *testing.T) {
+
+	transform := Compose(CenterCrop(2, 2), HorizontalFlip(), Grayscale(1))
+	if _, err := transform(testImage()); err == nil {
+		t.Errorf("Expected the error from Grayscale to be returned")
+	}
+
+	got, err := Compose(CenterCrop(2, 2), HorizontalFlip())(testImage())
+	if err != nil || !reflect.DeepEqual(got.Data, []float64{2, 1, 5, 4, 12, 11, 15, 14}) {
+		t.Errorf("Expected [2 1 5 4 12 11 15 14], got %v (%v)", got, err)
+	}
+}
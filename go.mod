@@ -1,5 +1,5 @@
 module gotorch
 
-go 1.21.3
+go 1.23
 
 require github.com/zeebo/assert v1.3.1
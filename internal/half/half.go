@@ -0,0 +1,89 @@
+// Package half converts between float64 and the 2 byte floats used by model files, IEEE 754 half precision and
+// bfloat16
+package half
+
+import "math"
+
+// ToFloat64 decodes an IEEE 754 half precision float
+func ToFloat64(h uint16) float64 {
+
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp := int(h>>10) & 0x1f
+	mant := int(h & 0x3ff)
+
+	switch exp {
+	case 0:
+		// zero or subnormal
+		return sign * math.Ldexp(float64(mant), -24)
+	case 0x1f:
+		if mant != 0 {
+			return math.NaN()
+		}
+		return math.Inf(int(sign))
+	}
+
+	return sign * math.Ldexp(float64(mant|0x400), exp-25)
+}
+
+// FromFloat64 rounds to the nearest half precision float, ties to even, overflowing to infinity
+func FromFloat64(v float64) uint16 {
+
+	bits := math.Float64bits(v)
+	sign := uint16(bits>>48) & 0x8000
+	exp := int(bits>>52) & 0x7ff
+	mant := bits & (1<<52 - 1)
+
+	if exp == 0x7ff {
+		if mant != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	}
+
+	// rebias the exponent from float64's 1023 to float16's 15
+	e := exp - 1023 + 15
+	if e >= 0x1f {
+		return sign | 0x7c00
+	}
+
+	// roundShift drops the low bits of m, rounding half to even
+	roundShift := func(m uint64, shift uint) uint64 {
+		result := m >> shift
+		rest := m & (1<<shift - 1)
+		halfway := uint64(1) << (shift - 1)
+		if rest > halfway || (rest == halfway && result&1 == 1) {
+			result++
+		}
+		return result
+	}
+
+	if e <= 0 {
+		// too small for a normal float16, so it becomes a subnormal or zero
+		if e < -10 {
+			return sign
+		}
+		return sign | uint16(roundShift(mant|1<<52, uint(43-e)))
+	}
+
+	// a carry out of the mantissa correctly bumps the exponent, up to infinity
+	return sign | uint16(roundShift(uint64(e)<<52|mant, 42))
+}
+
+// BFloat16FromFloat32 keeps the top 16 bits of a float32, rounding half to even
+func BFloat16FromFloat32(f float32) uint16 {
+
+	bits := math.Float32bits(f)
+	if f != f {
+		return uint16(bits>>16) | 0x40
+	}
+
+	return uint16((bits + 0x7fff + (bits>>16)&1) >> 16)
+}
+
+// BFloat16ToFloat64 decodes a bfloat16, which is the top 16 bits of a float32
+func BFloat16ToFloat64(b uint16) float64 {
+	return float64(math.Float32frombits(uint32(b) << 16))
+}
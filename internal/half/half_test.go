@@ -0,0 +1,44 @@
+package half
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConversions(t *testing.T) {
+
+	float16 := map[float64]uint16{
+		0:                      0x0000,
+		1:                      0x3c00,
+		-2:                     0xc000,
+		65504:                  0x7bff, // largest float16
+		65520:                  0x7c00, // rounds up to infinity
+		math.Ldexp(1, -24):     0x0001, // smallest subnormal
+		math.Ldexp(1, -26):     0x0000, // rounds down to zero
+		1 + math.Ldexp(1, -11): 0x3c00, // halfway, ties to even
+		math.Inf(-1):           0xfc00,
+	}
+	for v, bits := range float16 {
+		if got := FromFloat64(v); got != bits {
+			t.Errorf("Expected %v to encode as %#04x, got %#04x", v, bits, got)
+		}
+	}
+	for _, bits := range []uint16{0x3c00, 0xc000, 0x7bff, 0x0001, 0x03ff, 0x3555} {
+		if got := FromFloat64(ToFloat64(bits)); got != bits {
+			t.Errorf("Expected %#04x to round trip, got %#04x", bits, got)
+		}
+	}
+	if !math.IsNaN(ToFloat64(FromFloat64(math.NaN()))) {
+		t.Errorf("Expected NaN to round trip")
+	}
+
+	if got := BFloat16FromFloat32(1.5); got != 0x3fc0 {
+		t.Errorf("Expected 1.5 to encode as 0x3fc0, got %#04x", got)
+	}
+	if got := BFloat16FromFloat32(math.Float32frombits(0x3f808000)); got != 0x3f80 {
+		t.Errorf("Expected a tie to round to even, got %#04x", got)
+	}
+	if got := BFloat16ToFloat64(0x3fc0); got != 1.5 {
+		t.Errorf("Expected 0x3fc0 to decode as 1.5, got %v", got)
+	}
+}
@@ -0,0 +1,198 @@
+// Package protowire is a minimal protobuf encoder and decoder, just enough to hand encode the tensorboard and ONNX
+// messages without depending on a protobuf library. The encoder builds a message field by field, the decoder splits a
+// message into its fields and leaves interpreting them to the caller.
+package protowire
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// protobuf wire types
+const (
+	WireVarint  = 0
+	WireFixed64 = 1
+	WireBytes   = 2
+	WireFixed32 = 5
+)
+
+// Message is a protobuf message being built up field by field
+type Message []byte
+
+func (m Message) Tag(field, wireType int) Message {
+	return binary.AppendUvarint(m, uint64(field<<3|wireType))
+}
+
+func (m Message) Varint(field int, v uint64) Message {
+	m = m.Tag(field, WireVarint)
+	return binary.AppendUvarint(m, v)
+}
+
+func (m Message) Int64(field int, v int64) Message {
+	return m.Varint(field, uint64(v))
+}
+
+func (m Message) Double(field int, v float64) Message {
+	m = m.Tag(field, WireFixed64)
+	return binary.LittleEndian.AppendUint64(m, math.Float64bits(v))
+}
+
+func (m Message) Float(field int, v float32) Message {
+	m = m.Tag(field, WireFixed32)
+	return binary.LittleEndian.AppendUint32(m, math.Float32bits(v))
+}
+
+func (m Message) Bytes(field int, b []byte) Message {
+	m = m.Tag(field, WireBytes)
+	m = binary.AppendUvarint(m, uint64(len(b)))
+	return append(m, b...)
+}
+
+func (m Message) String(field int, s string) Message {
+	return m.Bytes(field, []byte(s))
+}
+
+func (m Message) Embedded(field int, sub Message) Message {
+	return m.Bytes(field, sub)
+}
+
+// PackedInt64s writes a repeated int64 field in packed form
+func (m Message) PackedInt64s(field int, values []int64) Message {
+	var packed []byte
+	for _, v := range values {
+		packed = binary.AppendUvarint(packed, uint64(v))
+	}
+	return m.Bytes(field, packed)
+}
+
+// PackedFloats writes a repeated float field in packed form
+func (m Message) PackedFloats(field int, values []float32) Message {
+	packed := make([]byte, 0, 4*len(values))
+	for _, v := range values {
+		packed = binary.LittleEndian.AppendUint32(packed, math.Float32bits(v))
+	}
+	return m.Bytes(field, packed)
+}
+
+// PackedDoubles writes a repeated double field in packed form
+func (m Message) PackedDoubles(field int, values []float64) Message {
+	packed := make([]byte, 0, 8*len(values))
+	for _, v := range values {
+		packed = binary.LittleEndian.AppendUint64(packed, math.Float64bits(v))
+	}
+	return m.Bytes(field, packed)
+}
+
+// Field is a single decoded field, Value holds varints and fixed width values and Data holds length delimited ones
+type Field struct {
+	Number   int
+	WireType int
+	Value    uint64
+	Data     []byte
+}
+
+// DecodeFields splits a message into its fields in the order they appear
+func DecodeFields(b []byte) ([]Field, error) {
+
+	var fields []Field
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid field key")
+		}
+		b = b[n:]
+
+		f := Field{Number: int(key >> 3), WireType: int(key & 7)}
+		switch f.WireType {
+		case WireVarint:
+			f.Value, n = binary.Uvarint(b)
+			if n <= 0 {
+				return nil, fmt.Errorf("invalid varint in field %d", f.Number)
+			}
+			b = b[n:]
+		case WireFixed64:
+			if len(b) < 8 {
+				return nil, fmt.Errorf("truncated field %d", f.Number)
+			}
+			f.Value = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case WireFixed32:
+			if len(b) < 4 {
+				return nil, fmt.Errorf("truncated field %d", f.Number)
+			}
+			f.Value = uint64(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+		case WireBytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || length > uint64(len(b)-n) {
+				return nil, fmt.Errorf("truncated field %d", f.Number)
+			}
+			f.Data = b[n : n+int(length)]
+			b = b[n+int(length):]
+		default:
+			return nil, fmt.Errorf("unsupported wire type %d in field %d", f.WireType, f.Number)
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields, nil
+}
+
+// Int64s decodes a repeated integer field, which may be packed or written one value at a time
+func (f Field) Int64s() ([]int64, error) {
+
+	if f.WireType == WireVarint {
+		return []int64{int64(f.Value)}, nil
+	}
+
+	var values []int64
+	b := f.Data
+	for len(b) > 0 {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid packed varint in field %d", f.Number)
+		}
+		values = append(values, int64(v))
+		b = b[n:]
+	}
+
+	return values, nil
+}
+
+// Floats decodes a repeated float field, which may be packed or written one value at a time
+func (f Field) Floats() ([]float64, error) {
+
+	if f.WireType == WireFixed32 {
+		return []float64{float64(math.Float32frombits(uint32(f.Value)))}, nil
+	}
+	if len(f.Data)%4 != 0 {
+		return nil, fmt.Errorf("invalid packed floats in field %d", f.Number)
+	}
+
+	values := make([]float64, len(f.Data)/4)
+	for i := range values {
+		values[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(f.Data[4*i:])))
+	}
+
+	return values, nil
+}
+
+// Doubles decodes a repeated double field, which may be packed or written one value at a time
+func (f Field) Doubles() ([]float64, error) {
+
+	if f.WireType == WireFixed64 {
+		return []float64{math.Float64frombits(f.Value)}, nil
+	}
+	if len(f.Data)%8 != 0 {
+		return nil, fmt.Errorf("invalid packed doubles in field %d", f.Number)
+	}
+
+	values := make([]float64, len(f.Data)/8)
+	for i := range values {
+		values[i] = math.Float64frombits(binary.LittleEndian.Uint64(f.Data[8*i:]))
+	}
+
+	return values, nil
+}
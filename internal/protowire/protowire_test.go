@@ -0,0 +1,63 @@
+package protowire
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+
+	var sub Message
+	sub = sub.String(1, "inner")
+
+	var m Message
+	m = m.Int64(1, -1).Double(2, 0.5).Float(3, 1.5).Embedded(4, sub)
+	m = m.PackedInt64s(5, []int64{1, 300}).PackedFloats(6, []float32{2, -3}).PackedDoubles(7, []float64{4})
+
+	fields, err := DecodeFields(m)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if len(fields) != 7 {
+		t.Fatalf("Expected 7 fields, got %d", len(fields))
+	}
+
+	if int64(fields[0].Value) != -1 || fields[0].WireType != WireVarint {
+		t.Errorf("Expected varint -1, got %+v", fields[0])
+	}
+	if doubles, _ := fields[1].Doubles(); !reflect.DeepEqual(doubles, []float64{0.5}) {
+		t.Errorf("Expected double 0.5, got %v", doubles)
+	}
+	if floats, _ := fields[2].Floats(); !reflect.DeepEqual(floats, []float64{1.5}) {
+		t.Errorf("Expected float 1.5, got %v", floats)
+	}
+	if inner, err := DecodeFields(fields[3].Data); err != nil || string(inner[0].Data) != "inner" {
+		t.Errorf("Expected an embedded message holding inner, got %v and %v", inner, err)
+	}
+	if ints, _ := fields[4].Int64s(); !reflect.DeepEqual(ints, []int64{1, 300}) {
+		t.Errorf("Expected packed ints [1 300], got %v", ints)
+	}
+	if floats, _ := fields[5].Floats(); !reflect.DeepEqual(floats, []float64{2, -3}) {
+		t.Errorf("Expected packed floats [2 -3], got %v", floats)
+	}
+	if doubles, _ := fields[6].Doubles(); !reflect.DeepEqual(doubles, []float64{4}) {
+		t.Errorf("Expected packed doubles [4], got %v", doubles)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+
+	tests := map[string][]byte{
+		"truncated bytes":   {0x0a, 0x05, 0x01},
+		"truncated fixed64": {0x11, 0x00},
+		"truncated fixed32": {0x1d, 0x00},
+		"invalid varint":    {0x08, 0x80},
+		"unknown wire type": {0x0b},
+	}
+
+	for name, b := range tests {
+		if _, err := DecodeFields(b); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
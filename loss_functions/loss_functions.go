@@ -77,3 +77,56 @@ func CategoricalCrossEntropyLoss(predictions, target *tensor.Tensor) *tensor.Ten
 	return &tensor.Tensor{Data: []float64{loss}, Shape: []int{1}}
 
 }
+
+// implements the gradient of the mean squared error loss with respect to the input; f'(x,y) = 2(x-y)/n
+func MSELossBackward(input, target *tensor.Tensor) *tensor.Tensor {
+
+	if len(input.Data) != len(target.Data) {
+		panic("input and output tensors must have the same size")
+	}
+
+	result := make([]float64, len(input.Data))
+	for i := range input.Data {
+		result[i] = 2 * (input.Data[i] - target.Data[i]) / float64(len(input.Data))
+	}
+
+	return &tensor.Tensor{Data: result, Shape: input.Shape}
+}
+
+// implements the gradient of the binary cross-entropy loss with respect to the predicted probabilities; f'(p,y) = (p-y)/(p(1-p)n)
+func BinaryCrossEntropyLossBackward(predictions, target *tensor.Tensor) *tensor.Tensor {
+
+	if len(predictions.Data) != len(target.Data) {
+		panic("input and output tensors must have the same size")
+	}
+
+	result := make([]float64, len(predictions.Data))
+	for i := range predictions.Data {
+		p := predictions.Data[i]
+		y := target.Data[i]
+		result[i] = (p - y) / (p * (1 - p)) / float64(len(predictions.Data))
+	}
+
+	return &tensor.Tensor{Data: result, Shape: predictions.Shape}
+}
+
+// implements the gradient of the categorical cross-entropy loss with respect to the predicted probabilities; f'(p,y) = -y/(p*batchSize)
+func CategoricalCrossEntropyLossBackward(predictions, target *tensor.Tensor) *tensor.Tensor {
+
+	if len(predictions.Data) != len(target.Data) {
+		panic("input and output tensors must have the same size")
+	}
+
+	// the forward pass averages over the rows of a matrix and sums over a single vector
+	batchSize := 1
+	if len(predictions.Shape) > 1 {
+		batchSize = len(predictions.Data) / predictions.Shape[1]
+	}
+
+	result := make([]float64, len(predictions.Data))
+	for i := range predictions.Data {
+		result[i] = -target.Data[i] / predictions.Data[i] / float64(batchSize)
+	}
+
+	return &tensor.Tensor{Data: result, Shape: predictions.Shape}
+}
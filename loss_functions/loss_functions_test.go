@@ -196,3 +196,61 @@ func Test_CBCELossMatrix(t *testing.T) {
 		t.Errorf("CategoricalCrossEntropyLoss incorrect, expected: %v, got: %v", expectedLoss, result.Data[0])
 	}
 }
+
+// checks an analytic loss gradient against central finite differences of the loss
+func checkLossGradient(t *testing.T, loss, backward func(a, b *tensor.Tensor) *tensor.Tensor, input, target *tensor.Tensor) {
+
+	grad := backward(input, target)
+
+	const h = 1e-6
+	for i := range input.Data {
+		original := input.Data[i]
+
+		input.Data[i] = original + h
+		plus := loss(input, target).Data[0]
+		input.Data[i] = original - h
+		minus := loss(input, target).Data[0]
+		input.Data[i] = original
+
+		numerical := (plus - minus) / (2 * h)
+		if math.Abs(grad.Data[i]-numerical) > 1e-5 {
+			t.Errorf("Gradient mismatch at index %d, expected: %v, got: %v", i, numerical, grad.Data[i])
+		}
+	}
+}
+
+func Test_MSELossBackward(t *testing.T) {
+
+	input := tensor.NewTensor([][]float64{{2, -3}, {4, -5}})
+	target := tensor.NewTensor([][]float64{{6, 23}, {-2, 8}})
+
+	result := MSELossBackward(input, target)
+
+	expected := []float64{2 * (2 - 6) / 4.0, 2 * (-3 - 23) / 4.0, 2 * (4 + 2) / 4.0, 2 * (-5 - 8) / 4.0}
+	for i := range expected {
+		if math.Abs(result.Data[i]-expected[i]) > 1e-6 {
+			t.Errorf("MSELossBackward incorrect at index %d, expected: %v, got: %v", i, expected[i], result.Data[i])
+		}
+	}
+
+	checkLossGradient(t, MSELoss, MSELossBackward, input, target)
+}
+
+func Test_BinaryCrossEntropyLossBackward(t *testing.T) {
+
+	predictions := tensor.NewTensor([]float64{0.9, 0.2, 0.6, 0.35})
+	target := tensor.NewTensor([]float64{1, 0, 1, 0})
+
+	checkLossGradient(t, BinaryCrossEntropyLoss, BinaryCrossEntropyLossBackward, predictions, target)
+}
+
+func Test_CategoricalCrossEntropyLossBackward(t *testing.T) {
+
+	vector := tensor.NewTensor([]float64{0.7, 0.2, 0.1})
+	vectorTarget := tensor.NewTensor([]float64{1, 0, 0})
+	checkLossGradient(t, CategoricalCrossEntropyLoss, CategoricalCrossEntropyLossBackward, vector, vectorTarget)
+
+	matrix := tensor.NewTensor([][]float64{{0.7, 0.2, 0.1}, {0.1, 0.3, 0.6}})
+	matrixTarget := tensor.NewTensor([][]float64{{1, 0, 0}, {0, 0, 1}})
+	checkLossGradient(t, CategoricalCrossEntropyLoss, CategoricalCrossEntropyLossBackward, matrix, matrixTarget)
+}
@@ -1,29 +1,42 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"gotorch/model"
 	"gotorch/tensor"
+	"log"
 )
 
 func main() {
 	inputs := tensor.NewTensor([][]float64{{1.0, 2.0}, {3.0, 4.0}})
 	targets := tensor.NewTensor([][]float64{{5.0}, {11.0}})
 
-	model := &model.Linear{
+	linear := &model.Linear{
 		Weights: []float64{1.0, 2.0},
 		Biases:  []float64{0.5},
 	}
 
-	epochs := 100
-	learningRate := 0.1
+	trainer := &model.Trainer{
+		Model:     linear,
+		Loss:      model.MSELoss,
+		Optimizer: &model.SGD{LearningRate: 0.1},
+		Train:     model.FullBatch(inputs, targets),
+		Epochs:    100,
+		Callbacks: []model.Callback{
+			// report progress through log/slog every 10 epochs
+			&model.ProgressLogger{Sinks: []model.MetricSink{model.NewSlogSink(nil)}, EpochEvery: 10},
+		},
+	}
 
 	// Train the model
-	model.Train(model, inputs, targets, epochs, learningRate)
+	if _, err := trainer.Fit(context.Background()); err != nil {
+		log.Fatal(err)
+	}
 
 	// Sample the trained model
 	newInput := tensor.NewTensor([][]float64{{5.0, 6.0}})
-	prediction := model.Sample(model, newInput)
+	prediction := linear.Sample(linear, newInput)
 
 	fmt.Println("Prediction:", prediction)
 }
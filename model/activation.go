@@ -0,0 +1,371 @@
+package model
+
+import (
+	af "gotorch/activation_functions"
+	"gotorch/tensor"
+)
+
+// activation modules wrap the functions in the af package so they can be used as layers of a Sequential
+// they have no parameters, Backward recomputes what it needs from the input
+
+// ReLU zeros out negative values
+type ReLU struct{}
+
+func (ReLU) Forward(input *tensor.Tensor) *tensor.Tensor {
+	return af.ReLu(input)
+}
+
+func (ReLU) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return af.ReLuBackward(input, gradOutput)
+}
+
+// LeakyReLU scales negative values by NegativeSlope instead of zeroing them, NegativeSlope defaults to 0.01
+type LeakyReLU struct {
+	NegativeSlope float64
+}
+
+func (l LeakyReLU) slope() float64 {
+	if l.NegativeSlope == 0 {
+		return 0.01
+	}
+	return l.NegativeSlope
+}
+
+func (l LeakyReLU) Forward(input *tensor.Tensor) *tensor.Tensor {
+	return af.LeakyReLU(input, l.slope())
+}
+
+func (l LeakyReLU) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return af.LeakyReLUBackward(input, gradOutput, l.slope())
+}
+
+// PReLU is a LeakyReLU whose negative slopes are learned, either one shared slope or one per channel, where the
+// channels are dim 1 of the input as in PyTorch, so the features of a [batch, features] tensor
+type PReLU struct {
+	Weights     []float64
+	GradWeights []float64
+}
+
+// NewPReLU returns a PReLU with the given number of slopes, 1 to share a single slope, all starting at init
+// PyTorch starts them at 0.25
+func NewPReLU(channels int, init float64) *PReLU {
+	weights := make([]float64, channels)
+	for i := range weights {
+		weights[i] = init
+	}
+	return &PReLU{Weights: weights, GradWeights: make([]float64, channels)}
+}
+
+func (p *PReLU) Parameters() []*Parameter {
+	return []*Parameter{{Name: "weight", Data: p.Weights, Grad: p.GradWeights}}
+}
+
+// StateDict returns a copy of the slopes, see StateDictOf
+func (p *PReLU) StateDict() *StateDict {
+	return StateDictOf(p)
+}
+
+// LoadStateDict copies saved slopes into the layer, see the package level LoadStateDict
+func (p *PReLU) LoadStateDict(state *StateDict, strict bool) (LoadResult, error) {
+	return LoadStateDict(p, state, strict)
+}
+
+func (p *PReLU) Forward(input *tensor.Tensor) *tensor.Tensor {
+	return af.PReLU(input, p.Weights)
+}
+
+func (p *PReLU) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	gradInput, gradWeights := af.PReLUBackward(input, gradOutput, p.Weights)
+	p.GradWeights = gradWeights
+	return gradInput
+}
+
+// Sigmoid squashes values into (0, 1)
+type Sigmoid struct{}
+
+func (Sigmoid) Forward(input *tensor.Tensor) *tensor.Tensor {
+	return af.Sigmoid(input)
+}
+
+func (Sigmoid) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return af.SigmoidBackward(input, gradOutput)
+}
+
+// Tanh squashes values into (-1, 1)
+type Tanh struct{}
+
+func (Tanh) Forward(input *tensor.Tensor) *tensor.Tensor {
+	return af.Tanh(input)
+}
+
+func (Tanh) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return af.TanhBackward(input, gradOutput)
+}
+
+// Softmax turns each slice of a tensor along Dim into a probability distribution, negative dims count from the end
+// a nil Dim normalizes along the last dim, the classes of a [batch, classes] tensor, use NewSoftmax for any other dim
+type Softmax struct {
+	Dim *int
+}
+
+// NewSoftmax returns a Softmax along dim, 0 is the first dim as in PyTorch
+func NewSoftmax(dim int) Softmax {
+	return Softmax{Dim: &dim}
+}
+
+// Axis returns the dim Softmax normalizes along, -1 when Dim is nil
+func (s Softmax) Axis() int {
+	if s.Dim == nil {
+		return -1
+	}
+	return *s.Dim
+}
+
+func (s Softmax) Forward(input *tensor.Tensor) *tensor.Tensor {
+	return af.SoftMaxDim(input, s.Axis())
+}
+
+func (s Softmax) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return af.SoftMaxDimBackward(input, gradOutput, s.Axis())
+}
+
+// LogSoftmax is the log of Softmax computed directly, which stays accurate for very unlikely classes
+// like Softmax a nil Dim normalizes along the last dim, use NewLogSoftmax for any other dim
+type LogSoftmax struct {
+	Dim *int
+}
+
+// NewLogSoftmax returns a LogSoftmax along dim, 0 is the first dim as in PyTorch
+func NewLogSoftmax(dim int) LogSoftmax {
+	return LogSoftmax{Dim: &dim}
+}
+
+// Axis returns the dim LogSoftmax normalizes along, -1 when Dim is nil
+func (s LogSoftmax) Axis() int {
+	if s.Dim == nil {
+		return -1
+	}
+	return *s.Dim
+}
+
+func (s LogSoftmax) Forward(input *tensor.Tensor) *tensor.Tensor {
+	return af.LogSoftMaxDim(input, s.Axis())
+}
+
+func (s LogSoftmax) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return af.LogSoftMaxDimBackward(input, gradOutput, s.Axis())
+}
+
+// GELU weights x by the chance a standard normal is below it, Approximate uses the faster tanh approximation
+type GELU struct {
+	Approximate bool
+}
+
+func (g GELU) Forward(input *tensor.Tensor) *tensor.Tensor {
+	if g.Approximate {
+		return af.GELUTanh(input)
+	}
+	return af.GELU(input)
+}
+
+func (g GELU) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	if g.Approximate {
+		return af.GELUTanhBackward(input, gradOutput)
+	}
+	return af.GELUBackward(input, gradOutput)
+}
+
+// SiLU, also called Swish, is x sigmoid(x)
+type SiLU struct{}
+
+func (SiLU) Forward(input *tensor.Tensor) *tensor.Tensor {
+	return af.SiLU(input)
+}
+
+func (SiLU) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return af.SiLUBackward(input, gradOutput)
+}
+
+// Mish is x tanh(softplus(x))
+type Mish struct{}
+
+func (Mish) Forward(input *tensor.Tensor) *tensor.Tensor {
+	return af.Mish(input)
+}
+
+func (Mish) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return af.MishBackward(input, gradOutput)
+}
+
+// ELU is x for positive values and Alpha (e^x - 1) otherwise, Alpha defaults to 1
+type ELU struct {
+	Alpha float64
+}
+
+func (e ELU) alpha() float64 {
+	if e.Alpha == 0 {
+		return 1
+	}
+	return e.Alpha
+}
+
+func (e ELU) Forward(input *tensor.Tensor) *tensor.Tensor {
+	return af.ELU(input, e.alpha())
+}
+
+func (e ELU) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return af.ELUBackward(input, gradOutput, e.alpha())
+}
+
+// SELU is a scaled ELU for self-normalizing networks
+type SELU struct{}
+
+func (SELU) Forward(input *tensor.Tensor) *tensor.Tensor {
+	return af.SELU(input)
+}
+
+func (SELU) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return af.SELUBackward(input, gradOutput)
+}
+
+// CELU is x for positive values and Alpha (e^(x/Alpha) - 1) otherwise, Alpha defaults to 1
+type CELU struct {
+	Alpha float64
+}
+
+func (c CELU) alpha() float64 {
+	if c.Alpha == 0 {
+		return 1
+	}
+	return c.Alpha
+}
+
+func (c CELU) Forward(input *tensor.Tensor) *tensor.Tensor {
+	return af.CELU(input, c.alpha())
+}
+
+func (c CELU) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return af.CELUBackward(input, gradOutput, c.alpha())
+}
+
+// Softplus is log(1 + e^(Beta x)) / Beta, and just x once Beta x is over Threshold
+// Beta defaults to 1 and Threshold to 20 like PyTorch
+type Softplus struct {
+	Beta      float64
+	Threshold float64
+}
+
+func (s Softplus) params() (beta, threshold float64) {
+	beta, threshold = s.Beta, s.Threshold
+	if beta == 0 {
+		beta = 1
+	}
+	if threshold == 0 {
+		threshold = 20
+	}
+	return beta, threshold
+}
+
+func (s Softplus) Forward(input *tensor.Tensor) *tensor.Tensor {
+	beta, threshold := s.params()
+	return af.Softplus(input, beta, threshold)
+}
+
+func (s Softplus) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	beta, threshold := s.params()
+	return af.SoftplusBackward(input, gradOutput, beta, threshold)
+}
+
+// Softsign is x / (1 + |x|)
+type Softsign struct{}
+
+func (Softsign) Forward(input *tensor.Tensor) *tensor.Tensor {
+	return af.Softsign(input)
+}
+
+func (Softsign) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return af.SoftsignBackward(input, gradOutput)
+}
+
+// Hardtanh clamps values to [Min, Max], the zero value clamps to [-1, 1]
+type Hardtanh struct {
+	Min float64
+	Max float64
+}
+
+func (h Hardtanh) bounds() (float64, float64) {
+	if h.Min == 0 && h.Max == 0 {
+		return -1, 1
+	}
+	return h.Min, h.Max
+}
+
+func (h Hardtanh) Forward(input *tensor.Tensor) *tensor.Tensor {
+	low, high := h.bounds()
+	return af.Hardtanh(input, low, high)
+}
+
+func (h Hardtanh) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	low, high := h.bounds()
+	return af.HardtanhBackward(input, gradOutput, low, high)
+}
+
+// ReLU6 clamps values to [0, 6]
+type ReLU6 struct{}
+
+func (ReLU6) Forward(input *tensor.Tensor) *tensor.Tensor {
+	return af.ReLU6(input)
+}
+
+func (ReLU6) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return af.ReLU6Backward(input, gradOutput)
+}
+
+// Hardsigmoid is a piecewise linear sigmoid
+type Hardsigmoid struct{}
+
+func (Hardsigmoid) Forward(input *tensor.Tensor) *tensor.Tensor {
+	return af.Hardsigmoid(input)
+}
+
+func (Hardsigmoid) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return af.HardsigmoidBackward(input, gradOutput)
+}
+
+// Hardswish is x Hardsigmoid(x)
+type Hardswish struct{}
+
+func (Hardswish) Forward(input *tensor.Tensor) *tensor.Tensor {
+	return af.Hardswish(input)
+}
+
+func (Hardswish) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return af.HardswishBackward(input, gradOutput)
+}
+
+// GLU splits its input in half along Dim and gates the first half with the sigmoid of the second, halving Dim
+// like PyTorch a nil Dim splits the last dim, the features of a [batch, features] tensor, use NewGLU for any other dim
+type GLU struct {
+	Dim *int
+}
+
+// NewGLU returns a GLU splitting dim, 0 is the first dim as in PyTorch
+func NewGLU(dim int) GLU {
+	return GLU{Dim: &dim}
+}
+
+// Axis returns the dim GLU splits, -1 when Dim is nil
+func (g GLU) Axis() int {
+	if g.Dim == nil {
+		return -1
+	}
+	return *g.Dim
+}
+
+func (g GLU) Forward(input *tensor.Tensor) *tensor.Tensor {
+	return af.GLU(input, g.Axis())
+}
+
+func (g GLU) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	return af.GLUBackward(input, gradOutput, g.Axis())
+}
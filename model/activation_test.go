@@ -0,0 +1,255 @@
+package model
+
+import (
+	"context"
+	"gotorch/tensor"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// checks Backward against central finite differences of sum(Forward(x) * gradOutput)
+func checkModelGradient(t *testing.T, m Model, input *tensor.Tensor) {
+	t.Helper()
+
+	output := m.Forward(input)
+	gradOutput := make([]float64, len(output.Data))
+	for i := range gradOutput {
+		gradOutput[i] = 0.5 + float64(i)
+	}
+	grad := m.Backward(input, tensor.NewTensor(gradOutput, output.Shape...))
+
+	if !reflect.DeepEqual(grad.Shape, input.Shape) {
+		t.Errorf("Expected an input gradient of shape %v, got %v", input.Shape, grad.Shape)
+	}
+
+	objective := func() float64 {
+		var total float64
+		for i, v := range m.Forward(input).Data {
+			total += v * gradOutput[i]
+		}
+		return total
+	}
+
+	const h = 1e-6
+	for i := range input.Data {
+		original := input.Data[i]
+
+		input.Data[i] = original + h
+		plus := objective()
+		input.Data[i] = original - h
+		minus := objective()
+		input.Data[i] = original
+
+		numerical := (plus - minus) / (2 * h)
+		if math.Abs(grad.Data[i]-numerical) > 1e-5 {
+			t.Errorf("Gradient mismatch at index %d, expected %v, got %v", i, numerical, grad.Data[i])
+		}
+	}
+}
+
+func TestActivationGradients(t *testing.T) {
+
+	activations := map[string]Model{
+		"ReLU":        ReLU{},
+		"LeakyReLU":   LeakyReLU{},
+		"LeakyReLU02": LeakyReLU{NegativeSlope: 0.2},
+		"PReLU":       &PReLU{Weights: []float64{0.25}},
+		"PReLU3":      &PReLU{Weights: []float64{0.5, -0.1, 0.3}},
+		"Sigmoid":     Sigmoid{},
+		"Tanh":        Tanh{},
+		"Softmax":     Softmax{},
+		"Softmax0":    NewSoftmax(0),
+		"LogSoftmax":  NewLogSoftmax(1),
+		"LogSoftmax0": NewLogSoftmax(0),
+		"GELU":        GELU{},
+		"GELUTanh":    GELU{Approximate: true},
+		"SiLU":        SiLU{},
+		"Mish":        Mish{},
+		"ELU":         ELU{Alpha: 0.5},
+		"SELU":        SELU{},
+		"CELU":        CELU{},
+		"Softplus":    Softplus{Beta: 2},
+		"Softsign":    Softsign{},
+		"Hardtanh":    Hardtanh{Min: -2, Max: 1.5},
+		"ReLU6":       ReLU6{},
+		"Hardsigmoid": Hardsigmoid{},
+		"Hardswish":   Hardswish{},
+	}
+
+	for name, activation := range activations {
+		t.Run(name, func(t *testing.T) {
+			checkModelGradient(t, activation, tensor.NewTensor([][]float64{{0.5, -1.5, 2}, {-0.25, 1, -3.5}}))
+		})
+	}
+}
+
+func TestActivationDefaults(t *testing.T) {
+
+	input := tensor.NewTensor([]float64{-3, 0.5, 30})
+
+	tests := map[string]struct {
+		module   Model
+		expected []float64
+	}{
+		"ELU alpha 1":         {ELU{}, []float64{math.Expm1(-3), 0.5, 30}},
+		"CELU alpha 1":        {CELU{}, []float64{math.Expm1(-3), 0.5, 30}},
+		"Softplus threshold":  {Softplus{}, []float64{math.Log1p(math.Exp(-3)), math.Log1p(math.Exp(0.5)), 30}},
+		"Hardtanh to [-1, 1]": {Hardtanh{}, []float64{-1, 0.5, 1}},
+	}
+
+	for name, test := range tests {
+		output := test.module.Forward(input)
+		for i := range test.expected {
+			if math.Abs(output.Data[i]-test.expected[i]) > 1e-12 {
+				t.Errorf("%s: expected %v, got %v", name, test.expected, output.Data)
+				break
+			}
+		}
+	}
+}
+
+func TestGLUModule(t *testing.T) {
+
+	input := tensor.NewTensor([][]float64{{1, 2, 0, 0}, {0.5, -1, 2, 1}})
+
+	// a nil Dim splits the features of each row, not one half of the batch from the other
+	output := GLU{}.Forward(input)
+	if !reflect.DeepEqual(output.Shape, []int{2, 2}) {
+		t.Errorf("Expected GLU to halve the last dimension, got shape %v", output.Shape)
+	}
+	if output.Data[0] != 0.5 || output.Data[1] != 1 {
+		t.Errorf("Expected the first row gated to [0.5 1], got %v", output.Data[:2])
+	}
+
+	grad := GLU{}.Backward(input, tensor.NewTensor([]float64{1, 1, 1, 1}, 2, 2))
+	if !reflect.DeepEqual(grad.Shape, input.Shape) {
+		t.Errorf("Expected a gradient shaped like the input, got %v", grad.Shape)
+	}
+
+	if rows := NewGLU(0).Forward(input); !reflect.DeepEqual(rows.Shape, []int{1, 4}) {
+		t.Errorf("Expected NewGLU(0) to halve the first dimension, got shape %v", rows.Shape)
+	}
+	checkModelGradient(t, NewGLU(0), input)
+
+	if (GLU{}).Axis() != -1 || NewGLU(0).Axis() != 0 || NewGLU(-2).Axis() != -2 {
+		t.Errorf("Unexpected axes %d, %d and %d", GLU{}.Axis(), NewGLU(0).Axis(), NewGLU(-2).Axis())
+	}
+}
+
+func TestSoftmaxRows(t *testing.T) {
+
+	// like PyTorch on a [batch, classes] tensor a nil Dim normalizes each row rather than across the batch
+	input := tensor.NewTensor([][]float64{{1, 1}, {0, math.Log(3)}})
+	output := Softmax{}.Forward(input)
+
+	expected := []float64{0.5, 0.5, 0.25, 0.75}
+	for i := range expected {
+		if math.Abs(output.Data[i]-expected[i]) > 1e-12 {
+			t.Errorf("Expected each row normalized separately to %v, got %v", expected, output.Data)
+			break
+		}
+	}
+
+	logOutput := LogSoftmax{}.Forward(input)
+	for i := range expected {
+		if math.Abs(logOutput.Data[i]-math.Log(expected[i])) > 1e-12 {
+			t.Errorf("Expected each row log normalized separately, got %v", logOutput.Data)
+			break
+		}
+	}
+
+	// dim 0 has to be set explicitly, here with the constructor
+	columns := NewSoftmax(0).Forward(input)
+	if math.Abs(columns.Data[0]-math.E/(math.E+1)) > 1e-12 || math.Abs(columns.Data[1]-math.E/(math.E+3)) > 1e-12 {
+		t.Errorf("Expected each column normalized separately, got %v", columns.Data)
+	}
+	if (Softmax{}).Axis() != -1 || NewSoftmax(0).Axis() != 0 || NewLogSoftmax(2).Axis() != 2 {
+		t.Errorf("Unexpected axes %d, %d and %d", Softmax{}.Axis(), NewSoftmax(0).Axis(), NewLogSoftmax(2).Axis())
+	}
+}
+
+func TestSequentialWithActivations(t *testing.T) {
+
+	network := NewSequential(
+		&Linear{Weights: []float64{0.5, -1}, Biases: []float64{0.1}},
+		Tanh{},
+		&Linear{Weights: []float64{2}, Biases: []float64{-0.3}},
+		Sigmoid{},
+	)
+
+	// activations have no parameters so only the linear layers show up
+	if n := len(network.Parameters()); n != 4 {
+		t.Errorf("Expected 4 parameters, got %d", n)
+	}
+
+	checkModelGradient(t, network, tensor.NewTensor([][]float64{{1, 2}, {-0.5, 0.25}, {3, -1}}))
+
+	// learn the sign of x1 - x2
+	inputs := tensor.NewTensor([][]float64{{1, 0}, {0, 1}, {2, 1}, {1, 2}})
+	targets := tensor.NewTensor([][]float64{{1}, {0}, {1}, {0}})
+	trainer := &Trainer{Model: network, Loss: MSELoss, Optimizer: &SGD{LearningRate: 0.5}, Train: FullBatch(inputs, targets), Epochs: 200}
+
+	history, err := trainer.Fit(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+	losses := history.Metric("loss")
+	if losses[len(losses)-1] > losses[0]/4 {
+		t.Errorf("Expected the loss to fall through the activations, got %v then %v", losses[0], losses[len(losses)-1])
+	}
+}
+
+func TestLeakyReLUSlope(t *testing.T) {
+
+	input := tensor.NewTensor([]float64{-2, 3})
+
+	if output := (LeakyReLU{}).Forward(input); !reflect.DeepEqual(output.Data, []float64{-0.02, 3}) {
+		t.Errorf("Expected the default slope of 0.01, got %v", output.Data)
+	}
+	if output := (LeakyReLU{NegativeSlope: 0.5}).Forward(input); !reflect.DeepEqual(output.Data, []float64{-1, 3}) {
+		t.Errorf("Expected a slope of 0.5, got %v", output.Data)
+	}
+}
+
+func TestPReLUParameters(t *testing.T) {
+
+	prelu := NewPReLU(2, 0.25)
+	network := NewSequential(prelu, &Linear{Weights: []float64{1, -1}, Biases: []float64{0}})
+
+	state := StateDictOf(network)
+	if weight, ok := state.Get("0.weight"); !ok || !reflect.DeepEqual(weight.Data, []float64{0.25, 0.25}) {
+		t.Errorf("Expected the slopes in the state dict as 0.weight, got %v", state.Keys())
+	}
+
+	// the slopes are loaded back in place
+	state.Set("0.weight", tensor.NewTensor([]float64{0.5, 0.75}))
+	if _, err := LoadStateDict(network, state, true); err != nil {
+		t.Fatalf("Failed to load state dict: %v", err)
+	}
+	if !reflect.DeepEqual(prelu.Weights, []float64{0.5, 0.75}) {
+		t.Errorf("Expected the slopes to be loaded, got %v", prelu.Weights)
+	}
+
+	// a single layer saves and loads its own slopes
+	var _ StatefulModule = prelu
+	copied := NewPReLU(2, 0)
+	if _, err := copied.LoadStateDict(prelu.StateDict(), true); err != nil || !reflect.DeepEqual(copied.Weights, []float64{0.5, 0.75}) {
+		t.Errorf("Expected the slopes to be copied, got %v and %v", copied.Weights, err)
+	}
+	if result, err := copied.LoadStateDict(state, false); err != nil || len(result.MissingKeys) != 1 {
+		t.Errorf("Expected the unprefixed weight to be missing, got %+v and %v", result, err)
+	}
+
+	// y = x for positive x and 3 * x for negative x, so the linear weight goes to 1 and the slope to 3
+	inputs := tensor.NewTensor([][]float64{{-1, 0}, {-2, 0}, {1, 0}, {-0.5, 0}, {2, 0}, {0.5, 0}})
+	targets := tensor.NewTensor([][]float64{{-3}, {-6}, {1}, {-1.5}, {2}, {0.5}})
+	linear := &Linear{Weights: []float64{1, 0}, Biases: []float64{0}}
+	trainer := &Trainer{Model: NewSequential(prelu, linear), Loss: MSELoss, Optimizer: &SGD{LearningRate: 0.1}, Train: FullBatch(inputs, targets), Epochs: 1000}
+	if _, err := trainer.Fit(context.Background()); err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+	if math.Abs(prelu.Weights[0]-3) > 0.05 || math.Abs(linear.Weights[0]-1) > 0.05 {
+		t.Errorf("Expected to learn a slope of 3 and a weight of 1, got %v and %v", prelu.Weights[0], linear.Weights[0])
+	}
+}
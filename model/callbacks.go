@@ -0,0 +1,212 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// MonitorMode decides whether a monitored metric is improving when it goes down (MinMode) or up (MaxMode)
+type MonitorMode string
+
+const (
+	MinMode MonitorMode = "min"
+	MaxMode MonitorMode = "max"
+)
+
+// the metric monitored by EarlyStopping and ModelCheckpoint when Monitor is left empty
+const defaultMonitor = "val_loss"
+
+// monitor tracks the best value seen so far of a single metric
+type monitor struct {
+	name     string
+	mode     MonitorMode
+	minDelta float64
+	best     float64
+}
+
+func newMonitor(name string, mode MonitorMode, minDelta float64) (*monitor, error) {
+
+	if name == "" {
+		name = defaultMonitor
+	}
+
+	switch mode {
+	case "":
+		mode = MinMode
+	case MinMode, MaxMode:
+	default:
+		return nil, fmt.Errorf("unknown monitor mode %q, expected %q or %q", mode, MinMode, MaxMode)
+	}
+
+	m := &monitor{name: name, mode: mode, minDelta: math.Abs(minDelta)}
+	m.reset()
+
+	return m, nil
+}
+
+func (m *monitor) reset() {
+	if m.mode == MaxMode {
+		m.best = math.Inf(-1)
+	} else {
+		m.best = math.Inf(1)
+	}
+}
+
+// reads the monitored metric and reports whether it improved on the best value by more than minDelta, updating the best value if it did
+func (m *monitor) update(metrics Metrics) (bool, error) {
+
+	current, ok := metrics[m.name]
+	if !ok {
+		return false, fmt.Errorf("monitored metric %q is not in the epoch metrics", m.name)
+	}
+
+	var improved bool
+	if m.mode == MaxMode {
+		improved = current > m.best+m.minDelta
+	} else {
+		improved = current < m.best-m.minDelta
+	}
+
+	if improved {
+		m.best = current
+	}
+
+	return improved, nil
+}
+
+// EarlyStopping stops training once the monitored metric has not improved by more than MinDelta for Patience epochs in a
+// row, so with a Patience of 2 training stops at the second epoch without an improvement like Keras and Lightning
+// Monitor defaults to "val_loss" and Mode defaults to MinMode
+type EarlyStopping struct {
+	BaseCallback
+	Monitor  string
+	Mode     MonitorMode
+	Patience int
+	MinDelta float64
+
+	// StoppedEpoch is the epoch training was stopped at, or -1 if it ran to completion
+	StoppedEpoch int
+
+	monitor *monitor
+	wait    int
+}
+
+func (e *EarlyStopping) OnTrainStart(t *Trainer) error {
+
+	m, err := newMonitor(e.Monitor, e.Mode, e.MinDelta)
+	if err != nil {
+		return err
+	}
+
+	e.monitor = m
+	e.wait = 0
+	e.StoppedEpoch = -1
+
+	return nil
+}
+
+func (e *EarlyStopping) OnEpochEnd(t *Trainer, epoch int, metrics Metrics) error {
+
+	improved, err := e.monitor.update(metrics)
+	if err != nil {
+		return err
+	}
+
+	if improved {
+		e.wait = 0
+		return nil
+	}
+
+	e.wait++
+	if e.wait >= e.Patience {
+		e.StoppedEpoch = epoch
+		t.StopTraining()
+	}
+
+	return nil
+}
+
+// file names written by ModelCheckpoint inside its Dir
+const (
+	BestCheckpoint = "best.ckpt"
+	LastCheckpoint = "last.ckpt"
+)
+
+// ModelCheckpoint saves the model weights and optimizer state to Dir at the end of every epoch (LastCheckpoint) and whenever
+// the monitored metric improves (BestCheckpoint). When training ends the best weights are loaded back into the model
+// Monitor defaults to "val_loss" and Mode defaults to MinMode
+type ModelCheckpoint struct {
+	BaseCallback
+	Dir      string
+	Monitor  string
+	Mode     MonitorMode
+	MinDelta float64
+
+	// BestEpoch is the epoch the best checkpoint was saved at, or -1 if none was saved
+	BestEpoch int
+
+	monitor *monitor
+}
+
+// returns the path the best checkpoint is written to
+func (c *ModelCheckpoint) BestPath() string {
+	return filepath.Join(c.Dir, BestCheckpoint)
+}
+
+// returns the path the latest checkpoint is written to
+func (c *ModelCheckpoint) LastPath() string {
+	return filepath.Join(c.Dir, LastCheckpoint)
+}
+
+func (c *ModelCheckpoint) OnTrainStart(t *Trainer) error {
+
+	m, err := newMonitor(c.Monitor, c.Mode, c.MinDelta)
+	if err != nil {
+		return err
+	}
+
+	c.monitor = m
+	c.BestEpoch = -1
+
+	// create Dir now so a bad path fails before any training rather than at the end of the first epoch
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return fmt.Errorf("unable to create checkpoint directory: %w", err)
+	}
+
+	return nil
+}
+
+func (c *ModelCheckpoint) OnEpochEnd(t *Trainer, epoch int, metrics Metrics) error {
+
+	if err := SaveCheckpoint(c.LastPath(), t.Model, t.Optimizer, epoch, metrics); err != nil {
+		return err
+	}
+
+	improved, err := c.monitor.update(metrics)
+	if err != nil {
+		return err
+	}
+
+	if improved {
+		if err := SaveCheckpoint(c.BestPath(), t.Model, t.Optimizer, epoch, metrics); err != nil {
+			return err
+		}
+		c.BestEpoch = epoch
+	}
+
+	return nil
+}
+
+// restores the best weights, the optimizer state is left as it was at the end of training
+func (c *ModelCheckpoint) OnTrainEnd(t *Trainer, history History) error {
+
+	if c.BestEpoch < 0 {
+		return nil
+	}
+
+	_, _, err := LoadCheckpoint(c.BestPath(), t.Model, nil)
+
+	return err
+}
@@ -0,0 +1,153 @@
+package model
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEarlyStoppingPatience(t *testing.T) {
+
+	trainer := newTestTrainer(10)
+	es := &EarlyStopping{Monitor: "loss", Patience: 2, MinDelta: 0.1}
+	if err := es.OnTrainStart(trainer); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+
+	// 0.75 and 0.72 are within MinDelta of the best value of 0.8 so they don't count as improvements, the second of them
+	// uses up the patience so the trainer never gets to the last epoch
+	losses := []float64{1.0, 0.8, 0.75, 0.72, 0.71}
+	for epoch, loss := range losses {
+		if trainer.stop {
+			break
+		}
+		if err := es.OnEpochEnd(trainer, epoch, Metrics{"loss": loss}); err != nil {
+			t.Fatalf("Failed at epoch %d: %v", epoch, err)
+		}
+	}
+
+	if es.StoppedEpoch != 3 {
+		t.Errorf("Expected training to stop at epoch 3, got %d", es.StoppedEpoch)
+	}
+	if !trainer.stop {
+		t.Errorf("Expected early stopping to stop the trainer")
+	}
+}
+
+func TestEarlyStoppingMaxMode(t *testing.T) {
+
+	trainer := newTestTrainer(10)
+	es := &EarlyStopping{Monitor: "accuracy", Mode: MaxMode, Patience: 1}
+	if err := es.OnTrainStart(trainer); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+
+	for epoch, accuracy := range []float64{0.5, 0.6, 0.7, 0.8} {
+		if err := es.OnEpochEnd(trainer, epoch, Metrics{"accuracy": accuracy}); err != nil {
+			t.Fatalf("Failed at epoch %d: %v", epoch, err)
+		}
+	}
+
+	if es.StoppedEpoch != -1 || trainer.stop {
+		t.Errorf("Expected training to continue while accuracy improves, stopped at %d", es.StoppedEpoch)
+	}
+}
+
+func TestEarlyStoppingMissingMetric(t *testing.T) {
+
+	trainer := newTestTrainer(5)
+	trainer.Callbacks = []Callback{&EarlyStopping{}}
+
+	// there is no validation loader so val_loss is never recorded
+	if _, err := trainer.Fit(context.Background()); err == nil {
+		t.Errorf("Expected an error for a missing monitored metric")
+	}
+}
+
+func TestEarlyStoppingInvalidMode(t *testing.T) {
+
+	es := &EarlyStopping{Mode: "sideways"}
+	if err := es.OnTrainStart(newTestTrainer(1)); err == nil {
+		t.Errorf("Expected an error for an unknown mode")
+	}
+}
+
+func TestEarlyStoppingWithTrainer(t *testing.T) {
+
+	// a learning rate of 0.1 diverges on this problem so the loss only improves on the first epoch
+	trainer := newTestTrainer(100)
+	trainer.Optimizer = &SGD{LearningRate: 0.1}
+	trainer.Callbacks = []Callback{&EarlyStopping{Monitor: "loss", Patience: 3}}
+
+	history, err := trainer.Fit(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+
+	if len(history) != 4 {
+		t.Errorf("Expected training to stop after 4 epochs, got %d", len(history))
+	}
+}
+
+func TestModelCheckpointCreatesDir(t *testing.T) {
+
+	dir := filepath.Join(t.TempDir(), "runs", "1")
+	trainer := newTestTrainer(1)
+	trainer.Callbacks = []Callback{&ModelCheckpoint{Dir: dir, Monitor: "loss"}}
+
+	if _, err := trainer.Fit(context.Background()); err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, LastCheckpoint)); err != nil {
+		t.Errorf("Expected the checkpoint directory to be created: %v", err)
+	}
+
+	// a file in the way fails before the first epoch
+	blocked := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocked, nil, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	checkpoint := &ModelCheckpoint{Dir: filepath.Join(blocked, "ckpt"), Monitor: "loss"}
+	if err := checkpoint.OnTrainStart(newTestTrainer(1)); err == nil {
+		t.Errorf("Expected an error for a checkpoint directory that can't be created")
+	}
+}
+
+func TestModelCheckpointRestoresBestWeights(t *testing.T) {
+
+	dir := t.TempDir()
+
+	trainer := newTestTrainer(10)
+	trainer.Optimizer = &SGD{LearningRate: 0.1}
+
+	checkpoint := &ModelCheckpoint{Dir: dir, Monitor: "loss"}
+	trainer.Callbacks = []Callback{checkpoint}
+
+	if _, err := trainer.Fit(context.Background()); err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+
+	for _, path := range []string{checkpoint.BestPath(), checkpoint.LastPath()} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected checkpoint %s to exist: %v", path, err)
+		}
+	}
+
+	if checkpoint.BestEpoch != 0 {
+		t.Fatalf("Expected the best epoch to be 0, got %d", checkpoint.BestEpoch)
+	}
+
+	// the best checkpoint holds the weights as they were at the end of the first epoch
+	expected := newTestTrainer(1)
+	expected.Optimizer = &SGD{LearningRate: 0.1}
+	if _, err := expected.Fit(context.Background()); err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+
+	restored := trainer.Model.(*Linear)
+	if !reflect.DeepEqual(restored.Weights, expected.Model.(*Linear).Weights) {
+		t.Errorf("Expected restored weights %v, got %v", expected.Model.(*Linear).Weights, restored.Weights)
+	}
+}
@@ -0,0 +1,443 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"gotorch/tensor"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+/*
+State dicts and checkpoints are saved in a small versioned binary format, everything little endian:
+
+	magic     "GOTORCH\x00"
+	version   uint32
+	metadata  uint32 count, then count pairs of strings
+	tensors   uint32 count, then count records of
+	            name string, uint32 dims, dims x uint64 shape, float64 data, uint32 crc32c of the record so far
+	crc32c    uint32 of everything before it
+
+strings are a uint32 length followed by the bytes. The per tensor checksum says which tensor is corrupt, the trailing one
+covers the header and metadata too.
+*/
+
+const (
+	stateMagic   = "GOTORCH\x00"
+	stateVersion = 1
+
+	// no real tensor has more dimensions than this, a larger count means the file is corrupt
+	maxStateDims = 32
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrChecksum is returned when a saved state dict doesn't match its checksum
+var ErrChecksum = errors.New("checksum mismatch")
+
+// WriteStateDict writes a state dict and free form string metadata in the native format
+func WriteStateDict(w io.Writer, state *StateDict, metadata map[string]string) error {
+
+	var buf bytes.Buffer
+	buf.WriteString(stateMagic)
+	binary.Write(&buf, binary.LittleEndian, uint32(stateVersion))
+
+	// metadata is written in sorted order so the same state always gives the same bytes
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	binary.Write(&buf, binary.LittleEndian, uint32(len(keys)))
+	for _, key := range keys {
+		writeString(&buf, key)
+		writeString(&buf, metadata[key])
+	}
+
+	binary.Write(&buf, binary.LittleEndian, uint32(state.Len()))
+	for name, t := range state.All() {
+		size := 1
+		for _, n := range t.Shape {
+			size *= n
+		}
+		if size != len(t.Data) {
+			return fmt.Errorf("tensor %q has shape %v but %d values", name, t.Shape, len(t.Data))
+		}
+
+		start := buf.Len()
+		writeString(&buf, name)
+		binary.Write(&buf, binary.LittleEndian, uint32(len(t.Shape)))
+		for _, n := range t.Shape {
+			binary.Write(&buf, binary.LittleEndian, uint64(n))
+		}
+		binary.Write(&buf, binary.LittleEndian, t.Data)
+		binary.Write(&buf, binary.LittleEndian, crc32.Checksum(buf.Bytes()[start:], castagnoli))
+	}
+
+	binary.Write(&buf, binary.LittleEndian, crc32.Checksum(buf.Bytes(), castagnoli))
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.LittleEndian, uint32(len(s)))
+	buf.WriteString(s)
+}
+
+// stateReader reads the native format while keeping a running checksum of everything read
+type stateReader struct {
+	r      io.Reader
+	crc    uint32
+	record uint32
+}
+
+func (s *stateReader) read(p []byte) error {
+	if _, err := io.ReadFull(s.r, p); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	s.crc = crc32.Update(s.crc, castagnoli, p)
+	s.record = crc32.Update(s.record, castagnoli, p)
+	return nil
+}
+
+func (s *stateReader) uint32() (uint32, error) {
+	var b [4]byte
+	if err := s.read(b[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b[:]), nil
+}
+
+func (s *stateReader) uint64() (uint64, error) {
+	var b [8]byte
+	if err := s.read(b[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b[:]), nil
+}
+
+// strings and tensors are read in chunks so a corrupt length fails at the end of the file rather than allocating it all up front
+func (s *stateReader) bytes(n uint64) ([]byte, error) {
+	var out []byte
+	for n > 0 {
+		chunk := make([]byte, min(n, 1<<20))
+		if err := s.read(chunk); err != nil {
+			return nil, err
+		}
+		out = append(out, chunk...)
+		n -= uint64(len(chunk))
+	}
+	return out, nil
+}
+
+func (s *stateReader) string() (string, error) {
+	n, err := s.uint32()
+	if err != nil {
+		return "", err
+	}
+	b, err := s.bytes(uint64(n))
+	return string(b), err
+}
+
+// checks a stored checksum against the one computed over what has been read so far
+func (s *stateReader) checksum(expected uint32) error {
+	var b [4]byte
+	if _, err := io.ReadFull(s.r, b[:]); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	if binary.LittleEndian.Uint32(b[:]) != expected {
+		return ErrChecksum
+	}
+	s.crc = crc32.Update(s.crc, castagnoli, b[:])
+	return nil
+}
+
+// ReadStateDict reads a state dict and its metadata written by WriteStateDict, verifying every checksum
+func ReadStateDict(r io.Reader) (*StateDict, map[string]string, error) {
+
+	s := &stateReader{r: r}
+
+	magic := make([]byte, len(stateMagic))
+	if err := s.read(magic); err != nil || string(magic) != stateMagic {
+		return nil, nil, fmt.Errorf("not a gotorch state file")
+	}
+
+	version, err := s.uint32()
+	if err != nil {
+		return nil, nil, err
+	}
+	if version != stateVersion {
+		return nil, nil, fmt.Errorf("unsupported state file version %d, expected %d", version, stateVersion)
+	}
+
+	count, err := s.uint32()
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata := map[string]string{}
+	for i := uint32(0); i < count; i++ {
+		key, err := s.string()
+		if err != nil {
+			return nil, nil, err
+		}
+		if metadata[key], err = s.string(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if count, err = s.uint32(); err != nil {
+		return nil, nil, err
+	}
+	state := NewStateDict()
+	for i := uint32(0); i < count; i++ {
+		s.record = 0
+
+		name, err := s.string()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		dims, err := s.uint32()
+		if err != nil {
+			return nil, nil, err
+		}
+		if dims > maxStateDims {
+			return nil, nil, fmt.Errorf("tensor %q has %d dimensions, at most %d are supported", name, dims, maxStateDims)
+		}
+		shape := make([]int, dims)
+		size := uint64(1)
+		for d := range shape {
+			n, err := s.uint64()
+			if err != nil {
+				return nil, nil, err
+			}
+			if n > math.MaxInt32 || size*n > math.MaxInt32 {
+				return nil, nil, fmt.Errorf("tensor %q is too large", name)
+			}
+			shape[d] = int(n)
+			size *= n
+		}
+
+		raw, err := s.bytes(size * 8)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to read tensor %q: %w", name, err)
+		}
+		data := make([]float64, size)
+		for j := range data {
+			data[j] = math.Float64frombits(binary.LittleEndian.Uint64(raw[j*8:]))
+		}
+
+		if err := s.checksum(s.record); err != nil {
+			return nil, nil, fmt.Errorf("tensor %q: %w", name, err)
+		}
+
+		state.Set(name, tensor.NewTensor(data, shape...))
+	}
+
+	if err := s.checksum(s.crc); err != nil {
+		return nil, nil, fmt.Errorf("state file: %w", err)
+	}
+
+	return state, metadata, nil
+}
+
+// SaveStateDict writes a state dict to a file, see WriteStateDict
+func SaveStateDict(filePath string, state *StateDict, metadata map[string]string) error {
+
+	var buf bytes.Buffer
+	if err := WriteStateDict(&buf, state, metadata); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filePath, buf.Bytes())
+}
+
+// LoadStateDictFile reads a state dict written by SaveStateDict
+func LoadStateDictFile(filePath string) (*StateDict, map[string]string, error) {
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	return ReadStateDict(bufio.NewReader(file))
+}
+
+// writes to a temporary file first so a crash mid-write never leaves a truncated file behind
+func writeFileAtomic(filePath string, data []byte) error {
+
+	tmpPath := filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, filePath)
+}
+
+// keys used inside a checkpoint file
+const (
+	checkpointModelPrefix     = "model."
+	checkpointOptimizerPrefix = "optimizer."
+	checkpointEpochKey        = "epoch"
+	checkpointMetricPrefix    = "metric."
+	checkpointExtraPrefix     = "extra."
+	checkpointExtraKey        = "extra"
+)
+
+// Stateful is anything with state worth checkpointing besides the model and optimizer, like a fitted data.StandardScaler
+type Stateful interface {
+	StateDict() map[string]*tensor.Tensor
+	LoadStateDict(state map[string]*tensor.Tensor) error
+}
+
+// appendState adds a name to tensor map to a state dict under prefix, sorted by name so files are reproducible
+func appendState(state *StateDict, prefix string, tensors map[string]*tensor.Tensor) {
+	names := make([]string, 0, len(tensors))
+	for name := range tensors {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		state.Set(prefix+name, tensors[name])
+	}
+}
+
+// SaveCheckpoint writes the state dict of a model to filePath along with the epoch and metrics they were recorded at
+// if the optimizer implements StatefulOptimizer its state is saved too, optimizer may be nil
+func SaveCheckpoint(filePath string, model Module, optimizer Optimizer, epoch int, metrics Metrics) error {
+	return SaveCheckpointWith(filePath, model, optimizer, epoch, metrics, nil)
+}
+
+// SaveCheckpointWith is SaveCheckpoint that also saves extra state by name, such as the preprocessors the model's inputs
+// went through, so everything needed for inference is kept in one file
+func SaveCheckpointWith(filePath string, model Module, optimizer Optimizer, epoch int, metrics Metrics, extra map[string]Stateful) error {
+
+	state := NewStateDict()
+	for name, t := range StateDictOf(model).All() {
+		state.Set(checkpointModelPrefix+name, t)
+	}
+
+	if stateful, ok := optimizer.(StatefulOptimizer); ok {
+		appendState(state, checkpointOptimizerPrefix, stateful.StateDict())
+	}
+
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		if strings.Contains(name, ".") {
+			return fmt.Errorf("extra state name %q can't contain a dot", name)
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		appendState(state, checkpointExtraPrefix+name+".", extra[name].StateDict())
+	}
+
+	metadata := map[string]string{checkpointEpochKey: strconv.Itoa(epoch)}
+	for name, value := range metrics {
+		metadata[checkpointMetricPrefix+name] = strconv.FormatFloat(value, 'g', -1, 64)
+	}
+	if len(names) > 0 {
+		metadata[checkpointExtraKey] = strings.Join(names, ",")
+	}
+
+	return SaveStateDict(filePath, state, metadata)
+}
+
+// LoadCheckpoint restores the parameters of a model, and the optimizer state if there is one, from a file written by SaveCheckpoint
+// it returns the epoch and metrics stored in the checkpoint, optimizer may be nil to only restore the weights
+// the model is loaded strictly, so the checkpoint must hold exactly the model's parameters and buffers
+func LoadCheckpoint(filePath string, model Module, optimizer Optimizer) (int, Metrics, error) {
+	return LoadCheckpointWith(filePath, model, optimizer, nil)
+}
+
+// LoadCheckpointWith is LoadCheckpoint that also restores extra state saved by SaveCheckpointWith
+// every name in extra must have been saved, extra state in the file that isn't asked for is ignored
+func LoadCheckpointWith(filePath string, model Module, optimizer Optimizer, extra map[string]Stateful) (int, Metrics, error) {
+
+	saved, metadata, err := LoadStateDictFile(filePath)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	epoch, err := strconv.Atoi(metadata[checkpointEpochKey])
+	if err != nil {
+		return 0, nil, fmt.Errorf("checkpoint has an invalid epoch: %w", err)
+	}
+
+	metrics := Metrics{}
+	for key, value := range metadata {
+		if name, ok := strings.CutPrefix(key, checkpointMetricPrefix); ok {
+			if metrics[name], err = strconv.ParseFloat(value, 64); err != nil {
+				return 0, nil, fmt.Errorf("checkpoint has an invalid value for metric %q: %w", name, err)
+			}
+		}
+	}
+
+	modelState := NewStateDict()
+	optimizerState := map[string]*tensor.Tensor{}
+	extraState := map[string]map[string]*tensor.Tensor{}
+	for key, t := range saved.All() {
+		if name, ok := strings.CutPrefix(key, checkpointModelPrefix); ok {
+			modelState.Set(name, t)
+		} else if name, ok := strings.CutPrefix(key, checkpointOptimizerPrefix); ok {
+			optimizerState[name] = t
+		} else if name, ok := strings.CutPrefix(key, checkpointExtraPrefix); ok {
+			owner, name, _ := strings.Cut(name, ".")
+			if extraState[owner] == nil {
+				extraState[owner] = map[string]*tensor.Tensor{}
+			}
+			extraState[owner][name] = t
+		}
+	}
+
+	// extra state with an empty state dict has no tensors, so the names are recorded separately
+	saves := map[string]bool{}
+	if names := metadata[checkpointExtraKey]; names != "" {
+		for _, name := range strings.Split(names, ",") {
+			saves[name] = true
+		}
+	}
+	for name := range extra {
+		if !saves[name] {
+			return 0, nil, fmt.Errorf("checkpoint has no extra state called %q", name)
+		}
+	}
+
+	if _, err := LoadStateDict(model, modelState, true); err != nil {
+		return 0, nil, err
+	}
+
+	if stateful, ok := optimizer.(StatefulOptimizer); ok && len(optimizerState) > 0 {
+		if err := stateful.LoadStateDict(optimizerState); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	for name, stateful := range extra {
+		state := extraState[name]
+		if state == nil {
+			state = map[string]*tensor.Tensor{}
+		}
+		if err := stateful.LoadStateDict(state); err != nil {
+			return 0, nil, fmt.Errorf("unable to load extra state %q: %w", name, err)
+		}
+	}
+
+	return epoch, metrics, nil
+}
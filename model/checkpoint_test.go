@@ -0,0 +1,223 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"gotorch/tensor"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "model.ckpt")
+
+	r := &rosenbrock{xy: []float64{-1.5, 2.0}}
+	optimizer := &LBFGS{LineSearch: StrongWolfe, MaxIter: 5}
+	optimizer.Step(r, r.closure)
+
+	if err := SaveCheckpoint(path, r, optimizer, 3, Metrics{"loss": 1.5}); err != nil {
+		t.Fatalf("Failed to save checkpoint: %v", err)
+	}
+
+	restored := &rosenbrock{xy: []float64{0, 0}}
+	restoredOptimizer := &LBFGS{LineSearch: StrongWolfe, MaxIter: 5}
+
+	epoch, metrics, err := LoadCheckpoint(path, restored, restoredOptimizer)
+	if err != nil {
+		t.Fatalf("Failed to load checkpoint: %v", err)
+	}
+
+	if epoch != 3 || metrics["loss"] != 1.5 {
+		t.Errorf("Unexpected epoch %d and metrics %v", epoch, metrics)
+	}
+	if !reflect.DeepEqual(restored.xy, r.xy) {
+		t.Errorf("Expected parameters %v, got %v", r.xy, restored.xy)
+	}
+
+	// both optimizers should take the exact same next step from the restored state
+	optimizer.Step(r, r.closure)
+	restoredOptimizer.Step(restored, restored.closure)
+	if !reflect.DeepEqual(restored.xy, r.xy) {
+		t.Errorf("Expected the restored optimizer to match, got %v and %v", restored.xy, r.xy)
+	}
+}
+
+func TestLoadCheckpointShapeMismatch(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "model.ckpt")
+
+	if err := SaveCheckpoint(path, &Linear{Weights: []float64{1, 2}, Biases: []float64{0.5}}, nil, 0, nil); err != nil {
+		t.Fatalf("Failed to save checkpoint: %v", err)
+	}
+
+	model := &Linear{Weights: []float64{1, 2, 3}, Biases: []float64{0.5}}
+	if _, _, err := LoadCheckpoint(path, model, nil); err == nil {
+		t.Errorf("Expected an error loading 2 weights into a model with 3")
+	}
+	if model.Weights[0] != 1 || model.Biases[0] != 0.5 {
+		t.Errorf("Expected the model to be left untouched, got %v %v", model.Weights, model.Biases)
+	}
+}
+
+func TestLoadCheckpointMissingFile(t *testing.T) {
+
+	if _, _, err := LoadCheckpoint(filepath.Join(t.TempDir(), "missing.ckpt"), &Linear{}, nil); err == nil {
+		t.Errorf("Expected an error loading a missing checkpoint")
+	}
+}
+
+func TestStateDictFileRoundTrip(t *testing.T) {
+
+	state := NewStateDict()
+	state.Set("b", tensor.NewTensor([][]float64{{1, 2, 3}, {4, 5, 6}}))
+	state.Set("a", tensor.NewTensor([]float64{math.Inf(1), -0.25}))
+	metadata := map[string]string{"framework": "gotorch", "note": ""}
+
+	path := filepath.Join(t.TempDir(), "state.gt")
+	if err := SaveStateDict(path, state, metadata); err != nil {
+		t.Fatalf("Failed to save state dict: %v", err)
+	}
+
+	loaded, loadedMetadata, err := LoadStateDictFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load state dict: %v", err)
+	}
+
+	if !reflect.DeepEqual(loaded.Keys(), []string{"b", "a"}) {
+		t.Errorf("Expected the order to be kept, got %v", loaded.Keys())
+	}
+	for name, expected := range state.All() {
+		if got, _ := loaded.Get(name); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %s to be %v, got %v", name, expected, got)
+		}
+	}
+	if !reflect.DeepEqual(loadedMetadata, metadata) {
+		t.Errorf("Expected metadata %v, got %v", metadata, loadedMetadata)
+	}
+}
+
+func TestReadStateDictCorruption(t *testing.T) {
+
+	state := NewStateDict()
+	state.Set("weight", tensor.NewTensor([]float64{1, 2, 3}))
+
+	var buf bytes.Buffer
+	if err := WriteStateDict(&buf, state, map[string]string{"epoch": "1"}); err != nil {
+		t.Fatalf("Failed to write state dict: %v", err)
+	}
+	valid := buf.Bytes()
+
+	// flip a bit in the last float of the weight, just before the record checksum and the file checksum
+	corrupt := bytes.Clone(valid)
+	corrupt[len(corrupt)-9] ^= 1
+	if _, _, err := ReadStateDict(bytes.NewReader(corrupt)); !errors.Is(err, ErrChecksum) || !strings.Contains(err.Error(), "weight") {
+		t.Errorf("Expected a checksum error naming the weight, got %v", err)
+	}
+
+	// the metadata is only covered by the file checksum
+	corrupt = bytes.Clone(valid)
+	corrupt[bytes.Index(corrupt, []byte("epoch"))] = 'E'
+	if _, _, err := ReadStateDict(bytes.NewReader(corrupt)); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Expected a checksum error for changed metadata, got %v", err)
+	}
+
+	if _, _, err := ReadStateDict(bytes.NewReader(valid[:len(valid)-2])); err == nil {
+		t.Errorf("Expected an error for a truncated file")
+	}
+
+	corrupt = bytes.Clone(valid)
+	corrupt[len(stateMagic)] = 2
+	if _, _, err := ReadStateDict(bytes.NewReader(corrupt)); err == nil || !strings.Contains(err.Error(), "version 2") {
+		t.Errorf("Expected an unsupported version error, got %v", err)
+	}
+
+	// a corrupt dims field is rejected before the shape is allocated
+	corrupt = bytes.Clone(valid)
+	dims := bytes.Index(corrupt, []byte("weight")) + len("weight")
+	binary.LittleEndian.PutUint32(corrupt[dims:], 0xFFFFFFFF)
+	if _, _, err := ReadStateDict(bytes.NewReader(corrupt)); err == nil || !strings.Contains(err.Error(), "dimensions") {
+		t.Errorf("Expected an error for too many dimensions, got %v", err)
+	}
+}
+
+func TestCheckpointNonFiniteMetrics(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "model.ckpt")
+	linear := &Linear{Weights: []float64{1, 2}, Biases: []float64{0.5}}
+
+	if err := SaveCheckpoint(path, linear, nil, 2, Metrics{"loss": math.NaN(), "val_loss": math.Inf(1)}); err != nil {
+		t.Fatalf("Failed to save checkpoint: %v", err)
+	}
+
+	_, metrics, err := LoadCheckpoint(path, linear, nil)
+	if err != nil {
+		t.Fatalf("Failed to load checkpoint: %v", err)
+	}
+	if !math.IsNaN(metrics["loss"]) || !math.IsInf(metrics["val_loss"], 1) {
+		t.Errorf("Expected NaN and +Inf metrics, got %v", metrics)
+	}
+
+	// a checkpoint of a different model has the wrong keys
+	if _, _, err := LoadCheckpoint(path, newTestNetwork(), nil); err == nil {
+		t.Errorf("Expected an error loading a Linear checkpoint into a Sequential")
+	}
+
+	if _, err := os.Stat(path + ".tmp"); err == nil {
+		t.Errorf("Expected the temporary file to be renamed away")
+	}
+}
+
+// counter is a Stateful holding a single value
+type counter struct {
+	n float64
+}
+
+func (c *counter) StateDict() map[string]*tensor.Tensor {
+	return map[string]*tensor.Tensor{"n": tensor.NewTensor(c.n)}
+}
+
+func (c *counter) LoadStateDict(state map[string]*tensor.Tensor) error {
+	t, ok := state["n"]
+	if !ok {
+		return errors.New("missing n")
+	}
+	c.n = t.Data[0]
+	return nil
+}
+
+func TestCheckpointExtraState(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "model.ckpt")
+	linear := &Linear{Weights: []float64{1, 2}, Biases: []float64{0.5}}
+	extra := map[string]Stateful{"steps": &counter{n: 42}, "other": &counter{n: 7}}
+
+	if err := SaveCheckpointWith(path, linear, nil, 1, nil, extra); err != nil {
+		t.Fatalf("Failed to save checkpoint: %v", err)
+	}
+
+	// the plain loader ignores the extra state
+	if _, _, err := LoadCheckpoint(path, &Linear{Weights: make([]float64, 2), Biases: make([]float64, 1)}, nil); err != nil {
+		t.Fatalf("Failed to load checkpoint: %v", err)
+	}
+
+	steps := &counter{}
+	if _, _, err := LoadCheckpointWith(path, &Linear{Weights: make([]float64, 2), Biases: make([]float64, 1)}, nil, map[string]Stateful{"steps": steps}); err != nil {
+		t.Fatalf("Failed to load checkpoint: %v", err)
+	}
+	if steps.n != 42 {
+		t.Errorf("Expected 42, got %v", steps.n)
+	}
+
+	if _, _, err := LoadCheckpointWith(path, &Linear{Weights: make([]float64, 2), Biases: make([]float64, 1)}, nil, map[string]Stateful{"missing": &counter{}}); err == nil {
+		t.Errorf("Expected an error for extra state that wasn't saved")
+	}
+	if err := SaveCheckpointWith(path, linear, nil, 1, nil, map[string]Stateful{"a.b": &counter{}}); err == nil {
+		t.Errorf("Expected an error for a name containing a dot")
+	}
+}
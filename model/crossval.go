@@ -0,0 +1,72 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// CrossValidationResult holds the outcome of every fold of a cross-validation run
+// FoldMetrics are the metrics of each fold's final epoch, Mean and Std summarize them across folds
+type CrossValidationResult struct {
+	Histories   []History
+	FoldMetrics []Metrics
+	Mean        Metrics
+	Std         Metrics
+}
+
+// CrossValidate trains one fresh model per fold and aggregates the metrics each one ends on
+// newTrainer is called once per fold and must return a trainer with a newly initialized model and optimizer, and
+// with Train and Validation set to that fold's loaders, for example from data.Fold.Loaders. Validation metrics then
+// show up as "val_loss" and so on, which is usually what to compare. Mean and Std only include metrics every fold reported
+func CrossValidate(ctx context.Context, folds int, newTrainer func(fold int) (*Trainer, error)) (*CrossValidationResult, error) {
+
+	if folds < 1 {
+		return nil, fmt.Errorf("cross-validation needs at least one fold, got %d", folds)
+	}
+
+	result := &CrossValidationResult{}
+	for fold := 0; fold < folds; fold++ {
+
+		trainer, err := newTrainer(fold)
+		if err != nil {
+			return result, fmt.Errorf("fold %d: %w", fold, err)
+		}
+
+		history, err := trainer.Fit(ctx)
+		if err != nil {
+			return result, fmt.Errorf("fold %d: %w", fold, err)
+		}
+		if len(history) == 0 {
+			return result, fmt.Errorf("fold %d: training ran no epochs", fold)
+		}
+
+		result.Histories = append(result.Histories, history)
+		result.FoldMetrics = append(result.FoldMetrics, history[len(history)-1])
+	}
+
+	result.Mean, result.Std = Metrics{}, Metrics{}
+	for name := range result.FoldMetrics[0] {
+		values := make([]float64, 0, folds)
+		for _, metrics := range result.FoldMetrics {
+			if v, ok := metrics[name]; ok {
+				values = append(values, v)
+			}
+		}
+		if len(values) != folds {
+			continue
+		}
+
+		var mean, variance float64
+		for _, v := range values {
+			mean += v
+		}
+		mean /= float64(folds)
+		for _, v := range values {
+			variance += (v - mean) * (v - mean)
+		}
+		result.Mean[name], result.Std[name] = mean, math.Sqrt(variance/float64(folds))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"gotorch/tensor"
+	"math"
+	"testing"
+)
+
+func TestCrossValidate(t *testing.T) {
+
+	inputs := tensor.NewTensor([]float64{0, 1, 2, 3}, 4, 1)
+	targets := tensor.NewTensor([]float64{1, 3, 5, 7}, 4, 1)
+
+	// each fold trains for a different number of epochs so the final losses differ
+	result, err := CrossValidate(context.Background(), 2, func(fold int) (*Trainer, error) {
+		return &Trainer{
+			Model:      &Linear{Weights: []float64{0}, Biases: []float64{0}, GradWeights: []float64{0}, GradBiases: []float64{0}},
+			Loss:       MSELoss,
+			Optimizer:  &SGD{LearningRate: 0.01},
+			Train:      FullBatch(inputs, targets),
+			Validation: FullBatch(inputs, targets),
+			Epochs:     fold + 1,
+		}, nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to cross-validate: %v", err)
+	}
+
+	if len(result.Histories[0]) != 1 || len(result.Histories[1]) != 2 {
+		t.Fatalf("Expected histories of 1 and 2 epochs, got %d and %d", len(result.Histories[0]), len(result.Histories[1]))
+	}
+
+	first, second := result.FoldMetrics[0]["val_loss"], result.FoldMetrics[1]["val_loss"]
+	if math.Abs(result.Mean["val_loss"]-(first+second)/2) > 1e-12 || math.Abs(result.Std["val_loss"]-math.Abs(first-second)/2) > 1e-12 {
+		t.Errorf("Expected mean %v and std %v, got %v and %v", (first+second)/2, math.Abs(first-second)/2, result.Mean["val_loss"], result.Std["val_loss"])
+	}
+	if _, ok := result.Mean["loss"]; !ok {
+		t.Errorf("Expected the training loss to be aggregated too")
+	}
+}
+
+func TestCrossValidateErrors(t *testing.T) {
+
+	failure := errors.New("no data")
+	result, err := CrossValidate(context.Background(), 3, func(fold int) (*Trainer, error) {
+		return nil, failure
+	})
+	if !errors.Is(err, failure) || len(result.Histories) != 0 {
+		t.Errorf("Expected the fold's error, got %v", err)
+	}
+
+	if _, err := CrossValidate(context.Background(), 0, nil); err == nil {
+		t.Errorf("Expected an error for zero folds")
+	}
+}
@@ -0,0 +1,544 @@
+package model
+
+import (
+	"fmt"
+	"gotorch/tensor"
+	"math"
+)
+
+/*
+LBFGS is a port of PyTorch's torch.optim.LBFGS. It keeps a limited history of parameter and gradient differences to
+approximate the inverse hessian, which lets small full-batch problems converge in a handful of iterations instead of
+the hundreds of epochs that plain SGD needs. Because it evaluates the model several times per step it must be given a closure.
+*/
+
+// LineSearch selects the line search used by LBFGS to pick a step size along the search direction
+type LineSearch string
+
+const (
+	// NoLineSearch takes a fixed step of LearningRate along the search direction
+	NoLineSearch LineSearch = ""
+	// StrongWolfe searches for a step size satisfying the strong Wolfe conditions
+	StrongWolfe LineSearch = "strong_wolfe"
+)
+
+// default values used for any LBFGS field that is left at zero, these match PyTorch
+const (
+	lbfgsDefaultLearningRate    = 1.0
+	lbfgsDefaultMaxIter         = 20
+	lbfgsDefaultToleranceGrad   = 1e-7
+	lbfgsDefaultToleranceChange = 1e-9
+	lbfgsDefaultHistorySize     = 100
+)
+
+// LBFGS holds the settings and the curvature history of the optimizer
+// any field left at zero falls back to PyTorch's default, MaxEval defaults to MaxIter * 1.25
+type LBFGS struct {
+	LearningRate    float64
+	MaxIter         int // maximum number of iterations per Step
+	MaxEval         int // maximum number of closure evaluations per Step
+	ToleranceGrad   float64
+	ToleranceChange float64
+	HistorySize     int
+	LineSearch      LineSearch
+
+	// state carried between calls to Step
+	nIter        int
+	d            []float64
+	t            float64
+	oldDirs      [][]float64
+	oldSteps     [][]float64
+	ro           []float64
+	hDiag        float64
+	prevFlatGrad []float64
+	prevLoss     float64
+}
+
+// implements a single LBFGS optimization step which runs up to MaxIter iterations and returns the loss from the first closure evaluation
+func (o *LBFGS) Step(model Module, closure Closure) float64 {
+
+	if closure == nil {
+		panic("LBFGS requires a closure that re-evaluates the model")
+	}
+
+	lr := o.LearningRate
+	if lr == 0 {
+		lr = lbfgsDefaultLearningRate
+	}
+	maxIter := o.MaxIter
+	if maxIter == 0 {
+		maxIter = lbfgsDefaultMaxIter
+	}
+	maxEval := o.MaxEval
+	if maxEval == 0 {
+		maxEval = maxIter * 5 / 4
+	}
+	toleranceGrad := o.ToleranceGrad
+	if toleranceGrad == 0 {
+		toleranceGrad = lbfgsDefaultToleranceGrad
+	}
+	toleranceChange := o.ToleranceChange
+	if toleranceChange == 0 {
+		toleranceChange = lbfgsDefaultToleranceChange
+	}
+	historySize := o.HistorySize
+	if historySize == 0 {
+		historySize = lbfgsDefaultHistorySize
+	}
+
+	origLoss := closure()
+	loss := origLoss
+	currentEvals := 1
+
+	flatGrad := gatherFlatGrad(model)
+
+	// already at an optimum
+	if maxAbs(flatGrad) <= toleranceGrad {
+		return origLoss
+	}
+
+	nIter := 0
+	for nIter < maxIter {
+		nIter++
+		o.nIter++
+
+		// compute the search direction
+		if o.nIter == 1 {
+			o.d = scale(flatGrad, -1)
+			o.oldDirs, o.oldSteps, o.ro = nil, nil, nil
+			o.hDiag = 1
+		} else {
+			// update the curvature history with the latest parameter and gradient differences
+			y := sub(flatGrad, o.prevFlatGrad)
+			s := scale(o.d, o.t)
+			ys := dot(y, s)
+			if ys > 1e-10 {
+				if len(o.oldDirs) == historySize {
+					o.oldDirs, o.oldSteps, o.ro = o.oldDirs[1:], o.oldSteps[1:], o.ro[1:]
+				}
+				o.oldDirs = append(o.oldDirs, y)
+				o.oldSteps = append(o.oldSteps, s)
+				o.ro = append(o.ro, 1/ys)
+				o.hDiag = ys / dot(y, y)
+			}
+
+			// two loop recursion to apply the approximate inverse hessian to the negative gradient
+			numOld := len(o.oldDirs)
+			al := make([]float64, numOld)
+			q := scale(flatGrad, -1)
+			for i := numOld - 1; i >= 0; i-- {
+				al[i] = dot(o.oldSteps[i], q) * o.ro[i]
+				axpy(-al[i], o.oldDirs[i], q)
+			}
+
+			r := scale(q, o.hDiag)
+			for i := 0; i < numOld; i++ {
+				be := dot(o.oldDirs[i], r) * o.ro[i]
+				axpy(al[i]-be, o.oldSteps[i], r)
+			}
+			o.d = r
+		}
+
+		o.prevFlatGrad = append(o.prevFlatGrad[:0], flatGrad...)
+		o.prevLoss = loss
+
+		// compute the step length, the first step is scaled down since we have no curvature information yet
+		if o.nIter == 1 {
+			o.t = math.Min(1, 1/sumAbs(flatGrad)) * lr
+		} else {
+			o.t = lr
+		}
+
+		// directional derivative, stop if we are no longer moving downhill
+		gtd := dot(flatGrad, o.d)
+		if gtd > -toleranceChange {
+			break
+		}
+
+		lsFuncEvals := 0
+		optCond := false
+		if o.LineSearch == StrongWolfe {
+			xInit := cloneParams(model)
+			objective := func(t float64) (float64, []float64) {
+				return directionalEvaluate(model, closure, xInit, t, o.d)
+			}
+			loss, flatGrad, o.t, lsFuncEvals = strongWolfe(objective, o.t, o.d, loss, flatGrad, gtd, toleranceChange)
+			addToParams(model, o.t, o.d)
+			optCond = maxAbs(flatGrad) <= toleranceGrad
+		} else {
+			addToParams(model, o.t, o.d)
+			if nIter != maxIter {
+				// re-evaluate the model at the new point, there is no need to on the last iteration
+				loss = closure()
+				flatGrad = gatherFlatGrad(model)
+				optCond = maxAbs(flatGrad) <= toleranceGrad
+				lsFuncEvals = 1
+			}
+		}
+
+		currentEvals += lsFuncEvals
+
+		// check the stopping conditions
+		if nIter == maxIter || currentEvals >= maxEval || optCond {
+			break
+		}
+		if maxAbs(o.d)*math.Abs(o.t) <= toleranceChange {
+			break
+		}
+		if math.Abs(loss-o.prevLoss) < toleranceChange {
+			break
+		}
+	}
+
+	return origLoss
+}
+
+// strongWolfe runs a bracketing line search until it finds a step size that satisfies the strong Wolfe conditions
+// it returns the loss and gradient at the chosen step along with the step itself and how many evaluations were used
+func strongWolfe(objective func(t float64) (float64, []float64), t float64, d []float64, f float64, g []float64, gtd, toleranceChange float64) (float64, []float64, float64, int) {
+
+	const (
+		c1    = 1e-4
+		c2    = 0.9
+		maxLS = 25
+	)
+
+	dNorm := maxAbs(d)
+	fNew, gNew := objective(t)
+	lsFuncEvals := 1
+	gtdNew := dot(gNew, d)
+
+	// bracketing phase, find an interval that contains a point satisfying the conditions
+	tPrev, fPrev, gPrev, gtdPrev := 0.0, f, g, gtd
+	done := false
+	lsIter := 0
+
+	var bracket, bracketF, bracketGtd []float64
+	var bracketG [][]float64
+
+	for lsIter < maxLS {
+		if fNew > f+c1*t*gtd || (lsIter > 1 && fNew >= fPrev) {
+			bracket = []float64{tPrev, t}
+			bracketF = []float64{fPrev, fNew}
+			bracketG = [][]float64{gPrev, gNew}
+			bracketGtd = []float64{gtdPrev, gtdNew}
+			break
+		}
+
+		if math.Abs(gtdNew) <= -c2*gtd {
+			bracket = []float64{t}
+			bracketF = []float64{fNew}
+			bracketG = [][]float64{gNew}
+			done = true
+			break
+		}
+
+		if gtdNew >= 0 {
+			bracket = []float64{tPrev, t}
+			bracketF = []float64{fPrev, fNew}
+			bracketG = [][]float64{gPrev, gNew}
+			bracketGtd = []float64{gtdPrev, gtdNew}
+			break
+		}
+
+		// extrapolate
+		minStep := t + 0.01*(t-tPrev)
+		maxStep := t * 10
+		tmp := t
+		t = cubicInterpolate(tPrev, fPrev, gtdPrev, t, fNew, gtdNew, minStep, maxStep)
+
+		tPrev, fPrev, gPrev, gtdPrev = tmp, fNew, gNew, gtdNew
+
+		fNew, gNew = objective(t)
+		lsFuncEvals++
+		gtdNew = dot(gNew, d)
+		lsIter++
+	}
+
+	// reached the max number of iterations without bracketing
+	if lsIter == maxLS {
+		bracket = []float64{0, t}
+		bracketF = []float64{f, fNew}
+		bracketG = [][]float64{g, gNew}
+	}
+
+	// zoom phase, shrink the bracket until we find a point satisfying the conditions
+	insufficientProgress := false
+	lowPos, highPos := 0, 0
+	if len(bracket) == 2 {
+		lowPos, highPos = orderBracket(bracketF)
+	}
+
+	for !done && lsIter < maxLS {
+		// the bracket is too small to make any more progress
+		if math.Abs(bracket[1]-bracket[0])*dNorm < toleranceChange {
+			break
+		}
+
+		t = cubicInterpolate(bracket[0], bracketF[0], bracketGtd[0], bracket[1], bracketF[1], bracketGtd[1], math.Min(bracket[0], bracket[1]), math.Max(bracket[0], bracket[1]))
+
+		// make sure we move far enough away from the edges of the bracket
+		bMax := math.Max(bracket[0], bracket[1])
+		bMin := math.Min(bracket[0], bracket[1])
+		eps := 0.1 * (bMax - bMin)
+		if math.Min(bMax-t, t-bMin) < eps {
+			if insufficientProgress || t >= bMax || t <= bMin {
+				if math.Abs(t-bMax) < math.Abs(t-bMin) {
+					t = bMax - eps
+				} else {
+					t = bMin + eps
+				}
+				insufficientProgress = false
+			} else {
+				insufficientProgress = true
+			}
+		} else {
+			insufficientProgress = false
+		}
+
+		fNew, gNew = objective(t)
+		lsFuncEvals++
+		gtdNew = dot(gNew, d)
+		lsIter++
+
+		if fNew > f+c1*t*gtd || fNew >= bracketF[lowPos] {
+			// armijo condition not satisfied or not lower than the lowest point
+			bracket[highPos] = t
+			bracketF[highPos] = fNew
+			bracketG[highPos] = gNew
+			bracketGtd[highPos] = gtdNew
+			lowPos, highPos = orderBracket(bracketF)
+		} else {
+			if math.Abs(gtdNew) <= -c2*gtd {
+				done = true
+			} else if gtdNew*(bracket[highPos]-bracket[lowPos]) >= 0 {
+				// the old high point becomes the new low point
+				bracket[highPos] = bracket[lowPos]
+				bracketF[highPos] = bracketF[lowPos]
+				bracketG[highPos] = bracketG[lowPos]
+				bracketGtd[highPos] = bracketGtd[lowPos]
+			}
+
+			// the new point becomes the new low point
+			bracket[lowPos] = t
+			bracketF[lowPos] = fNew
+			bracketG[lowPos] = gNew
+			bracketGtd[lowPos] = gtdNew
+		}
+	}
+
+	return bracketF[lowPos], bracketG[lowPos], bracket[lowPos], lsFuncEvals
+}
+
+// returns the positions of the lower and higher loss within a two point bracket
+func orderBracket(bracketF []float64) (int, int) {
+	if bracketF[0] <= bracketF[1] {
+		return 0, 1
+	}
+	return 1, 0
+}
+
+// cubicInterpolate finds the minimizer of the cubic that interpolates two points and their derivatives, clamped to [xMin, xMax]
+func cubicInterpolate(x1, f1, g1, x2, f2, g2, xMin, xMax float64) float64 {
+
+	d1 := g1 + g2 - 3*(f1-f2)/(x1-x2)
+	d2Square := d1*d1 - g1*g2
+	if d2Square < 0 {
+		return (xMin + xMax) / 2
+	}
+
+	d2 := math.Sqrt(d2Square)
+	var minPos float64
+	if x1 <= x2 {
+		minPos = x2 - (x2-x1)*((g2+d2-d1)/(g2-g1+2*d2))
+	} else {
+		minPos = x1 - (x1-x2)*((g1+d2-d1)/(g1-g2+2*d2))
+	}
+
+	return math.Min(math.Max(minPos, xMin), xMax)
+}
+
+// directionalEvaluate evaluates the loss and gradient at x + t*d and then restores the parameters to x
+func directionalEvaluate(model Module, closure Closure, x []float64, t float64, d []float64) (float64, []float64) {
+	addToParams(model, t, d)
+	loss := closure()
+	flatGrad := gatherFlatGrad(model)
+	setParams(model, x)
+	return loss, flatGrad
+}
+
+// flattens the gradients of every parameter into a single vector, missing gradients are treated as zero
+func gatherFlatGrad(model Module) []float64 {
+	var flat []float64
+	for _, p := range model.Parameters() {
+		if len(p.Grad) == 0 {
+			flat = append(flat, make([]float64, len(p.Data))...)
+			continue
+		}
+		flat = append(flat, p.Grad...)
+	}
+	return flat
+}
+
+// flattens the values of every parameter into a single vector
+func cloneParams(model Module) []float64 {
+	var flat []float64
+	for _, p := range model.Parameters() {
+		flat = append(flat, p.Data...)
+	}
+	return flat
+}
+
+// copies a flat vector back into the parameters of the model
+func setParams(model Module, flat []float64) {
+	offset := 0
+	for _, p := range model.Parameters() {
+		offset += copy(p.Data, flat[offset:offset+len(p.Data)])
+	}
+}
+
+// updates every parameter in place with params += t*d
+func addToParams(model Module, t float64, d []float64) {
+	offset := 0
+	for _, p := range model.Parameters() {
+		for i := range p.Data {
+			p.Data[i] += t * d[offset+i]
+		}
+		offset += len(p.Data)
+	}
+}
+
+func dot(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// y += alpha*x
+func axpy(alpha float64, x, y []float64) {
+	for i := range x {
+		y[i] += alpha * x[i]
+	}
+}
+
+func scale(a []float64, alpha float64) []float64 {
+	result := make([]float64, len(a))
+	for i, v := range a {
+		result[i] = v * alpha
+	}
+	return result
+}
+
+func sub(a, b []float64) []float64 {
+	result := make([]float64, len(a))
+	for i := range a {
+		result[i] = a[i] - b[i]
+	}
+	return result
+}
+
+func maxAbs(a []float64) float64 {
+	var m float64
+	for _, v := range a {
+		m = math.Max(m, math.Abs(v))
+	}
+	return m
+}
+
+func sumAbs(a []float64) float64 {
+	var sum float64
+	for _, v := range a {
+		sum += math.Abs(v)
+	}
+	return sum
+}
+
+// returns the curvature history and search state of the optimizer so it can be checkpointed
+// old_dirs and old_stps are stored as [history, numParams] matrices
+func (o *LBFGS) StateDict() map[string]*tensor.Tensor {
+	state := map[string]*tensor.Tensor{
+		"n_iter":    tensor.NewTensor(float64(o.nIter)),
+		"t":         tensor.NewTensor(o.t),
+		"h_diag":    tensor.NewTensor(o.hDiag),
+		"prev_loss": tensor.NewTensor(o.prevLoss),
+		"ro":        tensor.NewTensor(append([]float64{}, o.ro...)),
+	}
+
+	if o.d != nil {
+		state["d"] = tensor.NewTensor(append([]float64{}, o.d...))
+		state["prev_flat_grad"] = tensor.NewTensor(append([]float64{}, o.prevFlatGrad...))
+	}
+
+	state["old_dirs"] = stackHistory(o.oldDirs, len(o.d))
+	state["old_stps"] = stackHistory(o.oldSteps, len(o.d))
+
+	return state
+}
+
+// restores the state returned by StateDict
+func (o *LBFGS) LoadStateDict(state map[string]*tensor.Tensor) error {
+
+	for _, key := range []string{"n_iter", "t", "h_diag", "prev_loss", "ro", "old_dirs", "old_stps"} {
+		if _, ok := state[key]; !ok {
+			return fmt.Errorf("LBFGS state is missing %q", key)
+		}
+	}
+
+	// everything is checked before any of it is loaded, so a corrupt state leaves the optimizer untouched
+	for _, key := range []string{"n_iter", "t", "h_diag", "prev_loss"} {
+		if n := len(state[key].Data); n != 1 {
+			return fmt.Errorf("LBFGS state %q should hold a single value, got %d", key, n)
+		}
+	}
+	for _, key := range []string{"old_dirs", "old_stps"} {
+		history := state[key]
+		if len(history.Shape) != 2 || len(history.Data) != history.Shape[0]*history.Shape[1] {
+			return fmt.Errorf("LBFGS state %q should be a [history, params] matrix, got shape %v with %d values", key, history.Shape, len(history.Data))
+		}
+		if history.Shape[0] != len(state["ro"].Data) {
+			return fmt.Errorf("LBFGS state %q has %d entries but there are %d ro values", key, history.Shape[0], len(state["ro"].Data))
+		}
+	}
+
+	o.nIter = int(state["n_iter"].Data[0])
+	o.t = state["t"].Data[0]
+	o.hDiag = state["h_diag"].Data[0]
+	o.prevLoss = state["prev_loss"].Data[0]
+	o.ro = append([]float64{}, state["ro"].Data...)
+
+	o.d, o.prevFlatGrad = nil, nil
+	if d, ok := state["d"]; ok {
+		o.d = append([]float64{}, d.Data...)
+	}
+	if g, ok := state["prev_flat_grad"]; ok {
+		o.prevFlatGrad = append([]float64{}, g.Data...)
+	}
+
+	o.oldDirs = unstackHistory(state["old_dirs"])
+	o.oldSteps = unstackHistory(state["old_stps"])
+
+	return nil
+}
+
+// stacks the history vectors into a single [len(history), numParams] tensor
+func stackHistory(history [][]float64, numParams int) *tensor.Tensor {
+	data := make([]float64, 0, len(history)*numParams)
+	for _, v := range history {
+		data = append(data, v...)
+	}
+	return tensor.NewTensor(data, len(history), numParams)
+}
+
+// splits a [len(history), numParams] tensor back into its rows
+func unstackHistory(t *tensor.Tensor) [][]float64 {
+	if len(t.Shape) != 2 || t.Shape[0] == 0 {
+		return nil
+	}
+	history := make([][]float64, t.Shape[0])
+	for i := range history {
+		history[i] = append([]float64{}, t.Data[i*t.Shape[1]:(i+1)*t.Shape[1]]...)
+	}
+	return history
+}
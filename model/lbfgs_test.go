@@ -0,0 +1,175 @@
+package model
+
+import (
+	"context"
+	"gotorch/tensor"
+	"math"
+	"testing"
+)
+
+// rosenbrock is a two parameter module used to check that LBFGS can minimise the rosenbrock function f(x,y) = (1-x)^2 + 100(y-x^2)^2
+type rosenbrock struct {
+	xy   []float64
+	grad []float64
+}
+
+func (r *rosenbrock) Forward(input *tensor.Tensor) *tensor.Tensor { return input }
+
+func (r *rosenbrock) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor { return gradOutput }
+
+func (r *rosenbrock) Parameters() []*Parameter {
+	return []*Parameter{{Name: "xy", Data: r.xy, Grad: r.grad}}
+}
+
+func (r *rosenbrock) closure() float64 {
+	x, y := r.xy[0], r.xy[1]
+	r.grad = []float64{-2*(1-x) - 400*x*(y-x*x), 200 * (y - x*x)}
+	return (1-x)*(1-x) + 100*(y-x*x)*(y-x*x)
+}
+
+func linearRegressionClosure(model *Linear, inputs, targets *tensor.Tensor) Closure {
+	return func() float64 {
+		predictions := model.Forward(inputs)
+		var loss float64
+		gradOutput := make([]float64, len(predictions.Data))
+		for i := range predictions.Data {
+			diff := predictions.Data[i] - targets.Data[i]
+			loss += diff * diff / float64(len(predictions.Data))
+			gradOutput[i] = 2 * diff / float64(len(predictions.Data))
+		}
+		model.Backward(inputs, tensor.NewTensor(gradOutput, predictions.Shape...))
+		return loss
+	}
+}
+
+func TestLBFGSConvergesOnLinearRegression(t *testing.T) {
+
+	inputs := tensor.NewTensor([][]float64{{1.0, 2.0}, {3.0, 4.0}})
+	targets := tensor.NewTensor([][]float64{{5.0}, {11.0}})
+
+	model := &Linear{Weights: []float64{1.0, 2.0}, Biases: []float64{0.5}}
+	closure := linearRegressionClosure(model, inputs, targets)
+
+	optimizer := &LBFGS{}
+	for i := 0; i < 3; i++ {
+		optimizer.Step(model, closure)
+	}
+
+	if loss := closure(); loss > 1e-10 {
+		t.Errorf("Expected LBFGS to converge, got loss %v", loss)
+	}
+}
+
+func TestLBFGSStrongWolfeConvergesOnLinearRegression(t *testing.T) {
+
+	inputs := tensor.NewTensor([][]float64{{1.0, 2.0}, {3.0, 4.0}})
+	targets := tensor.NewTensor([][]float64{{5.0}, {11.0}})
+
+	model := &Linear{Weights: []float64{1.0, 2.0}, Biases: []float64{0.5}}
+	closure := linearRegressionClosure(model, inputs, targets)
+
+	optimizer := &LBFGS{LineSearch: StrongWolfe}
+	initialLoss := optimizer.Step(model, closure)
+
+	if initialLoss != 0.25 {
+		t.Errorf("Expected Step to return the initial loss 0.25, got %v", initialLoss)
+	}
+
+	if loss := closure(); loss > 1e-10 {
+		t.Errorf("Expected LBFGS to converge in a single step, got loss %v", loss)
+	}
+}
+
+func TestLBFGSStrongWolfeRosenbrock(t *testing.T) {
+
+	r := &rosenbrock{xy: []float64{-1.5, 2.0}}
+	optimizer := &LBFGS{LineSearch: StrongWolfe, MaxIter: 100, HistorySize: 10}
+
+	for i := 0; i < 5; i++ {
+		optimizer.Step(r, r.closure)
+	}
+
+	if math.Abs(r.xy[0]-1) > 1e-4 || math.Abs(r.xy[1]-1) > 1e-4 {
+		t.Errorf("Expected LBFGS to find the minimum at (1, 1), got %v", r.xy)
+	}
+}
+
+func TestLBFGSHistorySize(t *testing.T) {
+
+	r := &rosenbrock{xy: []float64{-1.5, 2.0}}
+	optimizer := &LBFGS{LineSearch: StrongWolfe, MaxIter: 50, HistorySize: 3}
+	optimizer.Step(r, r.closure)
+
+	if len(optimizer.oldDirs) > 3 || len(optimizer.oldSteps) > 3 || len(optimizer.ro) > 3 {
+		t.Errorf("Expected at most 3 history entries, got %d", len(optimizer.oldDirs))
+	}
+}
+
+func TestLBFGSLoadStateDictInvalid(t *testing.T) {
+
+	r := &rosenbrock{xy: []float64{-1.5, 2.0}}
+	source := &LBFGS{LineSearch: StrongWolfe, MaxIter: 5}
+	source.Step(r, r.closure)
+
+	corruptions := map[string]func(state map[string]*tensor.Tensor){
+		"empty n_iter":    func(state map[string]*tensor.Tensor) { state["n_iter"] = tensor.NewTensor([]float64{}) },
+		"empty t":         func(state map[string]*tensor.Tensor) { state["t"] = tensor.NewTensor([]float64{}) },
+		"two h_diag":      func(state map[string]*tensor.Tensor) { state["h_diag"] = tensor.NewTensor([]float64{1, 2}) },
+		"empty prev_loss": func(state map[string]*tensor.Tensor) { state["prev_loss"] = tensor.NewTensor([]float64{}) },
+		"short ro":        func(state map[string]*tensor.Tensor) { state["ro"] = tensor.NewTensor([]float64{1}) },
+		"short old_dirs":  func(state map[string]*tensor.Tensor) { state["old_dirs"] = tensor.NewTensor([]float64{1, 2}, 1, 2) },
+		"truncated old_stps": func(state map[string]*tensor.Tensor) {
+			state["old_stps"] = &tensor.Tensor{Data: []float64{1}, Shape: state["old_stps"].Shape}
+		},
+	}
+
+	for name, corrupt := range corruptions {
+		state := source.StateDict()
+		corrupt(state)
+
+		target := &LBFGS{}
+		if err := target.LoadStateDict(state); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+		if target.nIter != 0 || target.ro != nil {
+			t.Errorf("Expected a failed load to leave the optimizer untouched for %s", name)
+		}
+	}
+
+	target := &LBFGS{}
+	if err := target.LoadStateDict(source.StateDict()); err != nil {
+		t.Errorf("Failed to load a valid state: %v", err)
+	}
+}
+
+func TestLBFGSTrain(t *testing.T) {
+
+	inputs := tensor.NewTensor([][]float64{{1.0, 2.0}, {3.0, 4.0}})
+	targets := tensor.NewTensor([][]float64{{5.0}, {11.0}})
+
+	model := &Linear{Weights: []float64{1.0, 2.0}, Biases: []float64{0.5}}
+	trainer := &Trainer{
+		Model:     model,
+		Loss:      MSELoss,
+		Optimizer: &LBFGS{LineSearch: StrongWolfe},
+		Train:     FullBatch(inputs, targets),
+		Epochs:    5,
+	}
+	if _, err := trainer.Fit(context.Background()); err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+
+	prediction := model.Sample(model, tensor.NewTensor([][]float64{{5.0, 6.0}}))
+	if math.Abs(prediction.Data[0]-17) > 1e-4 {
+		t.Errorf("Expected prediction 17, got %v", prediction.Data[0])
+	}
+}
+
+func TestCubicInterpolate(t *testing.T) {
+
+	// f(x) = (x-1)^2 sampled at 0 and 3 has its minimum at 1
+	result := cubicInterpolate(0, 1, -2, 3, 4, 4, 0, 3)
+	if math.Abs(result-1) > 1e-12 {
+		t.Errorf("Expected minimum at 1, got %v", result)
+	}
+}
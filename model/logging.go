@@ -0,0 +1,318 @@
+package model
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+)
+
+/*
+Training progress is reported through the ProgressLogger callback rather than printed. The logger turns the trainer's
+batch and epoch hooks into Events, works out timing, throughput and ETA, and hands every Event to each of its MetricSinks.
+Sinks are provided for log/slog, CSV and JSON lines, anything else (a metrics service, TensorBoard) just has to implement MetricSink.
+*/
+
+// EventKind distinguishes per-batch progress events from end of epoch events
+type EventKind string
+
+const (
+	BatchEvent EventKind = "batch"
+	EpochEvent EventKind = "epoch"
+)
+
+// Event is a single progress report from the training loop
+// Batch is -1 for epoch events, ETA is zero until there is enough timing information to estimate it
+type Event struct {
+	Kind             EventKind
+	Time             time.Time
+	Epoch            int
+	Epochs           int
+	Batch            int
+	Metrics          Metrics
+	Elapsed          time.Duration // time since the start of training
+	SamplesPerSecond float64       // throughput over the current epoch
+	ETA              time.Duration // estimated time until training finishes
+}
+
+// returns the names of the metrics in the event in sorted order so sinks write them deterministically
+func (e Event) metricNames() []string {
+	names := make([]string, 0, len(e.Metrics))
+	for name := range e.Metrics {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// MetricSink receives the progress events of a training run
+type MetricSink interface {
+	Log(event Event) error
+}
+
+// ProgressLogger is a Callback that reports training progress to a set of sinks
+// batch events are sent every BatchEvery batches (0 disables them) and epoch events every EpochEvery epochs (0 means every epoch)
+type ProgressLogger struct {
+	BaseCallback
+	Sinks      []MetricSink
+	BatchEvery int
+	EpochEvery int
+
+	now             func() time.Time
+	trainStart      time.Time
+	epochStart      time.Time
+	epochSamples    float64
+	epochBatches    int
+	batchesPerEpoch int
+}
+
+func (p *ProgressLogger) clock() time.Time {
+	if p.now != nil {
+		return p.now()
+	}
+	return time.Now()
+}
+
+func (p *ProgressLogger) OnTrainStart(t *Trainer) error {
+	p.trainStart = p.clock()
+	p.batchesPerEpoch = 0
+	return nil
+}
+
+func (p *ProgressLogger) OnEpochStart(t *Trainer, epoch int) error {
+	p.epochStart = p.clock()
+	p.epochSamples = 0
+	p.epochBatches = 0
+	return nil
+}
+
+func (p *ProgressLogger) OnBatchEnd(t *Trainer, epoch, batch int, metrics Metrics) error {
+
+	p.epochSamples += metrics[BatchSizeMetric]
+	p.epochBatches++
+
+	if p.BatchEvery <= 0 || (batch+1)%p.BatchEvery != 0 {
+		return nil
+	}
+
+	now := p.clock()
+	event := p.newEvent(BatchEvent, now, t, epoch, batch, metrics)
+
+	// we only know how many batches make up an epoch once the first epoch has finished
+	if p.batchesPerEpoch > 0 {
+		batchDuration := now.Sub(p.trainStart) / time.Duration(epoch*p.batchesPerEpoch+batch+1)
+		remaining := (t.Epochs-epoch-1)*p.batchesPerEpoch + max(p.batchesPerEpoch-batch-1, 0)
+		event.ETA = batchDuration * time.Duration(remaining)
+	}
+
+	return p.log(event)
+}
+
+func (p *ProgressLogger) OnEpochEnd(t *Trainer, epoch int, metrics Metrics) error {
+
+	if p.batchesPerEpoch == 0 {
+		p.batchesPerEpoch = p.epochBatches
+	}
+
+	every := max(p.EpochEvery, 1)
+	if (epoch+1)%every != 0 && epoch != t.Epochs-1 {
+		return nil
+	}
+
+	now := p.clock()
+	event := p.newEvent(EpochEvent, now, t, epoch, -1, metrics)
+	event.ETA = now.Sub(p.trainStart) / time.Duration(epoch+1) * time.Duration(t.Epochs-epoch-1)
+
+	return p.log(event)
+}
+
+func (p *ProgressLogger) newEvent(kind EventKind, now time.Time, t *Trainer, epoch, batch int, metrics Metrics) Event {
+
+	event := Event{
+		Kind:    kind,
+		Time:    now,
+		Epoch:   epoch,
+		Epochs:  t.Epochs,
+		Batch:   batch,
+		Metrics: Metrics{},
+		Elapsed: now.Sub(p.trainStart),
+	}
+
+	for name, value := range metrics {
+		if name != BatchSizeMetric {
+			event.Metrics[name] = value
+		}
+	}
+
+	if seconds := now.Sub(p.epochStart).Seconds(); seconds > 0 {
+		event.SamplesPerSecond = p.epochSamples / seconds
+	}
+
+	return event
+}
+
+func (p *ProgressLogger) log(event Event) error {
+	for _, sink := range p.Sinks {
+		if err := sink.Log(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SlogSink writes progress events to a structured logger
+type SlogSink struct {
+	Logger *slog.Logger
+	Level  slog.Level
+}
+
+// NewSlogSink returns a sink that logs every event at info level, a nil logger uses slog.Default()
+func NewSlogSink(logger *slog.Logger) *SlogSink {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogSink{Logger: logger, Level: slog.LevelInfo}
+}
+
+func (s *SlogSink) Log(event Event) error {
+
+	attrs := []slog.Attr{
+		slog.Int("epoch", event.Epoch),
+		slog.Int("epochs", event.Epochs),
+	}
+	if event.Kind == BatchEvent {
+		attrs = append(attrs, slog.Int("batch", event.Batch))
+	}
+
+	metrics := make([]any, 0, len(event.Metrics))
+	for _, name := range event.metricNames() {
+		metrics = append(metrics, slog.Float64(name, event.Metrics[name]))
+	}
+
+	attrs = append(attrs,
+		slog.Group("metrics", metrics...),
+		slog.Duration("elapsed", event.Elapsed),
+		slog.Float64("samples_per_second", event.SamplesPerSecond),
+		slog.Duration("eta", event.ETA),
+	)
+
+	s.Logger.LogAttrs(context.Background(), s.Level, "training "+string(event.Kind), attrs...)
+
+	return nil
+}
+
+// CSVSink writes events of a single kind as rows of a CSV file, the columns are fixed by the first event written
+// metrics missing from later events are left empty and any new metrics are dropped
+type CSVSink struct {
+	kind    EventKind
+	writer  *csv.Writer
+	columns []string
+}
+
+// NewCSVSink returns a sink that writes the events of the given kind to w
+func NewCSVSink(w io.Writer, kind EventKind) *CSVSink {
+	return &CSVSink{kind: kind, writer: csv.NewWriter(w)}
+}
+
+var csvSinkFixedColumns = []string{"time", "epoch", "batch", "elapsed_seconds", "samples_per_second", "eta_seconds"}
+
+func (c *CSVSink) Log(event Event) error {
+
+	if event.Kind != c.kind {
+		return nil
+	}
+
+	// write the header on the first event
+	if c.columns == nil {
+		c.columns = event.metricNames()
+		if err := c.writer.Write(append(slices.Clone(csvSinkFixedColumns), c.columns...)); err != nil {
+			return err
+		}
+	}
+
+	record := []string{
+		event.Time.Format(time.RFC3339Nano),
+		strconv.Itoa(event.Epoch),
+		strconv.Itoa(event.Batch),
+		formatFloat(event.Elapsed.Seconds()),
+		formatFloat(event.SamplesPerSecond),
+		formatFloat(event.ETA.Seconds()),
+	}
+	for _, name := range c.columns {
+		value, ok := event.Metrics[name]
+		if !ok {
+			record = append(record, "")
+			continue
+		}
+		record = append(record, formatFloat(value))
+	}
+
+	if err := c.writer.Write(record); err != nil {
+		return err
+	}
+
+	// flush every row so the file can be followed while training is running
+	c.writer.Flush()
+
+	return c.writer.Error()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// JSONLinesSink writes every event as a single JSON object per line
+type JSONLinesSink struct {
+	encoder *json.Encoder
+}
+
+// NewJSONLinesSink returns a sink that writes events to w
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{encoder: json.NewEncoder(w)}
+}
+
+// jsonEvent is the JSON representation of an Event, durations are written in seconds
+// metrics are pointers because JSON can't represent NaN or Inf, which a diverging loss easily produces, so those are written as null
+type jsonEvent struct {
+	Kind             EventKind           `json:"kind"`
+	Time             time.Time           `json:"time"`
+	Epoch            int                 `json:"epoch"`
+	Epochs           int                 `json:"epochs"`
+	Batch            *int                `json:"batch,omitempty"`
+	Metrics          map[string]*float64 `json:"metrics"`
+	ElapsedSeconds   float64             `json:"elapsed_seconds"`
+	SamplesPerSecond float64             `json:"samples_per_second"`
+	ETASeconds       float64             `json:"eta_seconds"`
+}
+
+func (j *JSONLinesSink) Log(event Event) error {
+
+	out := jsonEvent{
+		Kind:             event.Kind,
+		Time:             event.Time,
+		Epoch:            event.Epoch,
+		Epochs:           event.Epochs,
+		Metrics:          map[string]*float64{},
+		ElapsedSeconds:   event.Elapsed.Seconds(),
+		SamplesPerSecond: event.SamplesPerSecond,
+		ETASeconds:       event.ETA.Seconds(),
+	}
+	if event.Kind == BatchEvent {
+		out.Batch = &event.Batch
+	}
+
+	for name, value := range event.Metrics {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			out.Metrics[name] = nil
+			continue
+		}
+		out.Metrics[name] = &value
+	}
+
+	return j.encoder.Encode(out)
+}
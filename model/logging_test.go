@@ -0,0 +1,207 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"gotorch/tensor"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingSink keeps every event it receives
+type recordingSink struct {
+	events []Event
+}
+
+func (r *recordingSink) Log(event Event) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+// returns a clock that advances by one second every time it is read
+func fakeClock() func() time.Time {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+}
+
+func newLoggedTrainer(epochs int, logger *ProgressLogger) *Trainer {
+	trainer := newTestTrainer(epochs)
+	trainer.Train = &rowLoader{
+		inputs:  tensor.NewTensor([][]float64{{1.0, 2.0}, {3.0, 4.0}}),
+		targets: tensor.NewTensor([][]float64{{5.0}, {11.0}}),
+	}
+	trainer.Callbacks = []Callback{logger}
+	return trainer
+}
+
+func TestProgressLoggerEvents(t *testing.T) {
+
+	sink := &recordingSink{}
+	logger := &ProgressLogger{Sinks: []MetricSink{sink}, BatchEvery: 1, now: fakeClock()}
+
+	if _, err := newLoggedTrainer(3, logger).Fit(context.Background()); err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+
+	// two batch events and one epoch event for each of the three epochs
+	if len(sink.events) != 9 {
+		t.Fatalf("Expected 9 events, got %d", len(sink.events))
+	}
+
+	first := sink.events[0]
+	if first.Kind != BatchEvent || first.Epoch != 0 || first.Batch != 0 {
+		t.Errorf("Unexpected first event %+v", first)
+	}
+	if _, ok := first.Metrics[BatchSizeMetric]; ok {
+		t.Errorf("Expected the batch size to be removed from the event metrics")
+	}
+	if first.SamplesPerSecond != 1 {
+		t.Errorf("Expected 1 sample per second, got %v", first.SamplesPerSecond)
+	}
+	if first.ETA != 0 {
+		t.Errorf("Expected no ETA before the first epoch completes, got %v", first.ETA)
+	}
+
+	epochEnd := sink.events[2]
+	if epochEnd.Kind != EpochEvent || epochEnd.Batch != -1 || epochEnd.ETA <= 0 {
+		t.Errorf("Unexpected epoch event %+v", epochEnd)
+	}
+
+	if second := sink.events[3]; second.ETA <= 0 {
+		t.Errorf("Expected an ETA once the number of batches per epoch is known, got %v", second.ETA)
+	}
+
+	if last := sink.events[8]; last.Kind != EpochEvent || last.ETA != 0 {
+		t.Errorf("Expected the final epoch event to have no time remaining, got %+v", last)
+	}
+}
+
+func TestProgressLoggerFrequency(t *testing.T) {
+
+	sink := &recordingSink{}
+	logger := &ProgressLogger{Sinks: []MetricSink{sink}, EpochEvery: 4, now: fakeClock()}
+
+	if _, err := newLoggedTrainer(10, logger).Fit(context.Background()); err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+
+	// epochs 3 and 7, plus the final epoch which is always logged
+	var epochs []int
+	for _, event := range sink.events {
+		if event.Kind != EpochEvent {
+			t.Errorf("Expected no batch events, got %+v", event)
+		}
+		epochs = append(epochs, event.Epoch)
+	}
+	if len(epochs) != 3 || epochs[0] != 3 || epochs[1] != 7 || epochs[2] != 9 {
+		t.Errorf("Expected epoch events for 3, 7 and 9, got %v", epochs)
+	}
+}
+
+func TestSlogSink(t *testing.T) {
+
+	var buf bytes.Buffer
+	sink := NewSlogSink(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	event := Event{Kind: EpochEvent, Epoch: 2, Epochs: 5, Batch: -1, Metrics: Metrics{"loss": 0.5}}
+	if err := sink.Log(event); err != nil {
+		t.Fatalf("Failed to log: %v", err)
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Failed to parse log line %q: %v", buf.String(), err)
+	}
+
+	if record["msg"] != "training epoch" || record["epoch"] != 2.0 {
+		t.Errorf("Unexpected log record %v", record)
+	}
+	if _, ok := record["batch"]; ok {
+		t.Errorf("Expected epoch events to not log a batch, got %v", record)
+	}
+	if metrics, ok := record["metrics"].(map[string]any); !ok || metrics["loss"] != 0.5 {
+		t.Errorf("Expected the loss in the metrics group, got %v", record["metrics"])
+	}
+}
+
+func TestCSVSink(t *testing.T) {
+
+	var buf bytes.Buffer
+	sink := NewCSVSink(&buf, EpochEvent)
+
+	events := []Event{
+		{Kind: EpochEvent, Epoch: 0, Batch: -1, Metrics: Metrics{"loss": 0.5, "val_loss": 0.75}},
+		{Kind: BatchEvent, Epoch: 1, Batch: 0, Metrics: Metrics{"loss": 0.4}},
+		{Kind: EpochEvent, Epoch: 1, Batch: -1, Metrics: Metrics{"loss": 0.25}},
+	}
+	for _, event := range events {
+		if err := sink.Log(event); err != nil {
+			t.Fatalf("Failed to log: %v", err)
+		}
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV: %v", err)
+	}
+
+	if len(records) != 3 {
+		t.Fatalf("Expected a header and two epoch rows, got %v", records)
+	}
+	if strings.Join(records[0], ",") != "time,epoch,batch,elapsed_seconds,samples_per_second,eta_seconds,loss,val_loss" {
+		t.Errorf("Unexpected header %v", records[0])
+	}
+	if records[1][6] != "0.5" || records[1][7] != "0.75" {
+		t.Errorf("Unexpected first row %v", records[1])
+	}
+	if records[2][6] != "0.25" || records[2][7] != "" {
+		t.Errorf("Expected the missing validation loss to be empty, got %v", records[2])
+	}
+}
+
+func TestJSONLinesSink(t *testing.T) {
+
+	var buf bytes.Buffer
+	sink := NewJSONLinesSink(&buf)
+
+	events := []Event{
+		{Kind: BatchEvent, Epoch: 0, Batch: 3, Metrics: Metrics{"loss": 0.5}, ETA: 2 * time.Second},
+		{Kind: EpochEvent, Epoch: 0, Batch: -1, Metrics: Metrics{"loss": math.Inf(1)}},
+	}
+	for _, event := range events {
+		if err := sink.Log(event); err != nil {
+			t.Fatalf("Failed to log: %v", err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+
+	var batch, epoch map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &batch); err != nil {
+		t.Fatalf("Failed to parse %q: %v", lines[0], err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &epoch); err != nil {
+		t.Fatalf("Failed to parse %q: %v", lines[1], err)
+	}
+
+	if batch["kind"] != "batch" || batch["batch"] != 3.0 || batch["eta_seconds"] != 2.0 {
+		t.Errorf("Unexpected batch line %v", batch)
+	}
+	if _, ok := epoch["batch"]; ok {
+		t.Errorf("Expected epoch lines to omit the batch, got %v", epoch)
+	}
+	if metrics := epoch["metrics"].(map[string]any); metrics["loss"] != nil {
+		t.Errorf("Expected an infinite loss to be written as null, got %v", metrics["loss"])
+	}
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	lf "gotorch/loss_functions"
+	"gotorch/tensor"
+)
+
+// Loss computes a scalar loss and the gradient of that loss with respect to the predictions
+type Loss interface {
+	Forward(predictions, targets *tensor.Tensor) *tensor.Tensor
+	Backward(predictions, targets *tensor.Tensor) *tensor.Tensor
+}
+
+// LossFunc adapts a pair of loss and gradient functions from the lf package to the Loss interface
+type LossFunc struct {
+	Fn   func(predictions, targets *tensor.Tensor) *tensor.Tensor
+	Grad func(predictions, targets *tensor.Tensor) *tensor.Tensor
+}
+
+func (l LossFunc) Forward(predictions, targets *tensor.Tensor) *tensor.Tensor {
+	return l.Fn(predictions, targets)
+}
+
+func (l LossFunc) Backward(predictions, targets *tensor.Tensor) *tensor.Tensor {
+	return l.Grad(predictions, targets)
+}
+
+// the losses implemented in the lf package
+var (
+	MSELoss                     = LossFunc{Fn: lf.MSELoss, Grad: lf.MSELossBackward}
+	BinaryCrossEntropyLoss      = LossFunc{Fn: lf.BinaryCrossEntropyLoss, Grad: lf.BinaryCrossEntropyLossBackward}
+	CategoricalCrossEntropyLoss = LossFunc{Fn: lf.CategoricalCrossEntropyLoss, Grad: lf.CategoricalCrossEntropyLossBackward}
+)
@@ -3,7 +3,6 @@ package model
 
 import (
 	"fmt"
-	lf "gotorch/loss_functions"
 	"gotorch/tensor"
 )
 
@@ -12,6 +11,33 @@ type Model interface {
 	Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor
 }
 
+// Parameter is a learnable slice of a model together with the gradient accumulated for it during the backward pass
+// Data shares its backing array with the model so optimizers can update it in place
+// Shape is how Data is laid out when it's saved, nil means a vector of len(Data)
+type Parameter struct {
+	Name  string
+	Data  []float64
+	Grad  []float64
+	Shape []int
+}
+
+// Module is a Model whose learnable parameters can be updated by an Optimizer
+type Module interface {
+	Model
+	Parameters() []*Parameter
+}
+
+// Closure re-evaluates the model, runs the backward pass and returns the loss
+// first-order optimizers call it at most once per step, LBFGS calls it once per function evaluation
+type Closure func() float64
+
+// Optimizer updates the parameters of a module using the gradients computed during the backward pass
+// closure may be nil for optimizers that only need the gradients that are already populated
+// Step returns the loss from the first call of closure, the loss before the update
+type Optimizer interface {
+	Step(model Module, closure Closure) float64
+}
+
 type Linear struct {
 	Weights     []float64
 	Biases      []float64
@@ -19,6 +45,25 @@ type Linear struct {
 	GradBiases  []float64
 }
 
+// returns the weights and biases of the layer along with their gradients
+// the weight has shape [1, inputs] to match a PyTorch Linear layer with a single output
+func (m *Linear) Parameters() []*Parameter {
+	return []*Parameter{
+		{Name: "weight", Data: m.Weights, Grad: m.GradWeights, Shape: []int{1, len(m.Weights)}},
+		{Name: "bias", Data: m.Biases, Grad: m.GradBiases},
+	}
+}
+
+// StateDict returns a copy of the weight and bias, see StateDictOf
+func (m *Linear) StateDict() *StateDict {
+	return StateDictOf(m)
+}
+
+// LoadStateDict copies a saved weight and bias into the layer, see the package level LoadStateDict
+func (m *Linear) LoadStateDict(state *StateDict, strict bool) (LoadResult, error) {
+	return LoadStateDict(m, state, strict)
+}
+
 // Defines the forward propagation function
 func (m *Linear) Forward(input *tensor.Tensor) *tensor.Tensor {
 
@@ -70,36 +115,6 @@ func (m *Linear) Backward(input *tensor.Tensor, gradOutput *tensor.Tensor) *tens
 
 }
 
-// Defines the Train function which actually trains a model
-func (m *Linear) Train(model *Linear, inputs, targets *tensor.Tensor, epochs int, learningRate float64) {
-	optimizer := &SGD{LearningRate: learningRate}
-
-	for epoch := 0; epoch < epochs; epoch++ {
-		// forward pass
-		predictions := model.Forward(inputs)
-
-		// compute loss
-		loss := lf.MSELoss(predictions, targets)
-
-		// compute the gradient of the loss with respect to the output (gradOutput)
-		gradOutputData := make([]float64, len(predictions.Data))
-		for i := 0; i < len(predictions.Data); i++ {
-			gradOutputData[i] = 2 * (predictions.Data[i] - targets.Data[i]) / float64(len(predictions.Data))
-		}
-		gradOutput := tensor.NewTensor(gradOutputData, predictions.Shape...)
-
-		// backward pass
-		model.Backward(inputs, gradOutput)
-
-		// update weights
-		optimizer.Step(model)
-
-		if epoch%10 == 0 {
-			fmt.Printf("Epoch %d: Loss = %f\n", epoch, loss)
-		}
-	}
-}
-
 // Defines a sample function which takes in a model and an input tensor and returns a new tensor
 func (m *Linear) Sample(model *Linear, newInput *tensor.Tensor) *tensor.Tensor {
 	return model.Forward(newInput)
@@ -110,14 +125,27 @@ type SGD struct {
 }
 
 // implements stochastic gradient descent optimization function which updates the models weights and biases using the gradients computed during the backward pass
-func (s *SGD) Step(model *Linear) {
+// if a closure is given it is evaluated first to populate the gradients and its loss is returned
+func (s *SGD) Step(model Module, closure Closure) float64 {
 
-	for i := range model.Weights {
-		model.Weights[i] -= s.LearningRate * model.GradWeights[i]
+	var loss float64
+	if closure != nil {
+		loss = closure()
 	}
 
-	for i := range model.Biases {
-		model.Biases[i] -= s.LearningRate * model.GradBiases[i]
+	for _, p := range model.Parameters() {
+		for i := range p.Grad {
+			p.Data[i] -= s.LearningRate * p.Grad[i]
+		}
 	}
 
+	return loss
+}
+
+// StatefulOptimizer is an Optimizer that carries state between steps, such as the curvature history of LBFGS
+// the state is exposed as named tensors so it can be checkpointed alongside the model
+type StatefulOptimizer interface {
+	Optimizer
+	StateDict() map[string]*tensor.Tensor
+	LoadStateDict(state map[string]*tensor.Tensor) error
 }
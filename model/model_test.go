@@ -3,6 +3,7 @@ package model
 import (
 	"fmt"
 	"gotorch/tensor"
+	"math"
 	"testing"
 )
 
@@ -193,7 +194,7 @@ func TestSGDStep(t *testing.T) {
 	expectedWeights := []float64{0.5 - 0.1*0.1, -1.5 - 0.1*(-0.2)} // {0.49, -1.48}
 	expectedBiases := []float64{0.0 - 0.1*0.05}                    // {-0.005}
 
-	optimizer.Step(model)
+	optimizer.Step(model, nil)
 
 	// Check if the weights are updated correctly
 	for i, weight := range model.Weights {
@@ -203,7 +204,8 @@ func TestSGDStep(t *testing.T) {
 	}
 
 	// Check if the biases are updated correctly
-	if model.Biases[0] != expectedBiases[0] {
+	// expectedBiases is folded at compile time with exact constant arithmetic so allow for float64 rounding
+	if math.Abs(model.Biases[0]-expectedBiases[0]) > 1e-12 {
 		t.Errorf("Bias: expected %f, got %f", expectedBiases[0], model.Biases[0])
 	}
 }
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"fmt"
+	"gotorch/tensor"
+)
+
+// Sequential chains layers together, the output of each layer is the input of the next
+// parameters and buffers are named after the layer's index, so the weight of the first layer is "0.weight"
+type Sequential struct {
+	Layers []Model
+
+	// the input each layer saw in the last forward pass, needed by Backward
+	inputs []*tensor.Tensor
+}
+
+// NewSequential returns a Sequential running layers in order
+func NewSequential(layers ...Model) *Sequential {
+	return &Sequential{Layers: layers}
+}
+
+// Forward runs every layer in turn
+func (s *Sequential) Forward(input *tensor.Tensor) *tensor.Tensor {
+
+	s.inputs = make([]*tensor.Tensor, len(s.Layers))
+
+	output := input
+	for i, layer := range s.Layers {
+		s.inputs[i] = output
+		output = layer.Forward(output)
+	}
+
+	return output
+}
+
+// Backward runs the layers backwards, passing each one the input it saw in the forward pass
+// if the last forward pass wasn't for input it is run again first
+func (s *Sequential) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+
+	if len(s.inputs) != len(s.Layers) || (len(s.inputs) > 0 && s.inputs[0] != input) {
+		s.Forward(input)
+	}
+
+	grad := gradOutput
+	for i := len(s.Layers) - 1; i >= 0; i-- {
+		grad = s.Layers[i].Backward(s.inputs[i], grad)
+	}
+
+	return grad
+}
+
+// Parameters returns the parameters of every layer that is a Module, prefixed with the layer's index
+// layers without parameters, like activations, are skipped
+func (s *Sequential) Parameters() []*Parameter {
+
+	var params []*Parameter
+	for i, layer := range s.Layers {
+		if module, ok := layer.(Module); ok {
+			params = append(params, prefixed(i, module.Parameters())...)
+		}
+	}
+
+	return params
+}
+
+// Buffers returns the buffers of every layer that has them, prefixed with the layer's index
+func (s *Sequential) Buffers() []*Parameter {
+
+	var buffers []*Parameter
+	for i, layer := range s.Layers {
+		if buffered, ok := layer.(BufferedModule); ok {
+			buffers = append(buffers, prefixed(i, buffered.Buffers())...)
+		}
+	}
+
+	return buffers
+}
+
+// StateDict returns a copy of the parameters and buffers of every layer, named after the layer's index
+func (s *Sequential) StateDict() *StateDict {
+	return StateDictOf(s)
+}
+
+// LoadStateDict copies a saved state into every layer, see the package level LoadStateDict
+func (s *Sequential) LoadStateDict(state *StateDict, strict bool) (LoadResult, error) {
+	return LoadStateDict(s, state, strict)
+}
+
+// prefixed returns copies of params named "index.name", the data is still shared with the layer
+func prefixed(index int, params []*Parameter) []*Parameter {
+
+	renamed := make([]*Parameter, len(params))
+	for i, p := range params {
+		renamed[i] = &Parameter{Name: fmt.Sprintf("%d.%s", index, p.Name), Data: p.Data, Grad: p.Grad, Shape: p.Shape}
+	}
+
+	return renamed
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"gotorch/tensor"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSequentialForwardBackward(t *testing.T) {
+
+	first := &Linear{Weights: []float64{1, 2}, Biases: []float64{0.5}}
+	second := &Linear{Weights: []float64{3}, Biases: []float64{-1}}
+	network := NewSequential(first, second)
+
+	input := tensor.NewTensor([][]float64{{1, 1}, {2, 0}})
+
+	// (x1 + 2*x2 + 0.5) * 3 - 1
+	output := network.Forward(input)
+	if !reflect.DeepEqual(output.Data, []float64{9.5, 6.5}) {
+		t.Errorf("Expected outputs [9.5 6.5], got %v", output.Data)
+	}
+
+	gradInput := network.Backward(input, tensor.NewTensor([][]float64{{1}, {1}}))
+
+	// every input gets 3 times its weight from the first layer
+	if !reflect.DeepEqual(gradInput.Data, []float64{3, 6, 3, 6}) {
+		t.Errorf("Expected input gradients [3 6 3 6], got %v", gradInput.Data)
+	}
+	if math.Abs(second.GradWeights[0]-(3.5+2.5)) > 1e-12 || math.Abs(first.GradWeights[0]-9) > 1e-12 {
+		t.Errorf("Unexpected weight gradients %v and %v", first.GradWeights, second.GradWeights)
+	}
+}
+
+func TestSequentialParameters(t *testing.T) {
+
+	network := NewSequential(&Linear{Weights: []float64{1, 2}, Biases: []float64{0.5}}, &Linear{Weights: []float64{3}, Biases: []float64{-1}})
+
+	var names []string
+	for _, p := range network.Parameters() {
+		names = append(names, p.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"0.weight", "0.bias", "1.weight", "1.bias"}) {
+		t.Errorf("Unexpected parameter names %v", names)
+	}
+
+	// the parameters share their data with the layers so an optimizer step updates the network
+	network.Parameters()[2].Data[0] = 4
+	if network.Layers[1].(*Linear).Weights[0] != 4 {
+		t.Errorf("Expected the parameter to share data with the layer")
+	}
+}
@@ -0,0 +1,181 @@
+package model
+
+import (
+	"fmt"
+	"gotorch/tensor"
+	"gotorch/utils"
+	"iter"
+	"slices"
+	"strings"
+)
+
+/*
+A state dict is a snapshot of everything needed to restore a module: its parameters and any buffers, like running
+statistics, that are saved with the model but aren't updated by the optimizer. Entries are named the same way PyTorch
+names them, so a Sequential holding a Linear at index 0 has the entries "0.weight" and "0.bias".
+*/
+
+// StatefulModule is a Module that saves and restores its own state dict, like Linear, Sequential and PReLU
+// modules without the methods can use StateDictOf and LoadStateDict directly
+type StatefulModule interface {
+	Module
+	StateDict() *StateDict
+	LoadStateDict(state *StateDict, strict bool) (LoadResult, error)
+}
+
+// BufferedModule is a Module with state that isn't learned but still needs saving, such as BatchNorm running statistics
+// buffers are returned as Parameters whose Grad is nil
+type BufferedModule interface {
+	Module
+	Buffers() []*Parameter
+}
+
+// StateDict is an ordered mapping from names to tensors, entries keep the order they were first set in
+type StateDict struct {
+	keys    []string
+	tensors map[string]*tensor.Tensor
+}
+
+// NewStateDict returns an empty state dict
+func NewStateDict() *StateDict {
+	return &StateDict{tensors: map[string]*tensor.Tensor{}}
+}
+
+// Set adds or replaces an entry, a new entry goes at the end
+func (s *StateDict) Set(name string, t *tensor.Tensor) {
+	if _, ok := s.tensors[name]; !ok {
+		s.keys = append(s.keys, name)
+	}
+	s.tensors[name] = t
+}
+
+// Get returns the tensor stored under name
+func (s *StateDict) Get(name string) (*tensor.Tensor, bool) {
+	t, ok := s.tensors[name]
+	return t, ok
+}
+
+// Keys returns the names of every entry in order
+func (s *StateDict) Keys() []string {
+	return slices.Clone(s.keys)
+}
+
+// Len returns the number of entries
+func (s *StateDict) Len() int {
+	return len(s.keys)
+}
+
+// All iterates over the entries in order
+func (s *StateDict) All() iter.Seq2[string, *tensor.Tensor] {
+	return func(yield func(string, *tensor.Tensor) bool) {
+		for _, key := range s.keys {
+			if !yield(key, s.tensors[key]) {
+				return
+			}
+		}
+	}
+}
+
+// StateDictFromTensors builds a state dict from tensors read from another format, such as a PyTorch or safetensors file
+// only names starting with prefix are kept, with the prefix removed, so a checkpoint holding {"model.0.weight": w} can
+// be loaded with the prefix "model.". Entries are sorted by name
+func StateDictFromTensors(tensors map[string]*tensor.Tensor, prefix string) *StateDict {
+
+	names := make([]string, 0, len(tensors))
+	for name := range tensors {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	state := NewStateDict()
+	for _, name := range names {
+		state.Set(strings.TrimPrefix(name, prefix), tensors[name])
+	}
+
+	return state
+}
+
+// shape returns a parameter's shape, parameters without one are vectors
+func (p *Parameter) shape() []int {
+	if p.Shape == nil {
+		return []int{len(p.Data)}
+	}
+	return p.Shape
+}
+
+// moduleState returns the parameters of a module followed by its buffers
+func moduleState(m Module) []*Parameter {
+	state := m.Parameters()
+	if buffered, ok := m.(BufferedModule); ok {
+		state = append(state, buffered.Buffers()...)
+	}
+	return state
+}
+
+// StateDictOf returns a copy of the parameters and buffers of a module, later training doesn't change it
+func StateDictOf(m Module) *StateDict {
+
+	state := NewStateDict()
+	for _, p := range moduleState(m) {
+		state.Set(p.Name, tensor.NewTensor(slices.Clone(p.Data), slices.Clone(p.shape())...))
+	}
+
+	return state
+}
+
+// LoadResult reports the entries that didn't line up when loading a state dict
+// MissingKeys are in the module but not the state dict and UnexpectedKeys are in the state dict but not the module
+type LoadResult struct {
+	MissingKeys    []string
+	UnexpectedKeys []string
+}
+
+// LoadStateDict copies a state dict into the parameters and buffers of a module
+// in strict mode any missing or unexpected key is an error, otherwise the matching entries are loaded and the rest
+// are reported in the result. A shape mismatch is always an error, and nothing is copied unless every entry is valid
+func LoadStateDict(m Module, state *StateDict, strict bool) (LoadResult, error) {
+
+	var result LoadResult
+
+	params := moduleState(m)
+	known := map[string]bool{}
+	for _, p := range params {
+		known[p.Name] = true
+
+		saved, ok := state.Get(p.Name)
+		if !ok {
+			result.MissingKeys = append(result.MissingKeys, p.Name)
+			continue
+		}
+		if !utils.AreSlicesEqual(saved.Shape, p.shape()) || len(saved.Data) != len(p.Data) {
+			return result, fmt.Errorf("size mismatch for %q: the state dict has shape %v but the module has %v", p.Name, saved.Shape, p.shape())
+		}
+	}
+
+	for _, key := range state.keys {
+		if !known[key] {
+			result.UnexpectedKeys = append(result.UnexpectedKeys, key)
+		}
+	}
+
+	if strict && (len(result.MissingKeys) > 0 || len(result.UnexpectedKeys) > 0) {
+		var problems []string
+		if len(result.MissingKeys) > 0 {
+			problems = append(problems, fmt.Sprintf("missing keys %q", result.MissingKeys))
+		}
+		if len(result.UnexpectedKeys) > 0 {
+			problems = append(problems, fmt.Sprintf("unexpected keys %q", result.UnexpectedKeys))
+		}
+		return result, fmt.Errorf("error loading state dict: %s", strings.Join(problems, ", "))
+	}
+
+	for _, p := range params {
+		if saved, ok := state.Get(p.Name); ok {
+			copy(p.Data, saved.Data)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,162 @@
+package model
+
+import (
+	"gotorch/tensor"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// normalizer is a module with a buffer, like the running statistics of a batch norm layer
+type normalizer struct {
+	scale, grad []float64
+	runningMean []float64
+}
+
+func (n *normalizer) Forward(input *tensor.Tensor) *tensor.Tensor              { return input }
+func (n *normalizer) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor { return gradOutput }
+
+func (n *normalizer) Parameters() []*Parameter {
+	return []*Parameter{{Name: "scale", Data: n.scale, Grad: n.grad}}
+}
+
+func (n *normalizer) Buffers() []*Parameter {
+	return []*Parameter{{Name: "running_mean", Data: n.runningMean}}
+}
+
+func newTestNetwork() *Sequential {
+	return NewSequential(
+		&normalizer{scale: []float64{1, 1}, grad: []float64{0, 0}, runningMean: []float64{0.5, -0.5}},
+		&Linear{Weights: []float64{1, 2}, Biases: []float64{0.5}},
+	)
+}
+
+func TestStateDictOf(t *testing.T) {
+
+	network := newTestNetwork()
+	state := StateDictOf(network)
+
+	expectedKeys := []string{"0.scale", "1.weight", "1.bias", "0.running_mean"}
+	if !reflect.DeepEqual(state.Keys(), expectedKeys) {
+		t.Errorf("Expected keys %v, got %v", expectedKeys, state.Keys())
+	}
+
+	weight, _ := state.Get("1.weight")
+	if !reflect.DeepEqual(weight.Shape, []int{1, 2}) {
+		t.Errorf("Expected the weight to have shape [1 2], got %v", weight.Shape)
+	}
+
+	// the state dict is a snapshot, so changing the model afterwards doesn't change it
+	network.Layers[1].(*Linear).Weights[0] = 10
+	if weight.Data[0] != 1 {
+		t.Errorf("Expected the state dict to keep the old weight, got %v", weight.Data[0])
+	}
+}
+
+func TestLoadStateDictStrict(t *testing.T) {
+
+	source := newTestNetwork()
+	source.Layers[1].(*Linear).Weights[1] = 7
+	source.Layers[0].(*normalizer).runningMean[0] = 3
+
+	target := newTestNetwork()
+	result, err := LoadStateDict(target, StateDictOf(source), true)
+	if err != nil {
+		t.Fatalf("Failed to load state dict: %v", err)
+	}
+	if len(result.MissingKeys) != 0 || len(result.UnexpectedKeys) != 0 {
+		t.Errorf("Expected no missing or unexpected keys, got %+v", result)
+	}
+
+	if target.Layers[1].(*Linear).Weights[1] != 7 || target.Layers[0].(*normalizer).runningMean[0] != 3 {
+		t.Errorf("Expected the parameters and buffers to be loaded")
+	}
+}
+
+func TestStateDictMethods(t *testing.T) {
+
+	// the buffer of the normalizer goes through Sequential's methods along with the parameters
+	source := NewSequential(
+		&normalizer{scale: []float64{2, 3}, grad: []float64{0, 0}, runningMean: []float64{0.25, 4}},
+		&Linear{Weights: []float64{5, 6}, Biases: []float64{7}},
+	)
+	target := NewSequential(
+		&normalizer{scale: []float64{1, 1}, grad: []float64{0, 0}, runningMean: []float64{0, 0}},
+		&Linear{Weights: []float64{1, 2}, Biases: []float64{0.5}},
+	)
+
+	var _ StatefulModule = source
+	state := source.StateDict()
+	expectedKeys := []string{"0.scale", "1.weight", "1.bias", "0.running_mean"}
+	if !reflect.DeepEqual(state.Keys(), expectedKeys) {
+		t.Errorf("Expected keys %v, got %v", expectedKeys, state.Keys())
+	}
+
+	if _, err := target.LoadStateDict(state, true); err != nil {
+		t.Fatalf("Failed to load state dict: %v", err)
+	}
+	if !reflect.DeepEqual(target.Layers[0].(*normalizer).runningMean, []float64{0.25, 4}) {
+		t.Errorf("Expected the buffer to be loaded, got %v", target.Layers[0].(*normalizer).runningMean)
+	}
+	if target.Layers[1].(*Linear).Weights[1] != 6 || target.Layers[1].(*Linear).Biases[0] != 7 {
+		t.Errorf("Expected the parameters to be loaded")
+	}
+
+	// single layers have the same methods
+	linear := &Linear{Weights: []float64{0, 0}, Biases: []float64{0}}
+	if _, err := linear.LoadStateDict(source.Layers[1].(*Linear).StateDict(), true); err != nil || linear.Biases[0] != 7 {
+		t.Errorf("Expected the linear layer to load, got bias %v and %v", linear.Biases[0], err)
+	}
+	result, err := linear.LoadStateDict(state, false)
+	if err != nil || len(result.MissingKeys) != 2 || linear.Biases[0] != 7 {
+		t.Errorf("Expected the unprefixed weight and bias to be missing, got %+v and %v", result, err)
+	}
+}
+
+func TestLoadStateDictMismatchedKeys(t *testing.T) {
+
+	state := StateDictOf(newTestNetwork())
+	state.Set("2.weight", tensor.NewTensor([]float64{1}))
+
+	// drop the bias by copying everything else into a new state dict
+	partial := NewStateDict()
+	for name, tensor := range state.All() {
+		if name != "1.bias" {
+			partial.Set(name, tensor)
+		}
+	}
+	weight, _ := partial.Get("1.weight")
+	weight.Data[0] = 9
+
+	target := newTestNetwork()
+	if _, err := LoadStateDict(target, partial, true); err == nil || !strings.Contains(err.Error(), "1.bias") || !strings.Contains(err.Error(), "2.weight") {
+		t.Errorf("Expected a strict load to fail naming both keys, got %v", err)
+	}
+	if target.Layers[1].(*Linear).Weights[0] != 1 {
+		t.Errorf("Expected a failed strict load to leave the model untouched")
+	}
+
+	result, err := LoadStateDict(target, partial, false)
+	if err != nil {
+		t.Fatalf("Failed to load non strict: %v", err)
+	}
+	if !reflect.DeepEqual(result.MissingKeys, []string{"1.bias"}) || !reflect.DeepEqual(result.UnexpectedKeys, []string{"2.weight"}) {
+		t.Errorf("Unexpected load result %+v", result)
+	}
+	if target.Layers[1].(*Linear).Weights[0] != 9 {
+		t.Errorf("Expected the matching keys to be loaded")
+	}
+}
+
+func TestLoadStateDictShapeMismatch(t *testing.T) {
+
+	state := StateDictOf(&Linear{Weights: []float64{1, 2, 3}, Biases: []float64{0}})
+
+	target := &Linear{Weights: []float64{1, 2}, Biases: []float64{0.5}}
+	if _, err := LoadStateDict(target, state, false); err == nil || !strings.Contains(err.Error(), "size mismatch") {
+		t.Errorf("Expected a size mismatch error even when not strict, got %v", err)
+	}
+	if target.Biases[0] != 0.5 {
+		t.Errorf("Expected the model to be left untouched")
+	}
+}
@@ -0,0 +1,291 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"gotorch/tensor"
+	"iter"
+)
+
+/*
+The Trainer drives the training loop for any Module. Each epoch it pulls mini-batches from the training Loader, runs the
+forward and backward passes inside a closure handed to the Optimizer, then evaluates the validation Loader (if there is one)
+and records the averaged metrics in the History. Callbacks are invoked around every epoch and batch.
+*/
+
+// Loader supplies the (inputs, targets) mini-batches that make up one epoch
+// any error hit while producing batches is reported by Err once the iteration stops
+type Loader interface {
+	Batches(ctx context.Context) iter.Seq2[*tensor.Tensor, *tensor.Tensor]
+	Err() error
+}
+
+// fullBatch is a Loader that yields the whole dataset as a single batch
+type fullBatch struct {
+	inputs  *tensor.Tensor
+	targets *tensor.Tensor
+}
+
+// FullBatch returns a Loader that yields inputs and targets as a single batch every epoch
+func FullBatch(inputs, targets *tensor.Tensor) Loader {
+	return &fullBatch{inputs: inputs, targets: targets}
+}
+
+func (f *fullBatch) Batches(ctx context.Context) iter.Seq2[*tensor.Tensor, *tensor.Tensor] {
+	return func(yield func(*tensor.Tensor, *tensor.Tensor) bool) {
+		yield(f.inputs, f.targets)
+	}
+}
+
+func (f *fullBatch) Err() error {
+	return nil
+}
+
+// Metrics maps a metric name such as "loss" or "val_loss" to its value
+type Metrics map[string]float64
+
+// BatchSizeMetric is the key of the number of samples in the batch within the metrics passed to OnBatchEnd
+// it starts with an underscore so it can't be mistaken for a metric, MetricFuncs can't use it or "loss" as a name
+const BatchSizeMetric = "_batch_size"
+
+// MetricFunc computes a metric for a batch of predictions, for example accuracy
+type MetricFunc func(predictions, targets *tensor.Tensor) float64
+
+// History holds the metrics recorded at the end of every epoch
+type History []Metrics
+
+// returns the values of a single metric across all epochs
+func (h History) Metric(name string) []float64 {
+	values := make([]float64, 0, len(h))
+	for _, metrics := range h {
+		if v, ok := metrics[name]; ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// Callback hooks into the training loop, returning an error from any hook stops training and Fit returns the error
+// embed BaseCallback to only implement the hooks you need
+type Callback interface {
+	OnTrainStart(t *Trainer) error
+	OnTrainEnd(t *Trainer, history History) error
+	OnEpochStart(t *Trainer, epoch int) error
+	OnEpochEnd(t *Trainer, epoch int, metrics Metrics) error
+	OnBatchEnd(t *Trainer, epoch, batch int, metrics Metrics) error
+}
+
+// BaseCallback implements every Callback hook as a no-op
+type BaseCallback struct{}
+
+func (BaseCallback) OnTrainStart(t *Trainer) error                                  { return nil }
+func (BaseCallback) OnTrainEnd(t *Trainer, history History) error                   { return nil }
+func (BaseCallback) OnEpochStart(t *Trainer, epoch int) error                       { return nil }
+func (BaseCallback) OnEpochEnd(t *Trainer, epoch int, metrics Metrics) error        { return nil }
+func (BaseCallback) OnBatchEnd(t *Trainer, epoch, batch int, metrics Metrics) error { return nil }
+
+// Trainer fits a module to the batches of a training Loader
+// Validation and MetricFuncs are optional, validation metrics are recorded with a "val_" prefix
+type Trainer struct {
+	Model       Module
+	Loss        Loss
+	Optimizer   Optimizer
+	Train       Loader
+	Validation  Loader
+	Epochs      int
+	MetricFuncs map[string]MetricFunc
+	Callbacks   []Callback
+
+	stop bool
+}
+
+// StopTraining asks the trainer to stop once the current epoch has finished, callbacks such as early stopping use this
+func (t *Trainer) StopTraining() {
+	t.stop = true
+}
+
+// Fit runs the training loop for the configured number of epochs and returns the metrics recorded at the end of each epoch
+// it stops early if the context is cancelled, a loader or callback fails, or a callback calls StopTraining
+func (t *Trainer) Fit(ctx context.Context) (History, error) {
+
+	if t.Model == nil || t.Loss == nil || t.Optimizer == nil || t.Train == nil {
+		return nil, fmt.Errorf("trainer requires a model, loss, optimizer and training loader")
+	}
+	for _, name := range []string{"loss", BatchSizeMetric} {
+		if _, ok := t.MetricFuncs[name]; ok {
+			return nil, fmt.Errorf("metric name %q is reserved by the trainer", name)
+		}
+	}
+
+	t.stop = false
+	history := History{}
+
+	for _, cb := range t.Callbacks {
+		if err := cb.OnTrainStart(t); err != nil {
+			return history, err
+		}
+	}
+
+	for epoch := 0; epoch < t.Epochs && !t.stop; epoch++ {
+
+		for _, cb := range t.Callbacks {
+			if err := cb.OnEpochStart(t, epoch); err != nil {
+				return history, err
+			}
+		}
+
+		metrics, err := t.trainEpoch(ctx, epoch)
+		if err != nil {
+			return history, err
+		}
+
+		if t.Validation != nil {
+			valMetrics, err := t.Evaluate(ctx, t.Validation)
+			if err != nil {
+				return history, err
+			}
+			for name, value := range valMetrics {
+				metrics["val_"+name] = value
+			}
+		}
+
+		history = append(history, metrics)
+
+		for _, cb := range t.Callbacks {
+			if err := cb.OnEpochEnd(t, epoch, metrics); err != nil {
+				return history, err
+			}
+		}
+	}
+
+	for _, cb := range t.Callbacks {
+		if err := cb.OnTrainEnd(t, history); err != nil {
+			return history, err
+		}
+	}
+
+	return history, nil
+}
+
+// runs a single pass over the training loader and returns the metrics averaged over every sample
+func (t *Trainer) trainEpoch(ctx context.Context, epoch int) (Metrics, error) {
+
+	totals := newMetricTotals()
+	batch := 0
+
+	for inputs, targets := range t.Train.Batches(ctx) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// optimizers return the loss of the first evaluation, before the update, so the metrics use the predictions
+		// from that evaluation too rather than those of later ones, like the trial points of a line search
+		var predictions *tensor.Tensor
+		closure := func() float64 {
+			// forward pass
+			output := t.Model.Forward(inputs)
+			if predictions == nil {
+				predictions = output
+			}
+
+			// compute the loss and its gradient with respect to the output
+			loss := t.Loss.Forward(output, targets)
+			gradOutput := t.Loss.Backward(output, targets)
+
+			// backward pass
+			t.Model.Backward(inputs, gradOutput)
+
+			return loss.Data[0]
+		}
+
+		// update weights
+		loss := t.Optimizer.Step(t.Model, closure)
+
+		batchMetrics := Metrics{"loss": loss}
+		for name, fn := range t.MetricFuncs {
+			batchMetrics[name] = fn(predictions, targets)
+		}
+		totals.add(batchMetrics, batchSize(inputs))
+
+		// callbacks also get the number of samples in the batch so they can report throughput
+		batchMetrics[BatchSizeMetric] = float64(batchSize(inputs))
+
+		for _, cb := range t.Callbacks {
+			if err := cb.OnBatchEnd(t, epoch, batch, batchMetrics); err != nil {
+				return nil, err
+			}
+		}
+		batch++
+	}
+
+	if err := t.Train.Err(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return totals.average(), nil
+}
+
+// Evaluate runs the model over every batch of a loader without updating it and returns the loss and metrics averaged over every sample
+func (t *Trainer) Evaluate(ctx context.Context, loader Loader) (Metrics, error) {
+
+	totals := newMetricTotals()
+
+	for inputs, targets := range loader.Batches(ctx) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		predictions := t.Model.Forward(inputs)
+
+		batchMetrics := Metrics{"loss": t.Loss.Forward(predictions, targets).Data[0]}
+		for name, fn := range t.MetricFuncs {
+			batchMetrics[name] = fn(predictions, targets)
+		}
+		totals.add(batchMetrics, batchSize(inputs))
+	}
+
+	if err := loader.Err(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return totals.average(), nil
+}
+
+// metricTotals accumulates batch metrics weighted by the number of samples in each batch
+type metricTotals struct {
+	sums    Metrics
+	samples int
+}
+
+func newMetricTotals() *metricTotals {
+	return &metricTotals{sums: Metrics{}}
+}
+
+func (m *metricTotals) add(metrics Metrics, samples int) {
+	for name, value := range metrics {
+		m.sums[name] += value * float64(samples)
+	}
+	m.samples += samples
+}
+
+func (m *metricTotals) average() Metrics {
+	result := Metrics{}
+	for name, sum := range m.sums {
+		result[name] = sum / float64(m.samples)
+	}
+	return result
+}
+
+// the number of samples in a batch is the size of the first dimension
+func batchSize(inputs *tensor.Tensor) int {
+	if len(inputs.Shape) == 0 {
+		return 1
+	}
+	return inputs.Shape[0]
+}
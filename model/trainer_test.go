@@ -0,0 +1,317 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"gotorch/tensor"
+	"iter"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// recordingCallback records the order in which the trainer invokes its hooks
+type recordingCallback struct {
+	BaseCallback
+	events []string
+}
+
+func (r *recordingCallback) OnTrainStart(t *Trainer) error {
+	r.events = append(r.events, "train_start")
+	return nil
+}
+
+func (r *recordingCallback) OnTrainEnd(t *Trainer, history History) error {
+	r.events = append(r.events, "train_end")
+	return nil
+}
+
+func (r *recordingCallback) OnEpochStart(t *Trainer, epoch int) error {
+	r.events = append(r.events, "epoch_start")
+	return nil
+}
+
+func (r *recordingCallback) OnEpochEnd(t *Trainer, epoch int, metrics Metrics) error {
+	r.events = append(r.events, "epoch_end")
+	return nil
+}
+
+func (r *recordingCallback) OnBatchEnd(t *Trainer, epoch, batch int, metrics Metrics) error {
+	r.events = append(r.events, "batch_end")
+	return nil
+}
+
+// rowLoader yields every row of inputs and targets as its own batch and can be made to fail
+type rowLoader struct {
+	inputs  *tensor.Tensor
+	targets *tensor.Tensor
+	err     error
+}
+
+func (r *rowLoader) Batches(ctx context.Context) iter.Seq2[*tensor.Tensor, *tensor.Tensor] {
+	return func(yield func(*tensor.Tensor, *tensor.Tensor) bool) {
+		if r.err != nil {
+			return
+		}
+		cols := r.inputs.Shape[1]
+		for i := 0; i < r.inputs.Shape[0]; i++ {
+			inputs := tensor.NewTensor(r.inputs.Data[i*cols:(i+1)*cols], 1, cols)
+			targets := tensor.NewTensor(r.targets.Data[i:i+1], 1, 1)
+			if !yield(inputs, targets) {
+				return
+			}
+		}
+	}
+}
+
+func (r *rowLoader) Err() error {
+	return r.err
+}
+
+func newTestTrainer(epochs int) *Trainer {
+	inputs := tensor.NewTensor([][]float64{{1.0, 2.0}, {3.0, 4.0}})
+	targets := tensor.NewTensor([][]float64{{5.0}, {11.0}})
+
+	return &Trainer{
+		Model:     &Linear{Weights: []float64{1.0, 2.0}, Biases: []float64{0.5}},
+		Loss:      MSELoss,
+		Optimizer: &SGD{LearningRate: 0.01},
+		Train:     FullBatch(inputs, targets),
+		Epochs:    epochs,
+	}
+}
+
+func TestTrainerFit(t *testing.T) {
+
+	trainer := newTestTrainer(50)
+
+	history, err := trainer.Fit(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+
+	if len(history) != 50 {
+		t.Fatalf("Expected 50 epochs of history, got %d", len(history))
+	}
+
+	losses := history.Metric("loss")
+	if losses[0] != 0.25 {
+		t.Errorf("Expected initial loss 0.25, got %v", losses[0])
+	}
+	if losses[len(losses)-1] >= losses[0] {
+		t.Errorf("Expected loss to decrease, got %v then %v", losses[0], losses[len(losses)-1])
+	}
+}
+
+func TestTrainerValidationAndMetrics(t *testing.T) {
+
+	trainer := newTestTrainer(3)
+	trainer.Validation = FullBatch(tensor.NewTensor([][]float64{{5.0, 6.0}}), tensor.NewTensor([][]float64{{17.0}}))
+	trainer.MetricFuncs = map[string]MetricFunc{
+		"mae": func(predictions, targets *tensor.Tensor) float64 {
+			var sum float64
+			for i := range predictions.Data {
+				diff := predictions.Data[i] - targets.Data[i]
+				if diff < 0 {
+					diff = -diff
+				}
+				sum += diff
+			}
+			return sum / float64(len(predictions.Data))
+		},
+	}
+
+	history, err := trainer.Fit(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+
+	for _, name := range []string{"loss", "mae", "val_loss", "val_mae"} {
+		if len(history.Metric(name)) != 3 {
+			t.Errorf("Expected 3 values for %s, got %v", name, history.Metric(name))
+		}
+	}
+
+	// the training metrics use the predictions from before the first update, 5.5 and 11.5
+	if history[0]["mae"] != 0.5 {
+		t.Errorf("Expected initial mae 0.5, got %v", history[0]["mae"])
+	}
+}
+
+// batchRecorder keeps the metrics passed to every OnBatchEnd
+type batchRecorder struct {
+	BaseCallback
+	metrics []Metrics
+}
+
+func (b *batchRecorder) OnBatchEnd(t *Trainer, epoch, batch int, metrics Metrics) error {
+	b.metrics = append(b.metrics, metrics)
+	return nil
+}
+
+func TestTrainerMetricsMatchLossWithLBFGS(t *testing.T) {
+
+	// LBFGS evaluates the model many times per step, the metrics must describe the same weights as the reported loss
+	trainer := newTestTrainer(3)
+	trainer.Optimizer = &LBFGS{LineSearch: StrongWolfe}
+	trainer.MetricFuncs = map[string]MetricFunc{
+		"mse": func(predictions, targets *tensor.Tensor) float64 {
+			return MSELoss.Forward(predictions, targets).Data[0]
+		},
+	}
+	recorder := &batchRecorder{}
+	trainer.Callbacks = []Callback{recorder}
+
+	if _, err := trainer.Fit(context.Background()); err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+
+	for i, metrics := range recorder.metrics {
+		if math.Abs(metrics["mse"]-metrics["loss"]) > 1e-12 {
+			t.Errorf("Batch %d: expected the mse metric to equal the loss %v, got %v", i, metrics["loss"], metrics["mse"])
+		}
+	}
+}
+
+func TestTrainerReservedMetricNames(t *testing.T) {
+
+	for _, name := range []string{"loss", BatchSizeMetric} {
+		trainer := newTestTrainer(1)
+		trainer.MetricFuncs = map[string]MetricFunc{
+			name: func(predictions, targets *tensor.Tensor) float64 { return 0 },
+		}
+		if _, err := trainer.Fit(context.Background()); err == nil {
+			t.Errorf("Expected an error for a metric named %q", name)
+		}
+	}
+
+	// a user metric called "size" is kept apart from the batch size given to callbacks
+	trainer := newTestTrainer(1)
+	trainer.MetricFuncs = map[string]MetricFunc{
+		"size": func(predictions, targets *tensor.Tensor) float64 { return 42 },
+	}
+	recorder := &batchRecorder{}
+	trainer.Callbacks = []Callback{recorder}
+	if _, err := trainer.Fit(context.Background()); err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+	if recorder.metrics[0]["size"] != 42 || recorder.metrics[0][BatchSizeMetric] != 2 {
+		t.Errorf("Expected size 42 and batch size 2, got %v", recorder.metrics[0])
+	}
+}
+
+func TestTrainerWeightsMetricsByBatchSize(t *testing.T) {
+
+	trainer := newTestTrainer(1)
+	trainer.Optimizer = &SGD{LearningRate: 0}
+	trainer.Train = &rowLoader{
+		inputs:  tensor.NewTensor([][]float64{{1.0, 2.0}, {3.0, 4.0}}),
+		targets: tensor.NewTensor([][]float64{{5.0}, {12.0}}),
+	}
+
+	history, err := trainer.Fit(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+
+	// squared errors of 0.25 and 0.25 averaged over both single row batches
+	if history[0]["loss"] != 0.25 {
+		t.Errorf("Expected loss 0.25, got %v", history[0]["loss"])
+	}
+}
+
+func TestTrainerCallbackOrder(t *testing.T) {
+
+	trainer := newTestTrainer(2)
+	recorder := &recordingCallback{}
+	trainer.Callbacks = []Callback{recorder}
+
+	if _, err := trainer.Fit(context.Background()); err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+
+	expected := []string{"train_start", "epoch_start", "batch_end", "epoch_end", "epoch_start", "batch_end", "epoch_end", "train_end"}
+	if !reflect.DeepEqual(recorder.events, expected) {
+		t.Errorf("Unexpected callback order, expected %v, got %v", expected, recorder.events)
+	}
+}
+
+type stopAfter struct {
+	BaseCallback
+	epoch int
+}
+
+func (s *stopAfter) OnEpochEnd(t *Trainer, epoch int, metrics Metrics) error {
+	if epoch == s.epoch {
+		t.StopTraining()
+	}
+	return nil
+}
+
+func TestTrainerStopTraining(t *testing.T) {
+
+	trainer := newTestTrainer(10)
+	trainer.Callbacks = []Callback{&stopAfter{epoch: 2}}
+
+	history, err := trainer.Fit(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+
+	if len(history) != 3 {
+		t.Errorf("Expected training to stop after 3 epochs, got %d", len(history))
+	}
+}
+
+type failingCallback struct {
+	BaseCallback
+}
+
+func (failingCallback) OnEpochStart(t *Trainer, epoch int) error {
+	return errors.New("callback failed")
+}
+
+func TestTrainerCallbackError(t *testing.T) {
+
+	trainer := newTestTrainer(10)
+	trainer.Callbacks = []Callback{failingCallback{}}
+
+	if _, err := trainer.Fit(context.Background()); err == nil {
+		t.Errorf("Expected the callback error to be returned")
+	}
+}
+
+func TestTrainerLoaderError(t *testing.T) {
+
+	trainer := newTestTrainer(10)
+	trainer.Train = &rowLoader{err: errors.New("unable to read batch")}
+
+	if _, err := trainer.Fit(context.Background()); err == nil {
+		t.Errorf("Expected the loader error to be returned")
+	}
+}
+
+func TestTrainerContextCancelled(t *testing.T) {
+
+	trainer := newTestTrainer(10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	history, err := trainer.Fit(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if len(history) != 0 {
+		t.Errorf("Expected no epochs to complete, got %d", len(history))
+	}
+}
+
+func TestTrainerMissingFields(t *testing.T) {
+
+	trainer := &Trainer{Epochs: 1}
+
+	if _, err := trainer.Fit(context.Background()); err == nil {
+		t.Errorf("Expected an error for a trainer without a model")
+	}
+}
@@ -0,0 +1,172 @@
+package onnx
+
+import (
+	"fmt"
+	"gotorch/model"
+	"gotorch/tensor"
+	"slices"
+)
+
+// the versions written by Export, IR version 8 is the one that goes with opset 13
+const (
+	exportIRVersion = 8
+	exportOpset     = 13
+)
+
+// ExportOptions configures Export
+type ExportOptions struct {
+	// GraphName defaults to "gotorch"
+	GraphName string
+	// DataType is Float, the default that every runtime supports, or Double to keep full precision
+	DataType DataType
+}
+
+// exporter builds a graph up one module at a time
+type exporter struct {
+	graph    Graph
+	dataType DataType
+}
+
+// Export describes a model as an ONNX graph taking a [batch, inputFeatures] input called "input" and producing "output"
+// weights are stored as initializers named like the model's state dict, so the first layer of a Sequential has "0.weight"
+// Linear, Sequential, ReLU, LeakyReLU, PReLU, Sigmoid, Tanh, Softmax and LogSoftmax layers are supported, any other
+// layer is an error
+func Export(m model.Model, inputFeatures int, opts ExportOptions) (*Model, error) {
+
+	e := &exporter{dataType: opts.DataType, graph: Graph{Name: opts.GraphName}}
+	if e.dataType == 0 {
+		e.dataType = Float
+	}
+	if e.dataType != Float && e.dataType != Double {
+		return nil, fmt.Errorf("unsupported export data type %d", e.dataType)
+	}
+	if e.graph.Name == "" {
+		e.graph.Name = "gotorch"
+	}
+
+	output, features, err := e.module(m, "", "input", inputFeatures)
+	if err != nil {
+		return nil, err
+	}
+
+	// rename the final value so the graph always ends in "output"
+	if n := len(e.graph.Nodes); n > 0 && e.graph.Nodes[n-1].Outputs[0] == output {
+		e.graph.Nodes[n-1].Outputs[0] = "output"
+	} else {
+		e.graph.Nodes = append(e.graph.Nodes, Node{Name: "Identity", OpType: "Identity", Inputs: []string{output}, Outputs: []string{"output"}})
+	}
+
+	e.graph.Inputs = []ValueInfo{{Name: "input", ElemType: e.dataType, Shape: []Dim{{Param: "batch"}, {Value: int64(inputFeatures)}}}}
+	e.graph.Outputs = []ValueInfo{{Name: "output", ElemType: e.dataType, Shape: []Dim{{Param: "batch"}, {Value: int64(features)}}}}
+
+	return &Model{
+		IRVersion:    exportIRVersion,
+		ProducerName: "gotorch",
+		Opsets:       map[string]int64{"": exportOpset},
+		Graph:        e.graph,
+	}, nil
+}
+
+// module adds the nodes for one layer reading from input, which has the given number of features
+// it returns the name of the layer's output and its number of features
+func (e *exporter) module(m model.Model, prefix, input string, features int) (string, int, error) {
+
+	switch layer := m.(type) {
+
+	case *model.Linear:
+		if len(layer.Weights) != features {
+			return "", 0, fmt.Errorf("layer %q expects %d features but gets %d", prefix, len(layer.Weights), features)
+		}
+		// Linear repeats its biases over the batch, which only lines up with Gemm's broadcasting for a single bias
+		if len(layer.Biases) != 1 {
+			return "", 0, fmt.Errorf("layer %q has %d biases, only a single bias can be exported", prefix, len(layer.Biases))
+		}
+
+		weight, bias := prefix+"weight", prefix+"bias"
+		e.initializer(weight, tensor.NewTensor(slices.Clone(layer.Weights), 1, len(layer.Weights)))
+		e.initializer(bias, tensor.NewTensor(slices.Clone(layer.Biases)))
+
+		output := "/" + prefix + "Gemm_output"
+		e.graph.Nodes = append(e.graph.Nodes, Node{
+			Name:       "/" + prefix + "Gemm",
+			OpType:     "Gemm",
+			Inputs:     []string{input, weight, bias},
+			Outputs:    []string{output},
+			Attributes: []Attribute{{Name: "transB", Type: AttributeInt, I: 1}},
+		})
+		return output, 1, nil
+
+	case model.ReLU, *model.ReLU:
+		return e.activation(prefix, "Relu", input), features, nil
+	case model.LeakyReLU:
+		return e.leakyRelu(prefix, input, layer), features, nil
+	case *model.LeakyReLU:
+		return e.leakyRelu(prefix, input, *layer), features, nil
+
+	case *model.PReLU:
+		// one slope per feature broadcasts along the last axis, which is the channel axis of a [batch, features] input
+		if len(layer.Weights) != 1 && len(layer.Weights) != features {
+			return "", 0, fmt.Errorf("layer %q has %d slopes but gets %d features", prefix, len(layer.Weights), features)
+		}
+		slope := prefix + "weight"
+		e.initializer(slope, tensor.NewTensor(slices.Clone(layer.Weights)))
+
+		output := "/" + prefix + "PRelu_output"
+		e.graph.Nodes = append(e.graph.Nodes, Node{Name: "/" + prefix + "PRelu", OpType: "PRelu", Inputs: []string{input, slope}, Outputs: []string{output}})
+		return output, features, nil
+	case model.Sigmoid, *model.Sigmoid:
+		return e.activation(prefix, "Sigmoid", input), features, nil
+	case model.Tanh, *model.Tanh:
+		return e.activation(prefix, "Tanh", input), features, nil
+	// from opset 13 Softmax and LogSoftmax normalize along the axis alone, the same as the module's Axis
+	case model.Softmax:
+		return e.activation(prefix, "Softmax", input, axisAttribute(layer.Axis())), features, nil
+	case *model.Softmax:
+		return e.activation(prefix, "Softmax", input, axisAttribute(layer.Axis())), features, nil
+	case model.LogSoftmax:
+		return e.activation(prefix, "LogSoftmax", input, axisAttribute(layer.Axis())), features, nil
+	case *model.LogSoftmax:
+		return e.activation(prefix, "LogSoftmax", input, axisAttribute(layer.Axis())), features, nil
+
+	case *model.Sequential:
+		var err error
+		for i, inner := range layer.Layers {
+			if input, features, err = e.module(inner, fmt.Sprintf("%s%d.", prefix, i), input, features); err != nil {
+				return "", 0, err
+			}
+		}
+		return input, features, nil
+	}
+
+	return "", 0, fmt.Errorf("layer %q of type %T can't be exported to ONNX", prefix, m)
+}
+
+// activation adds an elementwise node that keeps the number of features and returns its output
+func (e *exporter) activation(prefix, opType, input string, attributes ...Attribute) string {
+	output := "/" + prefix + opType + "_output"
+	e.graph.Nodes = append(e.graph.Nodes, Node{
+		Name:       "/" + prefix + opType,
+		OpType:     opType,
+		Inputs:     []string{input},
+		Outputs:    []string{output},
+		Attributes: attributes,
+	})
+	return output
+}
+
+// leakyRelu adds a LeakyRelu node with the layer's slope, an unset slope is the default 0.01 ONNX uses too
+func (e *exporter) leakyRelu(prefix, input string, layer model.LeakyReLU) string {
+	slope := layer.NegativeSlope
+	if slope == 0 {
+		slope = 0.01
+	}
+	return e.activation(prefix, "LeakyRelu", input, Attribute{Name: "alpha", Type: AttributeFloat, F: slope})
+}
+
+func axisAttribute(dim int) Attribute {
+	return Attribute{Name: "axis", Type: AttributeInt, I: int64(dim)}
+}
+
+func (e *exporter) initializer(name string, t *tensor.Tensor) {
+	e.graph.Initializers = append(e.graph.Initializers, Initializer{Name: name, DataType: e.dataType, Tensor: t})
+}
@@ -0,0 +1,475 @@
+package onnx
+
+import (
+	"fmt"
+	af "gotorch/activation_functions"
+	"gotorch/tensor"
+	"math"
+	"slices"
+	"strings"
+)
+
+// the newest opset the operators below have been checked against, newer models usually still work but are reported
+const maxTestedOpset = 21
+
+// opSupport is an operator Network can run, since is the first opset whose semantics we implement
+type opSupport struct {
+	since int64
+	run   func(n *Node, opset int64, inputs []*tensor.Tensor) (*tensor.Tensor, error)
+}
+
+var supportedOps = map[string]opSupport{
+	"Gemm":       {7, runGemm},
+	"MatMul":     {1, runMatMul},
+	"Add":        {7, elementwise(func(a, b float64) float64 { return a + b })},
+	"Sub":        {7, elementwise(func(a, b float64) float64 { return a - b })},
+	"Mul":        {7, elementwise(func(a, b float64) float64 { return a * b })},
+	"Div":        {7, elementwise(func(a, b float64) float64 { return a / b })},
+	"Relu":       {6, unary(func(x float64) float64 { return math.Max(x, 0) })},
+	"Sigmoid":    {6, unary(func(x float64) float64 { return 1 / (1 + math.Exp(-x)) })},
+	"Tanh":       {6, unary(math.Tanh)},
+	"LeakyRelu":  {6, runLeakyRelu},
+	"PRelu":      {7, elementwise(prelu)},
+	"Softmax":    {1, runSoftmax},
+	"LogSoftmax": {1, runLogSoftmax},
+	"Flatten":    {1, runFlatten},
+	"Identity":   {1, func(n *Node, opset int64, inputs []*tensor.Tensor) (*tensor.Tensor, error) { return inputs[0], nil }},
+}
+
+// OpReport is the compatibility of one operator type used in a model
+type OpReport struct {
+	OpType    string
+	Count     int
+	Supported bool
+	Reason    string
+}
+
+// CompatibilityReport says whether a model can be run by Network
+// Problems stop a model from being imported, Warnings are things that probably work but haven't been checked
+type CompatibilityReport struct {
+	Opset    int64
+	Ops      []OpReport
+	Problems []string
+	Warnings []string
+}
+
+// OK reports whether the model can be imported
+func (r *CompatibilityReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *CompatibilityReport) String() string {
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "opset %d\n", r.Opset)
+	for _, op := range r.Ops {
+		status := "supported"
+		if !op.Supported {
+			status = "unsupported: " + op.Reason
+		}
+		fmt.Fprintf(&b, "  %s x%d: %s\n", op.OpType, op.Count, status)
+	}
+	for _, p := range r.Problems {
+		fmt.Fprintf(&b, "problem: %s\n", p)
+	}
+	for _, w := range r.Warnings {
+		fmt.Fprintf(&b, "warning: %s\n", w)
+	}
+
+	return b.String()
+}
+
+// CheckCompatibility reports which operators in a model Network supports at the model's opset version
+func CheckCompatibility(m *Model) *CompatibilityReport {
+
+	r := &CompatibilityReport{Opset: m.Opset()}
+
+	switch {
+	case r.Opset == 0:
+		r.Problems = append(r.Problems, "the model doesn't import the default ai.onnx operator set")
+	case r.Opset > maxTestedOpset:
+		r.Warnings = append(r.Warnings, fmt.Sprintf("opset %d is newer than %d, the newest one operators have been checked against", r.Opset, maxTestedOpset))
+	}
+
+	index := map[string]int{}
+	for _, node := range m.Graph.Nodes {
+		key := node.OpType
+		if node.Domain != "" && node.Domain != "ai.onnx" {
+			key = node.Domain + "." + node.OpType
+		}
+
+		i, ok := index[key]
+		if !ok {
+			i = len(r.Ops)
+			index[key] = i
+			r.Ops = append(r.Ops, OpReport{OpType: key, Supported: true})
+			op, known := supportedOps[node.OpType]
+
+			switch {
+			case key != node.OpType:
+				r.Ops[i].Supported, r.Ops[i].Reason = false, fmt.Sprintf("custom operator domain %q", node.Domain)
+			case !known:
+				r.Ops[i].Supported, r.Ops[i].Reason = false, "operator not implemented"
+			case r.Opset != 0 && r.Opset < op.since:
+				r.Ops[i].Supported, r.Ops[i].Reason = false, fmt.Sprintf("only opset %d and later is implemented", op.since)
+			}
+
+			if !r.Ops[i].Supported {
+				r.Problems = append(r.Problems, fmt.Sprintf("%s: %s", key, r.Ops[i].Reason))
+			}
+		}
+		r.Ops[i].Count++
+	}
+
+	return r
+}
+
+// Network runs an imported ONNX graph, it is inference only
+type Network struct {
+	model   *Model
+	weights map[string]*tensor.Tensor
+	inputs  []string
+}
+
+// Import checks a model can be run and returns a Network for it, the error includes the compatibility report's problems
+func Import(m *Model) (*Network, error) {
+
+	report := CheckCompatibility(m)
+	if !report.OK() {
+		return nil, fmt.Errorf("unsupported onnx model: %s", strings.Join(report.Problems, "; "))
+	}
+
+	n := &Network{model: m, weights: map[string]*tensor.Tensor{}}
+	for _, init := range m.Graph.Initializers {
+		n.weights[init.Name] = init.Tensor
+	}
+
+	// before IR version 4 initializers were also listed as inputs
+	for _, input := range m.Graph.Inputs {
+		if _, ok := n.weights[input.Name]; !ok {
+			n.inputs = append(n.inputs, input.Name)
+		}
+	}
+
+	if len(m.Graph.Outputs) == 0 {
+		return nil, fmt.Errorf("unsupported onnx model: the graph has no outputs")
+	}
+
+	return n, nil
+}
+
+// Inputs returns the names of the graph inputs that must be fed to Run
+func (n *Network) Inputs() []string {
+	return slices.Clone(n.inputs)
+}
+
+// Run evaluates the graph for the given inputs and returns every graph output
+func (n *Network) Run(inputs map[string]*tensor.Tensor) (map[string]*tensor.Tensor, error) {
+
+	values := map[string]*tensor.Tensor{}
+	for name, t := range n.weights {
+		values[name] = t
+	}
+	for _, name := range n.inputs {
+		t, ok := inputs[name]
+		if !ok {
+			return nil, fmt.Errorf("missing input %q", name)
+		}
+		values[name] = t
+	}
+
+	opset := n.model.Opset()
+	for i := range n.model.Graph.Nodes {
+		node := &n.model.Graph.Nodes[i]
+
+		args := make([]*tensor.Tensor, len(node.Inputs))
+		for j, name := range node.Inputs {
+			// an empty name is an omitted optional input
+			if name == "" {
+				continue
+			}
+			t, ok := values[name]
+			if !ok {
+				return nil, fmt.Errorf("node %q reads %q before it is computed", node.Name, name)
+			}
+			args[j] = t
+		}
+		if len(args) == 0 || args[0] == nil || len(node.Outputs) == 0 {
+			return nil, fmt.Errorf("node %q has no inputs or outputs", node.Name)
+		}
+
+		result, err := supportedOps[node.OpType].run(node, opset, args)
+		if err != nil {
+			return nil, fmt.Errorf("node %q (%s): %w", node.Name, node.OpType, err)
+		}
+		values[node.Outputs[0]] = result
+	}
+
+	outputs := map[string]*tensor.Tensor{}
+	for _, output := range n.model.Graph.Outputs {
+		t, ok := values[output.Name]
+		if !ok {
+			return nil, fmt.Errorf("output %q is never computed", output.Name)
+		}
+		outputs[output.Name] = t
+	}
+
+	return outputs, nil
+}
+
+// Forward runs a graph with a single input and returns its first output, it panics if the graph fails like the other models do on bad input
+func (n *Network) Forward(input *tensor.Tensor) *tensor.Tensor {
+
+	if len(n.inputs) != 1 {
+		panic(fmt.Sprintf("Forward needs a graph with one input, this one has %v", n.inputs))
+	}
+
+	outputs, err := n.Run(map[string]*tensor.Tensor{n.inputs[0]: input})
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return outputs[n.model.Graph.Outputs[0].Name]
+}
+
+// Backward isn't supported, imported networks are inference only
+func (n *Network) Backward(input, gradOutput *tensor.Tensor) *tensor.Tensor {
+	panic("onnx networks are inference only")
+}
+
+func unary(f func(float64) float64) func(*Node, int64, []*tensor.Tensor) (*tensor.Tensor, error) {
+	return func(n *Node, opset int64, inputs []*tensor.Tensor) (*tensor.Tensor, error) {
+		x := inputs[0]
+		result := make([]float64, len(x.Data))
+		for i, v := range x.Data {
+			result[i] = f(v)
+		}
+		return &tensor.Tensor{Data: result, Shape: slices.Clone(x.Shape)}, nil
+	}
+}
+
+func runLeakyRelu(n *Node, opset int64, inputs []*tensor.Tensor) (*tensor.Tensor, error) {
+	alpha := 0.01
+	if a, ok := n.attribute("alpha"); ok {
+		alpha = a.F
+	}
+	return unary(func(x float64) float64 {
+		if x < 0 {
+			return alpha * x
+		}
+		return x
+	})(n, opset, inputs)
+}
+
+// broadcastShape returns the shape two shapes broadcast to under numpy rules
+func broadcastShape(a, b []int) ([]int, error) {
+
+	shape := make([]int, max(len(a), len(b)))
+	for i := range shape {
+		da, db := 1, 1
+		if j := len(a) - len(shape) + i; j >= 0 {
+			da = a[j]
+		}
+		if j := len(b) - len(shape) + i; j >= 0 {
+			db = b[j]
+		}
+		switch {
+		case da == db || db == 1:
+			shape[i] = da
+		case da == 1:
+			shape[i] = db
+		default:
+			return nil, fmt.Errorf("shapes %v and %v can't be broadcast together", a, b)
+		}
+	}
+
+	return shape, nil
+}
+
+// broadcastIndex maps an index into the broadcast shape to a flat offset into a tensor of the given shape
+func broadcastIndex(index, shape []int) int {
+	offset := 0
+	for d, n := range shape {
+		i := index[len(index)-len(shape)+d]
+		if n == 1 {
+			i = 0
+		}
+		offset = offset*n + i
+	}
+	return offset
+}
+
+// unravel converts a flat row major offset into an index along each dimension of shape
+func unravel(i int, shape []int) []int {
+	index := make([]int, len(shape))
+	for d := len(shape) - 1; d >= 0; d-- {
+		index[d] = i % shape[d]
+		i /= shape[d]
+	}
+	return index
+}
+
+func size(shape []int) int {
+	n := 1
+	for _, d := range shape {
+		n *= d
+	}
+	return n
+}
+
+func elementwise(f func(a, b float64) float64) func(*Node, int64, []*tensor.Tensor) (*tensor.Tensor, error) {
+	return func(n *Node, opset int64, inputs []*tensor.Tensor) (*tensor.Tensor, error) {
+
+		if len(inputs) != 2 || inputs[1] == nil {
+			return nil, fmt.Errorf("expected 2 inputs")
+		}
+		a, b := inputs[0], inputs[1]
+
+		shape, err := broadcastShape(a.Shape, b.Shape)
+		if err != nil {
+			return nil, err
+		}
+
+		result := make([]float64, size(shape))
+		for i := range result {
+			index := unravel(i, shape)
+			result[i] = f(a.Data[broadcastIndex(index, a.Shape)], b.Data[broadcastIndex(index, b.Shape)])
+		}
+
+		return &tensor.Tensor{Data: result, Shape: shape}, nil
+	}
+}
+
+// prelu is leaky relu with the slope as a second, broadcast input
+func prelu(x, slope float64) float64 {
+	if x < 0 {
+		return slope * x
+	}
+	return x
+}
+
+// matrix returns element (i, j) of a 2D tensor, optionally transposed
+func matrix(t *tensor.Tensor, transposed bool) (rows, cols int, at func(i, j int) float64) {
+	rows, cols = t.Shape[0], t.Shape[1]
+	if transposed {
+		return cols, rows, func(i, j int) float64 { return t.Data[j*t.Shape[1]+i] }
+	}
+	return rows, cols, func(i, j int) float64 { return t.Data[i*t.Shape[1]+j] }
+}
+
+// runGemm computes alpha * A' * B' + beta * C where ' is an optional transpose and C is broadcast to the result
+func runGemm(n *Node, opset int64, inputs []*tensor.Tensor) (*tensor.Tensor, error) {
+
+	if len(inputs) < 2 || inputs[1] == nil || len(inputs[0].Shape) != 2 || len(inputs[1].Shape) != 2 {
+		return nil, fmt.Errorf("expected two 2D inputs")
+	}
+
+	alpha, beta := 1.0, 1.0
+	if a, ok := n.attribute("alpha"); ok {
+		alpha = a.F
+	}
+	if a, ok := n.attribute("beta"); ok {
+		beta = a.F
+	}
+	transA, _ := n.attribute("transA")
+	transB, _ := n.attribute("transB")
+
+	m, k, a := matrix(inputs[0], transA.I != 0)
+	kb, cols, b := matrix(inputs[1], transB.I != 0)
+	if k != kb {
+		return nil, fmt.Errorf("can't multiply %dx%d by %dx%d", m, k, kb, cols)
+	}
+
+	result := make([]float64, m*cols)
+	for i := 0; i < m; i++ {
+		for j := 0; j < cols; j++ {
+			var sum float64
+			for l := 0; l < k; l++ {
+				sum += a(i, l) * b(l, j)
+			}
+			result[i*cols+j] = alpha * sum
+		}
+	}
+
+	out := &tensor.Tensor{Data: result, Shape: []int{m, cols}}
+	if len(inputs) < 3 || inputs[2] == nil {
+		return out, nil
+	}
+
+	c := inputs[2]
+	if shape, err := broadcastShape(out.Shape, c.Shape); err != nil || !slices.Equal(shape, out.Shape) {
+		return nil, fmt.Errorf("bias of shape %v can't be broadcast to %v", c.Shape, out.Shape)
+	}
+	for i := range result {
+		result[i] += beta * c.Data[broadcastIndex(unravel(i, out.Shape), c.Shape)]
+	}
+
+	return out, nil
+}
+
+func runMatMul(n *Node, opset int64, inputs []*tensor.Tensor) (*tensor.Tensor, error) {
+	if len(inputs) != 2 || inputs[1] == nil || len(inputs[0].Shape) != 2 || len(inputs[1].Shape) != 2 {
+		return nil, fmt.Errorf("only 2D matrix multiplication is supported")
+	}
+	return runGemm(&Node{}, opset, inputs)
+}
+
+// axis resolves a possibly negative axis attribute against a rank
+func axis(n *Node, name string, fallback int64, rank int) (int, error) {
+	a := fallback
+	if attr, ok := n.attribute(name); ok {
+		a = attr.I
+	}
+	if a < 0 {
+		a += int64(rank)
+	}
+	if a < 0 || a > int64(rank) {
+		return 0, fmt.Errorf("axis %d is out of range for rank %d", a, rank)
+	}
+	return int(a), nil
+}
+
+// runFlatten reshapes to 2D, the dimensions before axis become the rows
+func runFlatten(n *Node, opset int64, inputs []*tensor.Tensor) (*tensor.Tensor, error) {
+	x := inputs[0]
+	a, err := axis(n, "axis", 1, len(x.Shape))
+	if err != nil {
+		return nil, err
+	}
+	return &tensor.Tensor{Data: x.Data, Shape: []int{size(x.Shape[:a]), size(x.Shape[a:])}}, nil
+}
+
+// runSoftmax normalizes along an axis, before opset 13 the input is flattened at the axis (default 1) and each row is
+// normalized, from 13 on only the axis itself (default -1) is
+func runSoftmax(n *Node, opset int64, inputs []*tensor.Tensor) (*tensor.Tensor, error) {
+	return normalize(n, opset, inputs, af.SoftMaxDim)
+}
+
+// runLogSoftmax is runSoftmax returning log probabilities
+func runLogSoftmax(n *Node, opset int64, inputs []*tensor.Tensor) (*tensor.Tensor, error) {
+	return normalize(n, opset, inputs, af.LogSoftMaxDim)
+}
+
+func normalize(n *Node, opset int64, inputs []*tensor.Tensor, f func(t *tensor.Tensor, dim int) *tensor.Tensor) (*tensor.Tensor, error) {
+
+	x := inputs[0]
+	if len(x.Shape) == 0 {
+		return nil, fmt.Errorf("%s needs at least one dimension", n.OpType)
+	}
+
+	if opset < 13 {
+		a, err := axis(n, "axis", 1, len(x.Shape))
+		if err != nil {
+			return nil, err
+		}
+		flat := &tensor.Tensor{Data: x.Data, Shape: []int{size(x.Shape[:a]), size(x.Shape[a:])}}
+		return &tensor.Tensor{Data: f(flat, 1).Data, Shape: slices.Clone(x.Shape)}, nil
+	}
+
+	a, err := axis(n, "axis", -1, len(x.Shape))
+	if err != nil || a == len(x.Shape) {
+		return nil, fmt.Errorf("axis is out of range for rank %d", len(x.Shape))
+	}
+
+	return &tensor.Tensor{Data: f(x, a).Data, Shape: slices.Clone(x.Shape)}, nil
+}
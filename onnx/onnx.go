@@ -0,0 +1,699 @@
+// Package onnx exports gotorch modules to ONNX and imports ONNX models as runnable networks
+package onnx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"gotorch/internal/half"
+	"gotorch/internal/protowire"
+	"gotorch/tensor"
+	"maps"
+	"math"
+	"os"
+	"slices"
+)
+
+/*
+ONNX models are protobuf ModelProto messages. The types here mirror the parts of onnx/onnx.proto we need, a model with
+a single graph of nodes, its initializers (the weights) and typed inputs and outputs, and are encoded and decoded by hand
+so the package has no dependencies. Field numbers come from https://github.com/onnx/onnx/blob/main/onnx/onnx.proto
+*/
+
+// ModelProto fields
+const (
+	modelIRVersion       = 1
+	modelProducerName    = 2
+	modelProducerVersion = 3
+	modelGraph           = 7
+	modelOpsetImport     = 8
+)
+
+// OperatorSetIdProto fields
+const (
+	opsetDomain  = 1
+	opsetVersion = 2
+)
+
+// GraphProto fields
+const (
+	graphNode        = 1
+	graphName        = 2
+	graphInitializer = 5
+	graphInput       = 11
+	graphOutput      = 12
+)
+
+// NodeProto fields
+const (
+	nodeInput     = 1
+	nodeOutput    = 2
+	nodeName      = 3
+	nodeOpType    = 4
+	nodeAttribute = 5
+	nodeDomain    = 7
+)
+
+// AttributeProto fields
+const (
+	attributeName   = 1
+	attributeF      = 2
+	attributeI      = 3
+	attributeS      = 4
+	attributeFloats = 7
+	attributeInts   = 8
+	attributeType   = 20
+)
+
+// TensorProto fields
+const (
+	tensorDims       = 1
+	tensorDataType   = 2
+	tensorFloatData  = 4
+	tensorInt32Data  = 5
+	tensorInt64Data  = 7
+	tensorName       = 8
+	tensorRawData    = 9
+	tensorDoubleData = 10
+)
+
+// ValueInfoProto, TypeProto and TensorShapeProto fields
+const (
+	valueInfoName   = 1
+	valueInfoType   = 2
+	typeTensorType  = 1
+	tensorTypeElem  = 1
+	tensorTypeShape = 2
+	shapeDim        = 1
+	dimensionValue  = 1
+	dimensionParam  = 2
+)
+
+// DataType is an ONNX tensor element type
+type DataType int32
+
+const (
+	Float   DataType = 1
+	Int32   DataType = 6
+	Int64   DataType = 7
+	Float16 DataType = 10
+	Double  DataType = 11
+)
+
+// AttributeType says which field of an Attribute is set
+type AttributeType int32
+
+const (
+	AttributeFloat  AttributeType = 1
+	AttributeInt    AttributeType = 2
+	AttributeString AttributeType = 3
+	AttributeFloats AttributeType = 6
+	AttributeInts   AttributeType = 7
+)
+
+// Model is an ONNX model, Opsets maps each operator domain to its version with "" being the default ai.onnx domain
+type Model struct {
+	IRVersion       int64
+	ProducerName    string
+	ProducerVersion string
+	Opsets          map[string]int64
+	Graph           Graph
+}
+
+// Opset returns the version of the default operator set
+func (m *Model) Opset() int64 {
+	if v, ok := m.Opsets[""]; ok {
+		return v
+	}
+	return m.Opsets["ai.onnx"]
+}
+
+// Graph is a list of nodes in execution order along with the weights they use
+type Graph struct {
+	Name         string
+	Nodes        []Node
+	Initializers []Initializer
+	Inputs       []ValueInfo
+	Outputs      []ValueInfo
+}
+
+// Node is a single operator, Inputs and Outputs name the values it reads and writes
+type Node struct {
+	Name       string
+	OpType     string
+	Domain     string
+	Inputs     []string
+	Outputs    []string
+	Attributes []Attribute
+}
+
+// attribute returns the attribute called name
+func (n *Node) attribute(name string) (Attribute, bool) {
+	for _, a := range n.Attributes {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Attribute{}, false
+}
+
+// Attribute is a named operator argument, only the field matching Type is set
+// attributes of types we don't support, like tensors and graphs, are decoded with only their name and type
+type Attribute struct {
+	Name   string
+	Type   AttributeType
+	F      float64
+	I      int64
+	S      string
+	Floats []float64
+	Ints   []int64
+}
+
+// Initializer is a constant tensor in the graph, DataType is the type it is stored as
+type Initializer struct {
+	Name     string
+	DataType DataType
+	Tensor   *tensor.Tensor
+}
+
+// Dim is one dimension of a value's shape, either a fixed size or a named symbolic size like "batch"
+type Dim struct {
+	Value int64
+	Param string
+}
+
+// ValueInfo describes a graph input or output
+type ValueInfo struct {
+	Name     string
+	ElemType DataType
+	Shape    []Dim
+}
+
+// Encode serializes a model to the ONNX protobuf format
+func Encode(m *Model) ([]byte, error) {
+
+	var out protowire.Message
+	out = out.Int64(modelIRVersion, m.IRVersion)
+	if m.ProducerName != "" {
+		out = out.String(modelProducerName, m.ProducerName)
+	}
+	if m.ProducerVersion != "" {
+		out = out.String(modelProducerVersion, m.ProducerVersion)
+	}
+
+	graph, err := encodeGraph(&m.Graph)
+	if err != nil {
+		return nil, err
+	}
+	out = out.Embedded(modelGraph, graph)
+
+	for _, domain := range slices.Sorted(maps.Keys(m.Opsets)) {
+		var opset protowire.Message
+		if domain != "" {
+			opset = opset.String(opsetDomain, domain)
+		}
+		opset = opset.Int64(opsetVersion, m.Opsets[domain])
+		out = out.Embedded(modelOpsetImport, opset)
+	}
+
+	return out, nil
+}
+
+func encodeGraph(g *Graph) (protowire.Message, error) {
+
+	var out protowire.Message
+	for _, node := range g.Nodes {
+		var n protowire.Message
+		for _, input := range node.Inputs {
+			n = n.String(nodeInput, input)
+		}
+		for _, output := range node.Outputs {
+			n = n.String(nodeOutput, output)
+		}
+		if node.Name != "" {
+			n = n.String(nodeName, node.Name)
+		}
+		n = n.String(nodeOpType, node.OpType)
+		for _, a := range node.Attributes {
+			n = n.Embedded(nodeAttribute, encodeAttribute(a))
+		}
+		if node.Domain != "" {
+			n = n.String(nodeDomain, node.Domain)
+		}
+		out = out.Embedded(graphNode, n)
+	}
+
+	if g.Name != "" {
+		out = out.String(graphName, g.Name)
+	}
+
+	for _, init := range g.Initializers {
+		t, err := encodeTensor(init)
+		if err != nil {
+			return nil, err
+		}
+		out = out.Embedded(graphInitializer, t)
+	}
+
+	for _, input := range g.Inputs {
+		out = out.Embedded(graphInput, encodeValueInfo(input))
+	}
+	for _, output := range g.Outputs {
+		out = out.Embedded(graphOutput, encodeValueInfo(output))
+	}
+
+	return out, nil
+}
+
+func encodeAttribute(a Attribute) protowire.Message {
+
+	var out protowire.Message
+	out = out.String(attributeName, a.Name)
+
+	switch a.Type {
+	case AttributeFloat:
+		out = out.Float(attributeF, float32(a.F))
+	case AttributeInt:
+		out = out.Int64(attributeI, a.I)
+	case AttributeString:
+		out = out.String(attributeS, a.S)
+	case AttributeFloats:
+		floats := make([]float32, len(a.Floats))
+		for i, f := range a.Floats {
+			floats[i] = float32(f)
+		}
+		out = out.PackedFloats(attributeFloats, floats)
+	case AttributeInts:
+		out = out.PackedInt64s(attributeInts, a.Ints)
+	}
+
+	return out.Int64(attributeType, int64(a.Type))
+}
+
+func encodeTensor(init Initializer) (protowire.Message, error) {
+
+	dims := make([]int64, len(init.Tensor.Shape))
+	for i, n := range init.Tensor.Shape {
+		dims[i] = int64(n)
+	}
+
+	var raw []byte
+	for _, v := range init.Tensor.Data {
+		switch init.DataType {
+		case Float:
+			raw = binary.LittleEndian.AppendUint32(raw, math.Float32bits(float32(v)))
+		case Double:
+			raw = binary.LittleEndian.AppendUint64(raw, math.Float64bits(v))
+		case Int64:
+			raw = binary.LittleEndian.AppendUint64(raw, uint64(int64(v)))
+		default:
+			return nil, fmt.Errorf("initializer %q has unsupported data type %d", init.Name, init.DataType)
+		}
+	}
+
+	var out protowire.Message
+	if len(dims) > 0 {
+		out = out.PackedInt64s(tensorDims, dims)
+	}
+	out = out.Int64(tensorDataType, int64(init.DataType))
+	out = out.String(tensorName, init.Name)
+	return out.Bytes(tensorRawData, raw), nil
+}
+
+func encodeValueInfo(v ValueInfo) protowire.Message {
+
+	var shape protowire.Message
+	for _, d := range v.Shape {
+		var dim protowire.Message
+		if d.Param != "" {
+			dim = dim.String(dimensionParam, d.Param)
+		} else {
+			dim = dim.Int64(dimensionValue, d.Value)
+		}
+		shape = shape.Embedded(shapeDim, dim)
+	}
+
+	var tensorType protowire.Message
+	tensorType = tensorType.Int64(tensorTypeElem, int64(v.ElemType))
+	tensorType = tensorType.Embedded(tensorTypeShape, shape)
+
+	var typeProto protowire.Message
+	typeProto = typeProto.Embedded(typeTensorType, tensorType)
+
+	var out protowire.Message
+	out = out.String(valueInfoName, v.Name)
+	return out.Embedded(valueInfoType, typeProto)
+}
+
+// Decode parses an ONNX model, fields we don't use are skipped
+func Decode(b []byte) (*Model, error) {
+
+	fields, err := protowire.DecodeFields(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid model: %w", err)
+	}
+
+	m := &Model{Opsets: map[string]int64{}}
+	for _, f := range fields {
+		switch f.Number {
+		case modelIRVersion:
+			m.IRVersion = int64(f.Value)
+		case modelProducerName:
+			m.ProducerName = string(f.Data)
+		case modelProducerVersion:
+			m.ProducerVersion = string(f.Data)
+		case modelGraph:
+			if err := decodeGraph(f.Data, &m.Graph); err != nil {
+				return nil, err
+			}
+		case modelOpsetImport:
+			opset, err := protowire.DecodeFields(f.Data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid opset import: %w", err)
+			}
+			var domain string
+			var version int64
+			for _, o := range opset {
+				switch o.Number {
+				case opsetDomain:
+					domain = string(o.Data)
+				case opsetVersion:
+					version = int64(o.Value)
+				}
+			}
+			m.Opsets[domain] = version
+		}
+	}
+
+	return m, nil
+}
+
+func decodeGraph(b []byte, g *Graph) error {
+
+	fields, err := protowire.DecodeFields(b)
+	if err != nil {
+		return fmt.Errorf("invalid graph: %w", err)
+	}
+
+	for _, f := range fields {
+		switch f.Number {
+		case graphNode:
+			node, err := decodeNode(f.Data)
+			if err != nil {
+				return err
+			}
+			g.Nodes = append(g.Nodes, node)
+		case graphName:
+			g.Name = string(f.Data)
+		case graphInitializer:
+			init, err := decodeTensor(f.Data)
+			if err != nil {
+				return err
+			}
+			g.Initializers = append(g.Initializers, init)
+		case graphInput, graphOutput:
+			info, err := decodeValueInfo(f.Data)
+			if err != nil {
+				return err
+			}
+			if f.Number == graphInput {
+				g.Inputs = append(g.Inputs, info)
+			} else {
+				g.Outputs = append(g.Outputs, info)
+			}
+		}
+	}
+
+	return nil
+}
+
+func decodeNode(b []byte) (Node, error) {
+
+	fields, err := protowire.DecodeFields(b)
+	if err != nil {
+		return Node{}, fmt.Errorf("invalid node: %w", err)
+	}
+
+	var n Node
+	for _, f := range fields {
+		switch f.Number {
+		case nodeInput:
+			n.Inputs = append(n.Inputs, string(f.Data))
+		case nodeOutput:
+			n.Outputs = append(n.Outputs, string(f.Data))
+		case nodeName:
+			n.Name = string(f.Data)
+		case nodeOpType:
+			n.OpType = string(f.Data)
+		case nodeDomain:
+			n.Domain = string(f.Data)
+		case nodeAttribute:
+			a, err := decodeAttribute(f.Data)
+			if err != nil {
+				return Node{}, fmt.Errorf("node %q: %w", n.Name, err)
+			}
+			n.Attributes = append(n.Attributes, a)
+		}
+	}
+
+	return n, nil
+}
+
+func decodeAttribute(b []byte) (Attribute, error) {
+
+	fields, err := protowire.DecodeFields(b)
+	if err != nil {
+		return Attribute{}, fmt.Errorf("invalid attribute: %w", err)
+	}
+
+	var a Attribute
+	for _, f := range fields {
+		switch f.Number {
+		case attributeName:
+			a.Name = string(f.Data)
+		case attributeType:
+			a.Type = AttributeType(f.Value)
+		case attributeF:
+			a.F = float64(math.Float32frombits(uint32(f.Value)))
+		case attributeI:
+			a.I = int64(f.Value)
+		case attributeS:
+			a.S = string(f.Data)
+		case attributeFloats:
+			floats, err := f.Floats()
+			if err != nil {
+				return Attribute{}, err
+			}
+			a.Floats = append(a.Floats, floats...)
+		case attributeInts:
+			ints, err := f.Int64s()
+			if err != nil {
+				return Attribute{}, err
+			}
+			a.Ints = append(a.Ints, ints...)
+		}
+	}
+
+	return a, nil
+}
+
+func decodeTensor(b []byte) (Initializer, error) {
+
+	fields, err := protowire.DecodeFields(b)
+	if err != nil {
+		return Initializer{}, fmt.Errorf("invalid tensor: %w", err)
+	}
+
+	var init Initializer
+	var dims []int64
+	var raw []byte
+	var data []float64
+	for _, f := range fields {
+		switch f.Number {
+		case tensorDims:
+			d, err := f.Int64s()
+			if err != nil {
+				return Initializer{}, err
+			}
+			dims = append(dims, d...)
+		case tensorDataType:
+			init.DataType = DataType(f.Value)
+		case tensorName:
+			init.Name = string(f.Data)
+		case tensorRawData:
+			raw = f.Data
+		case tensorFloatData:
+			values, err := f.Floats()
+			if err != nil {
+				return Initializer{}, err
+			}
+			data = append(data, values...)
+		case tensorDoubleData:
+			values, err := f.Doubles()
+			if err != nil {
+				return Initializer{}, err
+			}
+			data = append(data, values...)
+		case tensorInt32Data, tensorInt64Data:
+			values, err := f.Int64s()
+			if err != nil {
+				return Initializer{}, err
+			}
+			for _, v := range values {
+				if f.Number == tensorInt32Data {
+					v = int64(int32(v))
+				}
+				data = append(data, float64(v))
+			}
+		}
+	}
+
+	shape := make([]int, len(dims))
+	size := 1
+	for i, d := range dims {
+		if d < 0 {
+			return Initializer{}, fmt.Errorf("tensor %q has invalid dims %v", init.Name, dims)
+		}
+		shape[i] = int(d)
+		size *= int(d)
+	}
+
+	if raw != nil {
+		var err error
+		if data, err = decodeRaw(raw, init.DataType, size); err != nil {
+			return Initializer{}, fmt.Errorf("tensor %q: %w", init.Name, err)
+		}
+	}
+
+	if len(data) != size {
+		return Initializer{}, fmt.Errorf("tensor %q has dims %v but %d values", init.Name, dims, len(data))
+	}
+
+	init.Tensor = &tensor.Tensor{Data: data, Shape: shape}
+	return init, nil
+}
+
+// decodeRaw converts little endian raw_data to float64
+func decodeRaw(raw []byte, dataType DataType, size int) ([]float64, error) {
+
+	width := map[DataType]int{Float: 4, Double: 8, Int32: 4, Int64: 8, Float16: 2}[dataType]
+	if width == 0 {
+		return nil, fmt.Errorf("unsupported data type %d", dataType)
+	}
+	if len(raw) != size*width {
+		return nil, fmt.Errorf("raw data has %d bytes but %d are needed", len(raw), size*width)
+	}
+
+	data := make([]float64, size)
+	for i := range data {
+		b := raw[i*width:]
+		switch dataType {
+		case Float:
+			data[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		case Double:
+			data[i] = math.Float64frombits(binary.LittleEndian.Uint64(b))
+		case Int32:
+			data[i] = float64(int32(binary.LittleEndian.Uint32(b)))
+		case Int64:
+			data[i] = float64(int64(binary.LittleEndian.Uint64(b)))
+		case Float16:
+			data[i] = half.ToFloat64(binary.LittleEndian.Uint16(b))
+		}
+	}
+
+	return data, nil
+}
+
+func decodeValueInfo(b []byte) (ValueInfo, error) {
+
+	fields, err := protowire.DecodeFields(b)
+	if err != nil {
+		return ValueInfo{}, fmt.Errorf("invalid value info: %w", err)
+	}
+
+	var v ValueInfo
+	for _, f := range fields {
+		switch f.Number {
+		case valueInfoName:
+			v.Name = string(f.Data)
+		case valueInfoType:
+			if err := decodeType(f.Data, &v); err != nil {
+				return ValueInfo{}, fmt.Errorf("value %q: %w", v.Name, err)
+			}
+		}
+	}
+
+	return v, nil
+}
+
+// decodeType reads the element type and shape of a tensor type, other types such as sequences are left empty
+func decodeType(b []byte, v *ValueInfo) error {
+
+	fields, err := protowire.DecodeFields(b)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		if f.Number != typeTensorType {
+			continue
+		}
+		tensorType, err := protowire.DecodeFields(f.Data)
+		if err != nil {
+			return err
+		}
+		for _, t := range tensorType {
+			switch t.Number {
+			case tensorTypeElem:
+				v.ElemType = DataType(t.Value)
+			case tensorTypeShape:
+				dims, err := protowire.DecodeFields(t.Data)
+				if err != nil {
+					return err
+				}
+				for _, d := range dims {
+					if d.Number != shapeDim {
+						continue
+					}
+					dim, err := protowire.DecodeFields(d.Data)
+					if err != nil {
+						return err
+					}
+					var parsed Dim
+					for _, part := range dim {
+						switch part.Number {
+						case dimensionValue:
+							parsed.Value = int64(part.Value)
+						case dimensionParam:
+							parsed.Param = string(part.Data)
+						}
+					}
+					v.Shape = append(v.Shape, parsed)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Save writes a model to an .onnx file
+func Save(filePath string, m *Model) error {
+
+	encoded, err := Encode(m)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filePath, encoded, 0o644)
+}
+
+// Load reads an .onnx file
+func Load(filePath string) (*Model, error) {
+
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return Decode(b)
+}
@@ -0,0 +1,373 @@
+package onnx
+
+import (
+	"gotorch/internal/protowire"
+	"gotorch/model"
+	"gotorch/tensor"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func approxEqual(a, b []float64, tolerance float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+func newTestModel() *model.Sequential {
+	return model.NewSequential(
+		&model.Linear{Weights: []float64{0.5, -1.25, 2}, Biases: []float64{0.1}},
+		&model.Linear{Weights: []float64{-3}, Biases: []float64{0.25}},
+	)
+}
+
+func TestExportImport(t *testing.T) {
+
+	m := newTestModel()
+	exported, err := Export(m, 3, ExportOptions{})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "model.onnx")
+	if err := Save(path, exported); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if loaded.Opset() != exportOpset || loaded.IRVersion != exportIRVersion || loaded.ProducerName != "gotorch" {
+		t.Errorf("Expected opset %d, IR version %d from gotorch, got %d, %d from %q", exportOpset, exportIRVersion, loaded.Opset(), loaded.IRVersion, loaded.ProducerName)
+	}
+	if len(loaded.Graph.Initializers) != 4 || loaded.Graph.Initializers[0].Name != "0.weight" || loaded.Graph.Initializers[3].Name != "1.bias" {
+		t.Errorf("Expected initializers named like the state dict, got %+v", loaded.Graph.Initializers)
+	}
+	if in := loaded.Graph.Inputs[0]; in.Name != "input" || in.Shape[0].Param != "batch" || in.Shape[1].Value != 3 {
+		t.Errorf("Expected input [batch, 3], got %+v", in)
+	}
+
+	network, err := Import(loaded)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	input := tensor.NewTensor([]float64{1, 2, 3, -0.5, 0.25, 4}, 2, 3)
+	expected := m.Forward(input)
+	got := network.Forward(input)
+
+	if !approxEqual(got.Data, expected.Data, 1e-6) || got.Shape[0] != 2 || got.Shape[1] != 1 {
+		t.Errorf("Expected %v with shape %v, got %v with shape %v", expected.Data, expected.Shape, got.Data, got.Shape)
+	}
+}
+
+func TestExportActivations(t *testing.T) {
+
+	m := model.NewSequential(
+		&model.Linear{Weights: []float64{0.5, -1.25}, Biases: []float64{0.1}},
+		model.Tanh{},
+		&model.Linear{Weights: []float64{-3}, Biases: []float64{0.25}},
+		&model.LeakyReLU{},
+		model.Sigmoid{},
+		model.ReLU{},
+		model.NewSoftmax(-1),
+	)
+
+	exported, err := Export(m, 2, ExportOptions{DataType: Double})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	var ops []string
+	for _, n := range exported.Graph.Nodes {
+		ops = append(ops, n.OpType)
+	}
+	expectedOps := []string{"Gemm", "Tanh", "Gemm", "LeakyRelu", "Sigmoid", "Relu", "Softmax"}
+	if !reflect.DeepEqual(ops, expectedOps) {
+		t.Errorf("Expected nodes %v, got %v", expectedOps, ops)
+	}
+
+	if axis, ok := exported.Graph.Nodes[6].attribute("axis"); !ok || axis.I != -1 {
+		t.Errorf("Expected softmax to be exported along axis -1, got %+v", exported.Graph.Nodes[6].Attributes)
+	}
+
+	// drop the softmax over a single feature, which is always 1, so the other activations are checked
+	m.Layers = m.Layers[:6]
+	exported, err = Export(m, 2, ExportOptions{DataType: Double})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	network, err := Import(exported)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	input := tensor.NewTensor([]float64{1, 2, -0.5, 0.25, 3, -1}, 3, 2)
+	expected := m.Forward(input)
+	if got := network.Forward(input); !approxEqual(got.Data, expected.Data, 1e-12) {
+		t.Errorf("Expected %v, got %v", expected.Data, got.Data)
+	}
+}
+
+func TestExportPReLU(t *testing.T) {
+
+	m := model.NewSequential(
+		&model.PReLU{Weights: []float64{0.25, 0.5}},
+		&model.Linear{Weights: []float64{0.5, -1.25}, Biases: []float64{0.1}},
+		model.LeakyReLU{NegativeSlope: 0.2},
+		model.NewPReLU(1, 0.3),
+	)
+
+	exported, err := Export(m, 2, ExportOptions{DataType: Double})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if alpha, ok := exported.Graph.Nodes[2].attribute("alpha"); !ok || alpha.F != 0.2 {
+		t.Errorf("Expected the leaky relu slope to be exported, got %+v", exported.Graph.Nodes[2].Attributes)
+	}
+	if exported.Graph.Initializers[0].Name != "0.weight" || exported.Graph.Initializers[3].Name != "3.weight" {
+		t.Errorf("Expected the slopes named like the state dict, got %+v", exported.Graph.Initializers)
+	}
+
+	network, err := Import(exported)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	input := tensor.NewTensor([]float64{1, -2, -0.5, 0.25, -3, -1}, 3, 2)
+	expected := m.Forward(input)
+	if got := network.Forward(input); !approxEqual(got.Data, expected.Data, 1e-12) {
+		t.Errorf("Expected %v, got %v", expected.Data, got.Data)
+	}
+
+	if _, err := Export(model.NewSequential(&model.PReLU{Weights: []float64{1, 2, 3}}), 2, ExportOptions{}); err == nil {
+		t.Errorf("Expected an error for slopes that don't match the features")
+	}
+}
+
+func TestExportDouble(t *testing.T) {
+
+	m := &model.Linear{Weights: []float64{1.0 / 3, math.Pi}, Biases: []float64{1e-10}}
+	exported, err := Export(m, 2, ExportOptions{DataType: Double})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	b, err := Encode(exported)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	decoded, err := Decode(b)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	weight := decoded.Graph.Initializers[0]
+	if weight.DataType != Double || weight.Tensor.Data[0] != 1.0/3 || weight.Tensor.Data[1] != math.Pi {
+		t.Errorf("Expected the weights to keep full precision, got %v", weight.Tensor.Data)
+	}
+
+	network, err := Import(decoded)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	input := tensor.NewTensor([]float64{2, -1}, 1, 2)
+	if got, expected := network.Forward(input).Data[0], m.Forward(input).Data[0]; got != expected {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+
+	tests := map[string]struct {
+		model    model.Model
+		features int
+	}{
+		"wrong features": {&model.Linear{Weights: []float64{1, 2}, Biases: []float64{0}}, 3},
+		"several biases": {&model.Linear{Weights: []float64{1, 2}, Biases: []float64{0, 1}}, 2},
+		"unsupported":    {model.NewSequential(&model.Linear{Weights: []float64{1}, Biases: []float64{0}}, &Network{}), 1},
+	}
+
+	for name, test := range tests {
+		if _, err := Export(test.model, test.features, ExportOptions{}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	if _, err := Export(newTestModel(), 3, ExportOptions{DataType: Int64}); err == nil {
+		t.Errorf("Expected an error for an integer data type")
+	}
+}
+
+// handBuilt returns a model with a single input x of shape [2, 2, 2] running the given nodes
+func handBuilt(opset int64, nodes ...Node) *Model {
+	return &Model{
+		IRVersion: 7,
+		Opsets:    map[string]int64{"": opset},
+		Graph: Graph{
+			Nodes:   nodes,
+			Inputs:  []ValueInfo{{Name: "x", ElemType: Float}},
+			Outputs: []ValueInfo{{Name: "y", ElemType: Float}},
+		},
+	}
+}
+
+func runHandBuilt(t *testing.T, m *Model, input *tensor.Tensor) *tensor.Tensor {
+
+	b, err := Encode(m)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	decoded, err := Decode(b)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	network, err := Import(decoded)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	return network.Forward(input)
+}
+
+func TestOperators(t *testing.T) {
+
+	input := tensor.NewTensor([]float64{-1, 2, 0, 3, 1, 1, -2, 4}, 2, 2, 2)
+
+	relu := runHandBuilt(t, handBuilt(13,
+		Node{OpType: "Relu", Inputs: []string{"x"}, Outputs: []string{"r"}},
+		Node{OpType: "Flatten", Inputs: []string{"r"}, Outputs: []string{"y"}},
+	), input)
+	if !approxEqual(relu.Data, []float64{0, 2, 0, 3, 1, 1, 0, 4}, 1e-12) || relu.Shape[0] != 2 || relu.Shape[1] != 4 {
+		t.Errorf("Expected relu then flatten, got %v with shape %v", relu.Data, relu.Shape)
+	}
+
+	leaky := runHandBuilt(t, handBuilt(13,
+		Node{OpType: "LeakyRelu", Inputs: []string{"x"}, Outputs: []string{"y"}, Attributes: []Attribute{{Name: "alpha", Type: AttributeFloat, F: 0.5}}},
+	), input)
+	if !approxEqual(leaky.Data, []float64{-0.5, 2, 0, 3, 1, 1, -1, 4}, 1e-12) {
+		t.Errorf("Expected leaky relu with alpha 0.5, got %v", leaky.Data)
+	}
+
+	tanh := runHandBuilt(t, handBuilt(13, Node{OpType: "Tanh", Inputs: []string{"x"}, Outputs: []string{"y"}}), input)
+	if math.Abs(tanh.Data[1]-math.Tanh(2)) > 1e-12 {
+		t.Errorf("Expected tanh(2) = %v, got %v", math.Tanh(2), tanh.Data[1])
+	}
+
+	// from opset 13 softmax normalizes the last axis on its own
+	softmax := runHandBuilt(t, handBuilt(13, Node{OpType: "Softmax", Inputs: []string{"x"}, Outputs: []string{"y"}}), input)
+	e := math.Exp(3) / (1 + math.Exp(3))
+	if !approxEqual(softmax.Data[:2], []float64{1 - e, e}, 1e-12) || softmax.Data[4] != 0.5 {
+		t.Errorf("Expected softmax over the last axis, got %v", softmax.Data)
+	}
+
+	// before 13 it flattens from axis 1, so each batch of 4 values sums to one
+	legacy := runHandBuilt(t, handBuilt(11, Node{OpType: "Softmax", Inputs: []string{"x"}, Outputs: []string{"y"}}), input)
+	if sum := legacy.Data[0] + legacy.Data[1] + legacy.Data[2] + legacy.Data[3]; math.Abs(sum-1) > 1e-12 {
+		t.Errorf("Expected the first 4 values to sum to 1, got %v", sum)
+	}
+
+	// large values mustn't overflow
+	large := runHandBuilt(t, handBuilt(13, Node{OpType: "Softmax", Inputs: []string{"x"}, Outputs: []string{"y"}}), tensor.NewTensor([]float64{1000, 1000}, 1, 2))
+	if !approxEqual(large.Data, []float64{0.5, 0.5}, 1e-12) {
+		t.Errorf("Expected [0.5 0.5], got %v", large.Data)
+	}
+
+	logSoftmax := runHandBuilt(t, handBuilt(13, Node{OpType: "LogSoftmax", Inputs: []string{"x"}, Outputs: []string{"y"}}), input)
+	if !approxEqual(logSoftmax.Data[:2], []float64{math.Log(1 - e), math.Log(e)}, 1e-12) {
+		t.Errorf("Expected log softmax over the last axis, got %v", logSoftmax.Data)
+	}
+}
+
+func TestBroadcasting(t *testing.T) {
+
+	m := handBuilt(13,
+		Node{OpType: "Add", Inputs: []string{"x", "row"}, Outputs: []string{"a"}},
+		Node{OpType: "Mul", Inputs: []string{"a", "column"}, Outputs: []string{"y"}},
+	)
+	m.Graph.Initializers = []Initializer{
+		{Name: "row", DataType: Float, Tensor: tensor.NewTensor([]float64{10, 20, 30}, 3)},
+		{Name: "column", DataType: Float, Tensor: tensor.NewTensor([]float64{1, -1}, 2, 1)},
+	}
+
+	got := runHandBuilt(t, m, tensor.NewTensor([]float64{1, 2, 3, 4, 5, 6}, 2, 3))
+	if !approxEqual(got.Data, []float64{11, 22, 33, -14, -25, -36}, 1e-12) {
+		t.Errorf("Expected [11 22 33 -14 -25 -36], got %v", got.Data)
+	}
+
+	bad := handBuilt(13, Node{OpType: "Add", Inputs: []string{"x", "row"}, Outputs: []string{"y"}})
+	bad.Graph.Initializers = []Initializer{{Name: "row", DataType: Float, Tensor: tensor.NewTensor([]float64{1, 2}, 2)}}
+	network, err := Import(bad)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if _, err := network.Run(map[string]*tensor.Tensor{"x": tensor.NewTensor([]float64{1, 2, 3}, 1, 3)}); err == nil {
+		t.Errorf("Expected an error for shapes that can't be broadcast")
+	}
+}
+
+func TestCompatibilityReport(t *testing.T) {
+
+	m := handBuilt(5,
+		Node{OpType: "Relu", Inputs: []string{"x"}, Outputs: []string{"a"}},
+		Node{OpType: "Relu", Inputs: []string{"a"}, Outputs: []string{"b"}},
+		Node{OpType: "Conv", Inputs: []string{"b"}, Outputs: []string{"c"}},
+		Node{OpType: "Custom", Domain: "com.example", Inputs: []string{"c"}, Outputs: []string{"y"}},
+	)
+
+	report := CheckCompatibility(m)
+	if report.OK() || len(report.Ops) != 3 || len(report.Problems) != 3 {
+		t.Fatalf("Expected 3 ops with 3 problems, got %s", report)
+	}
+	if report.Ops[0].OpType != "Relu" || report.Ops[0].Count != 2 || report.Ops[0].Supported {
+		t.Errorf("Expected Relu x2 to be unsupported before opset 6, got %+v", report.Ops[0])
+	}
+	if report.Ops[2].OpType != "com.example.Custom" || report.Ops[2].Supported {
+		t.Errorf("Expected the custom domain op to be unsupported, got %+v", report.Ops[2])
+	}
+	if _, err := Import(m); err == nil || !strings.Contains(err.Error(), "Conv") {
+		t.Errorf("Expected Import to fail naming Conv, got %v", err)
+	}
+
+	report = CheckCompatibility(handBuilt(maxTestedOpset+1, Node{OpType: "Relu", Inputs: []string{"x"}, Outputs: []string{"y"}}))
+	if !report.OK() || len(report.Warnings) != 1 {
+		t.Errorf("Expected a newer opset to only warn, got %s", report)
+	}
+
+	m.Opsets = map[string]int64{"com.example": 1}
+	if report := CheckCompatibility(m); report.OK() || !strings.Contains(report.String(), "ai.onnx") {
+		t.Errorf("Expected a missing default opset to be a problem, got %s", report)
+	}
+}
+
+func TestDecodeUnpackedFields(t *testing.T) {
+
+	// dims and float data written one value at a time, the way older exporters did
+	var tensorProto protowire.Message
+	tensorProto = tensorProto.Int64(1, 2).Int64(1, 1)
+	tensorProto = tensorProto.Int64(2, int64(Float))
+	tensorProto = tensorProto.Float(4, 1.5).Float(4, -2)
+	tensorProto = tensorProto.String(8, "w")
+
+	init, err := decodeTensor(tensorProto)
+	if err != nil {
+		t.Fatalf("decodeTensor failed: %v", err)
+	}
+	if init.Name != "w" || init.Tensor.Shape[0] != 2 || init.Tensor.Shape[1] != 1 || !approxEqual(init.Tensor.Data, []float64{1.5, -2}, 0) {
+		t.Errorf("Expected w = [1.5 -2] with shape [2 1], got %s = %v with shape %v", init.Name, init.Tensor.Data, init.Tensor.Shape)
+	}
+
+	if _, err := Decode([]byte{0x0a, 0x05, 0x01}); err == nil {
+		t.Errorf("Expected an error for a truncated model")
+	}
+}
@@ -0,0 +1,89 @@
+package tensorboard
+
+import "gotorch/internal/protowire"
+
+/*
+Field numbers of the tensorflow Event, Summary and GraphDef messages, which are hand encoded with the protowire package.
+They come from tensorflow/core/util/event.proto, tensorflow/core/framework/summary.proto and
+tensorflow/core/framework/graph.proto.
+*/
+
+// Event fields
+const (
+	eventWallTime    = 1
+	eventStep        = 2
+	eventFileVersion = 3
+	eventGraphDef    = 4
+	eventSummary     = 5
+)
+
+// Summary and Summary.Value fields
+const (
+	summaryValue = 1
+
+	valueTag         = 1
+	valueSimpleValue = 2
+	valueImage       = 4
+	valueHisto       = 5
+	valueTensor      = 8
+	valueMetadata    = 9
+)
+
+// Summary.Image fields
+const (
+	imageHeight     = 1
+	imageWidth      = 2
+	imageColorspace = 3
+	imageEncoded    = 4
+)
+
+// HistogramProto fields
+const (
+	histoMin         = 1
+	histoMax         = 2
+	histoNum         = 3
+	histoSum         = 4
+	histoSumSquares  = 5
+	histoBucketLimit = 6
+	histoBucket      = 7
+)
+
+// SummaryMetadata, PluginData, TensorProto and TensorShapeProto fields used by text summaries
+const (
+	metadataPluginData = 1
+	pluginDataName     = 1
+
+	tensorDtype     = 1
+	tensorShape     = 2
+	tensorStringVal = 8
+	shapeDim        = 2
+	dimSize         = 1
+
+	dtString = 7
+)
+
+// GraphDef, NodeDef and VersionDef fields
+const (
+	graphNode     = 1
+	graphVersions = 4
+	nodeName      = 1
+	nodeOp        = 2
+	nodeInput     = 3
+	versionsProd  = 1
+)
+
+func encodeEvent(wallTime float64, step int64, fill func(protowire.Message) protowire.Message) []byte {
+	var m protowire.Message
+	m = m.Double(eventWallTime, wallTime)
+	m = m.Int64(eventStep, step)
+	return fill(m)
+}
+
+func encodeSummary(tag string, fill func(protowire.Message) protowire.Message) protowire.Message {
+	var value protowire.Message
+	value = value.String(valueTag, tag)
+	value = fill(value)
+
+	var summary protowire.Message
+	return summary.Embedded(summaryValue, value)
+}
@@ -0,0 +1,444 @@
+// Package tensorboard writes tensorboard event files so training runs can be inspected with the standard tooling
+package tensorboard
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"gotorch/internal/protowire"
+	"gotorch/model"
+	"gotorch/tensor"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Event files are a sequence of TFRecords, each one holding a protobuf encoded Event. A record is framed as
+
+	uint64 length | uint32 masked crc32c of length | data | uint32 masked crc32c of data
+
+with every integer little endian. The first event in a file always declares the file version.
+*/
+
+const fileVersion = "brain.Event:2"
+
+// the default number of buckets used for histograms
+const defaultHistogramBins = 30
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// masks a crc the way TFRecord expects, storing crcs of data that contains crcs is otherwise problematic
+func maskedCRC(data []byte) uint32 {
+	crc := crc32.Checksum(data, crc32c)
+	return ((crc >> 15) | (crc << 17)) + 0xa282ead8
+}
+
+// SummaryWriter writes scalars, histograms, text, images and graphs to an event file in a log directory
+// it is safe for concurrent use and implements model.MetricSink so it can be plugged into a ProgressLogger
+type SummaryWriter struct {
+	// HistogramBins is the number of equal width buckets used by AddHistogram, 0 uses 30
+	HistogramBins int
+
+	mu         sync.Mutex
+	file       *os.File
+	writer     *bufio.Writer
+	path       string
+	batchSteps int64
+	now        func() time.Time
+}
+
+// NewSummaryWriter creates logDir if needed and opens a new event file in it
+func NewSummaryWriter(logDir string) (*SummaryWriter, error) {
+
+	if err := os.MkdirAll(logDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+
+	now := time.Now()
+	path := filepath.Join(logDir, fmt.Sprintf("events.out.tfevents.%010d.%s", now.Unix(), hostname))
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &SummaryWriter{file: file, writer: bufio.NewWriter(file), path: path, now: time.Now}
+
+	if err := w.writeEvent(0, func(m protowire.Message) protowire.Message {
+		return m.String(eventFileVersion, fileVersion)
+	}); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return w, w.Flush()
+}
+
+// returns the path of the event file being written
+func (w *SummaryWriter) Path() string {
+	return w.path
+}
+
+// encodes an event and writes it as a single TFRecord
+func (w *SummaryWriter) writeEvent(step int64, fill func(protowire.Message) protowire.Message) error {
+
+	wallTime := float64(w.now().UnixNano()) / 1e9
+	data := encodeEvent(wallTime, step, fill)
+
+	header := binary.LittleEndian.AppendUint64(nil, uint64(len(data)))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return fmt.Errorf("summary writer is closed")
+	}
+
+	record := make([]byte, 0, len(data)+16)
+	record = append(record, header...)
+	record = binary.LittleEndian.AppendUint32(record, maskedCRC(header))
+	record = append(record, data...)
+	record = binary.LittleEndian.AppendUint32(record, maskedCRC(data))
+
+	_, err := w.writer.Write(record)
+	return err
+}
+
+// AddScalar records a single value
+func (w *SummaryWriter) AddScalar(tag string, value float64, step int64) error {
+	return w.writeEvent(step, func(m protowire.Message) protowire.Message {
+		return m.Embedded(eventSummary, encodeSummary(tag, func(v protowire.Message) protowire.Message {
+			return v.Float(valueSimpleValue, float32(value))
+		}))
+	})
+}
+
+// AddHistogram records the distribution of a set of values, such as the weights or gradients of a layer
+// NaN and infinite values, which diverging gradients produce, have no bucket so they're left out
+func (w *SummaryWriter) AddHistogram(tag string, values []float64, step int64) error {
+
+	values = finite(values)
+	if len(values) == 0 {
+		return fmt.Errorf("cannot write a histogram of no finite values for %q", tag)
+	}
+
+	bins := w.HistogramBins
+	if bins <= 0 {
+		bins = defaultHistogramBins
+	}
+
+	histo := encodeHistogram(values, bins)
+
+	return w.writeEvent(step, func(m protowire.Message) protowire.Message {
+		return m.Embedded(eventSummary, encodeSummary(tag, func(v protowire.Message) protowire.Message {
+			return v.Embedded(valueHisto, histo)
+		}))
+	})
+}
+
+// finite returns the values that aren't NaN or infinite
+func finite(values []float64) []float64 {
+	result := make([]float64, 0, len(values))
+	for _, v := range values {
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// encodes a HistogramProto with equal width buckets between the min and max value
+func encodeHistogram(values []float64, bins int) protowire.Message {
+
+	minValue, maxValue := math.Inf(1), math.Inf(-1)
+	var sum, sumSquares float64
+	for _, v := range values {
+		minValue = math.Min(minValue, v)
+		maxValue = math.Max(maxValue, v)
+		sum += v
+		sumSquares += v * v
+	}
+
+	// a constant tensor gets a single bucket
+	if minValue == maxValue {
+		bins = 1
+	}
+
+	width := (maxValue - minValue) / float64(bins)
+	limits := make([]float64, bins)
+	counts := make([]float64, bins)
+	for i := range limits {
+		limits[i] = minValue + width*float64(i+1)
+	}
+	limits[bins-1] = maxValue
+
+	for _, v := range values {
+		i := bins - 1
+		if width > 0 {
+			i = min(int((v-minValue)/width), bins-1)
+		}
+		counts[i]++
+	}
+
+	var histo protowire.Message
+	histo = histo.Double(histoMin, minValue)
+	histo = histo.Double(histoMax, maxValue)
+	histo = histo.Double(histoNum, float64(len(values)))
+	histo = histo.Double(histoSum, sum)
+	histo = histo.Double(histoSumSquares, sumSquares)
+	histo = histo.PackedDoubles(histoBucketLimit, limits)
+	histo = histo.PackedDoubles(histoBucket, counts)
+
+	return histo
+}
+
+// AddText records a string, which tensorboard renders as markdown
+func (w *SummaryWriter) AddText(tag, text string, step int64) error {
+
+	var pluginData protowire.Message
+	pluginData = pluginData.String(pluginDataName, "text")
+	var metadata protowire.Message
+	metadata = metadata.Embedded(metadataPluginData, pluginData)
+
+	var dim protowire.Message
+	dim = dim.Int64(dimSize, 1)
+	var shape protowire.Message
+	shape = shape.Embedded(shapeDim, dim)
+
+	var t protowire.Message
+	t = t.Varint(tensorDtype, dtString)
+	t = t.Embedded(tensorShape, shape)
+	t = t.String(tensorStringVal, text)
+
+	return w.writeEvent(step, func(m protowire.Message) protowire.Message {
+		return m.Embedded(eventSummary, encodeSummary(tag, func(v protowire.Message) protowire.Message {
+			v = v.Embedded(valueMetadata, metadata)
+			return v.Embedded(valueTensor, t)
+		}))
+	})
+}
+
+// AddImage records an image from a tensor with values in [0, 1]
+// the tensor is either [height, width] for grayscale or [channels, height, width] with 1, 3 or 4 channels
+func (w *SummaryWriter) AddImage(tag string, t *tensor.Tensor, step int64) error {
+
+	encoded, height, width, channels, err := encodePNG(t)
+	if err != nil {
+		return err
+	}
+
+	var img protowire.Message
+	img = img.Int64(imageHeight, int64(height))
+	img = img.Int64(imageWidth, int64(width))
+	img = img.Int64(imageColorspace, int64(channels))
+	img = img.Bytes(imageEncoded, encoded)
+
+	return w.writeEvent(step, func(m protowire.Message) protowire.Message {
+		return m.Embedded(eventSummary, encodeSummary(tag, func(v protowire.Message) protowire.Message {
+			return v.Embedded(valueImage, img)
+		}))
+	})
+}
+
+// converts a CHW or HW tensor into a png image
+func encodePNG(t *tensor.Tensor) ([]byte, int, int, int, error) {
+
+	var channels, height, width int
+	switch len(t.Shape) {
+	case 2:
+		channels, height, width = 1, t.Shape[0], t.Shape[1]
+	case 3:
+		channels, height, width = t.Shape[0], t.Shape[1], t.Shape[2]
+	default:
+		return nil, 0, 0, 0, fmt.Errorf("image tensors must be [height, width] or [channels, height, width], got shape %v", t.Shape)
+	}
+
+	if channels != 1 && channels != 3 && channels != 4 {
+		return nil, 0, 0, 0, fmt.Errorf("image tensors must have 1, 3 or 4 channels, got %d", channels)
+	}
+	if len(t.Data) != channels*height*width {
+		return nil, 0, 0, 0, fmt.Errorf("image tensor has %d values but shape %v", len(t.Data), t.Shape)
+	}
+
+	// scales a value in [0, 1] to a byte, clamping anything out of range
+	pixel := func(c, y, x int) uint8 {
+		v := t.Data[c*height*width+y*width+x]
+		return uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))
+	}
+
+	var img image.Image
+	switch channels {
+	case 1:
+		gray := image.NewGray(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				gray.SetGray(x, y, color.Gray{Y: pixel(0, y, x)})
+			}
+		}
+		img = gray
+	default:
+		rgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				alpha := uint8(255)
+				if channels == 4 {
+					alpha = pixel(3, y, x)
+				}
+				rgba.SetNRGBA(x, y, color.NRGBA{R: pixel(0, y, x), G: pixel(1, y, x), B: pixel(2, y, x), A: alpha})
+			}
+		}
+		img = rgba
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, 0, 0, 0, err
+	}
+
+	return buf.Bytes(), height, width, channels, nil
+}
+
+// GraphNode is a single operation in a graph, Inputs are the names of the nodes feeding into it
+type GraphNode struct {
+	Name   string
+	Op     string
+	Inputs []string
+}
+
+// AddGraph records the structure of a model so it can be viewed in the graphs dashboard
+func (w *SummaryWriter) AddGraph(nodes []GraphNode) error {
+
+	var graph protowire.Message
+	for _, node := range nodes {
+		var n protowire.Message
+		n = n.String(nodeName, node.Name)
+		n = n.String(nodeOp, node.Op)
+		for _, input := range node.Inputs {
+			n = n.String(nodeInput, input)
+		}
+		graph = graph.Embedded(graphNode, n)
+	}
+
+	var versions protowire.Message
+	versions = versions.Int64(versionsProd, 22)
+	graph = graph.Embedded(graphVersions, versions)
+
+	return w.writeEvent(0, func(m protowire.Message) protowire.Message {
+		return m.Bytes(eventGraphDef, graph)
+	})
+}
+
+// ModuleGraph describes a module as an input placeholder and one variable per parameter feeding into a node for the module itself
+func ModuleGraph(name string, m model.Module) []GraphNode {
+
+	op := fmt.Sprintf("%T", m)
+	if i := strings.LastIndexByte(op, '.'); i >= 0 {
+		op = op[i+1:]
+	}
+
+	nodes := []GraphNode{{Name: "input", Op: "Placeholder"}}
+	inputs := []string{"input"}
+	for _, p := range m.Parameters() {
+		paramName := name + "/" + p.Name
+		nodes = append(nodes, GraphNode{Name: paramName, Op: "VariableV2"})
+		inputs = append(inputs, paramName)
+	}
+
+	return append(nodes, GraphNode{Name: name, Op: op, Inputs: inputs})
+}
+
+// Log implements model.MetricSink, every metric is written as a scalar tagged "epoch/<metric>" or "batch/<metric>"
+// epoch events use the epoch as the step and batch events use a running count of batch events
+func (w *SummaryWriter) Log(event model.Event) error {
+
+	step := int64(event.Epoch)
+	if event.Kind == model.BatchEvent {
+		w.mu.Lock()
+		step = w.batchSteps
+		w.batchSteps++
+		w.mu.Unlock()
+	}
+
+	for name, value := range event.Metrics {
+		if err := w.AddScalar(string(event.Kind)+"/"+name, value, step); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+// Flush writes any buffered events to disk
+func (w *SummaryWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	return w.writer.Flush()
+}
+
+// Close flushes and closes the event file
+func (w *SummaryWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.writer.Flush()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.file = nil
+
+	return err
+}
+
+// ParameterHistograms is a callback that writes a histogram of every parameter and its gradient at the end of each epoch
+// Every controls how often, 0 means every epoch
+type ParameterHistograms struct {
+	model.BaseCallback
+	Writer *SummaryWriter
+	Every  int
+}
+
+func (p *ParameterHistograms) OnEpochEnd(t *model.Trainer, epoch int, metrics model.Metrics) error {
+
+	if (epoch+1)%max(p.Every, 1) != 0 {
+		return nil
+	}
+
+	// a parameter that has diverged entirely has nothing to plot, it's skipped rather than stopping training
+	for _, param := range t.Model.Parameters() {
+		if len(finite(param.Data)) > 0 {
+			if err := p.Writer.AddHistogram("parameters/"+param.Name, param.Data, int64(epoch)); err != nil {
+				return err
+			}
+		}
+		if len(finite(param.Grad)) > 0 {
+			if err := p.Writer.AddHistogram("gradients/"+param.Name, param.Grad, int64(epoch)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return p.Writer.Flush()
+}
@@ -0,0 +1,357 @@
+package tensorboard
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"gotorch/internal/protowire"
+	"gotorch/model"
+	"gotorch/tensor"
+	"image/png"
+	"math"
+	"os"
+	"testing"
+)
+
+// decodeFields parses a protobuf message into its fields, varints and fixed width values are returned as uint64 and
+// length delimited fields as []byte
+func decodeFields(t *testing.T, data []byte) map[int][]any {
+	t.Helper()
+
+	decoded, err := protowire.DecodeFields(data)
+	if err != nil {
+		t.Fatalf("Failed to decode message: %v", err)
+	}
+
+	fields := map[int][]any{}
+	for _, f := range decoded {
+		if f.WireType == protowire.WireBytes {
+			fields[f.Number] = append(fields[f.Number], f.Data)
+		} else {
+			fields[f.Number] = append(fields[f.Number], f.Value)
+		}
+	}
+	return fields
+}
+
+// readEvents reads every record in an event file, checking the framing and crcs
+func readEvents(t *testing.T, path string) []map[int][]any {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read event file: %v", err)
+	}
+
+	var events []map[int][]any
+	for len(data) > 0 {
+		header := data[:8]
+		length := binary.LittleEndian.Uint64(header)
+		if binary.LittleEndian.Uint32(data[8:12]) != maskedCRC(header) {
+			t.Fatalf("length crc mismatch")
+		}
+		payload := data[12 : 12+length]
+		if binary.LittleEndian.Uint32(data[12+length:16+length]) != maskedCRC(payload) {
+			t.Fatalf("data crc mismatch")
+		}
+		events = append(events, decodeFields(t, payload))
+		data = data[16+length:]
+	}
+	return events
+}
+
+// returns the single Summary.Value of an event
+func firstValue(t *testing.T, event map[int][]any) map[int][]any {
+	t.Helper()
+
+	summary := decodeFields(t, event[eventSummary][0].([]byte))
+	return decodeFields(t, summary[summaryValue][0].([]byte))
+}
+
+func TestMaskedCRC(t *testing.T) {
+
+	// crc32c of "123456789" is 0xe3069283
+	crc := uint32(0xe3069283)
+	expected := ((crc >> 15) | (crc << 17)) + 0xa282ead8
+
+	if got := maskedCRC([]byte("123456789")); got != expected {
+		t.Errorf("Expected masked crc %x, got %x", expected, got)
+	}
+}
+
+func TestSummaryWriterFileVersion(t *testing.T) {
+
+	w, err := NewSummaryWriter(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	defer w.Close()
+
+	events := readEvents(t, w.Path())
+	if len(events) != 1 {
+		t.Fatalf("Expected only the file version event, got %d events", len(events))
+	}
+	if version := string(events[0][eventFileVersion][0].([]byte)); version != fileVersion {
+		t.Errorf("Expected file version %q, got %q", fileVersion, version)
+	}
+}
+
+func TestAddScalar(t *testing.T) {
+
+	w, err := NewSummaryWriter(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+
+	if err := w.AddScalar("loss", 0.25, 7); err != nil {
+		t.Fatalf("Failed to add scalar: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close writer: %v", err)
+	}
+
+	events := readEvents(t, w.Path())
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+
+	if step := events[1][eventStep][0].(uint64); step != 7 {
+		t.Errorf("Expected step 7, got %d", step)
+	}
+
+	value := firstValue(t, events[1])
+	if tag := string(value[valueTag][0].([]byte)); tag != "loss" {
+		t.Errorf("Expected tag loss, got %q", tag)
+	}
+	if v := math.Float32frombits(uint32(value[valueSimpleValue][0].(uint64))); v != 0.25 {
+		t.Errorf("Expected value 0.25, got %v", v)
+	}
+}
+
+func TestAddHistogram(t *testing.T) {
+
+	w, err := NewSummaryWriter(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	w.HistogramBins = 2
+
+	if err := w.AddHistogram("weights", []float64{0, 1, 2, 3, 4}, 0); err != nil {
+		t.Fatalf("Failed to add histogram: %v", err)
+	}
+	if err := w.AddHistogram("empty", nil, 0); err == nil {
+		t.Errorf("Expected an error for an empty histogram")
+	}
+	w.Close()
+
+	histo := decodeFields(t, firstValue(t, readEvents(t, w.Path())[1])[valueHisto][0].([]byte))
+
+	double := func(field int) float64 { return math.Float64frombits(histo[field][0].(uint64)) }
+	if double(histoMin) != 0 || double(histoMax) != 4 || double(histoNum) != 5 || double(histoSum) != 10 || double(histoSumSquares) != 30 {
+		t.Errorf("Unexpected histogram summary statistics %v", histo)
+	}
+
+	counts := histo[histoBucket][0].([]byte)
+	first := math.Float64frombits(binary.LittleEndian.Uint64(counts[:8]))
+	second := math.Float64frombits(binary.LittleEndian.Uint64(counts[8:]))
+	if first != 2 || second != 3 {
+		t.Errorf("Expected bucket counts 2 and 3, got %v and %v", first, second)
+	}
+}
+
+func TestAddHistogramNonFinite(t *testing.T) {
+
+	w, err := NewSummaryWriter(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	w.HistogramBins = 2
+
+	values := []float64{0, math.Inf(1), 1, math.NaN(), 2, math.Inf(-1), 3, 4}
+	if err := w.AddHistogram("gradients", values, 0); err != nil {
+		t.Fatalf("Failed to add histogram: %v", err)
+	}
+	if err := w.AddHistogram("diverged", []float64{math.NaN(), math.Inf(1)}, 0); err == nil {
+		t.Errorf("Expected an error for a histogram without finite values")
+	}
+	w.Close()
+
+	// the non-finite values are left out, leaving the same histogram as 0 to 4
+	histo := decodeFields(t, firstValue(t, readEvents(t, w.Path())[1])[valueHisto][0].([]byte))
+
+	double := func(field int) float64 { return math.Float64frombits(histo[field][0].(uint64)) }
+	if double(histoMin) != 0 || double(histoMax) != 4 || double(histoNum) != 5 || double(histoSum) != 10 || double(histoSumSquares) != 30 {
+		t.Errorf("Unexpected histogram summary statistics %v", histo)
+	}
+
+	counts := histo[histoBucket][0].([]byte)
+	first := math.Float64frombits(binary.LittleEndian.Uint64(counts[:8]))
+	second := math.Float64frombits(binary.LittleEndian.Uint64(counts[8:]))
+	if first != 2 || second != 3 {
+		t.Errorf("Expected bucket counts 2 and 3, got %v and %v", first, second)
+	}
+}
+
+func TestAddText(t *testing.T) {
+
+	w, err := NewSummaryWriter(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+
+	if err := w.AddText("notes", "hello **tensorboard**", 1); err != nil {
+		t.Fatalf("Failed to add text: %v", err)
+	}
+	w.Close()
+
+	value := firstValue(t, readEvents(t, w.Path())[1])
+
+	metadata := decodeFields(t, value[valueMetadata][0].([]byte))
+	plugin := decodeFields(t, metadata[metadataPluginData][0].([]byte))
+	if name := string(plugin[pluginDataName][0].([]byte)); name != "text" {
+		t.Errorf("Expected the text plugin, got %q", name)
+	}
+
+	tensorProto := decodeFields(t, value[valueTensor][0].([]byte))
+	if tensorProto[tensorDtype][0].(uint64) != dtString {
+		t.Errorf("Expected a string tensor")
+	}
+	if text := string(tensorProto[tensorStringVal][0].([]byte)); text != "hello **tensorboard**" {
+		t.Errorf("Unexpected text %q", text)
+	}
+}
+
+func TestAddImage(t *testing.T) {
+
+	w, err := NewSummaryWriter(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+
+	// a 3 channel 1x2 image with a red and a blue pixel
+	img := tensor.NewTensor([]float64{1, 0, 0, 0, 0, 1}, 3, 1, 2)
+	if err := w.AddImage("sample", img, 0); err != nil {
+		t.Fatalf("Failed to add image: %v", err)
+	}
+	if err := w.AddImage("bad", tensor.NewTensor([]float64{1, 2}, 2, 1, 1), 0); err == nil {
+		t.Errorf("Expected an error for a 2 channel image")
+	}
+	w.Close()
+
+	imageProto := decodeFields(t, firstValue(t, readEvents(t, w.Path())[1])[valueImage][0].([]byte))
+	if imageProto[imageHeight][0].(uint64) != 1 || imageProto[imageWidth][0].(uint64) != 2 || imageProto[imageColorspace][0].(uint64) != 3 {
+		t.Errorf("Unexpected image dimensions %v", imageProto)
+	}
+
+	decoded, err := png.Decode(bytes.NewReader(imageProto[imageEncoded][0].([]byte)))
+	if err != nil {
+		t.Fatalf("Failed to decode png: %v", err)
+	}
+	if r, _, b, _ := decoded.At(0, 0).RGBA(); r != 0xffff || b != 0 {
+		t.Errorf("Expected the first pixel to be red")
+	}
+	if r, _, b, _ := decoded.At(1, 0).RGBA(); r != 0 || b != 0xffff {
+		t.Errorf("Expected the second pixel to be blue")
+	}
+}
+
+func TestAddGraph(t *testing.T) {
+
+	w, err := NewSummaryWriter(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+
+	linear := &model.Linear{Weights: []float64{1, 2}, Biases: []float64{0.5}}
+	if err := w.AddGraph(ModuleGraph("linear", linear)); err != nil {
+		t.Fatalf("Failed to add graph: %v", err)
+	}
+	w.Close()
+
+	graph := decodeFields(t, readEvents(t, w.Path())[1][eventGraphDef][0].([]byte))
+	nodes := graph[graphNode]
+	if len(nodes) != 4 {
+		t.Fatalf("Expected 4 nodes, got %d", len(nodes))
+	}
+
+	last := decodeFields(t, nodes[3].([]byte))
+	if string(last[nodeName][0].([]byte)) != "linear" || string(last[nodeOp][0].([]byte)) != "Linear" || len(last[nodeInput]) != 3 {
+		t.Errorf("Unexpected module node %v", last)
+	}
+}
+
+func TestSummaryWriterWithTrainer(t *testing.T) {
+
+	w, err := NewSummaryWriter(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+
+	trainer := &model.Trainer{
+		Model:     &model.Linear{Weights: []float64{1, 2}, Biases: []float64{0.5}},
+		Loss:      model.MSELoss,
+		Optimizer: &model.SGD{LearningRate: 0.01},
+		Train:     model.FullBatch(tensor.NewTensor([][]float64{{1, 2}, {3, 4}}), tensor.NewTensor([][]float64{{5}, {11}})),
+		Epochs:    3,
+		Callbacks: []model.Callback{
+			&model.ProgressLogger{Sinks: []model.MetricSink{w}},
+			&ParameterHistograms{Writer: w},
+		},
+	}
+
+	if _, err := trainer.Fit(context.Background()); err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+	w.Close()
+
+	// file version, then per epoch one loss scalar and a weight and bias histogram for both parameters and gradients
+	events := readEvents(t, w.Path())
+	if len(events) != 1+3*5 {
+		t.Fatalf("Expected 16 events, got %d", len(events))
+	}
+
+	tags := map[string]int{}
+	for _, event := range events[1:] {
+		tags[string(firstValue(t, event)[valueTag][0].([]byte))]++
+	}
+	for _, tag := range []string{"epoch/loss", "parameters/weight", "parameters/bias", "gradients/weight", "gradients/bias"} {
+		if tags[tag] != 3 {
+			t.Errorf("Expected 3 events for %s, got %d", tag, tags[tag])
+		}
+	}
+}
+
+func TestParameterHistogramsDiverged(t *testing.T) {
+
+	w, err := NewSummaryWriter(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+
+	// the weights have blown up to Inf and NaN, the bias still has a value
+	linear := &model.Linear{Weights: []float64{math.Inf(1), math.NaN()}, Biases: []float64{0.5}}
+	trainer := &model.Trainer{Model: linear}
+
+	if err := (&ParameterHistograms{Writer: w}).OnEpochEnd(trainer, 0, nil); err != nil {
+		t.Fatalf("Expected diverged parameters to be skipped, got %v", err)
+	}
+	w.Close()
+
+	events := readEvents(t, w.Path())
+	if len(events) != 2 || string(firstValue(t, events[1])[valueTag][0].([]byte)) != "parameters/bias" {
+		t.Errorf("Expected only the bias histogram to be written, got %d events", len(events))
+	}
+}
+
+func TestSummaryWriterClosed(t *testing.T) {
+
+	w, err := NewSummaryWriter(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	w.Close()
+
+	if err := w.AddScalar("loss", 1, 0); err == nil {
+		t.Errorf("Expected an error writing to a closed writer")
+	}
+}
@@ -0,0 +1,191 @@
+package tokenizer
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+/*
+Byte pair encoding starts from single characters and learns merges, each one joining the most frequent adjacent pair of
+tokens in the corpus into a new token. Encoding a word replays the merges in the order they were learned. The last
+character of every word carries an end of word suffix, so "low" in "lower" and "low" on its own are different tokens
+and decoding knows where to put the spaces back.
+*/
+
+// the suffix marking the last token of a word, the same one the original subword-nmt implementation used
+const defaultEndOfWordSuffix = "</w>"
+
+// pair is two adjacent tokens
+type pair [2]string
+
+// BPETokenizer is a byte pair encoding tokenizer over characters
+type BPETokenizer struct {
+	vocab        *Vocabulary
+	merges       []pair
+	ranks        map[pair]int
+	unknown      string
+	lowercase    bool
+	preTokenizer string
+	suffix       string
+}
+
+// newBPE sets up a tokenizer from its vocabulary and merges in priority order
+func newBPE(vocab *Vocabulary, merges []pair, unknown string, lowercase bool, preTokenizer, suffix string) *BPETokenizer {
+	t := &BPETokenizer{vocab: vocab, merges: merges, ranks: map[pair]int{}, unknown: unknown, lowercase: lowercase, preTokenizer: preTokenizer, suffix: suffix}
+	for i, m := range merges {
+		if _, ok := t.ranks[m]; !ok {
+			t.ranks[m] = i
+		}
+	}
+	return t
+}
+
+// symbols splits a word into characters with the end of word suffix on the last one
+func symbols(word, suffix string) []string {
+	s := strings.Split(word, "")
+	if len(s) > 0 {
+		s[len(s)-1] += suffix
+	}
+	return s
+}
+
+// merge replaces every occurrence of p in s, from left to right, with the joined token
+func merge(s []string, p pair) []string {
+	merged := make([]string, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if i+1 < len(s) && s[i] == p[0] && s[i+1] == p[1] {
+			merged = append(merged, p[0]+p[1])
+			i++
+		} else {
+			merged = append(merged, s[i])
+		}
+	}
+	return merged
+}
+
+// TrainBPE learns merges from a corpus split on whitespace until the vocabulary reaches opts.VocabSize, or until no pair
+// appears at least opts.MinFrequency times. Every character in the corpus is in the vocabulary whatever VocabSize is
+// the most frequent pair is merged first, ties go to the pair that sorts first so training is deterministic
+func TrainBPE(corpus []string, opts TrainOptions) (*BPETokenizer, error) {
+
+	counts, err := countTokens(corpus, whitespaceSplit, opts.Lowercase)
+	if err != nil {
+		return nil, err
+	}
+
+	type word struct {
+		symbols []string
+		count   int
+	}
+	words := make([]word, 0, len(counts))
+	alphabet := map[string]bool{}
+	for w, count := range counts {
+		s := symbols(w, defaultEndOfWordSuffix)
+		words = append(words, word{s, count})
+		for _, symbol := range s {
+			alphabet[symbol] = true
+		}
+	}
+
+	vocab := NewVocabulary(opts.specialTokens()...)
+	initial := make([]string, 0, len(alphabet))
+	for symbol := range alphabet {
+		initial = append(initial, symbol)
+	}
+	slices.Sort(initial)
+	for _, symbol := range initial {
+		vocab.Add(symbol)
+	}
+
+	var merges []pair
+	for opts.VocabSize == 0 || vocab.Len() < opts.VocabSize {
+
+		pairs := map[pair]int{}
+		for _, w := range words {
+			for i := 0; i+1 < len(w.symbols); i++ {
+				pairs[pair{w.symbols[i], w.symbols[i+1]}] += w.count
+			}
+		}
+
+		var best pair
+		bestCount := 0
+		for p, count := range pairs {
+			if count > bestCount || count == bestCount && cmp.Or(cmp.Compare(p[0], best[0]), cmp.Compare(p[1], best[1])) < 0 {
+				best, bestCount = p, count
+			}
+		}
+		if bestCount == 0 || bestCount < opts.MinFrequency {
+			break
+		}
+
+		merges = append(merges, best)
+		vocab.Add(best[0] + best[1])
+		for i := range words {
+			words[i].symbols = merge(words[i].symbols, best)
+		}
+	}
+
+	return newBPE(vocab, merges, opts.unknownToken(), opts.Lowercase, whitespaceSplit, defaultEndOfWordSuffix), nil
+}
+
+// Merges returns the learned merges in the order they're applied
+func (t *BPETokenizer) Merges() [][2]string {
+	merges := make([][2]string, len(t.merges))
+	for i, m := range t.merges {
+		merges[i] = m
+	}
+	return merges
+}
+
+// tokenizeWord applies the merges to a single word, always merging the adjacent pair that was learned earliest
+func (t *BPETokenizer) tokenizeWord(w string) []string {
+
+	s := symbols(w, t.suffix)
+	for len(s) > 1 {
+		best, bestRank := pair{}, len(t.merges)
+		for i := 0; i+1 < len(s); i++ {
+			if rank, ok := t.ranks[pair{s[i], s[i+1]}]; ok && rank < bestRank {
+				best, bestRank = pair{s[i], s[i+1]}, rank
+			}
+		}
+		if bestRank == len(t.merges) {
+			break
+		}
+		s = merge(s, best)
+	}
+
+	return s
+}
+
+func (t *BPETokenizer) Tokenize(text string) []string {
+	var tokens []string
+	for _, w := range preTokenize(t.preTokenizer, normalize(text, t.lowercase)) {
+		tokens = append(tokens, t.tokenizeWord(w)...)
+	}
+	return tokens
+}
+
+func (t *BPETokenizer) Encode(text string) ([]int, error) {
+	return t.vocab.encode(t.Tokenize(text), t.unknown)
+}
+
+// Decode joins the tokens and turns each end of word suffix back into a space
+func (t *BPETokenizer) Decode(ids []int) (string, error) {
+
+	tokens, err := t.vocab.decode(ids)
+	if err != nil {
+		return "", err
+	}
+
+	text := strings.Join(tokens, "")
+	if t.suffix != "" {
+		text = strings.ReplaceAll(text, t.suffix, " ")
+	}
+
+	return strings.TrimRight(text, " "), nil
+}
+
+func (t *BPETokenizer) Vocabulary() *Vocabulary {
+	return t.vocab
+}
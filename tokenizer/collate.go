@@ -0,0 +1,163 @@
+package tokenizer
+
+import (
+	"fmt"
+	"gotorch/data"
+	"gotorch/tensor"
+	"slices"
+)
+
+// ToTensor returns token ids as a [len(ids)] tensor
+func ToTensor(ids []int) *tensor.Tensor {
+	values := make([]float64, len(ids))
+	for i, id := range ids {
+		values[i] = float64(id)
+	}
+	return tensor.NewTensor(values, len(values))
+}
+
+// TextDataset encodes texts as they are requested, sample i is the [tokens] ids of Texts[i] and, if there are
+// labels, the [1] label Labels[i]. Batch its samples with PadCollate since texts encode to different lengths
+type TextDataset struct {
+	Texts     []string
+	Labels    []float64
+	Tokenizer Tokenizer
+}
+
+// NewTextDataset checks there's a label for every text, labels may be nil
+func NewTextDataset(texts []string, labels []float64, t Tokenizer) (*TextDataset, error) {
+	if labels != nil && len(labels) != len(texts) {
+		return nil, fmt.Errorf("%d texts but %d labels", len(texts), len(labels))
+	}
+	return &TextDataset{Texts: texts, Labels: labels, Tokenizer: t}, nil
+}
+
+func (d *TextDataset) Len() int {
+	return len(d.Texts)
+}
+
+func (d *TextDataset) Get(i int) (data.Sample, error) {
+
+	if i < 0 || i >= d.Len() {
+		return data.Sample{}, fmt.Errorf("index %d out of range for dataset of length %d", i, d.Len())
+	}
+
+	ids, err := d.Tokenizer.Encode(d.Texts[i])
+	if err != nil {
+		return data.Sample{}, fmt.Errorf("unable to encode text %d: %w", i, err)
+	}
+
+	sample := data.Sample{Features: ToTensor(ids)}
+	if d.Labels != nil {
+		sample.Label = tensor.NewTensor(d.Labels[i])
+	}
+
+	return sample, nil
+}
+
+// PadOptions configures PadCollate
+// sequences longer than MaxLength are truncated, 0 means no limit. Batches are padded to their longest sequence, or
+// to MaxLength if PadToMaxLength is set so every batch has the same shape. PadLabels treats labels as sequences too,
+// padding them with LabelPadID, which is useful for language modelling targets
+type PadOptions struct {
+	PadID          int
+	MaxLength      int
+	PadToMaxLength bool
+	PadLabels      bool
+	LabelPadID     int
+}
+
+// padSequences truncates and pads 1D sequences into a [batch, length] tensor
+func padSequences(sequences []*tensor.Tensor, padID, maxLength int, padToMax bool) (*tensor.Tensor, error) {
+
+	length := 0
+	for i, s := range sequences {
+		if len(s.Shape) != 1 {
+			return nil, fmt.Errorf("sample %d has shape %v, expected a 1D sequence of ids", i, s.Shape)
+		}
+		length = max(length, len(s.Data))
+	}
+	if maxLength > 0 && (length > maxLength || padToMax) {
+		length = maxLength
+	}
+
+	values := make([]float64, len(sequences)*length)
+	for i, s := range sequences {
+		row := values[i*length : (i+1)*length]
+		n := copy(row, s.Data)
+		for j := n; j < length; j++ {
+			row[j] = float64(padID)
+		}
+	}
+
+	return tensor.NewTensor(values, len(sequences), length), nil
+}
+
+// PadCollate returns a collate function that batches token sequences of different lengths into a [batch, length] tensor
+func PadCollate(opts PadOptions) data.CollateFunc {
+	return func(samples []data.Sample) (*tensor.Tensor, *tensor.Tensor, error) {
+
+		if len(samples) == 0 {
+			return nil, nil, fmt.Errorf("cannot collate an empty batch")
+		}
+
+		features := make([]*tensor.Tensor, len(samples))
+		var labels []*tensor.Tensor
+		for i, s := range samples {
+			features[i] = s.Features
+			if s.Label != nil {
+				labels = append(labels, s.Label)
+			}
+		}
+
+		inputs, err := padSequences(features, opts.PadID, opts.MaxLength, opts.PadToMaxLength)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to collate features: %w", err)
+		}
+
+		if len(labels) == 0 {
+			return inputs, nil, nil
+		}
+		if len(labels) != len(samples) {
+			return nil, nil, fmt.Errorf("only %d of %d samples in the batch have labels", len(labels), len(samples))
+		}
+
+		var targets *tensor.Tensor
+		if opts.PadLabels {
+			targets, err = padSequences(labels, opts.LabelPadID, opts.MaxLength, opts.PadToMaxLength)
+		} else {
+			targets, err = stack(labels)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to collate labels: %w", err)
+		}
+
+		return inputs, targets, nil
+	}
+}
+
+// stack joins labels of the same shape along a new first dimension, like data.DefaultCollate does
+func stack(tensors []*tensor.Tensor) (*tensor.Tensor, error) {
+
+	shape := tensors[0].Shape
+	values := make([]float64, 0, len(tensors)*len(tensors[0].Data))
+	for _, t := range tensors {
+		if !slices.Equal(t.Shape, shape) {
+			return nil, fmt.Errorf("cannot stack shapes %v and %v", shape, t.Shape)
+		}
+		values = append(values, t.Data...)
+	}
+
+	return tensor.NewTensor(values, append([]int{len(tensors)}, shape...)...), nil
+}
+
+// AttentionMask returns a tensor shaped like a padded batch that is 1 for real tokens and 0 for padding
+func AttentionMask(batch *tensor.Tensor, padID int) *tensor.Tensor {
+	mask := make([]float64, len(batch.Data))
+	for i, id := range batch.Data {
+		if id != float64(padID) {
+			mask[i] = 1
+		}
+	}
+	return tensor.NewTensor(mask, append([]int{}, batch.Shape...)...)
+}
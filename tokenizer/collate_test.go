@@ -0,0 +1,78 @@
+package tokenizer
+
+import (
+	"context"
+	"gotorch/data"
+	"gotorch/tensor"
+	"reflect"
+	"testing"
+)
+
+func Test_PadCollate(t *testing.T) {
+
+	tok, err := TrainWordTokenizer([]string{"a b c d"}, TrainOptions{})
+	if err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+	dataset, err := NewTextDataset([]string{"a b c", "d", "a b c d a"}, []float64{1, 0, 1}, tok)
+	if err != nil {
+		t.Fatalf("Failed to create dataset: %v", err)
+	}
+
+	loader := &data.DataLoader{Dataset: dataset, BatchSize: 2, Collate: PadCollate(PadOptions{MaxLength: 4})}
+
+	var inputs, targets [][]float64
+	var shapes [][]int
+	for x, y := range loader.Batches(context.Background()) {
+		inputs = append(inputs, x.Data)
+		targets = append(targets, y.Data)
+		shapes = append(shapes, x.Shape)
+	}
+	if err := loader.Err(); err != nil {
+		t.Fatalf("Failed to load batches: %v", err)
+	}
+
+	// a b c d are ids 4 to 7, the first batch is padded to its longest text and the second truncated to 4
+	expectedInputs := [][]float64{{4, 5, 6, 7, 0, 0}, {4, 5, 6, 7}}
+	if !reflect.DeepEqual(inputs, expectedInputs) {
+		t.Errorf("Expected inputs %v, got %v", expectedInputs, inputs)
+	}
+	if !reflect.DeepEqual(shapes, [][]int{{2, 3}, {1, 4}}) {
+		t.Errorf("Expected shapes [[2 3] [1 4]], got %v", shapes)
+	}
+	if !reflect.DeepEqual(targets, [][]float64{{1, 0}, {1}}) {
+		t.Errorf("Expected targets [[1 0] [1]], got %v", targets)
+	}
+}
+
+func Test_PadCollateSequenceLabels(t *testing.T) {
+
+	collate := PadCollate(PadOptions{PadID: 9, MaxLength: 3, PadToMaxLength: true, PadLabels: true, LabelPadID: -1})
+	samples := []data.Sample{
+		{Features: ToTensor([]int{1, 2}), Label: ToTensor([]int{2, 3})},
+		{Features: ToTensor([]int{4}), Label: ToTensor([]int{5})},
+	}
+
+	inputs, targets, err := collate(samples)
+	if err != nil {
+		t.Fatalf("Failed to collate: %v", err)
+	}
+	if !reflect.DeepEqual(inputs.Shape, []int{2, 3}) || !reflect.DeepEqual(inputs.Data, []float64{1, 2, 9, 4, 9, 9}) {
+		t.Errorf("Expected inputs padded to the max length, got %v %v", inputs.Shape, inputs.Data)
+	}
+	if !reflect.DeepEqual(targets.Data, []float64{2, 3, -1, 5, -1, -1}) {
+		t.Errorf("Expected labels padded with -1, got %v", targets.Data)
+	}
+
+	mask := AttentionMask(inputs, 9)
+	if !reflect.DeepEqual(mask.Shape, inputs.Shape) || !reflect.DeepEqual(mask.Data, []float64{1, 1, 0, 1, 0, 0}) {
+		t.Errorf("Expected mask [1 1 0 1 0 0], got %v", mask.Data)
+	}
+
+	if _, _, err := collate([]data.Sample{{Features: tensor.NewTensor([]float64{1, 2}, 1, 2)}}); err == nil {
+		t.Errorf("Expected an error collating a sample that isn't a sequence")
+	}
+	if _, err := NewTextDataset([]string{"a"}, []float64{1, 2}, nil); err == nil {
+		t.Errorf("Expected an error for more labels than texts")
+	}
+}
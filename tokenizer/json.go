@@ -0,0 +1,303 @@
+package tokenizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+/*
+Tokenizers are saved in the tokenizer.json format of Hugging Face tokenizers, so a trained vocabulary and merges can be
+inspected or used from Python. Only the pieces these tokenizers use are read: a Lowercase normalizer, the Whitespace,
+WhitespaceSplit or character Split pre-tokenizers, and WordLevel or BPE models. BPE merges are read either as "a b"
+strings or as ["a", "b"] pairs, the two layouts different versions of the library write. Anything else, such as the
+byte level pre-tokenizer GPT-2 uses, is reported as unsupported.
+*/
+
+type tokenizerJSON struct {
+	Version       string          `json:"version"`
+	Truncation    json.RawMessage `json:"truncation"`
+	Padding       json.RawMessage `json:"padding"`
+	AddedTokens   []addedToken    `json:"added_tokens"`
+	Normalizer    *component      `json:"normalizer"`
+	PreTokenizer  *component      `json:"pre_tokenizer"`
+	PostProcessor json.RawMessage `json:"post_processor"`
+	Decoder       *component      `json:"decoder"`
+	Model         modelJSON       `json:"model"`
+}
+
+type addedToken struct {
+	ID         int    `json:"id"`
+	Content    string `json:"content"`
+	SingleWord bool   `json:"single_word"`
+	LStrip     bool   `json:"lstrip"`
+	RStrip     bool   `json:"rstrip"`
+	Normalized bool   `json:"normalized"`
+	Special    bool   `json:"special"`
+}
+
+// component is a normalizer, pre-tokenizer or decoder, only the fields these tokenizers use are kept
+type component struct {
+	Type     string        `json:"type"`
+	Pattern  *splitPattern `json:"pattern,omitempty"`
+	Behavior string        `json:"behavior,omitempty"`
+	Invert   *bool         `json:"invert,omitempty"`
+	Suffix   string        `json:"suffix,omitempty"`
+}
+
+type splitPattern struct {
+	Regex  string `json:"Regex,omitempty"`
+	String string `json:"String,omitempty"`
+}
+
+type modelJSON struct {
+	Type                    string            `json:"type"`
+	Dropout                 *float64          `json:"dropout,omitempty"`
+	UnkToken                *string           `json:"unk_token"`
+	ContinuingSubwordPrefix *string           `json:"continuing_subword_prefix,omitempty"`
+	EndOfWordSuffix         *string           `json:"end_of_word_suffix,omitempty"`
+	FuseUnk                 *bool             `json:"fuse_unk,omitempty"`
+	ByteFallback            *bool             `json:"byte_fallback,omitempty"`
+	Vocab                   map[string]int    `json:"vocab"`
+	Merges                  []json.RawMessage `json:"merges,omitempty"`
+}
+
+// the character pre-tokenizer is saved as a split that isolates every character
+const characterPattern = "(?s)."
+
+func optional[T any](v T) *T {
+	return &v
+}
+
+// marshalString quotes s as JSON without escaping the < and > of suffixes like </w>
+func marshalString(s string) json.RawMessage {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return bytes.TrimRight(buf.Bytes(), "\n")
+}
+
+// toJSON describes a tokenizer in the tokenizer.json layout
+func toJSON(t Tokenizer) (*tokenizerJSON, error) {
+
+	var (
+		vocab        *Vocabulary
+		unknown      string
+		lowercase    bool
+		preTokenizer string
+		out          = &tokenizerJSON{Version: "1.0"}
+	)
+
+	switch t := t.(type) {
+	case *CharTokenizer:
+		vocab, unknown, lowercase, preTokenizer = t.vocab, t.unknown, t.lowercase, t.preTokenizer
+		out.Model.Type = "WordLevel"
+		out.Decoder = &component{Type: "Fuse"}
+	case *WordTokenizer:
+		vocab, unknown, lowercase, preTokenizer = t.vocab, t.unknown, t.lowercase, t.preTokenizer
+		// without a decoder tokens are joined with spaces
+		out.Model.Type = "WordLevel"
+	case *BPETokenizer:
+		vocab, unknown, lowercase, preTokenizer = t.vocab, t.unknown, t.lowercase, t.preTokenizer
+		out.Model = modelJSON{Type: "BPE", EndOfWordSuffix: optional(t.suffix), FuseUnk: optional(false), ByteFallback: optional(false)}
+		for _, m := range t.merges {
+			out.Model.Merges = append(out.Model.Merges, marshalString(m[0]+" "+m[1]))
+		}
+		out.Decoder = &component{Type: "BPEDecoder", Suffix: t.suffix}
+	default:
+		return nil, fmt.Errorf("can't save tokenizer of type %T", t)
+	}
+
+	out.Model.Vocab = map[string]int{}
+	for id, token := range vocab.tokens {
+		out.Model.Vocab[token] = id
+		if vocab.special[token] {
+			out.AddedTokens = append(out.AddedTokens, addedToken{ID: id, Content: token, Special: true})
+		}
+	}
+	if unknown != "" {
+		out.Model.UnkToken = &unknown
+	}
+	if lowercase {
+		out.Normalizer = &component{Type: "Lowercase"}
+	}
+	if preTokenizer == characters {
+		out.PreTokenizer = &component{Type: "Split", Pattern: &splitPattern{Regex: characterPattern}, Behavior: "Isolated", Invert: optional(false)}
+	} else {
+		out.PreTokenizer = &component{Type: preTokenizer}
+	}
+
+	return out, nil
+}
+
+// Write writes a tokenizer as tokenizer.json
+func Write(w io.Writer, t Tokenizer) error {
+
+	out, err := toJSON(t)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(out)
+}
+
+// Save writes a tokenizer to a tokenizer.json file
+func Save(path string, t Tokenizer) error {
+
+	var buf bytes.Buffer
+	if err := Write(&buf, t); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// Read reads a tokenizer from tokenizer.json, returning a *CharTokenizer, *WordTokenizer or *BPETokenizer
+func Read(r io.Reader) (Tokenizer, error) {
+
+	var in tokenizerJSON
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, fmt.Errorf("unable to parse tokenizer.json: %w", err)
+	}
+
+	lowercase := false
+	if in.Normalizer != nil {
+		if in.Normalizer.Type != "Lowercase" {
+			return nil, fmt.Errorf("unsupported normalizer %q", in.Normalizer.Type)
+		}
+		lowercase = true
+	}
+
+	if in.PreTokenizer == nil {
+		return nil, fmt.Errorf("a pre-tokenizer is required")
+	}
+	preTokenizer := in.PreTokenizer.Type
+	switch {
+	case preTokenizer == whitespace || preTokenizer == whitespaceSplit:
+	case preTokenizer == "Split" && in.PreTokenizer.Pattern != nil && in.PreTokenizer.Pattern.Regex == characterPattern:
+		preTokenizer = characters
+	default:
+		return nil, fmt.Errorf("unsupported pre-tokenizer %q", in.PreTokenizer.Type)
+	}
+
+	vocab, err := vocabularyFromJSON(in.Model.Vocab, in.AddedTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	unknown := ""
+	if in.Model.UnkToken != nil {
+		unknown = *in.Model.UnkToken
+		if _, ok := vocab.ID(unknown); !ok {
+			return nil, fmt.Errorf("unknown token %q isn't in the vocabulary", unknown)
+		}
+	}
+
+	switch in.Model.Type {
+	case "WordLevel":
+		w := wordLevel{vocab: vocab, unknown: unknown, lowercase: lowercase, preTokenizer: preTokenizer}
+		if preTokenizer == characters {
+			return &CharTokenizer{w}, nil
+		}
+		w.separator = " "
+		return &WordTokenizer{w}, nil
+
+	case "BPE":
+		if in.Model.ContinuingSubwordPrefix != nil && *in.Model.ContinuingSubwordPrefix != "" {
+			return nil, fmt.Errorf("continuing subword prefixes aren't supported")
+		}
+		if in.Model.ByteFallback != nil && *in.Model.ByteFallback {
+			return nil, fmt.Errorf("byte fallback isn't supported")
+		}
+		suffix := ""
+		if in.Model.EndOfWordSuffix != nil {
+			suffix = *in.Model.EndOfWordSuffix
+		}
+
+		merges := make([]pair, len(in.Model.Merges))
+		for i, raw := range in.Model.Merges {
+			if merges[i], err = parseMerge(raw); err != nil {
+				return nil, err
+			}
+			if _, ok := vocab.ID(merges[i][0] + merges[i][1]); !ok {
+				return nil, fmt.Errorf("merge %q makes a token that isn't in the vocabulary", merges[i])
+			}
+		}
+		return newBPE(vocab, merges, unknown, lowercase, preTokenizer, suffix), nil
+	}
+
+	return nil, fmt.Errorf("unsupported tokenizer model %q", in.Model.Type)
+}
+
+// Load reads a tokenizer from a tokenizer.json file
+func Load(path string) (Tokenizer, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open tokenizer: %w", err)
+	}
+	defer file.Close()
+
+	return Read(file)
+}
+
+// vocabularyFromJSON orders a token to id map by id, the ids must run from 0 with no gaps once added tokens are included
+func vocabularyFromJSON(ids map[string]int, added []addedToken) (*Vocabulary, error) {
+
+	tokens := map[int]string{}
+	for token, id := range ids {
+		tokens[id] = token
+	}
+	for _, a := range added {
+		if existing, ok := tokens[a.ID]; ok && existing != a.Content {
+			return nil, fmt.Errorf("added token %q has id %d, which already belongs to %q", a.Content, a.ID, existing)
+		}
+		tokens[a.ID] = a.Content
+	}
+
+	vocab := NewVocabulary()
+	for id := 0; id < len(tokens); id++ {
+		token, ok := tokens[id]
+		if !ok {
+			return nil, fmt.Errorf("vocabulary ids must run from 0 to %d, %d is missing", len(tokens)-1, id)
+		}
+		if _, ok := vocab.ID(token); ok {
+			return nil, fmt.Errorf("token %q appears twice in the vocabulary", token)
+		}
+		vocab.Add(token)
+	}
+	for _, a := range added {
+		if a.Special {
+			vocab.special[a.Content] = true
+		}
+	}
+
+	return vocab, nil
+}
+
+// parseMerge reads a merge written as "a b" or ["a", "b"]
+func parseMerge(raw json.RawMessage) (pair, error) {
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		a, b, ok := strings.Cut(s, " ")
+		if !ok {
+			return pair{}, fmt.Errorf("merge %q isn't two tokens separated by a space", s)
+		}
+		return pair{a, b}, nil
+	}
+
+	var p []string
+	if err := json.Unmarshal(raw, &p); err != nil || len(p) != 2 {
+		return pair{}, fmt.Errorf("merge %s isn't a string or a pair of strings", raw)
+	}
+
+	return pair{p[0], p[1]}, nil
+}
@@ -0,0 +1,131 @@
+package tokenizer
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_SaveLoad(t *testing.T) {
+
+	char, err := TrainCharTokenizer(corpus, TrainOptions{Lowercase: true})
+	if err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+	word, err := TrainWordTokenizer(corpus, TrainOptions{})
+	if err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+	bpe, err := TrainBPE(corpus, TrainOptions{VocabSize: 30})
+	if err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+
+	for _, tok := range []Tokenizer{char, word, bpe} {
+
+		path := filepath.Join(t.TempDir(), "tokenizer.json")
+		if err := Save(path, tok); err != nil {
+			t.Fatalf("Failed to save %T: %v", tok, err)
+		}
+		loaded, err := Load(path)
+		if err != nil {
+			t.Fatalf("Failed to load %T: %v", tok, err)
+		}
+
+		if reflect.TypeOf(loaded) != reflect.TypeOf(tok) {
+			t.Errorf("Expected to load a %T, got %T", tok, loaded)
+		}
+		if !reflect.DeepEqual(loaded.Vocabulary().Tokens(), tok.Vocabulary().Tokens()) {
+			t.Errorf("Expected %T to keep its vocabulary", tok)
+		}
+		if !reflect.DeepEqual(loaded.Vocabulary().SpecialTokens(), tok.Vocabulary().SpecialTokens()) {
+			t.Errorf("Expected %T to keep its special tokens", tok)
+		}
+
+		text := "Lowest NEWER widest"
+		expected, _ := tok.Encode(text)
+		ids, err := loaded.Encode(text)
+		if err != nil || !reflect.DeepEqual(ids, expected) {
+			t.Errorf("Expected loaded %T to encode to %v, got %v, %v", tok, expected, ids, err)
+		}
+	}
+
+	loaded, _ := Load(filepath.Join(t.TempDir(), "missing.json"))
+	if loaded != nil {
+		t.Errorf("Expected no tokenizer from a missing file")
+	}
+}
+
+func Test_ReadMergePairs(t *testing.T) {
+
+	// newer versions of the library write merges as pairs
+	in := `{
+		"added_tokens": [{"id": 0, "content": "<unk>", "special": true}],
+		"normalizer": null,
+		"pre_tokenizer": {"type": "WhitespaceSplit"},
+		"model": {
+			"type": "BPE",
+			"unk_token": "<unk>",
+			"end_of_word_suffix": "</w>",
+			"vocab": {"<unk>": 0, "a": 1, "b</w>": 2, "ab</w>": 3},
+			"merges": [["a", "b</w>"]]
+		}
+	}`
+
+	tok, err := Read(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	bpe, ok := tok.(*BPETokenizer)
+	if !ok {
+		t.Fatalf("Expected a *BPETokenizer, got %T", tok)
+	}
+	if merges := bpe.Merges(); !reflect.DeepEqual(merges, [][2]string{{"a", "b</w>"}}) {
+		t.Errorf("Expected merges [[a b</w>]], got %v", merges)
+	}
+
+	ids, err := tok.Encode("ab a c")
+	if err != nil || !reflect.DeepEqual(ids, []int{3, 0, 0}) {
+		t.Errorf("Expected [3 0 0], got %v, %v", ids, err)
+	}
+}
+
+func Test_ReadUnsupported(t *testing.T) {
+
+	tests := map[string]string{
+		"byte level":    `{"pre_tokenizer": {"type": "ByteLevel"}, "model": {"type": "BPE", "vocab": {"a": 0}}}`,
+		"normalizer":    `{"normalizer": {"type": "NFKC"}, "pre_tokenizer": {"type": "Whitespace"}, "model": {"type": "WordLevel", "vocab": {"a": 0}}}`,
+		"model":         `{"pre_tokenizer": {"type": "Whitespace"}, "model": {"type": "Unigram", "vocab": {"a": 0}}}`,
+		"missing id":    `{"pre_tokenizer": {"type": "Whitespace"}, "model": {"type": "WordLevel", "vocab": {"a": 0, "b": 2}}}`,
+		"unknown token": `{"pre_tokenizer": {"type": "Whitespace"}, "model": {"type": "WordLevel", "unk_token": "<unk>", "vocab": {"a": 0}}}`,
+		"bad merge":     `{"pre_tokenizer": {"type": "Whitespace"}, "model": {"type": "BPE", "vocab": {"a": 0}, "merges": ["a"]}}`,
+		"prefix":        `{"pre_tokenizer": {"type": "Whitespace"}, "model": {"type": "BPE", "continuing_subword_prefix": "##", "vocab": {"a": 0}}}`,
+	}
+
+	for name, in := range tests {
+		if _, err := Read(strings.NewReader(in)); err == nil {
+			t.Errorf("Expected an error reading a tokenizer with an unsupported %s", name)
+		}
+	}
+}
+
+func Test_WriteFormat(t *testing.T) {
+
+	tok, err := TrainBPE([]string{"ab ab"}, TrainOptions{SpecialTokens: []string{PadToken}})
+	if err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, tok); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+
+	for _, expected := range []string{`"type": "BPE"`, `"ab</w>": 3`, `"a b</w>"`, `"content": "<pad>"`, `"type": "BPEDecoder"`} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected the tokenizer.json to contain %s, got %s", expected, buf.String())
+		}
+	}
+}
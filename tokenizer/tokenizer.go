@@ -0,0 +1,143 @@
+package tokenizer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+/*
+Tokenizers turn text into sequences of integer ids for sequence models and back again. Every tokenizer is trained on a
+corpus to build its Vocabulary, can be saved in the tokenizer.json format used by Hugging Face tokenizers with Save, and
+loaded again with Load. Encoded sequences are batched with PadCollate, which pads and truncates them to a common length.
+
+	tok, err := tokenizer.TrainBPE(corpus, tokenizer.TrainOptions{VocabSize: 1000})
+	ids, err := tok.Encode("hello world")
+*/
+
+// Tokenizer splits text into tokens from its vocabulary
+type Tokenizer interface {
+	// Tokenize splits text into tokens, which may not all be in the vocabulary
+	Tokenize(text string) []string
+	// Encode returns the ids of text's tokens, tokens missing from the vocabulary become the unknown token
+	Encode(text string) ([]int, error)
+	// Decode turns ids back into text, leaving out special tokens
+	Decode(ids []int) (string, error)
+	Vocabulary() *Vocabulary
+}
+
+// pre-tokenizers split normalized text into words before the model splits words into tokens
+// they're named after their Hugging Face equivalents so they can be saved in tokenizer.json
+const (
+	whitespaceSplit = "WhitespaceSplit"
+	whitespace      = "Whitespace"
+	characters      = "Characters"
+)
+
+// Whitespace splits runs of word characters from runs of punctuation, so "don't" becomes "don", "'", "t"
+var whitespacePattern = regexp.MustCompile(`\w+|[^\w\s]+`)
+
+func preTokenize(kind, text string) []string {
+	switch kind {
+	case whitespace:
+		return whitespacePattern.FindAllString(text, -1)
+	case characters:
+		return strings.Split(text, "")
+	}
+	return strings.Fields(text)
+}
+
+func normalize(text string, lowercase bool) string {
+	if lowercase {
+		return strings.ToLower(text)
+	}
+	return text
+}
+
+// countTokens counts how often each pre-tokenized word appears in a corpus
+func countTokens(corpus []string, kind string, lowercase bool) (map[string]int, error) {
+
+	counts := map[string]int{}
+	for _, text := range corpus {
+		for _, token := range preTokenize(kind, normalize(text, lowercase)) {
+			counts[token]++
+		}
+	}
+	if len(counts) == 0 {
+		return nil, fmt.Errorf("the corpus has no tokens")
+	}
+
+	return counts, nil
+}
+
+// wordLevel is a tokenizer whose tokens are exactly the pre-tokenized words, joined back together by separator when decoding
+type wordLevel struct {
+	vocab        *Vocabulary
+	unknown      string
+	lowercase    bool
+	preTokenizer string
+	separator    string
+}
+
+func (w *wordLevel) Tokenize(text string) []string {
+	return preTokenize(w.preTokenizer, normalize(text, w.lowercase))
+}
+
+func (w *wordLevel) Encode(text string) ([]int, error) {
+	return w.vocab.encode(w.Tokenize(text), w.unknown)
+}
+
+func (w *wordLevel) Decode(ids []int) (string, error) {
+	tokens, err := w.vocab.decode(ids)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(tokens, w.separator), nil
+}
+
+func (w *wordLevel) Vocabulary() *Vocabulary {
+	return w.vocab
+}
+
+// CharTokenizer has a token for every character, including spaces, so decoding gives back the original text
+type CharTokenizer struct {
+	wordLevel
+}
+
+// TrainCharTokenizer builds a character vocabulary from a corpus
+func TrainCharTokenizer(corpus []string, opts TrainOptions) (*CharTokenizer, error) {
+
+	counts, err := countTokens(corpus, characters, opts.Lowercase)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CharTokenizer{wordLevel{
+		vocab:        buildVocabulary(counts, opts),
+		unknown:      opts.unknownToken(),
+		lowercase:    opts.Lowercase,
+		preTokenizer: characters,
+	}}, nil
+}
+
+// WordTokenizer splits text on whitespace and has a token for every word, decoding joins words with single spaces
+type WordTokenizer struct {
+	wordLevel
+}
+
+// TrainWordTokenizer builds a vocabulary of the most frequent words in a corpus
+func TrainWordTokenizer(corpus []string, opts TrainOptions) (*WordTokenizer, error) {
+
+	counts, err := countTokens(corpus, whitespaceSplit, opts.Lowercase)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WordTokenizer{wordLevel{
+		vocab:        buildVocabulary(counts, opts),
+		unknown:      opts.unknownToken(),
+		lowercase:    opts.Lowercase,
+		preTokenizer: whitespaceSplit,
+		separator:    " ",
+	}}, nil
+}
@@ -0,0 +1,191 @@
+package tokenizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+var corpus = []string{
+	"low low low low low",
+	"lower lower",
+	"newest newest newest newest newest newest",
+	"widest widest widest",
+}
+
+func Test_Vocabulary(t *testing.T) {
+
+	v := NewVocabulary(PadToken, UnknownToken)
+	if id := v.Add("a"); id != 2 {
+		t.Errorf("Expected a to have id 2, got %d", id)
+	}
+	if id := v.Add("a"); id != 2 {
+		t.Errorf("Expected adding a twice to keep id 2, got %d", id)
+	}
+	if id, ok := v.ID(PadToken); !ok || id != 0 {
+		t.Errorf("Expected %s to have id 0, got %d, %v", PadToken, id, ok)
+	}
+	if token, ok := v.Token(2); !ok || token != "a" {
+		t.Errorf("Expected id 2 to be a, got %q, %v", token, ok)
+	}
+	if _, ok := v.Token(3); ok {
+		t.Errorf("Expected id 3 to be out of range")
+	}
+	if !v.IsSpecial(UnknownToken) || v.IsSpecial("a") {
+		t.Errorf("Expected only the special tokens to be special")
+	}
+	if special := v.SpecialTokens(); !reflect.DeepEqual(special, []string{PadToken, UnknownToken}) {
+		t.Errorf("Expected special tokens [%s %s], got %v", PadToken, UnknownToken, special)
+	}
+
+	ids, err := v.encode([]string{"a", "b"}, UnknownToken)
+	if err != nil || !reflect.DeepEqual(ids, []int{2, 1}) {
+		t.Errorf("Expected [2 1], got %v, %v", ids, err)
+	}
+	if _, err := v.encode([]string{"b"}, ""); err == nil {
+		t.Errorf("Expected an error encoding an unknown token without an unknown token")
+	}
+
+	tokens, err := v.decode([]int{0, 2, 1})
+	if err != nil || !reflect.DeepEqual(tokens, []string{"a"}) {
+		t.Errorf("Expected decoding to leave out special tokens, got %v, %v", tokens, err)
+	}
+	if _, err := v.decode([]int{5}); err == nil {
+		t.Errorf("Expected an error decoding an id out of range")
+	}
+}
+
+func Test_CharTokenizer(t *testing.T) {
+
+	tok, err := TrainCharTokenizer([]string{"abca", "ab"}, TrainOptions{})
+	if err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+
+	// special tokens first, then by frequency and alphabetically
+	expected := []string{PadToken, UnknownToken, BOSToken, EOSToken, "a", "b", "c"}
+	if tokens := tok.Vocabulary().Tokens(); !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("Expected vocabulary %v, got %v", expected, tokens)
+	}
+
+	ids, err := tok.Encode("abz")
+	if err != nil || !reflect.DeepEqual(ids, []int{4, 5, 1}) {
+		t.Errorf("Expected [4 5 1], got %v, %v", ids, err)
+	}
+
+	text, err := tok.Decode([]int{2, 6, 4, 5, 3})
+	if err != nil || text != "cab" {
+		t.Errorf("Expected cab, got %q, %v", text, err)
+	}
+}
+
+func Test_WordTokenizer(t *testing.T) {
+
+	tok, err := TrainWordTokenizer(corpus, TrainOptions{VocabSize: 6, SpecialTokens: []string{PadToken, UnknownToken}})
+	if err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+
+	expected := []string{PadToken, UnknownToken, "newest", "low", "widest", "lower"}
+	if tokens := tok.Vocabulary().Tokens(); !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("Expected vocabulary %v, got %v", expected, tokens)
+	}
+
+	ids, err := tok.Encode("  low   widest lowest ")
+	if err != nil || !reflect.DeepEqual(ids, []int{3, 4, 1}) {
+		t.Errorf("Expected [3 4 1], got %v, %v", ids, err)
+	}
+
+	text, err := tok.Decode([]int{3, 0, 4})
+	if err != nil || text != "low widest" {
+		t.Errorf("Expected low widest, got %q, %v", text, err)
+	}
+}
+
+func Test_WordTokenizerMinFrequency(t *testing.T) {
+
+	tok, err := TrainWordTokenizer([]string{"A a b"}, TrainOptions{MinFrequency: 2, SpecialTokens: []string{}, Lowercase: true})
+	if err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+	if tokens := tok.Vocabulary().Tokens(); !reflect.DeepEqual(tokens, []string{"a"}) {
+		t.Errorf("Expected vocabulary [a], got %v", tokens)
+	}
+	if _, err := tok.Encode("b"); err == nil {
+		t.Errorf("Expected an error encoding an unknown word without an unknown token")
+	}
+}
+
+func Test_TrainEmptyCorpus(t *testing.T) {
+
+	if _, err := TrainWordTokenizer([]string{" ", ""}, TrainOptions{}); err == nil {
+		t.Errorf("Expected an error training on an empty corpus")
+	}
+	if _, err := TrainBPE(nil, TrainOptions{}); err == nil {
+		t.Errorf("Expected an error training on an empty corpus")
+	}
+}
+
+func Test_BPE(t *testing.T) {
+
+	tok, err := TrainBPE(corpus, TrainOptions{MinFrequency: 2, SpecialTokens: []string{UnknownToken}})
+	if err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+
+	// the classic example from Sennrich et al., with the end of word suffix on the last character
+	merges := tok.Merges()
+	expected := [][2]string{{"e", "s"}, {"es", "t</w>"}, {"l", "o"}, {"e", "w"}}
+	if len(merges) < len(expected) || !reflect.DeepEqual(merges[:len(expected)], expected) {
+		t.Errorf("Expected merges to start with %v, got %v", expected, merges)
+	}
+
+	tokens := tok.Tokenize("lowest")
+	if !reflect.DeepEqual(tokens, []string{"low", "est</w>"}) {
+		t.Errorf("Expected [low est</w>], got %v", tokens)
+	}
+
+	ids, err := tok.Encode("newest lowest widest")
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	text, err := tok.Decode(ids)
+	if err != nil || text != "newest lowest widest" {
+		t.Errorf("Expected decoding to give back the text, got %q, %v", text, err)
+	}
+
+	ids, err = tok.Encode("lowq")
+	if err != nil || ids[len(ids)-1] != 0 {
+		t.Errorf("Expected an unseen character to be the unknown token, got %v, %v", ids, err)
+	}
+}
+
+func Test_BPEVocabSize(t *testing.T) {
+
+	tok, err := TrainBPE(corpus, TrainOptions{VocabSize: 20})
+	if err != nil {
+		t.Fatalf("Failed to train: %v", err)
+	}
+	if tok.Vocabulary().Len() != 20 {
+		t.Errorf("Expected 20 tokens, got %d", tok.Vocabulary().Len())
+	}
+	if len(tok.Merges()) != 20-4-len(initialAlphabet(t)) {
+		t.Errorf("Expected every token after the alphabet to be a merge, got %d merges", len(tok.Merges()))
+	}
+}
+
+// initialAlphabet is the set of characters in the corpus, marking the last one of each word
+func initialAlphabet(t *testing.T) map[string]bool {
+	t.Helper()
+
+	alphabet := map[string]bool{}
+	counts, err := countTokens(corpus, whitespaceSplit, false)
+	if err != nil {
+		t.Fatalf("Failed to count tokens: %v", err)
+	}
+	for w := range counts {
+		for _, s := range symbols(w, defaultEndOfWordSuffix) {
+			alphabet[s] = true
+		}
+	}
+	return alphabet
+}
@@ -0,0 +1,179 @@
+package tokenizer
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// special tokens added to every vocabulary trained with the default options, in this order so padding is always id 0
+const (
+	PadToken     = "<pad>"
+	UnknownToken = "<unk>"
+	BOSToken     = "<bos>"
+	EOSToken     = "<eos>"
+)
+
+// DefaultSpecialTokens are the special tokens used when TrainOptions.SpecialTokens is nil
+var DefaultSpecialTokens = []string{PadToken, UnknownToken, BOSToken, EOSToken}
+
+// Vocabulary maps tokens to consecutive integer ids in the order they were added
+// special tokens are ordinary entries that Decode leaves out and that training never splits or merges
+type Vocabulary struct {
+	tokens  []string
+	ids     map[string]int
+	special map[string]bool
+}
+
+// NewVocabulary returns a vocabulary holding the given special tokens as ids 0, 1, ...
+func NewVocabulary(special ...string) *Vocabulary {
+	v := &Vocabulary{ids: map[string]int{}, special: map[string]bool{}}
+	for _, token := range special {
+		v.AddSpecial(token)
+	}
+	return v
+}
+
+// Add returns the id of a token, adding it to the end of the vocabulary if it's new
+func (v *Vocabulary) Add(token string) int {
+	if id, ok := v.ids[token]; ok {
+		return id
+	}
+	v.ids[token] = len(v.tokens)
+	v.tokens = append(v.tokens, token)
+	return len(v.tokens) - 1
+}
+
+// AddSpecial adds a token like Add and marks it as special
+func (v *Vocabulary) AddSpecial(token string) int {
+	v.special[token] = true
+	return v.Add(token)
+}
+
+// ID returns the id of a token
+func (v *Vocabulary) ID(token string) (int, bool) {
+	id, ok := v.ids[token]
+	return id, ok
+}
+
+// Token returns the token with the given id
+func (v *Vocabulary) Token(id int) (string, bool) {
+	if id < 0 || id >= len(v.tokens) {
+		return "", false
+	}
+	return v.tokens[id], true
+}
+
+// Len returns the number of tokens
+func (v *Vocabulary) Len() int {
+	return len(v.tokens)
+}
+
+// Tokens returns every token in id order
+func (v *Vocabulary) Tokens() []string {
+	return slices.Clone(v.tokens)
+}
+
+// IsSpecial reports whether a token is a special token
+func (v *Vocabulary) IsSpecial(token string) bool {
+	return v.special[token]
+}
+
+// SpecialTokens returns the special tokens in id order
+func (v *Vocabulary) SpecialTokens() []string {
+	var special []string
+	for _, token := range v.tokens {
+		if v.special[token] {
+			special = append(special, token)
+		}
+	}
+	return special
+}
+
+// encode looks up the ids of tokens, replacing unknown ones with the unknown token if there is one
+func (v *Vocabulary) encode(tokens []string, unknown string) ([]int, error) {
+
+	ids := make([]int, len(tokens))
+	for i, token := range tokens {
+		id, ok := v.ids[token]
+		if !ok {
+			if id, ok = v.ids[unknown]; !ok || unknown == "" {
+				return nil, fmt.Errorf("token %q isn't in the vocabulary and there's no unknown token", token)
+			}
+		}
+		ids[i] = id
+	}
+
+	return ids, nil
+}
+
+// decode looks up the tokens for ids, leaving out special tokens
+func (v *Vocabulary) decode(ids []int) ([]string, error) {
+
+	tokens := make([]string, 0, len(ids))
+	for _, id := range ids {
+		token, ok := v.Token(id)
+		if !ok {
+			return nil, fmt.Errorf("id %d is out of range for a vocabulary of %d tokens", id, v.Len())
+		}
+		if !v.special[token] {
+			tokens = append(tokens, token)
+		}
+	}
+
+	return tokens, nil
+}
+
+// TrainOptions configures building a vocabulary from a corpus
+// VocabSize caps the number of tokens including special tokens, 0 means no limit. Tokens seen fewer than MinFrequency
+// times are left out, or for BPE pairs seen fewer times aren't merged. SpecialTokens defaults to DefaultSpecialTokens
+// and unknown tokens are encoded as UnknownToken when it's one of them. Lowercase lowercases text before tokenizing
+type TrainOptions struct {
+	VocabSize     int
+	MinFrequency  int
+	SpecialTokens []string
+	Lowercase     bool
+}
+
+func (o TrainOptions) specialTokens() []string {
+	if o.SpecialTokens == nil {
+		return DefaultSpecialTokens
+	}
+	return o.SpecialTokens
+}
+
+// unknownToken returns UnknownToken if it's one of the special tokens
+func (o TrainOptions) unknownToken() string {
+	if slices.Contains(o.specialTokens(), UnknownToken) {
+		return UnknownToken
+	}
+	return ""
+}
+
+// buildVocabulary makes a vocabulary of the special tokens followed by the most frequent tokens, ties broken alphabetically
+func buildVocabulary(counts map[string]int, opts TrainOptions) *Vocabulary {
+
+	type entry struct {
+		token string
+		count int
+	}
+	var entries []entry
+	for token, count := range counts {
+		if count >= opts.MinFrequency {
+			entries = append(entries, entry{token, count})
+		}
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		return cmp.Or(cmp.Compare(b.count, a.count), cmp.Compare(a.token, b.token))
+	})
+
+	v := NewVocabulary(opts.specialTokens()...)
+	for _, e := range entries {
+		if opts.VocabSize > 0 && v.Len() >= opts.VocabSize {
+			break
+		}
+		v.Add(e.token)
+	}
+
+	return v
+}